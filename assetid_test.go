@@ -0,0 +1,31 @@
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+func TestReadAssetIDOfOlderTags(t *testing.T) {
+	tests := []struct {
+		command string
+		ok      bool
+	}{
+		{"readlora", true},
+		{"readassetid", false},
+		{"readuserdata", false},
+	}
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			card := testCard(t, false)
+			// bytes an older tool left in the spare block 32: no valid user data record
+			_, err := card.WriteBlock(32, "01FF4142")
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := runCommand(test.command, "", card, io.Discard)
+			if result.OK != test.ok {
+				t.Errorf("%s OK = %v, want %v (error %v)", test.command, result.OK, test.ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/batch"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// tagAudit is the deep verification of a tag selected by the audit of a run, see batch.Audit
+type tagAudit struct {
+	// blocks is the number of blocks read back
+	blocks   int
+	failures []string
+}
+
+// auditField is an identity field an audit compares with the record: the hex value written and the
+// first block holding it
+type auditField struct {
+	name  string
+	value string
+	first int
+}
+
+func identityAudit(record batch.Record) []auditField {
+	return []auditField{
+		{"DevEUI", record.DevEUI, nfc.ASSET_PLUS_LORA_DEV_EUI_BLOCK_MSB},
+		{"JoinEUI", record.JoinEUI, nfc.ASSET_PLUS_LORA_JOIN_EUI_BLOCK_MSB},
+		{"JoinKey", record.JoinKey, nfc.ASSET_PLUS_LORA_JOIN_KEY_BLOCK_MSB1},
+	}
+}
+
+// deepVerify reads every block of a written tag again and compares the configuration with the one
+// read after programming, the stored CRC and the identity with the record
+func (run *productionRun) deepVerify(record batch.Record, image *nfc.CRCImage, card *nfc.NfcCard) *tagAudit {
+	audit := &tagAudit{}
+	blocks, err := card.Blocks()
+	if err != nil {
+		audit.failures = append(audit.failures, err.Error())
+		return audit
+	}
+	memory, err := card.ReadBlocks(0, blocks)
+	if err != nil {
+		audit.failures = append(audit.failures, fmt.Sprintf("failed to read the %d blocks of the tag: %v", blocks, err))
+		return audit
+	}
+	audit.blocks = blocks
+
+	config := memory[:nfc.CONFIG_BIN_SIZE]
+	var changed []int
+	for block := 0; block < nfc.ASSET_PLUS_CONFIG_BLOCKS; block++ {
+		if !nfc.KeysEqual(config[block*4:block*4+4], image.Config[block*4:block*4+4]) {
+			changed = append(changed, block)
+		}
+	}
+	if len(changed) > 0 {
+		audit.failures = append(audit.failures, fmt.Sprintf("blocks %v changed since programming", changed))
+	}
+	crcBlock := memory[nfc.ASSET_PLUS_CRC_BLOCK*4:]
+	if stored, calculated := uint16(crcBlock[1])<<8|uint16(crcBlock[0]), nfc.CalculateCRC(config); stored != calculated {
+		audit.failures = append(audit.failures, fmt.Sprintf("CRC 0x%04X stored, 0x%04X calculated", stored, calculated))
+	}
+	for _, field := range identityAudit(record) {
+		expected, err := hex.DecodeString(field.value)
+		if err != nil {
+			continue
+		}
+		if !nfc.KeysEqual(config[field.first*4:field.first*4+len(expected)], expected) {
+			audit.failures = append(audit.failures, fmt.Sprintf("%s differs from the record", field.name))
+		}
+		nfc.ZeroKey(expected)
+	}
+	return audit
+}
+
+// completeAudit cross checks an audited tag with the network server once the tag is finished and
+// appends the result of the audit to the audit results, finished is the outcome of the tag
+func (run *productionRun) completeAudit(audit *tagAudit, record batch.Record, image *nfc.CRCImage, finished error) {
+	failures := audit.failures
+	check := run.manifest.Audit.Check
+	switch {
+	case finished != nil:
+		failures = append(failures, fmt.Sprintf("tag failed: %v", finished))
+	case check != nil:
+		payload, err := hookPayload(batch.HookAudit, hideRecord(record), image)
+		if err == nil {
+			var output []byte
+			output, err = runHook(*check, payload, hideRecord(record))
+			if out := strings.TrimSpace(string(output)); err != nil && out != "" {
+				err = fmt.Errorf("%v: %s", err, out)
+			}
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("network server check %s failed: %v", check.Name, err))
+		}
+	}
+
+	result := "PASS"
+	if len(failures) > 0 {
+		result = "FAIL"
+		log.Errorf("Audit of tag %s failed: %s\n", record.UID, strings.Join(failures, "; "))
+	} else {
+		fmt.Printf("Audit of tag %s passed\n", record.UID)
+	}
+	header := []string{"Timestamp", "Batch", "Station", "Operator", "UID", "DevEUI", "Blocks Read", "Result", "Failures"}
+	err := export.AppendCSV(run.manifest.Path(run.manifest.Audit.Results), header, []string{
+		export.FormatTime(export.Now()), record.Batch, record.Station, operator, record.UID, record.DevEUI,
+		strconv.Itoa(audit.blocks), result, strings.Join(failures, "; "),
+	})
+	if err != nil {
+		log.Errorf("Failed to write audit result of tag %s: %v\n", record.UID, err)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/audit"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/auth"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/keystore"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+	"golang.org/x/term"
+)
+
+// authorizeDestructive checks that the operator may run a destructive operation on the tag uid and
+// records the attempt to the audit log. A signed approval token (-approval-token, verified with
+// -approval-key) is used when given, otherwise the operator PIN (-pin or prompted) is checked against
+// -operator-pin-hash. Without either configured the operation is refused, unless -allow-unauthorized
+// explicitly disables the authorization; the attempt is audited either way.
+func authorizeDestructive(operation string, uid string) error {
+	entry := audit.Entry{Operation: operation, UID: uid}
+	var err error
+	switch {
+	case approvalToken != "" || (approvalKey != "" && operatorPinHash == ""):
+		entry.Method = "token"
+		err = checkApproval(operation, uid, &entry)
+	case operatorPinHash != "":
+		entry.Method = "pin"
+		pin := operatorPIN
+		if pin == "" {
+			pin = promptPIN(operation, uid)
+		}
+		err = auth.CheckPIN(pin, operatorPinHash)
+	case allowUnauthorized:
+		entry.Method = "none"
+		entry.Reason = "authorization disabled by -allow-unauthorized"
+	default:
+		entry.Method = "none"
+		err = fmt.Errorf("%w: configure -operator-pin-hash or -approval-key, or run without authorization with -allow-unauthorized", auth.ErrDenied)
+	}
+	entry.Granted = err == nil
+	if err != nil {
+		entry.Reason = err.Error()
+	}
+
+	auditErr := audit.NewLog(auditLogFile).Record(entry)
+	if err != nil {
+		if auditErr != nil {
+			log.Errorf("%v\n", auditErr)
+		}
+		return err
+	}
+	if auditErr != nil {
+		return fmt.Errorf("%s not run, the attempt could not be audited: %v", operation, auditErr)
+	}
+	return nil
+}
+
+func checkApproval(operation string, uid string, entry *audit.Entry) error {
+	if approvalKey == "" {
+		return fmt.Errorf("%w: -approval-key not configured", auth.ErrDenied)
+	}
+	key, err := auth.LoadPublicKey(approvalKey)
+	if err != nil {
+		return fmt.Errorf("failed to load approval key: %v", err)
+	}
+	approval, err := auth.VerifyApproval(approvalToken, key, operation, uid, time.Now())
+	if approval != nil {
+		entry.Subject = approval.Subject
+	}
+	return err
+}
+
+func promptPIN(operation string, uid string) string {
+	pin, _ := readSecret(fmt.Sprintf("Operator PIN to %s tag %s: ", operation, uid))
+	return pin
+}
+
+// readSecret reads a line from stdin after prompt, without echo when stdin is a terminal
+func readSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		secret, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return strings.TrimSpace(string(secret)), err
+	}
+	secret, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && secret == "" {
+		return "", err
+	}
+	return strings.TrimSpace(secret), nil
+}
+
+// signApproval creates an approval token from "operation,uid[,validity]" with -approval-signing-key,
+// the validity defaults to one hour
+func signApproval(param string) (string, error) {
+	parts := strings.Split(param, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", fmt.Errorf("invalid params '%s', expected operation,uid[,validity]", param)
+	}
+	validity := time.Hour
+	if len(parts) == 3 {
+		var err error
+		validity, err = time.ParseDuration(strings.TrimSpace(parts[2]))
+		if err != nil {
+			return "", fmt.Errorf("invalid validity '%s': %v", parts[2], err)
+		}
+	}
+	if approvalSigningKey == "" {
+		return "", fmt.Errorf("missing -approval-signing-key")
+	}
+	key, err := auth.LoadPrivateKey(approvalSigningKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to load signing key: %v", err)
+	}
+	approval := auth.Approval{
+		Operation: strings.TrimSpace(parts[0]),
+		UID:       strings.ToLower(strings.TrimSpace(parts[1])),
+		Subject:   approvalSubject,
+		Expires:   time.Now().Add(validity).Unix(),
+	}
+	return approval.Sign(key)
+}
+
+// authCommand returns the offline handler of a command of runAuthCommand
+func authCommand(name string) func(param string) error {
+	return func(param string) error {
+		return runAuthCommand(name, param)
+	}
+}
+
+// runAuthCommand runs the commands managing PINs, approval and configuration keys, which do not need a tag
+func runAuthCommand(name string, param string) error {
+	switch name {
+	case "hashpin":
+		if param != "" {
+			return fmt.Errorf("the PIN is read from stdin, not from -param where it would stay in the shell history")
+		}
+		pin, err := readSecret("Operator PIN: ")
+		if err != nil {
+			return fmt.Errorf("failed to read the PIN: %v", err)
+		}
+		if pin == "" {
+			return fmt.Errorf("empty PIN")
+		}
+		hash, err := auth.HashPIN(pin)
+		if err != nil {
+			return fmt.Errorf("failed to hash the PIN: %v", err)
+		}
+		fmt.Printf("operator-pin-hash: '%s'\n", hash)
+	case "genapprovalkey":
+		if param == "" {
+			return fmt.Errorf("missing params (key file name)")
+		}
+		err := auth.GenerateKey(param)
+		if err != nil {
+			return fmt.Errorf("failed to generate approval key: %v", err)
+		}
+		fmt.Printf("Signing key written to %s, distribute %s.pub as -approval-key\n", param, param)
+	case "genconfigkey":
+		if param == "" {
+			return fmt.Errorf("missing params (key file name)")
+		}
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		if err != nil {
+			return fmt.Errorf("failed to generate configuration key: %v", err)
+		}
+		err = os.WriteFile(param, []byte(hex.EncodeToString(key)+"\n"), 0600)
+		if err != nil {
+			return fmt.Errorf("failed to write configuration key: %v", err)
+		}
+		fmt.Printf("Configuration key written to %s, use it with -config-key-file\n", param)
+	case "genkeystorekey":
+		if param == "" {
+			return fmt.Errorf("missing params (key file name)")
+		}
+		err := keystore.GenerateKey(param)
+		if err != nil {
+			return fmt.Errorf("failed to generate keystore key: %v", err)
+		}
+		fmt.Printf("Keystore key written to %s, distribute %s.pub as -cm-keystore-key\n", param, param)
+	case "gentoken":
+		name, permission, _ := strings.Cut(param, ",")
+		if name == "" || permission == "" {
+			return fmt.Errorf("missing params (<name>,<permission>)")
+		}
+		token, err := auth.GenerateToken()
+		if err != nil {
+			return fmt.Errorf("failed to generate access token: %v", err)
+		}
+		entry := auth.Token{Name: name, SHA256: auth.HashToken(token), Permission: permission}
+		if !entry.Allows(auth.PermissionRead) {
+			return fmt.Errorf("invalid permission %q, expected %s, %s or %s", permission, auth.PermissionRead, auth.PermissionProgram, auth.PermissionErase)
+		}
+		fmt.Printf("Access token (give it to the client as -transport-token): %s\n", token)
+		fmt.Printf("Add to the -serve-tokens file:\n  - name: %s\n    sha256: %s\n    permission: %s\n", entry.Name, entry.SHA256, entry.Permission)
+	case "approve":
+		token, err := signApproval(param)
+		if err != nil {
+			return err
+		}
+		fmt.Println(token)
+	}
+	return nil
+}
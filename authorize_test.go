@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/audit"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/store"
+)
+
+func TestDestructiveCommandsNeedAuthorization(t *testing.T) {
+	tests := []struct {
+		command string
+		params  string
+		setup   func(t *testing.T, card *nfc.NfcCard)
+	}{
+		{"erase", "confirm", nil},
+		{"writeConfigBin", "AssetPlus_Config.bin", nil},
+		{"programTag", "AssetPlus_Config.bin", nil},
+		{"writeblemac", "00:11:22:33:44:55", nil},
+		{"decommission", "confirm", func(t *testing.T, card *nfc.NfcCard) {
+			db, err := store.Open(filepath.Join(t.TempDir(), "station.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			saved := retiredRegistry
+			retiredRegistry = db.Retired()
+			t.Cleanup(func() { retiredRegistry = saved })
+			err = card.WriteLoraDevEui("0011223344556677")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			defer func(audit, pinHash, key, token string, unauthorized, force bool) {
+				auditLogFile, operatorPinHash, approvalKey, approvalToken, allowUnauthorized, forceFactory = audit, pinHash, key, token, unauthorized, force
+			}(auditLogFile, operatorPinHash, approvalKey, approvalToken, allowUnauthorized, forceFactory)
+			auditLogFile = filepath.Join(t.TempDir(), "audit.log")
+			operatorPinHash, approvalKey, approvalToken = "", "", ""
+			allowUnauthorized = false
+			forceFactory = true
+
+			card := testCard(t, false)
+			if test.setup != nil {
+				test.setup(t, card)
+			}
+			result, _ := runCommand(test.command, test.params, card, io.Discard)
+			if result.OK {
+				t.Fatal("command ran without authorization")
+			}
+
+			file, err := os.Open(auditLogFile)
+			if err != nil {
+				t.Fatalf("attempt not audited: %v", err)
+			}
+			defer file.Close()
+			var entries []audit.Entry
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				var entry audit.Entry
+				err = json.Unmarshal(scanner.Bytes(), &entry)
+				if err != nil {
+					t.Fatal(err)
+				}
+				entries = append(entries, entry)
+			}
+			if len(entries) != 1 || entries[0].Operation != test.command || entries[0].Granted {
+				t.Errorf("audit log %+v, want one refused %s", entries, test.command)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// beaconAssignment is the identity given to one tag by a beacon loop
+type beaconAssignment struct {
+	UID      string
+	BleMac   string
+	UUID     string
+	Major    uint16
+	Minor    uint16
+	Instance uint64
+	TxPower  string
+}
+
+// beaconIdentity is the next identity handed out by a beacon loop
+type beaconIdentity struct {
+	eddystone bool
+	uuid      string // iBeacon UUID or Eddystone namespace
+	major     uint16
+	minor     uint16
+	instance  uint64
+}
+
+// parseBeaconLoopParams parses "UUID,major,minor" for ibeaconloop and "namespace,instance" for eddystoneloop,
+// the instance is given in hex
+func parseBeaconLoopParams(param string, eddystone bool) (*beaconIdentity, error) {
+	if !eddystone {
+		uuid, major, minor, err := parseIBeaconIdentity(param)
+		if err != nil {
+			return nil, err
+		}
+		return &beaconIdentity{uuid: uuid, major: major, minor: minor}, nil
+	}
+	namespace, instance, ok := strings.Cut(param, ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid params '%s', expected namespace,instance", param)
+	}
+	instanceValue, err := strconv.ParseUint(strings.TrimSpace(instance), 16, 48)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Eddystone instance '%s', expected up to 12 hex characters", instance)
+	}
+	return &beaconIdentity{eddystone: true, uuid: strings.TrimSpace(namespace), instance: instanceValue}, nil
+}
+
+// next advances to the identity of the following tag: the minor (rolling over into the major) or the instance
+func (id *beaconIdentity) next() error {
+	if id.eddystone {
+		if id.instance == 0xFFFFFFFFFFFF {
+			return fmt.Errorf("Eddystone instance range exhausted")
+		}
+		id.instance++
+		return nil
+	}
+	if id.minor < 0xFFFF {
+		id.minor++
+		return nil
+	}
+	if id.major == 0xFFFF {
+		return fmt.Errorf("iBeacon major/minor range exhausted")
+	}
+	id.major++
+	id.minor = 0
+	return nil
+}
+
+func (id *beaconIdentity) write(card *nfc.NfcCard) error {
+	if id.eddystone {
+		return card.WriteEddystoneIdentity(id.uuid, id.instance)
+	}
+	return card.WriteIBeaconIdentity(id.uuid, id.major, id.minor)
+}
+
+func writeBeaconAssignmentToCSV(filename string, assignment *beaconAssignment) error {
+	header := []string{"Timestamp", "UID", "BLE MAC", "UUID", "Major", "Minor", "Instance", "TX Power", "Tool Version"}
+	record := []string{
+		export.FormatTime(time.Now()),
+		assignment.UID,
+		assignment.BleMac,
+		assignment.UUID,
+		strconv.Itoa(int(assignment.Major)),
+		strconv.Itoa(int(assignment.Minor)),
+		fmt.Sprintf("%012X", assignment.Instance),
+		assignment.TxPower,
+		buildinfo.Version().String(),
+	}
+	return export.AppendCSV(filename, header, record)
+}
+
+// runBeaconLoop programs one beacon identity per tag presented by the operator, advancing the
+// identity after every tag and logging the assignments to assignmentsFile. A tag presented twice
+// keeps the identity it was given.
+func runBeaconLoop(params string, eddystone bool, nfcCardInstance *nfc.NfcCard) error {
+	id, err := parseBeaconLoopParams(params, eddystone)
+	if err != nil {
+		return err
+	}
+	command := "ibeaconloop"
+	if eddystone {
+		command = "eddystoneloop"
+	}
+	pinnedUID := nfcCardInstance.PinnedUID()
+	defer nfcCardInstance.PinUID(pinnedUID)
+
+	assigned := make(map[string]*beaconAssignment)
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Println("Starting beacon programming loop...")
+	fmt.Printf("Assignments will be saved to: %s\n", assignmentsFile)
+	for {
+		fmt.Print("\nPresent the next tag and press <Enter> (or 'x' + <Enter> to exit): ")
+		input, _ := reader.ReadString('\n')
+		if strings.TrimSpace(strings.ToLower(input)) == "x" {
+			fmt.Printf("Loop ended. Total tags programmed: %d\n", len(assigned))
+			return nil
+		}
+
+		uid, err := nfcCardInstance.NextTag()
+		if err != nil {
+			log.Errorf("Failed to read tag: %v\n", err)
+			tagCompleted(command, "", err)
+			continue
+		}
+		if previous, ok := assigned[uid]; ok {
+			log.Warnf("Tag %s was already programmed (Major %d, Minor %d, Instance %012X), skipping\n",
+				uid, previous.Major, previous.Minor, previous.Instance)
+			continue
+		}
+
+		err = id.write(nfcCardInstance)
+		if err != nil {
+			log.Errorf("Failed to program tag %s: %v\n", uid, err)
+			tagCompleted(command, uid, err)
+			continue
+		}
+		assignment := &beaconAssignment{UID: uid, UUID: strings.ToUpper(id.uuid), Major: id.major, Minor: id.minor, Instance: id.instance}
+		assignment.BleMac, err = nfcCardInstance.ReadBleMac()
+		if err != nil {
+			log.Warnf("Failed to read BLE MAC of tag %s: %v\n", uid, err)
+		}
+		assignment.BleMac = strings.ToUpper(assignment.BleMac)
+		assignment.TxPower, err = nfcCardInstance.ReadBLETxPower()
+		if err != nil {
+			log.Warnf("Failed to read BLE TX power of tag %s: %v\n", uid, err)
+		}
+		assigned[uid] = assignment
+
+		if eddystone {
+			fmt.Printf("Tag %s programmed: Namespace %s, Instance %012X\n", uid, assignment.UUID, assignment.Instance)
+		} else {
+			fmt.Printf("Tag %s programmed: UUID %s, Major %d, Minor %d\n", uid, assignment.UUID, assignment.Major, assignment.Minor)
+		}
+		// the tag is programmed, a failed log write is not a failure of the tag
+		err = writeBeaconAssignmentToCSV(assignmentsFile, assignment)
+		if err != nil {
+			log.Errorf("Failed to log the assignment of tag %s to %s, record it by hand: %v\n", uid, assignmentsFile, err)
+		}
+		tagCompleted(command, uid, nil)
+
+		err = id.next()
+		if err != nil {
+			return err
+		}
+	}
+}
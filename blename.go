@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/store"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// checkNameCollision checks -db for a BLE local name written to a tag other than uid.
+// Duplicate advertised names break the pairing flow of the installer app, so the write is refused
+// unless -name-collision is warn.
+func checkNameCollision(name string, uid string) error {
+	if nameHistory == nil {
+		return nil
+	}
+	owner, err := nameHistory.Owner(name, uid)
+	if err != nil {
+		return err
+	}
+	if owner == nil {
+		return nil
+	}
+	collision := fmt.Errorf("BLE local name %q was written to tag %s on %s", name, owner.UID, export.FormatTime(owner.Written))
+	if nameCollision == "warn" {
+		log.Warnf("%v, writing it anyway (-name-collision warn)", collision)
+		return nil
+	}
+	return collision
+}
+
+// recordName records a BLE local name written to the tag uid in -db
+func recordName(name string, uid string) error {
+	if nameHistory == nil {
+		return nil
+	}
+	return nameHistory.Record(store.NameEntry{Name: name, UID: uid, Station: station, Written: export.Now()})
+}
+
+// legacyPadding is the '0' character the BLE local names of older tools were padded with
+const legacyPadding = '0'
+
+// storedName formats the name blocks as read without the 0x00 padding and blank (0xFF) bytes
+func storedName(raw []byte) string {
+	return nfc.FormatText(bytes.TrimRight(bytes.TrimRight(raw, "\xff"), "\x00"))
+}
+
+// repairedName returns the BLE local name stored in raw (the name blocks as read) without padding
+// '0' characters, ok false if the name is not padded with them. The name recorded for the tag in
+// -db is trusted when raw starts with it; otherwise a name length greater than 0 keeps
+// that many bytes. Without either the padding can not be told from names ending with '0' (e.g.
+// "TRK-0010"), so the name is not repaired.
+func repairedName(raw []byte, recorded string, length int) (name []byte, ok bool, err error) {
+	// blank (0xFF) bytes and the 0x00 padding of a later write are not part of the name
+	stored := bytes.TrimRight(bytes.TrimRight(raw, "\xff"), "\x00")
+	if len(stored) == 0 || stored[len(stored)-1] != legacyPadding {
+		return nil, false, nil
+	}
+	switch {
+	case recorded != "" && bytes.HasPrefix(stored, []byte(recorded)):
+		name = []byte(recorded)
+	case length > 0:
+		if length > len(stored) {
+			return nil, false, fmt.Errorf("stored name %q is shorter than %d bytes", stored, length)
+		}
+		name = stored[:length]
+	default:
+		return nil, false, fmt.Errorf("stored name %q ends with '0' and neither -db nor a name length tells the padding", stored)
+	}
+	if len(name) == 0 {
+		return nil, false, fmt.Errorf("stored name %q has only '0' characters", stored)
+	}
+	if strings.Trim(string(stored[len(name):]), string(legacyPadding)) != "" {
+		return nil, false, fmt.Errorf("stored name %q does not continue with '0' characters after %q", stored, name)
+	}
+	if len(name) == len(stored) {
+		return nil, false, nil
+	}
+	return name, true, nil
+}
+
+// migrateBLEName repairs the BLE local name of one tag, see runMigrateBLEName. Returns the stored and
+// the repaired name, the repaired name is nil when the tag needs no repair.
+func migrateBLEName(uid string, length int, nfcCardInstance *nfc.NfcCard) (stored []byte, repaired []byte, err error) {
+	stored, err = nfcCardInstance.ReadBLELocalNameBytes()
+	if err != nil {
+		return nil, nil, err
+	}
+	recorded := ""
+	if nameHistory != nil {
+		recorded, err = nameHistory.Name(uid)
+		if err != nil {
+			return stored, nil, err
+		}
+	}
+	name, ok, err := repairedName(stored, recorded, length)
+	if err != nil || !ok {
+		return stored, nil, err
+	}
+	err = checkNameCollision(string(name), uid)
+	if err != nil {
+		return stored, nil, err
+	}
+	err = nfcCardInstance.WriteBLELocalNameBytes(name)
+	if err != nil {
+		return stored, nil, err
+	}
+	return stored, name, recordName(string(name), uid)
+}
+
+// runMigrateBLEName repairs the BLE local names older tools padded with '0' characters instead of
+// 0x00: the operator presents one tag after another until 'x' (or -count tags are repaired), the
+// padding of every tag is trimmed, the name written again with 0x00 padding and the outcome logged to
+// the CSV log. params: <log.csv>[,<name length>], see repairedName for the length.
+func runMigrateBLEName(params string, nfcCardInstance *nfc.NfcCard) error {
+	logFile, lengthParam, _ := strings.Cut(params, ",")
+	length := 0
+	if lengthParam != "" {
+		var err error
+		length, err = strconv.Atoi(strings.TrimSpace(lengthParam))
+		if err != nil || length < 1 || length > nfc.BLE_NAME_MAX {
+			return fmt.Errorf("invalid name length %q, expected 1 to %d", lengthParam, nfc.BLE_NAME_MAX)
+		}
+	}
+	if length == 0 && nameHistory == nil {
+		return fmt.Errorf("give the name length (-param <log.csv>,<name length>) or -db, otherwise the '0' padding can not be told from names ending with '0'")
+	}
+	if nfc.NamePadding() == legacyPadding {
+		return fmt.Errorf("-name-pad is 0x30, the names would be padded with '0' characters again")
+	}
+	pinnedUID := nfcCardInstance.PinnedUID()
+	defer nfcCardInstance.PinUID(pinnedUID)
+
+	done := make(map[string]bool)
+	repaired, skipped, failed := 0, 0, 0
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("Repairing BLE local names padded with '0' characters, repaired tags are logged to: %s\n", logFile)
+	defer func() {
+		fmt.Printf("BLE name migration: %d tags repaired, %d needed no repair, %d failed\n", repaired, skipped, failed)
+	}()
+	for verifyCount == 0 || repaired < verifyCount {
+		fmt.Printf("\n[%d] Present the next tag and press <Enter> (or 'x' + <Enter> to stop): ", repaired+skipped+failed+1)
+		input, _ := reader.ReadString('\n')
+		if strings.TrimSpace(strings.ToLower(input)) == "x" {
+			fmt.Println("Migration stopped by the operator")
+			return nil
+		}
+
+		uid, err := nfcCardInstance.NextTag()
+		if err != nil {
+			log.Errorf("Failed to read tag: %v\n", err)
+			tagCompleted("migrate-blename", "", err)
+			continue
+		}
+		if done[uid] {
+			log.Warnf("Tag %s was already migrated, skipping\n", uid)
+			continue
+		}
+		stored, name, err := migrateBLEName(uid, length, nfcCardInstance)
+		result, detail := "REPAIRED", ""
+		switch {
+		case err != nil:
+			failed++
+			result, detail = "FAILED", err.Error()
+			log.Errorf("Failed to repair the BLE local name of tag %s: %v\n", uid, err)
+		case name == nil:
+			skipped++
+			done[uid] = true
+			result = "NOT PADDED"
+			fmt.Printf("Tag %s: name %q is not padded with '0' characters\n", uid, storedName(stored))
+		default:
+			repaired++
+			done[uid] = true
+			fmt.Printf("Tag %s: name %q repaired to %q\n", uid, storedName(stored), nfc.FormatText(name))
+		}
+		header := []string{"Timestamp", "Station", "Operator", "UID", "Stored Name", "Repaired Name", "Result", "Detail"}
+		logErr := export.AppendCSV(logFile, header, []string{
+			export.FormatTime(export.Now()), station, operator, uid, storedName(stored), nfc.FormatText(name), result, detail,
+		})
+		if logErr != nil {
+			log.Errorf("Failed to write migration log: %v\n", logErr)
+		}
+		tagCompleted("migrate-blename", uid, err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestRepairedName(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		recorded string
+		length   int
+		want     string
+		ok       bool
+		err      bool
+	}{
+		{"recorded name", "TRK-0010", "TRK-001", 0, "TRK-001", true, false},
+		{"recorded name ending with 0", "TRK-0010", "TRK-0010", 0, "", false, false},
+		{"name length", "TRK-0010", "", 8, "", false, false},
+		{"padding by length", "TRK00000", "", 3, "TRK", true, false},
+		{"no length or history", "TRK-0010", "", 0, "", false, true},
+		{"blank bytes", "TRK0\xff\xff\xff\xff", "", 3, "TRK", true, false},
+		{"no padding", "TRK-1\x00\x00\x00", "", 0, "", false, false},
+		{"length over the stored name", "TRK0", "", 6, "", false, true},
+		{"other characters after the length", "TRK-0010", "", 3, "", false, true},
+		{"only zeros", "0000", "", 0, "", false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name, ok, err := repairedName([]byte(test.raw), test.recorded, test.length)
+			if (err != nil) != test.err {
+				t.Fatalf("repairedName() error = %v, want error %v", err, test.err)
+			}
+			if ok != test.ok || string(name) != test.want {
+				t.Errorf("repairedName() = %q, %v, want %q, %v", name, ok, test.want, test.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// defaultCalibrationWindow is the number of reads the success rate of calibrate is computed over
+const defaultCalibrationWindow = 50
+
+// calibrationLogger drops the warnings of failed reads, calibrate shows them as its success rate
+type calibrationLogger struct {
+	nfc.Logger
+}
+
+func (calibrationLogger) Warn(...interface{})          {}
+func (calibrationLogger) Warnf(string, ...interface{}) {}
+
+// beepInterval returns the time between two beeps at a success rate: a beep every 100ms at 100%,
+// slowing down to one per second as reads fail, silence when no read succeeds
+func beepInterval(rate float64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	return 100*time.Millisecond + time.Duration((1-rate)*float64(900*time.Millisecond))
+}
+
+// runCalibration reads block 0 continuously until the operator presses <Enter> and shows the
+// success rate of the last window reads as a bar, with a beep whose rate follows it, so a
+// technician can move the reader or antenna in the jig until a stable position is found.
+// Returns the final success rate.
+func runCalibration(window int, card *nfc.NfcCard) (float64, error) {
+	stop := make(chan struct{})
+	go func() {
+		bufio.NewReader(os.Stdin).ReadString('\n')
+		close(stop)
+	}()
+	logger := card.Logger()
+	card.SetLogger(calibrationLogger{logger})
+	defer card.SetLogger(logger)
+	fmt.Printf("Calibrating: move the reader or antenna until the success rate stays at 100%%, press <Enter> to stop\n")
+
+	var results []bool // outcome of the last window APDUs
+	var rate float64
+	var lastLine, lastBeep time.Time
+	for {
+		select {
+		case <-stop:
+			fmt.Println()
+			return rate, nil
+		default:
+		}
+
+		attempts, latency, err := card.ProbeRead()
+		if errors.Is(err, nfc.ErrAPDUTimeout) || errors.Is(err, nfc.ErrOperationTimeout) {
+			fmt.Println()
+			return rate, err
+		}
+		// every attempt but the last failed, the last one failed as well on an error
+		for i := 1; i < attempts; i++ {
+			results = append(results, false)
+		}
+		if attempts > 0 {
+			results = append(results, err == nil)
+		}
+		if len(results) > window {
+			results = results[len(results)-window:]
+		}
+		succeeded := 0
+		for _, ok := range results {
+			if ok {
+				succeeded++
+			}
+		}
+		rate = 0
+		if len(results) > 0 {
+			rate = float64(succeeded) / float64(len(results))
+		}
+
+		now := time.Now()
+		if now.Sub(lastLine) >= 200*time.Millisecond {
+			bar := int(rate*20 + 0.5)
+			status := fmt.Sprintf("%5.1f ms", float64(latency)/float64(time.Millisecond))
+			if err != nil {
+				status = "no tag  "
+			}
+			fmt.Printf("\rSuccess %3.0f%% [%s%s] %s", 100*rate, strings.Repeat("#", bar), strings.Repeat("-", 20-bar), status)
+			lastLine = now
+		}
+		if interval := beepInterval(rate); interval > 0 && now.Sub(lastBeep) >= interval {
+			fmt.Print("\a")
+			lastBeep = now
+		}
+		if err != nil {
+			// no tag in the field, don't spin on the reader
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+func TestParseCommandChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		chain  string
+		shared string
+		steps  []commandStep
+		err    bool
+	}{
+		{"single command", "readlora", "", []commandStep{{"readlora", ""}}, false},
+		{"shared param", "readlora,readmacs", "x", []commandStep{{"readlora", "x"}, {"readmacs", "x"}}, false},
+		{"own params", "writeloradeveui=00:11:22:33:44:55:66:77;writelorajoineui=aabbccddeeff0011;readlora", "",
+			[]commandStep{{"writeloradeveui", "0011223344556677"}, {"writelorajoineui", "AABBCCDDEEFF0011"}, {"readlora", ""}}, false},
+		{"own and shared params", "writeblelocal=Moni;sleep", "true", []commandStep{{"writeblelocal", "Moni"}, {"sleep", "true"}}, false},
+		{"param holding '='", "writeuserdata=asset-id=PAL-1", "", []commandStep{{"writeuserdata", "asset-id=PAL-1"}}, false},
+		{"empty steps", " ;readlora;; ", "", []commandStep{{"readlora", ""}}, false},
+		{"quoted ';'", `writeblelocal="Dock;7";readblelocal`, "", []commandStep{{"writeblelocal", `"Dock;7"`}, {"readblelocal", ""}}, false},
+		{"quoted ','", `writeblelocal="a,b"`, "", []commandStep{{"writeblelocal", `"a,b"`}}, false},
+		{"unterminated quote", `writeblelocal="Dock;7`, "", nil, true},
+		{"no command", " ; ", "", nil, true},
+		{"missing name", "=0011223344556677", "", nil, true},
+		{"invalid key", "writelorajoinkey=0011", "", nil, true},
+		{"invalid shared key", "writeloradeveui,readlora", "0011", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			steps, err := parseCommandChain(test.chain, test.shared)
+			if (err != nil) != test.err {
+				t.Fatalf("parseCommandChain() error = %v, want error %v", err, test.err)
+			}
+			if !reflect.DeepEqual(steps, test.steps) {
+				t.Errorf("steps = %+v, want %+v", steps, test.steps)
+			}
+		})
+	}
+}
+
+func TestRunChain(t *testing.T) {
+	tests := []struct {
+		name    string
+		chain   string
+		err     bool
+		written bool
+	}{
+		{"every step runs", "writeassetid=PAL-1;writeblelocal=Moni", false, true},
+		{"stops at the failing step", "writeassetid=PAL-1;readsector;writeblelocal=Moni", true, false},
+		{"first step fails", "readsector;writeblelocal=Moni", true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tag, err := emulator.New(64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			session := nfc.NewSession(func() (nfc.CardTransport, error) { return tag, nil })
+			defer session.Close()
+			steps, err := parseCommandChain(test.chain, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = runChain(steps, session)
+			if (err != nil) != test.err {
+				t.Fatalf("runChain() error = %v, want error %v", err, test.err)
+			}
+			card, err := session.Card()
+			if err != nil {
+				t.Fatal(err)
+			}
+			name, err := card.ReadBLELocalName()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (name == "Moni") != test.written {
+				t.Errorf("BLE local name = %q, want the last step run %v", name, test.written)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/batch"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/keystore"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// cmKeystoreRecipient is the public key of -cm-keystore-key, loaded by applyCMMode
+var cmKeystoreRecipient *ecdh.PublicKey
+
+// applyCMMode enables -cm-mode. Contract manufacturer stations never show or export the JoinEUI and
+// the keys, the records with keys go to the sealed -cm-keystore only, which the station can not open.
+func applyCMMode() error {
+	nfc.SetCMMode(cmMode)
+	if !cmMode {
+		return nil
+	}
+	if exportKeys {
+		return fmt.Errorf("-export-keys can not be used with -cm-mode")
+	}
+	if serveAddr != "" {
+		return fmt.Errorf("-serve can not be used with -cm-mode, the proxy passes the key blocks to its clients")
+	}
+	if cmKeystoreKey == "" {
+		return fmt.Errorf("-cm-mode needs -cm-keystore-key, the public key of the keystore (see -cmd genkeystorekey)")
+	}
+	key, err := keystore.LoadPublicKey(cmKeystoreKey)
+	if err != nil {
+		return fmt.Errorf("failed to load keystore key: %v", err)
+	}
+	cmKeystoreRecipient = key
+	return nil
+}
+
+// sealRecord appends the record with its keys to -cm-keystore in CM mode
+func sealRecord(record batch.Record) error {
+	if !cmMode {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	err = keystore.Append(cmKeystore, cmKeystoreRecipient, data)
+	if err != nil {
+		return fmt.Errorf("failed to write keystore %s: %v", cmKeystore, err)
+	}
+	return nil
+}
+
+// hideRecord removes the JoinEUI and the keys from a record in CM mode, fingerprints are kept
+func hideRecord(record batch.Record) batch.Record {
+	if cmMode {
+		record.JoinEUI, record.JoinKey, record.NwkKey = "", "", ""
+	}
+	return record
+}
+
+// runOpenKeystore prints the records of a sealed keystore as JSON lines, params: <keystore>,<key file>
+func runOpenKeystore(params string) error {
+	path, keyFile, _ := strings.Cut(params, ",")
+	if path == "" || keyFile == "" {
+		return fmt.Errorf("missing params (<keystore>,<key file>)")
+	}
+	key, err := keystore.LoadPrivateKey(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load keystore key: %v", err)
+	}
+	records, err := keystore.ReadFile(path, key)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		fmt.Println(string(record))
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// commandInfo describes a command accepted by -cmd and scripts with its handler: run for the commands
+// run on the connected tag by execCommand, offline for the commands which need no reader
+type commandInfo struct {
+	Name        string
+	Params      string
+	Description string
+	Examples    []string
+	run         commandHandler
+	offline     func(params string) error
+}
+
+// commandHandler runs a command on the tag, it logs its errors
+type commandHandler func(params string, nfcCardInstance *nfc.NfcCard, result *Result) error
+
+// commandList is the registry of the commands, filled by registerCommands. Help, the check of
+// command chains before connecting to a tag and the dispatch of every command use it.
+var commandList []commandInfo
+
+// commandProducts holds the product of the commands of the product command sets. Each set is a
+// commands_<product>.go file registering its commands from init, behind a build tag so variants of
+// the tool can leave it out (e.g. -tags no_senserange). Commands shared by every product are
+// registered below.
+var commandProducts = make(map[string]string)
+
+func init() {
+	registerCommands("", []commandInfo{
+		{"commands", "", "List all commands", []string{"-cmd commands"}, nil, func(string) error { printCommands(); return nil }},
+		{"help", "<command>", "Show the parameters and examples of a command", []string{"-cmd help -param writelorajoinkey"}, nil, printCommandHelp},
+		{"readers", "", "List every reader with the product name, serial number and firmware version of its SAM slot, queried concurrently; -output json prints a JSON array", []string{"-cmd readers", "-cmd readers -output json"}, nil, func(string) error { return printReaderInventory() }},
+		{"srnr", "", "Same as readers", []string{"-cmd srnr"}, nil, func(string) error { return printReaderInventory() }},
+		{"SerialNumberTest", "", "Same as srnr, run against a connected tag", nil, serialNumberTestCommand, nil},
+
+		{"readlora", "", "Read BLE MAC, DevEUI, JoinEUI, JoinKey and CRC status", []string{"-cmd readlora"}, readloraCommand, nil},
+		{"calibrate", "[window]", "Fixture calibration: read block 0 continuously and show (and beep) the success rate of the last reads (default 50) until <Enter>", []string{"-cmd calibrate", "-cmd calibrate -param 100"}, calibrateCommand, nil},
+		{"inventory", "", "List every tag in the field (ISO 15693 inventory) with its UID, DSFID and AFI, e.g. to audit enclosures holding several tagged boards", []string{"-cmd inventory", "-cmd inventory -output json"}, inventoryCommand, nil},
+		{"sysinfo", "", "Decode the ISO 15693 system information: UID, manufacturer, IC reference, DSFID, AFI, block size and count, memory layout", []string{"-cmd sysinfo", "-cmd sysinfo -output json"}, sysinfoCommand, nil},
+		{"sectors", "", "List the M24LR sectors (32 blocks each, 64 on the M24LR64E-R) with their security status: lock, read/write protection and password", []string{"-cmd sectors", "-cmd sectors -output json"}, sectorsCommand, nil},
+		{"lockstatus", "", "Map the locked and writable blocks of the tag from the security status of every block, to diagnose writes refused by a locked sector (status word 6982)", []string{"-cmd lockstatus", "-cmd lockstatus -output json"}, lockstatusCommand, nil},
+		{"readsector", "<sector>", "Read every block of an M24LR sector", []string{"-cmd readsector -param 40"}, readsectorCommand, nil},
+		{"ehconfig", "[setting=value,...]", "Show or set the M24LR energy harvesting and digital output configuration: enable=on|off (until power down), powerup=on|off, load=0-3 (6 mA, 3 mA, 1 mA, 300 uA), output=wip|busy", []string{"-cmd ehconfig", "-cmd ehconfig -param powerup=on,load=1", "-cmd ehconfig -param enable=on,output=wip"}, ehconfigCommand, nil},
+		{"writeafi", "<2 hex>", "Write the Application Family Identifier of the tag (ISO 15693 write AFI), see -afi", []string{"-cmd writeafi -param 07"}, writeIdentifierCommand("writeafi"), nil},
+		{"writedsfid", "<2 hex>", "Write the Data Storage Format Identifier of the tag (ISO 15693 write DSFID)", []string{"-cmd writedsfid -param 01"}, writeIdentifierCommand("writedsfid"), nil},
+		{"rfcheck", "[reads]", "Score the RF coupling of the tag: repeated reads at increasing payload sizes, error rate and latency (default 20 reads per size)", []string{"-cmd rfcheck", "-cmd rfcheck -param 50"}, rfcheckCommand, nil},
+		{"readloraloop", "[csv file]", "Read the LoRa information of one tag after another into a CSV file (default -export), columns per -export-template, a tag left on the reader is skipped (see -duplicates)", []string{"-cmd readloraloop -param tags.csv", "-cmd readloraloop -param erp.csv -export-template erp.yaml"}, readloraloopCommand, nil},
+		{"readmacs", "", "Read the LoRa and BLE MAC addresses", nil, readmacsCommand, nil},
+		{"writeloradeveui", "<16 hex>", "Write the LoRa DevEUI (blocks 11-12) and update the CRC", []string{"-cmd writeloradeveui -param 0011223344556677"}, writeloradeveuiCommand, nil},
+		{"writelorajoineui", "<16 hex>", "Write the LoRa JoinEUI (blocks 0-1) and update the CRC", []string{"-cmd writelorajoineui -param AABBCCDDEEFF0011"}, writelorajoineuiCommand, nil},
+		{"writelorajoinkey", "<32 hex>", "Write the LoRa Join (App) Key (blocks 3-6) and update the CRC", []string{"-cmd writelorajoinkey -param 00112233445566778899AABBCCDDEEFF"}, writelorajoinkeyCommand, nil},
+		{"sleep", "<true|false>", "Put the tag to sleep (true) or wake it up (false)", []string{"-cmd sleep -param true"}, sleepCommand, nil},
+
+		{"readblelocal", "", "Read the BLE local name", nil, readblelocalCommand, nil},
+		{"writeblelocal", "<name>", "Write the BLE local name, up to 8 bytes: plain text, hex:<bytes> or a quoted string keeping spaces, padded with -name-pad; with -db a name another tag already has is refused (or only warned about with -name-collision warn)", []string{"-cmd writeblelocal -param Moni-ID", "-cmd writeblelocal -param hex:53503430", "-cmd writeblelocal -param '\"Dock 7\"'", "-cmd writeblelocal -param Moni-0042 -db station.db"}, writeblelocalCommand, nil},
+		{"writeblemac", "<MAC>", "Overwrite the factory BLE MAC, requires -force-factory and -confirm-uid", []string{"-cmd writeblemac -param 11:22:33:44:55:66 -force-factory -confirm-uid E002..."}, writeblemacCommand, nil},
+		{"readsku", "", "Read the beacon type (SKU)", nil, readskuCommand, nil},
+		{"wear", "", "Show the writes -db recorded for the tag: sessions which wrote it, block writes and the most written block", []string{"-cmd wear -db station.db"}, wearCommand, nil},
+		{"capabilities", "", "Show the features of the tag from its beacon type, firmware version and memory size: Class B, GNSS and Sense Range settings", []string{"-cmd capabilities", "-cmd capabilities -output json"}, capabilitiesCommand, nil},
+		{"setsku", "<type>", "Write the beacon type by hex code or name and update the CRC", []string{"-cmd setsku -param 15", "-cmd setsku -param \"Sense Asset +\""}, setskuCommand, nil},
+		{"readibeacon", "", "Read the iBeacon UUID, major and minor", nil, readibeaconCommand, nil},
+		{"writeibeacon", "<UUID>,<major>,<minor>", "Write the iBeacon identity, major and minor in decimal", []string{"-cmd writeibeacon -param f7826da6-4fa2-4e98-8024-bc5b71e0893e,1,100"}, writeibeaconCommand, nil},
+		{"crosscheck", "", "Look the DevEUI of the tag up on -network-server (ChirpStack v4 or The Things Stack v3) and compare the registration with the tag: JoinEUI and AppKey fingerprint, catching registration drift", []string{"-cmd crosscheck -network-server chirpstack:https://ns.example.com:8090", "-cmd crosscheck -network-server ttn:https://eu1.cloud.thethings.network,asset-trackers"}, crosscheckCommand, nil},
+		{"rekey", "<batch>", "Respond to leaked keys batch by batch: give every presented tag of the batch (listed in -rekey-records) a new random JoinKey, after operator authorization; the key is sealed to -cm-keystore (needs -cm-keystore-key), set on the Join Server with -network-server and then written to the tag, a failed tag write restores the old key; the old and new key fingerprints are logged to -rekey-log", []string{"-cmd rekey -param B-2024-07 -rekey-records batch_export.csv -cm-keystore-key keystore.key.pub", "-cmd rekey -param B-2024-07 -rekey-records B-2024-07_report.json -cm-keystore-key keystore.key.pub -network-server chirpstack:https://ns.example.com:8090"}, rekeyCommand, nil},
+		{"decommission", "confirm[,<reason>]", "Retire a tag: read its identifiers, erase the key blocks (the factory BLE MAC and EUIs stay), mark the DevEUI retired in -db so run-manifest never reuses it, delete the device from -network-server with -decommission-delete and write a decommission certificate to -decommission-dir; requires the operator PIN or an approval token when configured", []string{"-cmd decommission -param confirm,end of life -db station.db", "-cmd decommission -param confirm -db station.db -decommission-delete -network-server chirpstack:https://ns.example.com:8090"}, decommissionCommand, nil},
+		{"migrate-blename", "[<log.csv>][,<name length>]", "Repair the BLE local names older tools padded with '0' characters: for every presented tag the '0' padding is trimmed (to the name in -db, or to the name length; one of them is required), the name written again padded with 0x00 and the UID logged to <log.csv> (default blename_migration.csv)", []string{"-cmd migrate-blename -db station.db", "-cmd migrate-blename -param repaired.csv,4 -db station.db"}, migrateBlenameCommand, nil},
+		{"readassetid", "", "Read the customer asset ID of the tag, kept in the user data area", nil, readassetidCommand, nil},
+		{"writeassetid", "<asset ID>", fmt.Sprintf("Write the customer asset ID of the tag, up to %d printable ASCII characters (plain, hex:<bytes> or quoted); readlora, readloraloop export templates ({{.AssetID}}) and run-manifest exports include it", nfc.ASSET_ID_MAX), []string{"-cmd writeassetid -param PAL-00172"}, writeassetidCommand, nil},
+		{"readuserdata", "[type]", fmt.Sprintf("Read the user data records of the tag (blocks %d-%d, %d bytes), or the record of one type: asset-id, customer-ref or a number 1-254", nfc.USERDATA_BLOCK_FIRST, nfc.USERDATA_BLOCK_LAST, nfc.USERDATA_SIZE), []string{"-cmd readuserdata", "-cmd readuserdata -param asset-id"}, readuserdataCommand, nil},
+		{"writeuserdata", "<type>=<value>", "Add or replace a user data record, the value as plain text, hex:<bytes> or a quoted string, instead of writing integrator data to blocks the firmware may use", []string{"-cmd writeuserdata -param asset-id=PAL-00172", "-cmd writeuserdata -param customer-ref=PO4471"}, writeuserdataCommand, nil},
+		{"deleteuserdata", "<type>", "Remove a user data record", []string{"-cmd deleteuserdata -param customer-ref"}, deleteuserdataCommand, nil},
+		{"ibeaconloop", "<UUID>,<major>,<minor>", "Program one iBeacon identity per tag with an incrementing minor, logged to -assignments", []string{"-cmd ibeaconloop -param f7826da6-4fa2-4e98-8024-bc5b71e0893e,1,1"}, beaconLoopCommand("ibeaconloop"), nil},
+		{"eddystoneloop", "<namespace>,<instance>", "Program one Eddystone-UID per tag with an incrementing instance (hex), logged to -assignments", []string{"-cmd eddystoneloop -param 00112233445566778899,1"}, beaconLoopCommand("eddystoneloop"), nil},
+		{"run-manifest", "<manifest.yaml>", "Run a production batch: quantity, profile, EUI pool, exports and hooks (pre-write, post-write, post-finalize; tag state as JSON on stdin) from the manifest, ends with a batch report; with audit every Nth tag (at random within each group) gets a deep verification and a network server check, logged to <name>_audit.csv", []string{"-cmd run-manifest -param batch-2024-07.yaml"}, runManifestCommand, nil},
+		{"verify-batch", "<profile.yaml>", "Incoming inspection of pre-programmed tags: read each presented tag and check it against the inspection profile (CRC, configuration image, beacon type, firmware, LoRa identity), recording pass or fail per UID to the results CSV until count (or -count) tags are inspected", []string{"-cmd verify-batch -param supplier-lot-4471.yaml -count 500"}, verifyBatchCommand, nil},
+		{"report", "<file>[,<file>...]", "Print yield statistics of run-manifest exports (CSV, JSON lines) and batch reports, see -since and -report-format", []string{"-cmd report -param batch1.csv,batch2.csv -since 2024-01-01", "-cmd report -param B1_report.json -report-format html > yield.html"}, nil, runReport},
+		{"counter", "[<name>[=<value>]]", "List the named counters of -db, show one, or set it (the next {{counter}} in an export template gets value+1), no reader needed", []string{"-cmd counter -db station.db", "-cmd counter -param trackers=1000 -db station.db"}, nil, runCounter},
+		{"retry-queue", "<manifest.yaml>", "Send the hooks run-manifest queued in -db while their target was unreachable (hooks with queue: true) and list the ones still failing, no reader needed", []string{"-cmd retry-queue -param batch-2024-07.yaml -db station.db"}, nil, runRetryQueue},
+		{"import-legacy", "<export.xml|export.csv>[,<output dir>]", "Convert an export of the legacy .NET provisioning app into a keystore (<name>_keystore.jsonl, run-manifest JSON records with keys) and one profile per distinct configuration (<name>_profile_<n>.bin), no reader needed", []string{"-cmd import-legacy -param devices_2019.xml", "-cmd import-legacy -param devices.csv,rework"}, nil, runImportLegacy},
+		{"exportbeacons", "<file.json|file.csv>", "Export the -assignments log as a beacon registry manifest", []string{"-cmd exportbeacons -param beacons.json"}, exportbeaconsCommand, nil},
+
+		{"explain", "<block>:<8 hex>[,...]", "Explain every field of captured blocks according to the Asset+ block schema, no reader needed", []string{"-cmd explain -param 13:10100000", "-cmd explain -param 30:3C000012,31:11020000"}, nil, runExplain},
+		{"backup", "<file.snap>[,<note>]", "Save the complete tag state (all blocks, system info, decoded settings, station and time) to a snapshot file, which compare reads and -emulate can emulate", []string{"-cmd backup -param tag.snap", "-cmd backup -param tag.snap,before rework"}, backupCommand, nil},
+		{"compare", "<file.snap>[,<file.snap>]", "Compare a snapshot with the tag, or two snapshots without a reader, listing the changed blocks and their fields", []string{"-cmd compare -param tag.snap", "-cmd compare -param before.snap,after.snap"}, compareCommand, nil},
+		{"readAllBlocks", "", "Dump all blocks of the tag", nil, readAllBlocksCommand, nil},
+		{"readConfigBin", "<file>", "Print the configuration fields of a binary configuration file, encrypted files need -config-key-file", []string{"-cmd readConfigBin -param AssetPlus_Config.bin"}, readConfigBinCommand, nil},
+		{"generateConfigBin", "[file]", "Save the configuration of the tag to a binary file (default AssetPlus_Config.bin), encrypted with -config-key-file if given", []string{"-cmd generateConfigBin -param cm_config.bin -config-key-file config.key"}, generateConfigBinCommand, nil},
+		{"writeConfigBin", "<file>", "Apply a configuration file to the tag, keeping its keys, EUIs, BLE MAC and name; verified against -image-verify-key (unsigned only with -allow-unsigned-images)", []string{"-cmd writeConfigBin -param AssetPlus_Config.bin -image-verify-key release.key.pub"}, writeConfigBinCommand, nil},
+		{"programTag", "<file>", "Write the blocks of a configuration file which differ from the tag in one pass (every block with -rewrite-unchanged), then verify and write the CRC; keeps the BLE MAC and device specific blocks left blank (0xFF) in the file", []string{"-cmd programTag -param AssetPlus_Config.bin"}, programTagCommand, nil},
+		{"genkeystorekey", "<key file>", "Create the key pair of the sealed -cm-keystore: <key file> opens it, <key file>.pub goes to the -cm-mode stations; X25519, P-256 in -fips mode", []string{"-cmd genkeystorekey -param keystore.key"}, nil, authCommand("genkeystorekey")},
+		{"verify-export", "<file>[,...]", "Check export, report and log files against the <file>.sha256 written with -export-checksums, e.g. after transfer from the production network, no reader needed", []string{"-cmd verify-export -param lora_info.csv", "-cmd verify-export -param batch_export.csv,batch_report.json"}, nil, runVerifyExport},
+		{"open-keystore", "<keystore>,<key file>", "Print the records of a sealed -cm-keystore as JSON lines, no reader needed", []string{"-cmd open-keystore -param cm_keystore.jsonl,keystore.key"}, nil, runOpenKeystore},
+		{"gentoken", "<name>,<permission>", "Create an access token of the -serve proxy with permission read, program (writes) or erase (blank writes and locks as well), printing the token and its -serve-tokens entry", []string{"-cmd gentoken -param desk-engineering,read"}, nil, authCommand("gentoken")},
+		{"genconfigkey", "<key file>", "Create an AES-256 key for encrypted configuration images", []string{"-cmd genconfigkey -param config.key"}, nil, authCommand("genconfigkey")},
+		{"export-mobile", "<file.json>[,<config.bin>]", "Export the configuration of the tag (or of a configuration file) for the mobile NFC app as a JSON bundle and deep link", []string{"-cmd export-mobile -param config.json", "-cmd export-mobile -param config.json,AssetPlus_Config.bin"}, exportMobileCommand, nil},
+		{"validateCrc", "", "Validate the configuration CRC", nil, validateCrcCommand, nil},
+		{"erase", "confirm", "Erase all data from the tag, requires the operator PIN or an approval token when configured", []string{"-cmd erase -param confirm", "-cmd erase -param confirm -approval-token <token>"}, eraseCommand, nil},
+		{"hashpin", "", "Print the -operator-pin-hash of an operator PIN read from stdin (Argon2id with a random salt, PBKDF2-SHA256 in FIPS mode)", []string{"-cmd hashpin"}, nil, authCommand("hashpin")},
+		{"genapprovalkey", "<key file>", "Create a key pair for signing approval tokens or configuration images (Ed25519, not available in -fips mode)", []string{"-cmd genapprovalkey -param supervisor.key"}, nil, authCommand("genapprovalkey")},
+		{"approve", "<command>,<UID|*>[,<validity>]", "Sign an approval token for a destructive command with -approval-signing-key (default validity 1h)", []string{"-cmd approve -param erase,e00235c1af8630f0,15m -approval-signing-key supervisor.key"}, nil, authCommand("approve")},
+	}...)
+}
+
+// registerCommands adds commands to the registry, the commands of a product command set with their
+// product
+func registerCommands(product string, commands ...commandInfo) {
+	for _, c := range commands {
+		if _, ok := findCommand(c.Name); ok {
+			panic(fmt.Sprintf("command %s registered twice", c.Name))
+		}
+		commandList = append(commandList, c)
+		if product != "" {
+			commandProducts[c.Name] = product
+		}
+	}
+}
+
+// findCommand returns the registry entry of a command
+func findCommand(name string) (*commandInfo, bool) {
+	for i := range commandList {
+		if commandList[i].Name == name {
+			return &commandList[i], true
+		}
+	}
+	return nil, false
+}
+
+// printCommands lists every command with its parameters
+func printCommands() {
+	commands := make([]commandInfo, len(commandList))
+	copy(commands, commandList)
+	sort.Slice(commands, func(i, j int) bool {
+		return strings.ToLower(commands[i].Name) < strings.ToLower(commands[j].Name)
+	})
+	fmt.Println("Commands (run with -cmd <command> [-param <params>]):")
+	for _, c := range commands {
+		fmt.Printf("  %-40s %s\n", strings.TrimSpace(c.Name+" "+c.Params), c.Description)
+	}
+	fmt.Println("\nUse -cmd help -param <command> for examples")
+}
+
+// printCommandHelp prints the parameters and examples of a command
+func printCommandHelp(name string) error {
+	if name == "" {
+		printCommands()
+		return nil
+	}
+	c, ok := findCommand(name)
+	if !ok {
+		return fmt.Errorf("unknown command '%s', use -cmd commands to list all commands", name)
+	}
+	fmt.Printf("%s %s\n\n  %s\n", c.Name, c.Params, c.Description)
+	if product, ok := commandProducts[c.Name]; ok {
+		fmt.Printf("\n  %s only\n", product)
+	}
+	if len(c.Examples) > 0 {
+		fmt.Println("\nExamples:")
+		for _, example := range c.Examples {
+			fmt.Printf("  %s\n", example)
+		}
+	}
+	return nil
+}
+
+// validateCommands rejects unknown commands before connecting to a tag
+func validateCommands(steps []commandStep) error {
+	for _, step := range steps {
+		if _, ok := findCommand(step.name); !ok {
+			return fmt.Errorf("unknown command '%s', use -cmd commands to list all commands", step.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+//go:build !no_assetplus
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// Asset+ commands: the region blocks and the settings of the Asset+ block schema
+func init() {
+	registerCommands("Asset+",
+		commandInfo{"region", "[preset]", "Show or write the LoRa region (byte 1 of block 7) and update the CRC: EU868, US915, AU915, AS923_GRP1-3, KR920, IN865", []string{"-cmd region", "-cmd region -param US915", "-cmd region -param EU868"}, regionCommand, nil},
+		commandInfo{"cfgr", "", "Print all Asset+ settings, -strict fails on undecodable fields", nil, readSettingsCommand, nil},
+		commandInfo{"cfgcheck", "[file]", "Check the Asset+ settings of the tag (or of a configuration file) for conflicting values, e.g. a ping slot without Class B or GNSS max below min; writeConfigBin, programTag and run-manifest refuse such images unless -allow-conflicts", []string{"-cmd cfgcheck", "-cmd cfgcheck -param AssetPlus_Config.bin"}, checkSettingsCommand, nil},
+		commandInfo{"cfgverify", "", "Check that the Asset+ settings survive a decode/encode round trip", nil, verifySettingsCommand, nil},
+	)
+}
+
+func regionCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	if params != "" {
+		preset, err := nfc.ParseRegionPreset(params)
+		if err != nil {
+			log.Errorf("Invalid region: %v\n", err)
+			return err
+		}
+		err = nfcCardInstance.WriteRegionPreset(preset)
+		if err != nil {
+			log.Errorf("Failed to write LoRa region: %v\n", err)
+			return err
+		}
+	}
+	preset, hopping, err := nfcCardInstance.ReadRegionPreset()
+	if err != nil {
+		log.Errorf("Failed to read LoRa region: %v\n", err)
+		return err
+	}
+	result.Set("LoRa Region", preset.String())
+	if preset.HasSubBands() {
+		result.Set("Sub-band Hopping", hopping)
+	}
+	if params != "" {
+		result.Message("LoRa region written successfully")
+	}
+	return nil
+}
+
+func readSettingsCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	mode := nfc.ParseLenient
+	if strict {
+		mode = nfc.ParseStrict
+	}
+	settings, warnings, err := nfcCardInstance.ReadDittoSettingsMode(mode)
+	if err != nil {
+		log.Errorf("Failed to read settings: %v\n", err)
+		return err
+	}
+	for _, warning := range warnings {
+		log.Warnf("Settings field not decoded: %v\n", warning)
+	}
+	setConfigFields(result, nfc.MappedDittoSettings(settings))
+	return nil
+}
+
+func verifySettingsCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	mismatches, err := nfcCardInstance.VerifyDittoSettings()
+	if err != nil {
+		log.Errorf("Failed to verify settings: %v\n", err)
+		return err
+	}
+	if len(mismatches) > 0 {
+		err = fmt.Errorf("settings blocks %v do not survive a decode/encode round trip", mismatches)
+		log.Errorf("%v\n", err)
+		return err
+	}
+	result.Message("Settings decode/encode round trip OK")
+	return nil
+}
+
+func checkSettingsCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var conflicts []error
+	var err error
+	if params != "" {
+		var image []byte
+		image, err = nfc.LoadConfigBin(params)
+		if err == nil {
+			conflicts, err = nfc.ValidateConfigImage(image)
+		}
+	} else {
+		var settings *nfc.DittoSettings
+		settings, err = nfcCardInstance.ReadDittoSettings()
+		if err == nil {
+			conflicts = nfc.ValidateDittoSettings(settings)
+			var caps *nfc.Capabilities
+			caps, err = nfcCardInstance.Capabilities()
+			if err == nil {
+				conflicts = append(conflicts, nfc.ValidateCapabilities(settings, caps)...)
+			}
+		}
+	}
+	if err != nil {
+		log.Errorf("Failed to read settings: %v\n", err)
+		return err
+	}
+	for i, conflict := range conflicts {
+		result.Set(fmt.Sprintf("Conflict %d", i+1), conflict.Error())
+	}
+	if len(conflicts) > 0 {
+		err = fmt.Errorf("%d settings conflicts", len(conflicts))
+		log.Errorf("%v\n", err)
+		return err
+	}
+	result.Message("Settings consistent")
+	return nil
+}
@@ -0,0 +1,81 @@
+//go:build !no_senserange
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// Sense Range commands: the LoRa control bits of block 9 of the Sense LoRa tags, which Asset+ uses
+// for the temperature and motion thresholds
+func init() {
+	registerCommands("Sense Range",
+		commandInfo{"loraDwnTrgL", "<0-255>", "Write the number of failed downlinks before the tag leaves the network", []string{"-cmd loraDwnTrgL -param 10"}, loraDwnTrgLCommand, nil},
+		commandInfo{"uplinkEnable", "<true|false>", "Enable or disable LoRa uplinks", []string{"-cmd uplinkEnable -param true"}, uplinkEnableCommand, nil},
+		commandInfo{"tagpostbit", "<true|false>", "Set the tag post bit", []string{"-cmd tagpostbit -param false"}, tagPostBitCommand, nil},
+	)
+}
+
+func loraDwnTrgLCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	if params == "" {
+		err := fmt.Errorf("missing params")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	loraFailedDownLinktrigerLeave, err := strconv.ParseUint(params, 10, 8)
+	if err != nil {
+		log.Errorf("Failed to parse params: %v\n", err)
+		return err
+	}
+	err = nfcCardInstance.WriteLoraDwnTrgL(uint8(loraFailedDownLinktrigerLeave))
+	if err != nil {
+		log.Errorf("Failed to write loraDwnTrgL: %v\n", err)
+		return err
+	}
+	result.Message("loraDwnTrgL written successfully")
+	return nil
+}
+
+func uplinkEnableCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	if params == "" {
+		err := fmt.Errorf("missing params")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	bitValue, err := strconv.ParseBool(params)
+	if err != nil {
+		log.Errorf("Failed to parse params: %v\n", err)
+		return err
+	}
+	err = nfcCardInstance.WriteTagUplinkBit(bitValue)
+	if err != nil {
+		log.Errorf("Failed to write tag post bit: %v\n", err)
+		return err
+	}
+	result.Message("Tag uplink written successfully")
+	return nil
+}
+
+func tagPostBitCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	if params == "" {
+		err := fmt.Errorf("missing params")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	bitValue, err := strconv.ParseBool(params)
+	if err != nil {
+		log.Errorf("Failed to parse params: %v\n", err)
+		return err
+	}
+	err = nfcCardInstance.WriteTagPostBit(bitValue)
+	if err != nil {
+		log.Errorf("Failed to write tag post bit: %v\n", err)
+		return err
+	}
+	result.Message("Tag post bit written successfully")
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCommandRegistry(t *testing.T) {
+	for _, c := range commandList {
+		if (c.run == nil) == (c.offline == nil) {
+			t.Errorf("command %s needs exactly one of run and offline", c.Name)
+		}
+	}
+}
+
+func TestExecCommandDispatch(t *testing.T) {
+	tests := []struct {
+		command string
+		ok      bool
+		err     string
+	}{
+		{"readblelocal", true, ""},
+		{"hashpin", false, "does not run on a tag"},
+		{"no-such-command", false, "unknown command"},
+	}
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			result, _ := runCommand(test.command, "", testCard(t, false), io.Discard)
+			if result.OK != test.ok || !strings.Contains(result.Error, test.err) {
+				t.Errorf("OK = %v (%s), want %v (%s)", result.OK, result.Error, test.ok, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/auth"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// signConfigImage writes the signature of the configuration image file to <file>.sig with -image-signing-key
+func signConfigImage(filename string) error {
+	key, err := auth.LoadPrivateKey(imageSigningKey)
+	if err != nil {
+		return fmt.Errorf("failed to load signing key: %v", err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return nfc.WriteSignature(filename+".sig", nfc.SignConfigBin(data, key))
+}
+
+// loadVerifiedConfigImage reads a configuration image file for writeConfigBin, programTag and
+// run-manifest. The image must carry a valid signature (<file>.sig) of -image-verify-key, only
+// -allow-unsigned-images waives the verification when no key is configured.
+func loadVerifiedConfigImage(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	switch {
+	case imageVerifyKey == "" && !allowUnsignedImages:
+		return nil, fmt.Errorf("%s can not be verified: configure -image-verify-key, or apply unsigned images with -allow-unsigned-images", filename)
+	case imageVerifyKey == "":
+		log.Warnf("-allow-unsigned-images set, %s is applied without signature verification\n", filename)
+	default:
+		key, err := auth.LoadPublicKey(imageVerifyKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load verify key: %v", err)
+		}
+		signature, err := nfc.ReadSignature(filename + ".sig")
+		if err != nil {
+			return nil, fmt.Errorf("missing or invalid signature: %v", err)
+		}
+		err = nfc.VerifyConfigBin(data, signature, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	image, err := nfc.DecodeConfigBin(data)
+	if err != nil {
+		return nil, err
+	}
+	return image, checkImageSettings(filename, image)
+}
+
+// checkImageSettings refuses a configuration image whose settings conflict, see cfgcheck. With
+// -allow-conflicts the conflicts are only logged.
+func checkImageSettings(filename string, image []byte) error {
+	conflicts, err := nfc.ValidateConfigImage(image)
+	if err != nil {
+		return err
+	}
+	return refuseConflicts(filename, conflicts)
+}
+
+// checkImageCapabilities refuses a configuration image with settings the tag does not support, e.g.
+// Class B on a tag without Class B (see -cmd capabilities). With -allow-conflicts they are only
+// logged.
+func checkImageCapabilities(filename string, image []byte, card *nfc.NfcCard) error {
+	caps, err := card.Capabilities()
+	if err != nil {
+		return err
+	}
+	conflicts, err := nfc.ValidateImageCapabilities(image, caps)
+	if err != nil {
+		return err
+	}
+	return refuseConflicts(filename, conflicts)
+}
+
+func refuseConflicts(filename string, conflicts []error) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+	if allowConflicts {
+		for _, conflict := range conflicts {
+			log.Warnf("%s: %v\n", filename, conflict)
+		}
+		return nil
+	}
+	return fmt.Errorf("%d settings conflicts, fix the image or use -allow-conflicts:\n%w", len(conflicts), errors.Join(conflicts...))
+}
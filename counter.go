@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// runCounter lists the counters of -db, params: empty for all counters, <name> for one or
+// <name>=<value> to set one, e.g. to continue a sequence started elsewhere
+func runCounter(params string) error {
+	if counterStore == nil {
+		return fmt.Errorf("no -db given")
+	}
+	name, value, set := strings.Cut(params, "=")
+	name = strings.TrimSpace(name)
+	if set {
+		n, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value %q of counter %s", value, name)
+		}
+		err = counterStore.Set(name, n)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Counter %s set to %d, the next value is %d\n", name, n, n+1)
+		return nil
+	}
+
+	counters, err := counterStore.All()
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		n, ok := counters[name]
+		if !ok {
+			return fmt.Errorf("no counter %s in %s", name, localDB.Path())
+		}
+		fmt.Printf("%s: %d\n", name, n)
+		return nil
+	}
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s: %d\n", name, counters[name])
+	}
+	if len(names) == 0 {
+		fmt.Printf("No counters in %s\n", localDB.Path())
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/netserver"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// crosscheckTag looks the DevEUI of the tag up on -network-server and compares the registration with
+// the tag: the JoinEUI and the fingerprints of the root keys. Keys are never printed. Returns an
+// error naming every difference, e.g. a tag reprogrammed after its registration.
+func crosscheckTag(nfcCardInstance *nfc.NfcCard, result *Result) error {
+	if networkServer == "" {
+		return fmt.Errorf("no -network-server given")
+	}
+	server, err := netserver.Open(networkServer, networkServerToken)
+	if err != nil {
+		return err
+	}
+	info, err := nfcCardInstance.ReadLoraInfo()
+	if err != nil {
+		return err
+	}
+	devEUI := strings.ReplaceAll(info.DevEUI, ":", "")
+	result.Set("Network Server", server.Name())
+	result.Set("DevEUI", devEUI)
+
+	device, err := server.Device(devEUI)
+	if errors.Is(err, netserver.ErrNotFound) {
+		result.Set("Registered", false)
+		return fmt.Errorf("DevEUI %s is not registered on %s", devEUI, server.Name())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up DevEUI %s: %w", devEUI, err)
+	}
+	result.Set("Registered", true)
+
+	var drift []string
+	if device.JoinEUI != "" {
+		result.Set("JoinEUI Tag", nfc.RedactJoinEUI(info.JoinEUI))
+		result.Set("JoinEUI Network Server", nfc.RedactJoinEUI(device.JoinEUI))
+		if !strings.EqualFold(strings.ReplaceAll(info.JoinEUI, ":", ""), device.JoinEUI) {
+			drift = append(drift, "JoinEUI")
+		}
+	}
+	keys := []struct {
+		name, tag, server string
+	}{
+		{"AppKey", info.JoinKey, device.AppKey},
+	}
+	for _, key := range keys {
+		if key.tag == "" {
+			continue
+		}
+		if key.server == "" {
+			// LoRaWAN 1.0 registrations have no NwkKey, the tag joins with its AppKey (JoinKey)
+			if key.name == "AppKey" {
+				drift = append(drift, "AppKey (not returned by the network server, check the permissions of the API key)")
+			}
+			continue
+		}
+		tagFingerprint, serverFingerprint := nfc.KeyFingerprint(key.tag), nfc.KeyFingerprint(key.server)
+		result.Set(key.name+" Fingerprint Tag", tagFingerprint)
+		result.Set(key.name+" Fingerprint Network Server", serverFingerprint)
+		if tagFingerprint != serverFingerprint {
+			drift = append(drift, key.name)
+		}
+	}
+	result.Set("Match", len(drift) == 0)
+	if len(drift) > 0 {
+		return fmt.Errorf("tag and network server registration differ: %s", strings.Join(drift, ", "))
+	}
+	result.Message("Tag matches its registration on %s", server.Name())
+	return nil
+}
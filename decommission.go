@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/auth"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/batch"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/netserver"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/store"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// decommissionTag retires a tag at the end of its life: it reads the identifiers of the tag, erases
+// the key blocks (the factory BLE MAC and the EUIs stay), records the DevEUI in -db, deletes
+// the device from -network-server with -decommission-delete and writes a decommission certificate to
+// -decommission-dir. params is "confirm" or "confirm,<reason>".
+func decommissionTag(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	confirm, reason, _ := strings.Cut(params, ",")
+	if confirm != "confirm" {
+		return fmt.Errorf("to decommission the tag, use: -cmd decommission -param confirm[,<reason>]")
+	}
+	// everything which may fail without touching the tag is checked before the keys are erased
+	if retiredRegistry == nil {
+		return fmt.Errorf("no -db given")
+	}
+	var server netserver.Server
+	if decommissionDelete {
+		if networkServer == "" {
+			return fmt.Errorf("-decommission-delete without -network-server")
+		}
+		var err error
+		server, err = netserver.Open(networkServer, networkServerToken)
+		if err != nil {
+			return err
+		}
+	}
+	certificate := &batch.DecommissionCertificate{
+		UID:      nfcCardInstance.UID(),
+		Reason:   strings.TrimSpace(reason),
+		Operator: operator,
+		Station:  station,
+		Tool:     buildinfo.Version(),
+	}
+	var signingKey ed25519.PrivateKey
+	if decommissionKey != "" {
+		var err error
+		signingKey, err = auth.LoadPrivateKey(decommissionKey)
+		if err != nil {
+			return fmt.Errorf("decommission key: %v", err)
+		}
+	}
+
+	info, err := nfcCardInstance.ReadLoraInfo()
+	if err != nil {
+		return err
+	}
+	devEUI := strings.ReplaceAll(info.DevEUI, ":", "")
+	if strings.Trim(devEUI, "F") == "" || strings.Trim(devEUI, "0") == "" {
+		return fmt.Errorf("the tag has no DevEUI, nothing to decommission")
+	}
+	certificate.DevEUI = devEUI
+	certificate.JoinEUI = strings.ReplaceAll(info.JoinEUI, ":", "")
+	certificate.AssetID = info.AssetID
+	if info.JoinKey != "" {
+		certificate.KeyFingerprint = nfc.KeyFingerprint(info.JoinKey)
+	}
+	bleMac, err := nfcCardInstance.ReadBleMac()
+	if err != nil {
+		return err
+	}
+	certificate.BLEMAC = strings.ToUpper(bleMac)
+	if previous, err := retiredRegistry.Get(devEUI); err == nil && previous != nil {
+		log.Warnf("DevEUI %s was already retired with tag %s on %s", devEUI, previous.UID, export.FormatTime(previous.Retired))
+	}
+
+	err = authorizeDestructive("decommission", certificate.UID)
+	if err != nil {
+		return fmt.Errorf("decommission refused: %v", err)
+	}
+	certificate.ErasedBlocks, err = nfcCardInstance.EraseKeys()
+	if err != nil {
+		return err
+	}
+	result.Set("UID", certificate.UID)
+	result.Set("DevEUI", devEUI)
+	result.Set("BLE MAC", certificate.BLEMAC)
+	result.Set("Erased Blocks", fmt.Sprint(certificate.ErasedBlocks))
+
+	// the keys are gone, from here on every step is attempted and the certificate is always written
+	var errs []error
+	if server != nil {
+		certificate.NetworkServer = server.Name()
+		err = server.DeleteDevice(devEUI)
+		if errors.Is(err, netserver.ErrNotFound) {
+			log.Warnf("DevEUI %s is not registered on %s, nothing to delete", devEUI, server.Name())
+			err = nil
+		}
+		certificate.NetworkServerDeleted = err == nil
+		if err != nil {
+			certificate.NetworkServerError = err.Error()
+			errs = append(errs, fmt.Errorf("failed to delete DevEUI %s from %s: %w", devEUI, server.Name(), err))
+		}
+		result.Set("Network Server Deleted", certificate.NetworkServerDeleted)
+	}
+	certificate.Decommissioned = export.Now()
+	if signingKey != nil {
+		err = certificate.Sign(signingKey)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to sign decommission certificate: %w", err))
+		}
+	}
+	digest, err := certificate.Digest()
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	err = retiredRegistry.Retire(store.RetiredEntry{
+		DevEUI:      devEUI,
+		UID:         certificate.UID,
+		Reason:      certificate.Reason,
+		Operator:    operator,
+		Station:     station,
+		Retired:     certificate.Decommissioned,
+		Certificate: hex.EncodeToString(digest),
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	path, err := certificate.Save(decommissionDir)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		result.Set("Certificate", path)
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	result.Message("Tag %s decommissioned, DevEUI %s retired", certificate.UID, devEUI)
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// applyEHSettings applies the comma separated settings of ehconfig to an M24LR:
+// enable=on|off (energy harvesting now), powerup=on|off (energy harvesting at power up),
+// load=0-3 (output, see nfc.EHLoads) and output=wip|busy (digital output)
+func applyEHSettings(card *nfc.NfcCard, params string) error {
+	config, err := card.ReadM24LRConfig()
+	if err != nil {
+		return err
+	}
+	load, atPowerUp := config.EHLoad(), config.EHAtPowerUp()
+	writeEH := false
+	for _, setting := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		if !ok {
+			return fmt.Errorf("invalid setting %q, expected key=value", setting)
+		}
+		key = strings.ToLower(key)
+		switch key {
+		case "enable", "powerup":
+			var on bool
+			switch strings.ToLower(value) {
+			case "on":
+				on = true
+			case "off":
+			default:
+				return fmt.Errorf("invalid %s %q, expected on or off", key, value)
+			}
+			if key == "powerup" {
+				atPowerUp, writeEH = on, true
+				continue
+			}
+			err = card.SetEnergyHarvesting(on)
+			if err != nil {
+				return err
+			}
+		case "load":
+			load, err = strconv.Atoi(value)
+			if err != nil || load < 0 || load >= len(nfc.EHLoads) {
+				return fmt.Errorf("invalid load %q, expected 0-%d (%s)", value, len(nfc.EHLoads)-1, strings.Join(nfc.EHLoads, ", "))
+			}
+			writeEH = true
+		case "output":
+			switch strings.ToLower(value) {
+			case "wip":
+				err = card.WriteRFWIP(true)
+			case "busy":
+				err = card.WriteRFWIP(false)
+			default:
+				return fmt.Errorf("invalid output %q, expected wip or busy", value)
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown setting %q, expected enable, powerup, load or output", key)
+		}
+	}
+	if writeEH {
+		return card.WriteEHConfig(load, atPowerUp)
+	}
+	return nil
+}
+
+// setEHResult adds the energy harvesting configuration of an M24LR to a command result
+func setEHResult(result *Result, config *nfc.M24LRConfig) {
+	onOff := func(on bool) string {
+		if on {
+			return "on"
+		}
+		return "off"
+	}
+	result.Set("Energy Harvesting", onOff(config.EHEnabled()))
+	result.Set("EH At Power Up", onOff(config.EHAtPowerUp()))
+	result.Set("EH Load", nfc.EHLoads[config.EHLoad()])
+	output := "RF BUSY"
+	if config.RFWIP() {
+		output = "RF WIP"
+	}
+	result.Set("Digital Output", output)
+	result.Set("Configuration Byte", fmt.Sprintf("%02X", config.Config))
+	result.Set("Control Register", fmt.Sprintf("%02X", config.Control))
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// runExplain prints the meaning of every field of captured blocks given as <block>:<hex>, several
+// separated by commas, e.g. "13:10100000,31:01000000". It needs no reader.
+func runExplain(params string) error {
+	if params == "" {
+		return fmt.Errorf("missing params (<block>:<8 hex>)")
+	}
+	for _, dump := range strings.Split(params, ",") {
+		blockText, data, ok := strings.Cut(strings.TrimSpace(dump), ":")
+		if !ok {
+			return fmt.Errorf("invalid block dump %q, expected <block>:<8 hex>", dump)
+		}
+		block, err := strconv.Atoi(strings.TrimSpace(blockText))
+		if err != nil || block < 0 {
+			return fmt.Errorf("invalid block number %q", blockText)
+		}
+		fields, err := nfc.ExplainBlock(block, strings.ReplaceAll(data, " ", ""))
+		if err != nil {
+			return fmt.Errorf("block %d: %v", block, err)
+		}
+		fmt.Printf("Block %d: %s\n", block, strings.ToUpper(strings.ReplaceAll(data, " ", "")))
+		for _, field := range fields {
+			fmt.Printf("  %-16s %-34s %-10s %s\n", field.Position, field.Name, field.Raw, field.Meaning)
+		}
+	}
+	return nil
+}
@@ -3,12 +3,15 @@ module github.com/jenish-rudani/HID_NFC_READER
 go 1.21.3
 
 require (
-	bitbucket.org/bluvision-cloud/kit v1.0.64
 	bitbucket.org/bluvision/pcsc v0.0.1
 	github.com/ebfe/scard v0.0.0-20230420082256-7db3f9b7c8a7
 	github.com/sirupsen/logrus v1.9.3
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/crypto v0.27.0
+	golang.org/x/sys v0.25.0
+	golang.org/x/term v0.24.0
+	google.golang.org/protobuf v1.34.2
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/sys v0.25.0 // indirect
-
 replace bitbucket.org/bluvision/pcsc => /Users/atom/Documents/BB/pcsc
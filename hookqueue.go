@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/batch"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/store"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// queueRetryInterval is how often run-manifest retries the queued hooks in the background
+const queueRetryInterval = time.Minute
+
+// webhookClient posts the HookInput of URL hooks, an unreachable server fails the hook after the timeout
+var webhookClient = &http.Client{Timeout: 15 * time.Second}
+
+// postHook posts the payload of a URL hook as JSON, any status but 2xx fails it
+func postHook(url string, payload []byte) ([]byte, error) {
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return body, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return body, nil
+}
+
+// sendQueued runs a queued hook again with the tag state it was queued with
+func sendQueued(manifest *batch.Manifest, item store.QueueItem) error {
+	hook := manifest.FindHook(item.Target)
+	if hook == nil {
+		return fmt.Errorf("hook %s is no longer in the manifest", item.Target)
+	}
+	var input batch.HookInput
+	err := json.Unmarshal(item.Payload, &input)
+	if err != nil {
+		return fmt.Errorf("invalid queued tag state: %v", err)
+	}
+	output, err := runHook(*hook, item.Payload, input.Record)
+	if err != nil {
+		if text := strings.TrimSpace(string(output)); text != "" {
+			return fmt.Errorf("%v: %s", err, text)
+		}
+		return err
+	}
+	log.Infof("Queued hook %s of tag %s sent after %d failed attempts", hook.Name, input.UID, item.Attempts)
+	return nil
+}
+
+// retryQueue retries the queued hooks of the run once
+func (run *productionRun) retryQueue() {
+	sent, remaining, err := run.queue.Retry(func(item store.QueueItem) error {
+		return sendQueued(run.manifest, item)
+	})
+	if err != nil {
+		log.Errorf("Failed to retry queued hooks: %v\n", err)
+		return
+	}
+	if sent > 0 {
+		log.Infof("%d queued hooks sent, %d still queued", sent, remaining)
+	}
+}
+
+// retryInBackground retries the queued hooks every interval until the returned function is called,
+// which reports the hooks left in the queue
+func (run *productionRun) retryInBackground(interval time.Duration) func() {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				run.retryQueue()
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		wg.Wait()
+		remaining, err := run.queue.Len()
+		if err == nil && remaining > 0 {
+			log.Warnf("%d hooks are still queued in %s, they are retried on the next run or with -cmd retry-queue\n",
+				remaining, localDB.Path())
+		}
+	}
+}
+
+// runRetryQueue retries the queued hooks of a manifest without a reader and lists the hooks which
+// still fail
+func runRetryQueue(filename string) error {
+	if filename == "" {
+		return fmt.Errorf("missing params (manifest file)")
+	}
+	manifest, err := batch.LoadManifest(filename)
+	if err != nil {
+		return err
+	}
+	if localDB == nil {
+		return fmt.Errorf("no -db given")
+	}
+	q := localDB.Queue(manifest.Name)
+	sent, remaining, err := q.Retry(func(item store.QueueItem) error {
+		return sendQueued(manifest, item)
+	})
+	if err != nil {
+		return err
+	}
+	items, err := q.Items()
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		fmt.Printf("Queued %s: hook %s, %d attempts, last error: %s\n", export.FormatTime(item.Queued), item.Target, item.Attempts, item.LastError)
+	}
+	fmt.Printf("Sent %d queued hooks, %d still queued in %s\n", sent, remaining, localDB.Path())
+	return nil
+}
@@ -0,0 +1,216 @@
+// Package actions runs the post-read actions of loop modes: a command, a Raspberry Pi GPIO pulse, a
+// sound or a message for the operator after every tag that succeeded or failed. The actions are
+// subscribed to the ProvisionCompleted events of the loop modes through a Queue, which runs them off
+// the publishing goroutine.
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultTimeout limits commands and sounds which do not set a timeout
+const DefaultTimeout = 10 * time.Second
+
+// Config lists the actions run after a tag succeeded and after it failed, e.g.
+//
+//	success:
+//	  - sound: ok.wav
+//	  - gpio: {pin: 17, duration: 500ms}
+//	failure:
+//	  - message: Put the tag in the reject bin
+//	  - command: [curl, -s, http://tower.local/red]
+type Config struct {
+	Success []Action `yaml:"success"`
+	Failure []Action `yaml:"failure"`
+	// Player is the command playing sounds, the file is appended. The default is aplay on Linux,
+	// afplay on macOS and PowerShell's SoundPlayer on Windows.
+	Player []string `yaml:"player"`
+}
+
+// Action is one post-read action, exactly one of Command, GPIO, Sound and Message is set
+type Action struct {
+	// Command is run with HIDNFC_RESULT (success or failure), HIDNFC_UID and HIDNFC_ERROR in the environment
+	Command []string `yaml:"command"`
+	GPIO    *GPIO    `yaml:"gpio"`
+	// Sound is a sound file played with the player
+	Sound string `yaml:"sound"`
+	// Message is printed for the operator, e.g. to remove the tag
+	Message string `yaml:"message"`
+	// Timeout limits Command and Sound, default DefaultTimeout
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Load reads the actions from a YAML file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read actions: %v", err)
+	}
+	config := &Config{}
+	err = yaml.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse actions %s: %v", path, err)
+	}
+	for _, list := range [][]Action{config.Success, config.Failure} {
+		for i, action := range list {
+			err = action.validate()
+			if err != nil {
+				return nil, fmt.Errorf("actions %s: action %d: %v", path, i+1, err)
+			}
+		}
+	}
+	return config, nil
+}
+
+func (a *Action) validate() error {
+	set := 0
+	for _, ok := range []bool{len(a.Command) > 0, a.GPIO != nil, a.Sound != "", a.Message != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("expected exactly one of command, gpio, sound or message")
+	}
+	if a.GPIO != nil && a.GPIO.Pin < 0 {
+		return fmt.Errorf("invalid GPIO pin %d", a.GPIO.Pin)
+	}
+	if a.GPIO != nil && a.GPIO.Value != nil && *a.GPIO.Value != 0 && *a.GPIO.Value != 1 {
+		return fmt.Errorf("invalid GPIO value %d, expected 0 or 1", *a.GPIO.Value)
+	}
+	return nil
+}
+
+// Run runs the success or failure actions for a ProvisionCompleted event, other events are ignored.
+// Failing actions are logged, they never fail the tag. A nil Config runs nothing.
+func (c *Config) Run(event events.Event) {
+	if c == nil || event.Type != events.ProvisionCompleted {
+		return
+	}
+	list := c.Success
+	if !event.OK {
+		list = c.Failure
+	}
+	for _, action := range list {
+		err := c.run(action, event)
+		if err != nil {
+			log.Warnf("Post-read action failed for tag %s: %v\n", event.UID, err)
+		}
+	}
+}
+
+// QueueSize is the number of events a Queue holds while their actions wait to run
+const QueueSize = 64
+
+// Queue runs the actions of the events handed to Handle on its own goroutine, one event after the
+// other in the order they were handed over. Event handlers must not block, the commands, sounds and
+// GPIO pulses of the actions would hold up the loop publishing the events.
+type Queue struct {
+	config *Config
+	events chan events.Event
+	done   sync.WaitGroup
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewQueue starts the goroutine running the actions of config
+func NewQueue(config *Config) *Queue {
+	q := &Queue{config: config, events: make(chan events.Event, QueueSize)}
+	q.done.Add(1)
+	go func() {
+		defer q.done.Done()
+		for event := range q.events {
+			q.config.Run(event)
+		}
+	}()
+	return q
+}
+
+// Handle queues the actions of a ProvisionCompleted event without waiting for them, the event is
+// dropped with a warning when QueueSize events are waiting already or the queue is closed
+func (q *Queue) Handle(event events.Event) {
+	if event.Type != events.ProvisionCompleted {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	select {
+	case q.events <- event:
+	default:
+		log.Warnf("Post-read actions for tag %s dropped, %d tags are waiting for their actions\n", event.UID, QueueSize)
+	}
+}
+
+// Close waits for the actions of the queued events to finish
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	q.mu.Unlock()
+	q.done.Wait()
+}
+
+func (c *Config) run(action Action, event events.Event) error {
+	switch {
+	case action.Message != "":
+		fmt.Println(action.Message)
+		return nil
+	case action.GPIO != nil:
+		return action.GPIO.pulse()
+	case action.Sound != "":
+		player := c.Player
+		if len(player) == 0 {
+			player = defaultPlayer()
+		}
+		return runCommand(append(append([]string{}, player...), action.Sound), nil, action.Timeout)
+	default:
+		result := "success"
+		if !event.OK {
+			result = "failure"
+		}
+		env := []string{"HIDNFC_RESULT=" + result, "HIDNFC_UID=" + event.UID, "HIDNFC_ERROR=" + event.Error}
+		return runCommand(action.Command, env, action.Timeout)
+	}
+}
+
+func runCommand(command []string, env []string, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %v: %s", command[0], err, output)
+	}
+	return nil
+}
+
+func defaultPlayer() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"afplay"}
+	case "windows":
+		return []string{"powershell", "-NoProfile", "-Command", "& { param($f) (New-Object Media.SoundPlayer $f).PlaySync() }"}
+	default:
+		return []string{"aplay", "-q"}
+	}
+}
@@ -0,0 +1,32 @@
+package actions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
+)
+
+func TestQueueDoesNotBlockThePublisher(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "done")
+	config := &Config{Failure: []Action{{Command: []string{"sh", "-c", "sleep 0.5; touch " + marker}}}}
+	queue := NewQueue(config)
+
+	start := time.Now()
+	queue.Handle(events.Event{Type: events.ProvisionCompleted, UID: "E001", OK: false})
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("Handle() took %v, it waited for the action", elapsed)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("the action finished before Handle() returned")
+	}
+
+	queue.Close()
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("Close() returned before the queued action finished: %v", err)
+	}
+	// events after Close are dropped
+	queue.Handle(events.Event{Type: events.ProvisionCompleted, UID: "E002"})
+}
@@ -0,0 +1,78 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// gpioRoot is the sysfs GPIO interface of the Raspberry Pi
+var gpioRoot = "/sys/class/gpio"
+
+// GPIO sets an output pin, e.g. to gate a conveyor or switch a light tower. Pin is the sysfs GPIO
+// number, on kernels 6.6 and later the BCM pins of the Raspberry Pi start at the base of gpiochip512
+// (BCM 17 is 529). With Duration the pin is set back after that time, otherwise it keeps Value.
+type GPIO struct {
+	Pin      int           `yaml:"pin"`
+	Value    *int          `yaml:"value"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+// pulse sets the pin to Value (default 1) and resets it after Duration
+func (g *GPIO) pulse() error {
+	value := 1
+	if g.Value != nil {
+		value = *g.Value
+	}
+	err := g.export()
+	if err != nil {
+		return err
+	}
+	err = g.set(value)
+	if err != nil || g.Duration <= 0 {
+		return err
+	}
+	time.Sleep(g.Duration)
+	return g.set(1 - value)
+}
+
+func (g *GPIO) dir() string {
+	return filepath.Join(gpioRoot, "gpio"+strconv.Itoa(g.Pin))
+}
+
+// export makes the pin available in sysfs and configures it as output
+func (g *GPIO) export() error {
+	if _, err := os.Stat(g.dir()); errors.Is(err, os.ErrNotExist) {
+		err = os.WriteFile(filepath.Join(gpioRoot, "export"), []byte(strconv.Itoa(g.Pin)), 0)
+		if err != nil {
+			return fmt.Errorf("failed to export GPIO %d: %v", g.Pin, err)
+		}
+	}
+	direction, err := os.ReadFile(filepath.Join(g.dir(), "direction"))
+	if err == nil && string(direction) == "out\n" {
+		return nil
+	}
+	// udev may need a moment to grant access to a freshly exported pin
+	for i := 0; ; i++ {
+		err = os.WriteFile(filepath.Join(g.dir(), "direction"), []byte("out"), 0)
+		if err == nil || i == 10 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to configure GPIO %d as output: %v", g.Pin, err)
+	}
+	return nil
+}
+
+func (g *GPIO) set(value int) error {
+	err := os.WriteFile(filepath.Join(g.dir(), "value"), []byte(strconv.Itoa(value)), 0)
+	if err != nil {
+		return fmt.Errorf("failed to set GPIO %d: %v", g.Pin, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package actions
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGPIO points gpioRoot at an empty sysfs tree with the pins of exported
+func fakeGPIO(t *testing.T, exported ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	old := gpioRoot
+	gpioRoot = root
+	t.Cleanup(func() { gpioRoot = old })
+	for _, pin := range exported {
+		err := os.MkdirAll(filepath.Join(root, "gpio"+pin), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestGPIOPulse(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		name      string
+		gpio      GPIO
+		exported  bool
+		value     string
+		exportArg string
+		err       string
+	}{
+		{"set", GPIO{Pin: 17}, true, "1", "", ""},
+		{"set to 0", GPIO{Pin: 17, Value: &zero}, true, "0", "", ""},
+		{"pulse", GPIO{Pin: 529, Duration: 10 * time.Millisecond}, true, "0", "", ""},
+		{"pulse of 0", GPIO{Pin: 529, Value: &zero, Duration: 10 * time.Millisecond}, true, "1", "", ""},
+		// the fake sysfs does not create the pin directory on export
+		{"not exported", GPIO{Pin: 22}, false, "", "22", "failed to configure GPIO 22 as output"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var root string
+			if test.exported {
+				root = fakeGPIO(t, strconv.Itoa(test.gpio.Pin))
+			} else {
+				root = fakeGPIO(t)
+			}
+			err := test.gpio.pulse()
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("pulse() = %v, want %q", err, test.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if test.exportArg != "" {
+				data, _ := os.ReadFile(filepath.Join(root, "export"))
+				if string(data) != test.exportArg {
+					t.Errorf("export = %q, want %q", data, test.exportArg)
+				}
+			}
+			if test.value == "" {
+				return
+			}
+			pin := test.gpio.dir()
+			direction, _ := os.ReadFile(filepath.Join(pin, "direction"))
+			if string(direction) != "out" {
+				t.Errorf("direction = %q, want out", direction)
+			}
+			value, _ := os.ReadFile(filepath.Join(pin, "value"))
+			if string(value) != test.value {
+				t.Errorf("value = %q, want %q", value, test.value)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{"actions", "success:\n  - sound: ok.wav\n  - gpio: {pin: 17, duration: 500ms}\nfailure:\n  - message: Put the tag in the reject bin\n", ""},
+		{"ambiguous action", "failure:\n  - message: reject\n    command: [true]\n", "action 1: expected exactly one"},
+		{"empty action", "success:\n  - timeout: 1s\n", "action 1: expected exactly one"},
+		{"negative pin", "success:\n  - gpio: {pin: -1}\n", "invalid GPIO pin -1"},
+		{"invalid value", "success:\n  - gpio: {pin: 17, value: 2}\n", "invalid GPIO value 2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "actions.yaml")
+			err := os.WriteFile(path, []byte(test.yaml), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Load(path)
+			if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("Load() = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+)
+
+// Entry is one record of the audit log
+type Entry struct {
+	Time      time.Time      `json:"time"`
+	Operation string         `json:"operation"`
+	UID       string         `json:"uid,omitempty"`
+	Method    string         `json:"method,omitempty"`
+	Subject   string         `json:"subject,omitempty"`
+	Granted   bool           `json:"granted"`
+	Reason    string         `json:"reason,omitempty"`
+	Tool      buildinfo.Info `json:"tool"`
+}
+
+// Log appends entries as JSON lines to a file. The file is opened for every entry, so the log
+// survives crashes and may be rotated by external tools.
+type Log struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewLog creates an audit log writing to path
+func NewLog(path string) *Log {
+	return &Log{path: path}
+}
+
+// Record appends the entry, the time and tool version are filled in if not set
+func (l *Log) Record(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.Tool == (buildinfo.Info{}) {
+		entry.Tool = buildinfo.Version()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return file.Sync()
+}
@@ -0,0 +1,213 @@
+// Package auth authorizes destructive operations (erase, ...) by an operator PIN or by an approval
+// token signed by a supervisor.
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/fips"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ErrDenied is returned when an operation is not authorized
+var ErrDenied = errors.New("operation not authorized")
+
+// parameters of the PIN hashes: Argon2id as recommended by RFC 9106 for memory constrained
+// environments, PBKDF2-HMAC-SHA256 with the iterations OWASP recommends in FIPS mode
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	pbkdf2Rounds  = 600000
+	pinSaltSize   = 16
+	pinKeySize    = 32
+)
+
+// HashPIN returns the hash of an operator PIN as stored in the configuration, in the PHC string
+// format with a random salt: $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>, or in FIPS mode
+// $pbkdf2-sha256$i=600000$<salt>$<hash> (salt and hash base64 without padding)
+func HashPIN(pin string) (string, error) {
+	if pin == "" {
+		return "", errors.New("empty PIN")
+	}
+	salt := make([]byte, pinSaltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	encode := base64.RawStdEncoding.EncodeToString
+	if fips.Check(fips.Argon2id) != nil {
+		key := pbkdf2.Key([]byte(pin), salt, pbkdf2Rounds, pinKeySize, sha256.New)
+		return fmt.Sprintf("$pbkdf2-sha256$i=%d$%s$%s", pbkdf2Rounds, encode(salt), encode(key)), nil
+	}
+	key := argon2.IDKey([]byte(pin), salt, argon2Time, argon2Memory, argon2Threads, pinKeySize)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads, encode(salt), encode(key)), nil
+}
+
+// CheckPIN verifies pin against a hash returned by HashPIN
+func CheckPIN(pin string, hash string) error {
+	if pin == "" {
+		return fmt.Errorf("%w: operator PIN required", ErrDenied)
+	}
+	key, expected, err := pinKey(pin, strings.TrimSpace(hash))
+	if err != nil {
+		return fmt.Errorf("%w: -operator-pin-hash: %v", ErrDenied, err)
+	}
+	if subtle.ConstantTimeCompare(key, expected) != 1 {
+		return fmt.Errorf("%w: wrong operator PIN", ErrDenied)
+	}
+	return nil
+}
+
+// pinKey derives the key of pin with the parameters and salt of hash, and returns it with the key
+// of hash
+func pinKey(pin string, hash string) ([]byte, []byte, error) {
+	fields := strings.Split(hash, "$")
+	decode := base64.RawStdEncoding.DecodeString
+	switch {
+	case len(fields) == 1 && len(hash) == 2*sha256.Size:
+		return nil, nil, errors.New("unsalted hash of an earlier version, create a new one with -cmd hashpin")
+	case len(fields) == 6 && fields[0] == "" && fields[1] == "argon2id":
+		err := fips.Check(fips.Argon2id)
+		if err != nil {
+			return nil, nil, err
+		}
+		var version, memory, passes, threads int
+		_, err = fmt.Sscanf(fields[2]+","+fields[3], "v=%d,m=%d,t=%d,p=%d", &version, &memory, &passes, &threads)
+		if err != nil || version != argon2.Version || memory <= 0 || passes <= 0 || threads <= 0 || threads > 255 {
+			return nil, nil, errors.New("invalid argon2id parameters")
+		}
+		salt, err1 := decode(fields[4])
+		expected, err2 := decode(fields[5])
+		if err1 != nil || err2 != nil || len(expected) == 0 {
+			return nil, nil, errors.New("invalid argon2id hash")
+		}
+		return argon2.IDKey([]byte(pin), salt, uint32(passes), uint32(memory), uint8(threads), uint32(len(expected))), expected, nil
+	case len(fields) == 5 && fields[0] == "" && fields[1] == "pbkdf2-sha256":
+		var rounds int
+		_, err := fmt.Sscanf(fields[2], "i=%d", &rounds)
+		if err != nil || rounds <= 0 {
+			return nil, nil, errors.New("invalid pbkdf2-sha256 parameters")
+		}
+		salt, err1 := decode(fields[3])
+		expected, err2 := decode(fields[4])
+		if err1 != nil || err2 != nil || len(expected) == 0 {
+			return nil, nil, errors.New("invalid pbkdf2-sha256 hash")
+		}
+		return pbkdf2.Key([]byte(pin), salt, rounds, len(expected), sha256.New), expected, nil
+	}
+	return nil, nil, errors.New("unknown hash format, create one with -cmd hashpin")
+}
+
+// Approval is the content of a signed approval token: the operation, the tag UID ("*" for any tag)
+// and the expiry as Unix time
+type Approval struct {
+	Operation string `json:"op"`
+	UID       string `json:"uid"`
+	Subject   string `json:"sub,omitempty"`
+	Expires   int64  `json:"exp"`
+}
+
+// Sign returns the approval as token: base64url(JSON) "." base64url(Ed25519 signature)
+func (a Approval) Sign(key ed25519.PrivateKey) (string, error) {
+	payload, err := json.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+	signature := ed25519.Sign(key, payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyApproval checks the signature of token and that it approves operation on the tag uid at time now
+func VerifyApproval(token string, key ed25519.PublicKey, operation string, uid string, now time.Time) (*Approval, error) {
+	if token == "" {
+		return nil, fmt.Errorf("%w: approval token required", ErrDenied)
+	}
+	encodedPayload, encodedSignature, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed approval token", ErrDenied)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed approval token", ErrDenied)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !ed25519.Verify(key, payload, signature) {
+		return nil, fmt.Errorf("%w: invalid approval signature", ErrDenied)
+	}
+
+	var approval Approval
+	err = json.Unmarshal(payload, &approval)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed approval token", ErrDenied)
+	}
+	switch {
+	case !strings.EqualFold(approval.Operation, operation):
+		return &approval, fmt.Errorf("%w: token approves %s, not %s", ErrDenied, approval.Operation, operation)
+	case approval.UID != "*" && !strings.EqualFold(approval.UID, uid):
+		return &approval, fmt.Errorf("%w: token approves tag %s, not %s", ErrDenied, approval.UID, uid)
+	case now.Unix() > approval.Expires:
+		return &approval, fmt.Errorf("%w: approval token expired at %s", ErrDenied, time.Unix(approval.Expires, 0).Format(time.RFC3339))
+	}
+	return &approval, nil
+}
+
+// GenerateKey writes a new signing key (hex seed) to path and its public key (hex) to path.pub.
+// Ed25519 keys are not generated in FIPS mode.
+func GenerateKey(path string) error {
+	err := fips.Check(fips.Ed25519)
+	if err != nil {
+		return err
+	}
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path, []byte(hex.EncodeToString(private.Seed())+"\n"), 0600)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+".pub", []byte(hex.EncodeToString(public)+"\n"), 0644)
+}
+
+// LoadPrivateKey reads a signing key written by GenerateKey
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	seed, err := readHexKey(path, ed25519.SeedSize)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// LoadPublicKey reads a public key written by GenerateKey
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	key, err := readHexKey(path, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func readHexKey(path string, size int) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != size {
+		return nil, fmt.Errorf("%s: expected %d hex encoded bytes", path, size)
+	}
+	return key, nil
+}
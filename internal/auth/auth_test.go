@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func TestHashPIN(t *testing.T) {
+	hash, err := HashPIN("4711")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=4$") {
+		t.Errorf("HashPIN() = %q, want an argon2id PHC string", hash)
+	}
+	other, err := HashPIN("4711")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == other {
+		t.Error("HashPIN() returned the same hash twice, the salt is not random")
+	}
+	if err := CheckPIN("4711", hash); err != nil {
+		t.Errorf("CheckPIN(right PIN) = %v", err)
+	}
+	if err := CheckPIN("4712", hash); !errors.Is(err, ErrDenied) {
+		t.Errorf("CheckPIN(wrong PIN) = %v, want ErrDenied", err)
+	}
+	if _, err := HashPIN(""); err == nil {
+		t.Error("HashPIN(\"\") accepted an empty PIN")
+	}
+}
+
+func TestCheckPIN(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	key := pbkdf2.Key([]byte("4711"), salt, 1000, 32, sha256.New)
+	encode := base64.RawStdEncoding.EncodeToString
+	pbkdf2Hash := fmt.Sprintf("$pbkdf2-sha256$i=1000$%s$%s", encode(salt), encode(key))
+	legacy := sha256.Sum256([]byte("hidnfc-operator-pin:4711"))
+
+	tests := []struct {
+		name string
+		pin  string
+		hash string
+		ok   bool
+	}{
+		{"pbkdf2", "4711", pbkdf2Hash, true},
+		{"pbkdf2 wrong PIN", "0000", pbkdf2Hash, false},
+		{"pbkdf2 with spaces", "4711", " " + pbkdf2Hash + "\n", true},
+		{"empty PIN", "", pbkdf2Hash, false},
+		{"unsalted SHA-256 of earlier versions", "4711", hex.EncodeToString(legacy[:]), false},
+		{"empty hash", "4711", "", false},
+		{"unknown algorithm", "4711", "$md5$x$y", false},
+		{"invalid argon2id parameters", "4711", "$argon2id$v=19$m=0,t=3,p=4$c2FsdA$a2V5", false},
+		{"invalid base64", "4711", "$pbkdf2-sha256$i=1000$!!$a2V5", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckPIN(test.pin, test.hash)
+			if (err == nil) != test.ok {
+				t.Errorf("CheckPIN() = %v, want ok %v", err, test.ok)
+			}
+			if err != nil && !errors.Is(err, ErrDenied) {
+				t.Errorf("CheckPIN() = %v, want ErrDenied", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Permissions of access tokens, each one includes the ones before it
+const (
+	// PermissionRead allows reading tags, except their key blocks, and the reader
+	PermissionRead = "read"
+	// PermissionProgram allows reading key blocks, writing tags and reader settings as well
+	PermissionProgram = "program"
+	// PermissionErase allows erasing blocks (writing them all 0xFF), clearing key blocks (all 0x00),
+	// locking blocks and commands which can not be classified as well
+	PermissionErase = "erase"
+)
+
+var permissionLevels = map[string]int{PermissionRead: 1, PermissionProgram: 2, PermissionErase: 3}
+
+// tokenSalt separates the token hashes of the tool from plain SHA-256 hashes of the same token
+const tokenSalt = "hidnfc-access-token:"
+
+// Token is an access token of the reader proxy. Only the hash of the token is stored.
+type Token struct {
+	Name       string `yaml:"name"`
+	SHA256     string `yaml:"sha256"`
+	Permission string `yaml:"permission"`
+}
+
+// Tokens are the access tokens accepted by the reader proxy, see LoadTokens
+type Tokens struct {
+	Tokens []Token `yaml:"tokens"`
+}
+
+// HashToken returns the hash of an access token as stored in the tokens file (hex SHA-256)
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(tokenSalt + token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateToken returns a new random access token
+func GenerateToken() (string, error) {
+	token := make([]byte, 32)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// LoadTokens reads a YAML tokens file:
+//
+//	tokens:
+//	  - name: desk-engineering
+//	    sha256: <HashToken of the token>
+//	    permission: read
+func LoadTokens(path string) (*Tokens, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tokens := &Tokens{}
+	err = yaml.Unmarshal(data, tokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if len(tokens.Tokens) == 0 {
+		return nil, fmt.Errorf("%s: no tokens", path)
+	}
+	for _, token := range tokens.Tokens {
+		if token.Name == "" {
+			return nil, fmt.Errorf("%s: token without name", path)
+		}
+		if hash, err := hex.DecodeString(token.SHA256); err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("%s: token %s: invalid sha256, expected 64 hex characters (see -cmd gentoken)", path, token.Name)
+		}
+		if permissionLevels[token.Permission] == 0 {
+			return nil, fmt.Errorf("%s: token %s: invalid permission %q, expected %s, %s or %s", path, token.Name,
+				token.Permission, PermissionRead, PermissionProgram, PermissionErase)
+		}
+	}
+	return tokens, nil
+}
+
+// Find returns the token matching token
+func (t *Tokens) Find(token string) (*Token, error) {
+	if token == "" {
+		return nil, fmt.Errorf("%w: access token required", ErrDenied)
+	}
+	hash := []byte(HashToken(token))
+	for i := range t.Tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(strings.ToLower(t.Tokens[i].SHA256))) == 1 {
+			return &t.Tokens[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown access token", ErrDenied)
+}
+
+// Allows reports whether the token has permission, e.g. PermissionProgram
+func (t *Token) Allows(permission string) bool {
+	return permissionLevels[t.Permission] >= permissionLevels[permission]
+}
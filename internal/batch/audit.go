@@ -0,0 +1,62 @@
+package batch
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// HookAudit is the stage of the check hook of an audit, see Audit.Check
+const HookAudit = "audit"
+
+// Audit selects a random sample of the tags of a run for a deep verification: every block of the
+// tag is read back and compared with what was written, and Check cross checks the tag with the
+// network server. One tag out of every Every programmed tags is selected, at a random position
+// within each group so the operator can not tell which tag will be audited. The results are
+// written to Results apart from the exports, for the quality records.
+type Audit struct {
+	Every int `yaml:"every"`
+	// Check is run for every audited tag once its post-write hooks ran, e.g. a command looking up
+	// the DevEUI on the network server; it gets the HookInput with stage "audit" like the other hooks
+	// and the audit fails if it fails. Optional and Queue do not apply.
+	Check *Hook `yaml:"check"`
+	// Results is the CSV file receiving the audit results, default <name>_audit.csv
+	Results string `yaml:"results"`
+}
+
+func (a *Audit) validate() error {
+	if a.Every <= 0 {
+		return fmt.Errorf("audit every must be positive")
+	}
+	if a.Check != nil && len(a.Check.Command) == 0 && a.Check.URL == "" {
+		return fmt.Errorf("audit check without command or url")
+	}
+	if a.Check != nil && len(a.Check.Command) > 0 && a.Check.URL != "" {
+		return fmt.Errorf("audit check has both a command and a url")
+	}
+	return nil
+}
+
+// Sampler selects one tag out of every n, at a random position within each group of n tags
+type Sampler struct {
+	n        int
+	position int
+	pick     int
+}
+
+// NewSampler returns a sampler selecting one tag out of every n
+func NewSampler(n int) *Sampler {
+	s := &Sampler{n: n}
+	s.pick = rand.Intn(n)
+	return s
+}
+
+// Next reports whether the next tag is selected
+func (s *Sampler) Next() bool {
+	selected := s.position == s.pick
+	s.position++
+	if s.position == s.n {
+		s.position = 0
+		s.pick = rand.Intn(s.n)
+	}
+	return selected
+}
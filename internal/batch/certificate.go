@@ -0,0 +1,121 @@
+package batch
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+)
+
+// certificateSignaturePrefix separates certificate signatures from other signatures of the same key
+const certificateSignaturePrefix = "hidnfc-certificate:"
+
+// ErrBadCertificate is returned when the signature of a certificate does not verify
+var ErrBadCertificate = errors.New("invalid certificate signature")
+
+// Certificates configures the birth certificates of a run: one JSON file per programmed tag in Dir,
+// signed with the Ed25519 Key (see -cmd genapprovalkey) when given. With Record the digest of the
+// certificate is recorded by UID in the station database (-db), so the tag can be matched to its
+// certificate.
+type Certificates struct {
+	Dir    string `yaml:"dir"`
+	Key    string `yaml:"key"`
+	Record bool   `yaml:"record"`
+}
+
+// Certificate is the birth certificate of a programmed tag
+type Certificate struct {
+	UID    string `json:"uid"`
+	Batch  string `json:"batch"`
+	DevEUI string `json:"devEui"`
+	// KeyFingerprint identifies the JoinKey, the key itself is never part of a certificate
+	KeyFingerprint string `json:"joinKeyFingerprint"`
+	Firmware       string `json:"firmware,omitempty"`
+	// ProfileHash is the SHA-256 of the profile image and the commands the tag was programmed with
+	ProfileHash string         `json:"profileHash,omitempty"`
+	Operator    string         `json:"operator,omitempty"`
+	Station     string         `json:"station,omitempty"`
+	Tool        buildinfo.Info `json:"tool"`
+	Issued      time.Time      `json:"issued"`
+	// Signature is the hex Ed25519 signature of the certificate without the signature
+	Signature string `json:"signature,omitempty"`
+}
+
+// ProfileHash returns the hex SHA-256 of a profile image and the commands run after it, empty
+// without either
+func ProfileHash(profile []byte, commands string) string {
+	if len(profile) == 0 && commands == "" {
+		return ""
+	}
+	sum := sha256.Sum256(append(append([]byte{}, profile...), commands...))
+	return hex.EncodeToString(sum[:])
+}
+
+// payload returns the signed content: the JSON of the certificate without the signature
+func (c *Certificate) payload() ([]byte, error) {
+	unsigned := *c
+	unsigned.Signature = ""
+	return json.Marshal(unsigned)
+}
+
+// Digest returns the SHA-256 of the certificate without the signature
+func (c *Certificate) Digest() ([]byte, error) {
+	payload, err := c.payload()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(payload)
+	return sum[:], nil
+}
+
+// Sign sets the signature of the certificate
+func (c *Certificate) Sign(key ed25519.PrivateKey) error {
+	payload, err := c.payload()
+	if err != nil {
+		return err
+	}
+	c.Signature = hex.EncodeToString(ed25519.Sign(key, append([]byte(certificateSignaturePrefix), payload...)))
+	return nil
+}
+
+// Verify checks the signature of the certificate
+func (c *Certificate) Verify(key ed25519.PublicKey) error {
+	signature, err := hex.DecodeString(c.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return ErrBadCertificate
+	}
+	payload, err := c.payload()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, append([]byte(certificateSignaturePrefix), payload...), signature) {
+		return ErrBadCertificate
+	}
+	return nil
+}
+
+// Save writes the certificate to <dir>/<UID>.json
+func (c *Certificate) Save(dir string) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, strings.ToLower(c.UID)+".json")
+	err = os.WriteFile(path, append(data, '\n'), 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write certificate: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,105 @@
+package batch
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+)
+
+// decommissionSignaturePrefix separates decommission certificate signatures from birth certificate
+// signatures of the same key
+const decommissionSignaturePrefix = "hidnfc-decommission:"
+
+// DecommissionCertificate records the decommissioning of a tag: the identifiers read before the keys
+// were erased, which blocks were erased and whether the device was deleted from the network server
+type DecommissionCertificate struct {
+	UID     string `json:"uid"`
+	DevEUI  string `json:"devEui"`
+	JoinEUI string `json:"joinEui,omitempty"`
+	// KeyFingerprint identifies the erased JoinKey, the key itself is never part of a certificate
+	KeyFingerprint string `json:"joinKeyFingerprint,omitempty"`
+	BLEMAC         string `json:"bleMac,omitempty"`
+	AssetID        string `json:"assetId,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+	ErasedBlocks   []int  `json:"erasedBlocks"`
+	// NetworkServer is the server the device was deleted from, empty if deletion was not requested
+	NetworkServer        string         `json:"networkServer,omitempty"`
+	NetworkServerDeleted bool           `json:"networkServerDeleted"`
+	NetworkServerError   string         `json:"networkServerError,omitempty"`
+	Operator             string         `json:"operator,omitempty"`
+	Station              string         `json:"station,omitempty"`
+	Tool                 buildinfo.Info `json:"tool"`
+	Decommissioned       time.Time      `json:"decommissioned"`
+	// Signature is the hex Ed25519 signature of the certificate without the signature
+	Signature string `json:"signature,omitempty"`
+}
+
+// payload returns the signed content: the JSON of the certificate without the signature
+func (c *DecommissionCertificate) payload() ([]byte, error) {
+	unsigned := *c
+	unsigned.Signature = ""
+	return json.Marshal(unsigned)
+}
+
+// Digest returns the SHA-256 of the certificate without the signature
+func (c *DecommissionCertificate) Digest() ([]byte, error) {
+	payload, err := c.payload()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(payload)
+	return sum[:], nil
+}
+
+// Sign sets the signature of the certificate
+func (c *DecommissionCertificate) Sign(key ed25519.PrivateKey) error {
+	payload, err := c.payload()
+	if err != nil {
+		return err
+	}
+	c.Signature = hex.EncodeToString(ed25519.Sign(key, append([]byte(decommissionSignaturePrefix), payload...)))
+	return nil
+}
+
+// Verify checks the signature of the certificate
+func (c *DecommissionCertificate) Verify(key ed25519.PublicKey) error {
+	signature, err := hex.DecodeString(c.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return ErrBadCertificate
+	}
+	payload, err := c.payload()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, append([]byte(decommissionSignaturePrefix), payload...), signature) {
+		return ErrBadCertificate
+	}
+	return nil
+}
+
+// Save writes the certificate to <dir>/<UID>_decommission.json, next to the birth certificate of the
+// tag when dir is the certificate directory of the run
+func (c *DecommissionCertificate) Save(dir string) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, strings.ToLower(c.UID)+"_decommission.json")
+	err = os.WriteFile(path, append(data, '\n'), 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write decommission certificate: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,64 @@
+package batch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// DevEUI derivation schemes, see EUIPool.DevEUIScheme
+const (
+	// DeriveSHA256 fills the DevEUI after the prefix with the first bytes of the SHA-256 of the UID
+	DeriveSHA256 = "sha256"
+	// DeriveTruncate fills the DevEUI after the prefix with the last bytes of the UID, so the UID can
+	// be read off the DevEUI. ST UIDs hold a 6 byte serial, prefixes longer than 2 bytes cut it short.
+	DeriveTruncate = "truncate"
+)
+
+// Derivation derives the DevEUI of a tag from its UID: the prefix (e.g. an IEEE OUI) followed by
+// bytes of the UID taken by the scheme. The same UID always gives the same DevEUI, so tags without
+// a factory EUI can be provisioned without an EUI pool.
+type Derivation struct {
+	prefix []byte
+	scheme string
+}
+
+// NewDerivation creates the derivation of a prefix of 1 to 7 bytes (hex, colons allowed) and a
+// scheme, DeriveSHA256 if empty
+func NewDerivation(prefix string, scheme string) (*Derivation, error) {
+	data, err := hex.DecodeString(strings.ReplaceAll(prefix, ":", ""))
+	if err != nil || len(data) < 1 || len(data) > 7 {
+		return nil, fmt.Errorf("invalid deveui-prefix %q, expected 2 to 14 hex characters, e.g. an OUI", prefix)
+	}
+	switch scheme {
+	case "":
+		scheme = DeriveSHA256
+	case DeriveSHA256, DeriveTruncate:
+	default:
+		return nil, fmt.Errorf("invalid deveui-scheme %q, expected %s or %s", scheme, DeriveSHA256, DeriveTruncate)
+	}
+	return &Derivation{prefix: data, scheme: scheme}, nil
+}
+
+// DevEUI returns the DevEUI of the tag with uid (16 hex characters, most significant byte first)
+func (d *Derivation) DevEUI(uid string) (string, error) {
+	data, err := hex.DecodeString(uid)
+	if err != nil || len(data) != 8 {
+		return "", fmt.Errorf("cannot derive DevEUI from UID %q, expected 16 hex characters", uid)
+	}
+	n := 8 - len(d.prefix)
+	var tail []byte
+	if d.scheme == DeriveTruncate {
+		tail = data[8-n:]
+	} else {
+		sum := sha256.Sum256(data)
+		tail = sum[:n]
+	}
+	return strings.ToUpper(hex.EncodeToString(append(append([]byte{}, d.prefix...), tail...))), nil
+}
+
+// String describes the derivation for the exports, e.g. "uid/sha256/70B3D5"
+func (d *Derivation) String() string {
+	return fmt.Sprintf("uid/%s/%s", d.scheme, strings.ToUpper(hex.EncodeToString(d.prefix)))
+}
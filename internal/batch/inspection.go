@@ -0,0 +1,66 @@
+package batch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Inspection is the profile of an incoming inspection: the state tags received pre-programmed from a
+// supplier must be in, see verify-batch. Every tag must hold a valid CRC, the other checks are
+// optional.
+type Inspection struct {
+	// Name identifies the lot in the results
+	Name string `yaml:"name"`
+	// Count is the number of tags to inspect, the run ends when it is reached
+	Count int `yaml:"count"`
+	// Image is the configuration image the tags must hold, the BLE MAC and device specific blocks
+	// left 0xFFFFFFFF in the image (keys and EUIs of generateConfigBin images) are not compared
+	Image string `yaml:"image"`
+	// BeaconType is the beacon type (SKU) of the tags by hex code or name, e.g. "15"
+	BeaconType string `yaml:"beacon-type"`
+	// Firmware is the firmware version of the tags, e.g. "8.2"
+	Firmware string `yaml:"firmware"`
+	// Identity requires a LoRa identity: a DevEUI, JoinEUI and JoinKey which are not blank
+	Identity bool `yaml:"identity"`
+	// Results is the CSV file receiving the result of every tag, default <name>_inspection.csv
+	Results string `yaml:"results"`
+
+	// dir is the directory of the profile, relative paths are resolved against it
+	dir string
+}
+
+// LoadInspection reads and validates an inspection profile
+func LoadInspection(path string) (*Inspection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inspection profile: %v", err)
+	}
+	inspection := &Inspection{}
+	err = yaml.Unmarshal(data, inspection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	inspection.dir = filepath.Dir(path)
+	if inspection.Name == "" {
+		inspection.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if inspection.Results == "" {
+		inspection.Results = inspection.Name + "_inspection.csv"
+	}
+	if inspection.Count < 0 {
+		return nil, fmt.Errorf("inspection profile %s: count must not be negative", inspection.Name)
+	}
+	return inspection, nil
+}
+
+// Path resolves a path of the profile relative to the profile file
+func (i *Inspection) Path(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(i.dir, path)
+}
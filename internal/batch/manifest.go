@@ -0,0 +1,253 @@
+// Package batch describes production runs: a manifest listing what to program on a batch of tags,
+// the pool of EUIs handed out and the report of the run.
+package batch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest describes a production batch, see run-manifest
+type Manifest struct {
+	// Name identifies the batch in exports and the report
+	Name string `yaml:"name"`
+	// Quantity is the number of tags to program, the run ends when it is reached
+	Quantity int `yaml:"quantity"`
+	// Profile is the configuration image programmed into every tag together with its identity (see
+	// programTag), optional
+	Profile string `yaml:"profile"`
+	// Commands are run on every tag after the profile, in -cmd chain syntax ("setsku=15;uplinkEnable=true")
+	Commands string `yaml:"commands"`
+	// EUIs is the pool of LoRa identities handed out to the tags
+	EUIs EUIPool `yaml:"euis"`
+	// Exports receive one record per programmed tag
+	Exports []Export `yaml:"exports"`
+	// Hooks are run for every programmed tag, e.g. to register it with the network server
+	Hooks []Hook `yaml:"hooks"`
+	// Certificates are the birth certificates issued for the programmed tags, optional
+	Certificates *Certificates `yaml:"certificates"`
+	// Audit selects a random sample of the tags for a deep verification, optional
+	Audit *Audit `yaml:"audit"`
+	// Report is the file the final batch report is written to, default <name>_report.json
+	Report string `yaml:"report"`
+	// State is the file the progress of the run is saved to for resuming, default <name>_state.json
+	State string `yaml:"state"`
+	// Workers is the number of tags finished (CRC check, hooks, exports) in the background while the
+	// next tag is programmed, default 1 finishes every tag before the next one is presented
+	Workers int `yaml:"workers"`
+
+	// dir is the directory of the manifest, relative paths are resolved against it
+	dir string
+}
+
+// EUIPool hands out consecutive DevEUIs from Start, JoinEUI and JoinKey are the same for every tag.
+// Every attempt consumes a DevEUI, also the attempts of failed tags, so the range must hold
+// DevEUIMargin DevEUIs beyond the quantity for them.
+// JoinKey "random" gives every tag its own random key. NwkKey is refused: the firmware memory map
+// has no LoRaWAN 1.1 NwkKey block yet.
+// DevEUIFrom "uid" derives the DevEUI of every tag from its UID instead, DevEUIPrefix (e.g. an OUI)
+// followed by the bytes of DevEUIScheme, see Derivation. Records of derived DevEUIs name the
+// derivation in DevEUISource.
+type EUIPool struct {
+	DevEUIStart string `yaml:"deveui-start"`
+	DevEUIEnd   string `yaml:"deveui-end"`
+	// DevEUIMargin is the number of spare DevEUIs reserved for the attempts of failed tags
+	DevEUIMargin int    `yaml:"deveui-margin"`
+	JoinEUI      string `yaml:"joineui"`
+	JoinKey      string `yaml:"joinkey"`
+	NwkKey       string `yaml:"nwkkey"`
+
+	DevEUIFrom   string `yaml:"deveui-from"`
+	DevEUIPrefix string `yaml:"deveui-prefix"`
+	DevEUIScheme string `yaml:"deveui-scheme"`
+}
+
+// Export is a file receiving the records of the batch as "csv" or "json" (JSON lines). Keys must be
+// requested explicitly, they are masked otherwise. Template selects the columns of a CSV export, see
+// export.Template, the values are executed with the Record. Schema selects the key fields:
+// lorawan-1.0 (default, JoinKey) or lorawan-1.1 (NwkKey and AppKey, see LoRaWAN11Record).
+type Export struct {
+	Format   string `yaml:"format"`
+	Path     string `yaml:"path"`
+	Keys     bool   `yaml:"keys"`
+	Template string `yaml:"template"`
+	Schema   string `yaml:"schema"`
+
+	template *export.Template
+}
+
+// Hook stages, see Hook.Stage
+const (
+	// HookPreWrite hooks run before anything is written to the tag, a failing one leaves it untouched
+	HookPreWrite = "pre-write"
+	// HookPostWrite hooks run once the tag is written and its CRC checked, the default
+	HookPostWrite = "post-write"
+	// HookPostFinalize hooks run last, once the birth certificate of the tag is saved
+	HookPostFinalize = "post-finalize"
+)
+
+// Hook is a command run for every programmed tag. It gets the tag in the environment variables
+// HIDNFC_BATCH, HIDNFC_UID, HIDNFC_DEVEUI, HIDNFC_JOINEUI and HIDNFC_JOINKEY and the tag state as a HookInput JSON document on stdin. A hook with a URL
+// instead of a command posts the HookInput to the URL (a webhook), any status but 2xx fails it.
+// A failing hook marks the tag as failed unless Optional is set. A failing Queue hook is queued
+// for retry instead (in the station database, -db), e.g. the registration with an unreachable
+// network server.
+type Hook struct {
+	Name     string   `yaml:"name"`
+	Command  []string `yaml:"command"`
+	URL      string   `yaml:"url"`
+	Optional bool     `yaml:"optional"`
+	Queue    bool     `yaml:"queue"`
+	// Stage is when the hook runs: pre-write, post-write (default) or post-finalize
+	Stage string `yaml:"stage"`
+}
+
+// QueuesHooks reports whether a hook of the manifest is queued when it fails
+func (m *Manifest) QueuesHooks() bool {
+	for _, hook := range m.Hooks {
+		if hook.Queue {
+			return true
+		}
+	}
+	return false
+}
+
+// FindHook returns the hook called name, nil if there is none
+func (m *Manifest) FindHook(name string) *Hook {
+	for i := range m.Hooks {
+		if m.Hooks[i].Name == name {
+			return &m.Hooks[i]
+		}
+	}
+	return nil
+}
+
+// RunsAt reports whether the hook runs at stage
+func (h Hook) RunsAt(stage string) bool {
+	if h.Stage == "" {
+		return stage == HookPostWrite
+	}
+	return h.Stage == stage
+}
+
+// HookInput is the tag state a hook reads from stdin. Config is the hex content of the
+// configuration blocks and CRC the stored CRC as read from the tag, before writing for pre-write
+// hooks.
+type HookInput struct {
+	Stage string `json:"stage"`
+	Record
+	Config string `json:"config,omitempty"`
+	CRC    string `json:"crc,omitempty"`
+}
+
+// LoadManifest reads and validates a manifest
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	manifest := &Manifest{}
+	err = yaml.Unmarshal(data, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	manifest.dir = filepath.Dir(path)
+	if manifest.Name == "" {
+		manifest.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if manifest.Report == "" {
+		manifest.Report = manifest.Name + "_report.json"
+	}
+	if manifest.State == "" {
+		manifest.State = manifest.Name + "_state.json"
+	}
+	if manifest.Audit != nil && manifest.Audit.Results == "" {
+		manifest.Audit.Results = manifest.Name + "_audit.csv"
+	}
+	return manifest, manifest.validate()
+}
+
+func (m *Manifest) validate() error {
+	if m.Quantity <= 0 {
+		return fmt.Errorf("manifest %s: quantity must be positive", m.Name)
+	}
+	if m.Workers < 0 {
+		return fmt.Errorf("manifest %s: workers must not be negative", m.Name)
+	}
+	_, err := NewPool(m.EUIs, m.Quantity)
+	if err != nil {
+		return fmt.Errorf("manifest %s: %v", m.Name, err)
+	}
+	for i := range m.Exports {
+		exp := &m.Exports[i]
+		if exp.Format != "csv" && exp.Format != "json" {
+			return fmt.Errorf("manifest %s: unknown export format %q, expected csv or json", m.Name, exp.Format)
+		}
+		if exp.Path == "" {
+			return fmt.Errorf("manifest %s: export without path", m.Name)
+		}
+		err := CheckSchema(exp.Schema)
+		if err != nil {
+			return fmt.Errorf("manifest %s: export %s: %v", m.Name, exp.Path, err)
+		}
+		if exp.Template == "" {
+			continue
+		}
+		if exp.Format != "csv" {
+			return fmt.Errorf("manifest %s: template given for %s export %s, only csv exports use templates", m.Name, exp.Format, exp.Path)
+		}
+		tmpl, err := export.LoadTemplate(m.Path(exp.Template))
+		if err == nil && exp.Schema == SchemaLoRaWAN11 {
+			err = tmpl.Check(LoRaWAN11Record{})
+		} else if err == nil {
+			err = tmpl.Check(Record{})
+		}
+		if err != nil {
+			return fmt.Errorf("manifest %s: %v", m.Name, err)
+		}
+		exp.template = tmpl
+	}
+	for i, hook := range m.Hooks {
+		if len(hook.Command) == 0 && hook.URL == "" {
+			return fmt.Errorf("manifest %s: hook %q without command or url", m.Name, hook.Name)
+		}
+		if len(hook.Command) > 0 && hook.URL != "" {
+			return fmt.Errorf("manifest %s: hook %q has both a command and a url", m.Name, hook.Name)
+		}
+		if hook.Queue && hook.Stage == HookPreWrite {
+			return fmt.Errorf("manifest %s: pre-write hook %q can not be queued", m.Name, hook.Name)
+		}
+		if hook.Queue && (hook.Name == "" || m.FindHook(hook.Name) != &m.Hooks[i]) {
+			return fmt.Errorf("manifest %s: queued hooks need a unique name, they are retried by name", m.Name)
+		}
+		switch hook.Stage {
+		case "", HookPreWrite, HookPostWrite, HookPostFinalize:
+		default:
+			return fmt.Errorf("manifest %s: hook %q has invalid stage %q, expected %s, %s or %s", m.Name, hook.Name,
+				hook.Stage, HookPreWrite, HookPostWrite, HookPostFinalize)
+		}
+	}
+	if m.Certificates != nil && m.Certificates.Dir == "" {
+		return fmt.Errorf("manifest %s: certificates without dir", m.Name)
+	}
+	if m.Audit != nil {
+		err := m.Audit.validate()
+		if err != nil {
+			return fmt.Errorf("manifest %s: %v", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// Path resolves a path of the manifest relative to the manifest file
+func (m *Manifest) Path(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(m.dir, path)
+}
@@ -0,0 +1,188 @@
+package batch
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Identity is the LoRa identity given to one tag
+type Identity struct {
+	DevEUI  string
+	JoinEUI string
+	JoinKey string
+	// DevEUISource is the derivation of a DevEUI derived from the UID, see Derivation.String
+	DevEUISource string
+}
+
+// ErrPoolExhausted is returned by Next once every DevEUI of the pool was handed out
+var ErrPoolExhausted = errors.New("DevEUI pool exhausted")
+
+// Pool hands out the identities of an EUIPool
+type Pool struct {
+	spec      EUIPool
+	start     uint64
+	next      uint64
+	end       uint64
+	exhausted bool
+	// derive is set when the DevEUIs are derived from the UIDs instead of handed out
+	derive *Derivation
+}
+
+// NewPool creates the pool of spec, which must hold at least quantity DevEUIs and the margin of
+// spec unless they are derived from the UIDs
+func NewPool(spec EUIPool, quantity int) (*Pool, error) {
+	pool := &Pool{spec: spec}
+	if spec.DevEUIMargin < 0 {
+		return nil, fmt.Errorf("deveui-margin must not be negative")
+	}
+	var err error
+	switch spec.DevEUIFrom {
+	case "":
+		err = pool.setRange(quantity + spec.DevEUIMargin)
+	case "uid":
+		if spec.DevEUIStart != "" || spec.DevEUIEnd != "" {
+			return nil, fmt.Errorf("deveui-from uid can not be combined with deveui-start and deveui-end")
+		}
+		pool.derive, err = NewDerivation(spec.DevEUIPrefix, spec.DevEUIScheme)
+	default:
+		return nil, fmt.Errorf("invalid deveui-from %q, expected uid or none", spec.DevEUIFrom)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, err = parseEUI("joineui", spec.JoinEUI)
+	if err != nil {
+		return nil, err
+	}
+	if spec.JoinKey != "random" {
+		key, err := hex.DecodeString(spec.JoinKey)
+		if err != nil || len(key) != 16 {
+			return nil, fmt.Errorf("invalid joinkey, expected 32 hex characters or random")
+		}
+	}
+	if spec.NwkKey != "" {
+		return nil, fmt.Errorf("nwkkey is not supported, the firmware memory map does not define a NwkKey block yet")
+	}
+	return pool, nil
+}
+
+// setRange sets the DevEUI range of the spec, which must hold at least size DevEUIs
+func (p *Pool) setRange(size int) error {
+	start, err := parseEUI("deveui-start", p.spec.DevEUIStart)
+	if err != nil {
+		return err
+	}
+	end := ^uint64(0)
+	if p.spec.DevEUIEnd != "" {
+		end, err = parseEUI("deveui-end", p.spec.DevEUIEnd)
+		if err != nil {
+			return err
+		}
+	}
+	if end < start || end-start < uint64(size-1) {
+		return fmt.Errorf("DevEUI pool %016X-%016X is smaller than the quantity and deveui-margin (%d DevEUIs)", start, end, size)
+	}
+	p.start, p.next, p.end = start, start, end
+	return nil
+}
+
+func parseEUI(name string, eui string) (uint64, error) {
+	value, err := strconv.ParseUint(strings.ReplaceAll(eui, ":", ""), 16, 64)
+	if err != nil || len(strings.ReplaceAll(eui, ":", "")) != 16 {
+		return 0, fmt.Errorf("invalid %s %q, expected 16 hex characters", name, eui)
+	}
+	return value, nil
+}
+
+// Derives reports whether the DevEUIs are derived from the UIDs, see Derivation
+func (p *Pool) Derives() bool {
+	return p.derive != nil
+}
+
+// Next returns the identity of the tag with uid, consuming its DevEUI unless it is derived from uid
+func (p *Pool) Next(uid string) (*Identity, error) {
+	if p.exhausted {
+		return nil, fmt.Errorf("%w: all %d DevEUIs %016X-%016X were handed out, failed tags consume one each; "+
+			"raise deveui-end (or deveui-margin for new batches) in the manifest and run it again to resume",
+			ErrPoolExhausted, p.end-p.start+1, p.start, p.end)
+	}
+	id := &Identity{
+		DevEUI:  fmt.Sprintf("%016X", p.next),
+		JoinEUI: strings.ToUpper(strings.ReplaceAll(p.spec.JoinEUI, ":", "")),
+		JoinKey: strings.ToUpper(p.spec.JoinKey),
+	}
+	if p.derive != nil {
+		devEUI, err := p.derive.DevEUI(uid)
+		if err != nil {
+			return nil, err
+		}
+		id.DevEUI, id.DevEUISource = devEUI, p.derive.String()
+	}
+	if p.spec.JoinKey == "random" {
+		key, err := RandomKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate JoinKey: %v", err)
+		}
+		id.JoinKey = key
+	}
+	if p.derive != nil {
+		return id, nil
+	}
+	if p.next == p.end {
+		p.exhausted = true
+	} else {
+		p.next++
+	}
+	return id, nil
+}
+
+// RandomKey returns a random 128 bit key as hex
+func RandomKey() (string, error) {
+	key := make([]byte, 16)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(key)), nil
+}
+
+// NextDevEUI returns the DevEUI the next call of Next hands out, the DevEUI after the end of an
+// exhausted pool, empty if there is none or the pool derives the DevEUIs
+func (p *Pool) NextDevEUI() string {
+	if p.derive != nil || p.exhausted && p.end == ^uint64(0) {
+		return ""
+	}
+	if p.exhausted {
+		return fmt.Sprintf("%016X", p.end+1)
+	}
+	return fmt.Sprintf("%016X", p.next)
+}
+
+// Resume continues handing out DevEUIs at next as returned by NextDevEUI of an earlier run
+func (p *Pool) Resume(next string) error {
+	if p.derive != nil {
+		return nil
+	}
+	if next == "" {
+		p.exhausted = true
+		return nil
+	}
+	value, err := parseEUI("next DevEUI", next)
+	if err != nil {
+		return err
+	}
+	if p.end != ^uint64(0) && value == p.end+1 {
+		// exhausted, until deveui-end is raised
+		p.next, p.exhausted = p.end, true
+		return nil
+	}
+	if value < p.next || value > p.end {
+		return fmt.Errorf("next DevEUI %s is outside the pool", next)
+	}
+	p.next = value
+	return nil
+}
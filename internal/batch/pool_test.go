@@ -0,0 +1,78 @@
+package batch
+
+import (
+	"errors"
+	"testing"
+)
+
+func testSpec(end string, margin int) EUIPool {
+	return EUIPool{
+		DevEUIStart:  "70B3D57ED0000000",
+		DevEUIEnd:    end,
+		DevEUIMargin: margin,
+		JoinEUI:      "70B3D57ED0000001",
+		JoinKey:      "random",
+	}
+}
+
+func TestNewPoolMargin(t *testing.T) {
+	tests := []struct {
+		end    string
+		margin int
+		ok     bool
+	}{
+		{"70B3D57ED0000003", 0, true},
+		{"70B3D57ED0000003", 1, false},
+		{"70B3D57ED0000004", 1, true},
+		{"70B3D57ED0000004", -1, false},
+		{"", 1000, true},
+	}
+	for _, test := range tests {
+		_, err := NewPool(testSpec(test.end, test.margin), 4)
+		if (err == nil) != test.ok {
+			t.Errorf("NewPool(end %s, margin %d) = %v, want ok %v", test.end, test.margin, err, test.ok)
+		}
+	}
+}
+
+func TestPoolExhausted(t *testing.T) {
+	pool, err := NewPool(testSpec("70B3D57ED0000001", 0), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err = pool.Next("E001")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = pool.Next("E001")
+	if !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("Next() of an exhausted pool = %v, want ErrPoolExhausted", err)
+	}
+	next := pool.NextDevEUI()
+	if next != "70B3D57ED0000002" {
+		t.Fatalf("NextDevEUI() = %q, want the DevEUI after the end", next)
+	}
+
+	// the same range stays exhausted after a resume
+	again, _ := NewPool(testSpec("70B3D57ED0000001", 0), 2)
+	err = again.Resume(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = again.Next("E002"); !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("Next() after resuming an exhausted pool = %v, want ErrPoolExhausted", err)
+	}
+
+	// a raised deveui-end goes on with the DevEUI after the old end
+	extended, _ := NewPool(testSpec("70B3D57ED0000009", 0), 2)
+	err = extended.Resume(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := extended.Next("E002")
+	if err != nil || id.DevEUI != "70B3D57ED0000002" {
+		t.Errorf("Next() after raising deveui-end = %v, %v, want 70B3D57ED0000002", id, err)
+	}
+}
@@ -0,0 +1,122 @@
+package batch
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
+	exportfile "github.com/jenish-rudani/HID_NFC_READER/internal/export"
+)
+
+// Record is the result of programming one tag
+type Record struct {
+	Time    time.Time `json:"time"`
+	Batch   string    `json:"batch"`
+	Station string    `json:"station,omitempty"`
+	UID     string    `json:"uid"`
+	DevEUI  string    `json:"devEui,omitempty"`
+	JoinEUI string    `json:"joinEui,omitempty"`
+	JoinKey string    `json:"joinKey,omitempty"`
+	// DevEUISource is the derivation of a DevEUI derived from the UID (see Derivation), empty for
+	// DevEUIs handed out by the pool
+	DevEUISource string `json:"devEuiSource,omitempty"`
+	// KeyFingerprint identifies the JoinKey in exports without keys
+	KeyFingerprint string `json:"joinKeyFingerprint,omitempty"`
+	// NwkKey is the LoRaWAN 1.1 NwkKey of records imported with import-legacy, tags have a single key
+	NwkKey            string `json:"nwkKey,omitempty"`
+	NwkKeyFingerprint string `json:"nwkKeyFingerprint,omitempty"`
+	OK                bool   `json:"ok"`
+	Error             string `json:"error,omitempty"`
+	// Marginal is set when writing the tag needed retries, see Retries
+	Marginal bool `json:"marginal,omitempty"`
+	// Retries are the blocks which needed more than one write attempt, keyed by block
+	Retries map[int]int `json:"retries,omitempty"`
+	// Certificate is the hex digest of the birth certificate of the tag, if one was issued
+	Certificate string `json:"certificate,omitempty"`
+	// AssetID is the customer asset ID of the tag, if one was written
+	AssetID string `json:"assetId,omitempty"`
+}
+
+// AppendExport appends the record to the export, masking the JoinKey with mask unless the export asks for keys
+func AppendExport(export Export, path string, record Record, mask func(string) string) error {
+	if !export.Keys {
+		record.JoinKey = mask(record.JoinKey)
+		record.NwkKey = mask(record.NwkKey)
+	}
+
+	record.Time = record.Time.In(exportfile.Location())
+	var value any = record
+	if export.Schema == SchemaLoRaWAN11 {
+		value = record.LoRaWAN11()
+	}
+	if export.Format == "json" {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		return exportfile.AppendLine(path, data)
+	}
+	if export.template != nil {
+		row, err := export.template.Row(value)
+		if err != nil {
+			return err
+		}
+		return exportfile.AppendCSV(path, export.template.Header(), row)
+	}
+	if export.Schema == SchemaLoRaWAN11 {
+		v11 := record.LoRaWAN11()
+		return exportfile.AppendCSV(path, v11.header(), v11.row(exportfile.FormatTime(record.Time)))
+	}
+	header := []string{"Timestamp", "Batch", "Station", "UID", "DevEUI", "JoinEUI", "JoinKey", "JoinKey Fingerprint", "OK", "Error", "Marginal", "Retries"}
+	return exportfile.AppendCSV(path, header, []string{
+		exportfile.FormatTime(record.Time), record.Batch, record.Station, record.UID, record.DevEUI, record.JoinEUI,
+		record.JoinKey, record.KeyFingerprint, fmt.Sprint(record.OK), record.Error,
+		fmt.Sprint(record.Marginal), events.FormatRetries(record.Retries),
+	})
+}
+
+// Report is the final report of a production run
+type Report struct {
+	Batch     string         `json:"batch"`
+	Tool      buildinfo.Info `json:"tool"`
+	Started   time.Time      `json:"started"`
+	Finished  time.Time      `json:"finished"`
+	Quantity  int            `json:"quantity"`
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	// Records lists every attempt, keys are never included
+	Records []Record `json:"records"`
+}
+
+// Add counts a record, its keys are dropped
+func (r *Report) Add(record Record) {
+	record.JoinKey = ""
+	record.NwkKey = ""
+	if record.OK {
+		r.Succeeded++
+	} else {
+		r.Failed++
+	}
+	r.Records = append(r.Records, record)
+}
+
+// Write writes the report as JSON to path
+func (r *Report) Write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return exportfile.WriteFile(path, append(data, '\n'))
+}
+
+// Summary returns the report as text for the console
+func (r *Report) Summary() string {
+	yield := 0.0
+	if attempts := r.Succeeded + r.Failed; attempts > 0 {
+		yield = 100 * float64(r.Succeeded) / float64(attempts)
+	}
+	return fmt.Sprintf("Batch %s: %d/%d tags programmed, %d failed attempts (yield %.1f%%) in %s",
+		r.Batch, r.Succeeded, r.Quantity, r.Failed, yield, r.Finished.Sub(r.Started).Round(time.Second))
+}
@@ -0,0 +1,86 @@
+package batch
+
+import (
+	"fmt"
+	"time"
+)
+
+// Key schemas of the exports: LoRaWAN 1.0 devices have a single root key (the JoinKey, AppKey in
+// LoRaWAN 1.0 terms), LoRaWAN 1.1 devices a NwkKey and an AppKey
+const (
+	SchemaLoRaWAN10 = "lorawan-1.0"
+	SchemaLoRaWAN11 = "lorawan-1.1"
+)
+
+// CheckSchema validates a key schema, empty selects SchemaLoRaWAN10
+func CheckSchema(schema string) error {
+	switch schema {
+	case "", SchemaLoRaWAN10, SchemaLoRaWAN11:
+		return nil
+	default:
+		return fmt.Errorf("unknown key schema %q, expected %s or %s", schema, SchemaLoRaWAN10, SchemaLoRaWAN11)
+	}
+}
+
+// LoRaWAN11Record is a Record in the lorawan-1.1 schema, for network servers importing LoRaWAN 1.1
+// devices. Tags whose firmware uses a single root key have the JoinKey in NwkKey and AppKey.
+type LoRaWAN11Record struct {
+	Schema  string    `json:"schema"`
+	Time    time.Time `json:"time"`
+	Batch   string    `json:"batch"`
+	Station string    `json:"station,omitempty"`
+	UID     string    `json:"uid"`
+	DevEUI  string    `json:"devEui,omitempty"`
+	JoinEUI string    `json:"joinEui,omitempty"`
+	NwkKey  string    `json:"nwkKey,omitempty"`
+	AppKey  string    `json:"appKey,omitempty"`
+	// DevEUISource is the derivation of a DevEUI derived from the UID, see Record
+	DevEUISource string `json:"devEuiSource,omitempty"`
+	// NwkKeyFingerprint and AppKeyFingerprint identify the keys in exports without keys
+	NwkKeyFingerprint string `json:"nwkKeyFingerprint,omitempty"`
+	AppKeyFingerprint string `json:"appKeyFingerprint,omitempty"`
+	// Certificate is the digest of the birth certificate binding the DevEUI to the tag, if issued
+	Certificate string `json:"certificate,omitempty"`
+	OK          bool   `json:"ok"`
+	Error       string `json:"error,omitempty"`
+	Marginal    bool   `json:"marginal,omitempty"`
+	AssetID     string `json:"assetId,omitempty"`
+}
+
+// LoRaWAN11 returns the record in the lorawan-1.1 schema
+func (r Record) LoRaWAN11() LoRaWAN11Record {
+	nwkKey, nwkKeyFingerprint := r.JoinKey, r.KeyFingerprint
+	if r.NwkKeyFingerprint != "" {
+		nwkKey, nwkKeyFingerprint = r.NwkKey, r.NwkKeyFingerprint
+	}
+	return LoRaWAN11Record{
+		Schema:            SchemaLoRaWAN11,
+		Time:              r.Time,
+		Batch:             r.Batch,
+		Station:           r.Station,
+		UID:               r.UID,
+		DevEUI:            r.DevEUI,
+		DevEUISource:      r.DevEUISource,
+		JoinEUI:           r.JoinEUI,
+		NwkKey:            nwkKey,
+		AppKey:            r.JoinKey,
+		NwkKeyFingerprint: nwkKeyFingerprint,
+		AppKeyFingerprint: r.KeyFingerprint,
+		Certificate:       r.Certificate,
+		OK:                r.OK,
+		Error:             r.Error,
+		Marginal:          r.Marginal,
+		AssetID:           r.AssetID,
+	}
+}
+
+// header and row of a lorawan-1.1 CSV export, the columns shared with Record keep their names
+func (r LoRaWAN11Record) header() []string {
+	return []string{"Timestamp", "Schema", "Batch", "Station", "UID", "DevEUI", "JoinEUI", "NwkKey", "AppKey",
+		"NwkKey Fingerprint", "AppKey Fingerprint", "Certificate", "OK", "Error", "Marginal"}
+}
+
+func (r LoRaWAN11Record) row(timestamp string) []string {
+	return []string{timestamp, r.Schema, r.Batch, r.Station, r.UID, r.DevEUI, r.JoinEUI, r.NwkKey, r.AppKey,
+		r.NwkKeyFingerprint, r.AppKeyFingerprint, r.Certificate, fmt.Sprint(r.OK), r.Error, fmt.Sprint(r.Marginal)}
+}
@@ -0,0 +1,71 @@
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// State is the progress of a production run, saved after every step so an interrupted run can be
+// resumed without handing out a DevEUI twice
+type State struct {
+	Batch string `json:"batch"`
+	// NextDevEUI is the first DevEUI not handed out yet, past the end of the pool once it is
+	// exhausted (empty if the pool ends at FFFFFFFFFFFFFFFF) so a run can go on after deveui-end
+	// was raised
+	NextDevEUI string `json:"nextDevEui"`
+	// Pending is the DevEUI handed out to the tag being programmed, set before the tag is written
+	Pending string `json:"pending,omitempty"`
+	// InFlight lists the DevEUIs of the written tags still being finished by a worker
+	InFlight []string `json:"inFlight,omitempty"`
+	// Done lists the UIDs of the tags programmed successfully
+	Done     []string `json:"done"`
+	Report   *Report  `json:"report"`
+	Complete bool     `json:"complete"`
+}
+
+// LoadState reads the state saved at path, nil if there is none
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run state: %v", err)
+	}
+	state := &State{}
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse run state %s: %v", path, err)
+	}
+	return state, nil
+}
+
+// Save writes the state to path atomically, a crash leaves either the old or the new state
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save run state: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save run state: %v", err)
+	}
+	return nil
+}
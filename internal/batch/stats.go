@@ -0,0 +1,266 @@
+package batch
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+)
+
+// ReadRecords reads the records of run-manifest exports (CSV or JSON lines) and batch reports
+func ReadRecords(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return readCSVRecords(file)
+	}
+
+	var records []Record
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for {
+		// a batch report holds its records, an export line is a record
+		var value struct {
+			Record
+			Records []Record `json:"records"`
+		}
+		err = decoder.Decode(&value)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if value.Records != nil {
+			records = append(records, value.Records...)
+		} else {
+			records = append(records, value.Record)
+		}
+	}
+}
+
+func readCSVRecords(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[name] = i
+	}
+	field := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return row[i]
+	}
+	var records []Record
+	for line, row := range rows[1:] {
+		t, err := export.ParseTime(field(row, "Timestamp"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid timestamp: %v", line+2, err)
+		}
+		records = append(records, Record{
+			Time:     t,
+			Batch:    field(row, "Batch"),
+			Station:  field(row, "Station"),
+			UID:      field(row, "UID"),
+			DevEUI:   field(row, "DevEUI"),
+			OK:       field(row, "OK") == "true",
+			Error:    field(row, "Error"),
+			Marginal: field(row, "Marginal") == "true",
+		})
+	}
+	return records, nil
+}
+
+// StationStats are the statistics of one station
+type StationStats struct {
+	Station     string  `json:"station"`
+	Attempts    int     `json:"attempts"`
+	Succeeded   int     `json:"succeeded"`
+	TagsPerHour float64 `json:"tagsPerHour"`
+}
+
+// Stats are the yield statistics of provisioning records
+type Stats struct {
+	Since     *time.Time     `json:"since,omitempty"`
+	First     time.Time      `json:"first"`
+	Last      time.Time      `json:"last"`
+	Attempts  int            `json:"attempts"`
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	Marginal  int            `json:"marginal"`
+	Yield     float64        `json:"yield"`
+	Failures  map[string]int `json:"failuresByClass"`
+	Stations  []StationStats `json:"stations"`
+}
+
+// ErrorClass groups errors by their outermost message, e.g. "failed to write DevEUI: ..." is
+// "failed to write DevEUI"
+func ErrorClass(err string) string {
+	class, _, _ := strings.Cut(err, ":")
+	class = strings.TrimSpace(class)
+	if class == "" {
+		return "unknown"
+	}
+	return class
+}
+
+// ComputeStats aggregates the records since the given time (zero for all records)
+func ComputeStats(records []Record, since time.Time) *Stats {
+	stats := &Stats{Failures: make(map[string]int)}
+	if !since.IsZero() {
+		stats.Since = &since
+	}
+	type span struct {
+		stats       StationStats
+		first, last time.Time
+	}
+	stations := make(map[string]*span)
+	for _, record := range records {
+		if record.Time.Before(since) {
+			continue
+		}
+		if stats.Attempts == 0 || record.Time.Before(stats.First) {
+			stats.First = record.Time
+		}
+		if record.Time.After(stats.Last) {
+			stats.Last = record.Time
+		}
+		stats.Attempts++
+
+		name := record.Station
+		if name == "" {
+			name = "unknown"
+		}
+		station, ok := stations[name]
+		if !ok {
+			station = &span{stats: StationStats{Station: name}, first: record.Time, last: record.Time}
+			stations[name] = station
+		}
+		station.stats.Attempts++
+		if record.Time.Before(station.first) {
+			station.first = record.Time
+		}
+		if record.Time.After(station.last) {
+			station.last = record.Time
+		}
+
+		if record.Marginal {
+			stats.Marginal++
+		}
+		if record.OK {
+			stats.Succeeded++
+			station.stats.Succeeded++
+		} else {
+			stats.Failed++
+			stats.Failures[ErrorClass(record.Error)]++
+		}
+	}
+	if stats.Attempts > 0 {
+		stats.Yield = 100 * float64(stats.Succeeded) / float64(stats.Attempts)
+	}
+	for _, station := range stations {
+		// a station which worked less than a minute is counted as one minute
+		hours := station.last.Sub(station.first).Hours()
+		if hours < 1.0/60 {
+			hours = 1.0 / 60
+		}
+		station.stats.TagsPerHour = float64(station.stats.Succeeded) / hours
+		stats.Stations = append(stats.Stations, station.stats)
+	}
+	sort.Slice(stats.Stations, func(i, j int) bool {
+		return stats.Stations[i].Station < stats.Stations[j].Station
+	})
+	return stats
+}
+
+// failureClasses returns the failure classes, most frequent first
+func (s *Stats) failureClasses() []string {
+	classes := make([]string, 0, len(s.Failures))
+	for class := range s.Failures {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if s.Failures[classes[i]] != s.Failures[classes[j]] {
+			return s.Failures[classes[i]] > s.Failures[classes[j]]
+		}
+		return classes[i] < classes[j]
+	})
+	return classes
+}
+
+// WriteText writes the statistics as text
+func (s *Stats) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Records:   %d (%s - %s)\n", s.Attempts, s.First.Format(time.RFC3339), s.Last.Format(time.RFC3339))
+	fmt.Fprintf(w, "Succeeded: %d\n", s.Succeeded)
+	fmt.Fprintf(w, "Failed:    %d\n", s.Failed)
+	fmt.Fprintf(w, "Marginal:  %d (needed write retries)\n", s.Marginal)
+	fmt.Fprintf(w, "Yield:     %.1f%%\n", s.Yield)
+	if len(s.Failures) > 0 {
+		fmt.Fprintln(w, "\nFailures by class:")
+		for _, class := range s.failureClasses() {
+			fmt.Fprintf(w, "  %5d  %s\n", s.Failures[class], class)
+		}
+	}
+	fmt.Fprintln(w, "\nStations:")
+	for _, station := range s.Stations {
+		fmt.Fprintf(w, "  %-20s %5d attempts %5d ok %8.1f tags/hour\n", station.Station, station.Attempts, station.Succeeded, station.TagsPerHour)
+	}
+}
+
+// WriteJSON writes the statistics as JSON
+func (s *Stats) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+var statsTemplate = template.Must(template.New("stats").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Provisioning yield</title>
+<style>body{font-family:sans-serif}table{border-collapse:collapse}td,th{border:1px solid #ccc;padding:4px 8px;text-align:left}</style>
+</head><body>
+<h1>Provisioning yield</h1>
+<p>{{.Stats.Attempts}} records from {{.Stats.First.Format "2006-01-02 15:04"}} to {{.Stats.Last.Format "2006-01-02 15:04"}}</p>
+<table>
+<tr><th>Succeeded</th><td>{{.Stats.Succeeded}}</td></tr>
+<tr><th>Failed</th><td>{{.Stats.Failed}}</td></tr>
+<tr><th>Marginal</th><td>{{.Stats.Marginal}}</td></tr>
+<tr><th>Yield</th><td>{{printf "%.1f" .Stats.Yield}}%</td></tr>
+</table>
+{{if .Classes}}<h2>Failures by class</h2>
+<table><tr><th>Class</th><th>Count</th></tr>
+{{range .Classes}}<tr><td>{{.}}</td><td>{{index $.Stats.Failures .}}</td></tr>
+{{end}}</table>{{end}}
+<h2>Stations</h2>
+<table><tr><th>Station</th><th>Attempts</th><th>Succeeded</th><th>Tags/hour</th></tr>
+{{range .Stats.Stations}}<tr><td>{{.Station}}</td><td>{{.Attempts}}</td><td>{{.Succeeded}}</td><td>{{printf "%.1f" .TagsPerHour}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+// WriteHTML writes the statistics as an HTML page
+func (s *Stats) WriteHTML(w io.Writer) error {
+	return statsTemplate.Execute(w, struct {
+		Stats   *Stats
+		Classes []string
+	}{s, s.failureClasses()})
+}
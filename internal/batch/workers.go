@@ -0,0 +1,54 @@
+package batch
+
+import "sync"
+
+// WorkerPool runs the CPU and network bound part of programming a tag (CRC check, hooks, exports)
+// in the background, so the RF exchanges of the next tag overlap with it. A pool of at most one
+// worker runs every job inline, in the order submitted.
+type WorkerPool struct {
+	jobs chan func()
+	wg   sync.WaitGroup
+}
+
+// NewWorkerPool starts workers goroutines, see Manifest.Workers
+func NewWorkerPool(workers int) *WorkerPool {
+	p := &WorkerPool{}
+	if workers <= 1 {
+		return p
+	}
+	jobs := make(chan func())
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				job()
+				p.wg.Done()
+			}
+		}()
+	}
+	p.jobs = jobs
+	return p
+}
+
+// Submit runs job on a free worker, it blocks while every worker is busy
+func (p *WorkerPool) Submit(job func()) {
+	if p.jobs == nil {
+		job()
+		return
+	}
+	p.wg.Add(1)
+	p.jobs <- job
+}
+
+// Wait blocks until every submitted job has finished
+func (p *WorkerPool) Wait() {
+	p.wg.Wait()
+}
+
+// Close waits for the submitted jobs and stops the workers
+func (p *WorkerPool) Close() {
+	p.Wait()
+	if p.jobs != nil {
+		close(p.jobs)
+		p.jobs = nil
+	}
+}
@@ -0,0 +1,38 @@
+package buildinfo
+
+// Info identifies the build of the tool, it is recorded with every provisioning result
+type Info struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildTime string `json:"buildTime"`
+}
+
+var info = Info{Version: "0.0.0", GitCommit: "unknown", BuildTime: "unknown"}
+
+// Set records the build information, called by main with the values set through -ldflags
+func Set(version, gitCommit, buildTime string) {
+	info = Info{Version: version, GitCommit: gitCommit, BuildTime: buildTime}
+}
+
+// Version returns the build information of the running tool
+func Version() Info {
+	return info
+}
+
+// String returns the version and the short git commit, e.g. "v1.4.0 (3f2a9c1)"
+func (i Info) String() string {
+	commit := i.GitCommit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	return i.Version + " (" + commit + ")"
+}
+
+// Fields returns the build information as log fields
+func (i Info) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"tool_version":    i.Version,
+		"tool_git_commit": i.GitCommit,
+		"tool_build_time": i.BuildTime,
+	}
+}
@@ -0,0 +1,316 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFileName is the name of the configuration file looked up in the user's home directory
+const DefaultFileName = ".hidnfc.yaml"
+
+// EnvPrefix prefixes every environment variable overriding a configuration value, e.g. HIDNFC_READER
+const EnvPrefix = "HIDNFC_"
+
+// Config holds the defaults for the global command line options.
+// The yaml keys match the flag names so a value can be applied to its flag directly.
+type Config struct {
+	Reader         string `yaml:"reader"`
+	ReaderFilter   string `yaml:"reader-filter"`
+	Transport      string `yaml:"transport"`
+	Station        string `yaml:"station"`
+	Operator       string `yaml:"operator"`
+	Export         string `yaml:"export"`
+	ExportTemplate string `yaml:"export-template"`
+	KeySchema      string `yaml:"key-schema"`
+	Duplicates     string `yaml:"duplicates"`
+	Actions        string `yaml:"actions"`
+	EventsLog      string `yaml:"events-log"`
+	NameCollision  string `yaml:"name-collision"`
+	NamePad        string `yaml:"name-pad"`
+	DB             string `yaml:"db"`
+	WearWarn       string `yaml:"wear-warn"`
+	Summary        string `yaml:"summary"`
+	Output         string `yaml:"output"`
+	ReadChunk      string `yaml:"read-chunk"`
+	BlockSize      string `yaml:"block-size"`
+	BlockCache     string `yaml:"block-cache"`
+	Rewrite        string `yaml:"rewrite-unchanged"`
+	MultipleTags   string `yaml:"allow-multiple-tags"`
+	AFI            string `yaml:"afi"`
+	BeaconTypes    string `yaml:"beacon-types"`
+	AnySKU         string `yaml:"allow-any-sku"`
+	TimeZone       string `yaml:"timezone"`
+	TimeFormat     string `yaml:"time-format"`
+	LogLevel       string `yaml:"log-level"`
+	LogFormat      string `yaml:"log-format"`
+
+	LogFile        string `yaml:"log-file"`
+	LogMaxSize     string `yaml:"log-max-size"`
+	LogRotateDaily string `yaml:"log-rotate-daily"`
+	LogMaxBackups  string `yaml:"log-max-backups"`
+
+	APDUTimeout      string `yaml:"apdu-timeout"`
+	OperationTimeout string `yaml:"op-timeout"`
+
+	ReaderMinFirmware   string `yaml:"reader-min-firmware"`
+	ReaderFirmwareCheck string `yaml:"reader-firmware-check"`
+
+	CMMode        string `yaml:"cm-mode"`
+	CMKeystore    string `yaml:"cm-keystore"`
+	CMKeystoreKey string `yaml:"cm-keystore-key"`
+
+	ExportChecksums string `yaml:"export-checksums"`
+
+	ServeTokens      string `yaml:"serve-tokens"`
+	ServeTLS         string `yaml:"serve-tls"`
+	ServeIdleTimeout string `yaml:"serve-idle-timeout"`
+	TransportToken   string `yaml:"transport-token"`
+	TransportTLS     string `yaml:"transport-tls"`
+
+	NetworkServer      string `yaml:"network-server"`
+	NetworkServerToken string `yaml:"network-server-token"`
+
+	DecommissionDir    string `yaml:"decommission-dir"`
+	DecommissionKey    string `yaml:"decommission-key"`
+	DecommissionDelete string `yaml:"decommission-delete"`
+	RekeyLog           string `yaml:"rekey-log"`
+	RekeyRecords       string `yaml:"rekey-records"`
+
+	OperatorPinHash     string `yaml:"operator-pin-hash"`
+	ApprovalKey         string `yaml:"approval-key"`
+	AllowUnauthorized   string `yaml:"allow-unauthorized"`
+	AuditLog            string `yaml:"audit-log"`
+	RedactKeys          string `yaml:"redact-keys"`
+	ConfigKey           string `yaml:"config-key"`
+	ConfigKeyFile       string `yaml:"config-key-file"`
+	ImageVerifyKey      string `yaml:"image-verify-key"`
+	AllowUnsignedImages string `yaml:"allow-unsigned-images"`
+	FIPS                string `yaml:"fips"`
+}
+
+// Secured are the options protecting destructive operations and key material. Once set by the
+// configuration file, neither an environment variable nor a flag may change them, e.g. blank the
+// operator PIN hash to run erase without it.
+var Secured = map[string]bool{
+	"operator-pin-hash":     true,
+	"approval-key":          true,
+	"allow-unauthorized":    true,
+	"audit-log":             true,
+	"redact-keys":           true,
+	"image-verify-key":      true,
+	"allow-unsigned-images": true,
+	"cm-mode":               true,
+	"cm-keystore-key":       true,
+	"serve-tokens":          true,
+	"fips":                  true,
+}
+
+// SystemPath is the station-wide configuration file. Its Secured options, and those of the
+// default file, hold whichever file -config selects.
+var SystemPath = "/etc/hidnfc.yaml"
+
+// DefaultPath returns ~/.hidnfc.yaml, or an empty string if the home directory is unknown
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, DefaultFileName)
+}
+
+// Load reads the configuration file at path and applies the HIDNFC_* environment variables on top of it.
+// A missing file is not an error unless required is set, i.e. the path was given explicitly.
+// The Secured options of SystemPath and DefaultPath are pinned: the file at path and the environment
+// variables may not change them, and they are kept even if the file at path omits them, so another
+// -config cannot drop e.g. the operator PIN hash.
+func Load(path string, required bool) (*Config, error) {
+	pinned, err := loadPinned()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := readFile(path, required)
+	if err != nil {
+		return nil, err
+	}
+	fields := cfg.fields()
+	for name, pin := range pinned {
+		if *fields[name] != "" && !SameValue(*fields[name], pin.value) {
+			return nil, fmt.Errorf("%s may not change %s set by %s", path, name, pin.path)
+		}
+		*fields[name] = pin.value
+	}
+
+	for name, value := range fields {
+		if env, ok := os.LookupEnv(EnvName(name)); ok {
+			if Secured[name] && *value != "" && !SameValue(env, *value) {
+				source := path
+				if pin, ok := pinned[name]; ok {
+					source = pin.path
+				}
+				return nil, fmt.Errorf("%s may not change %s set by %s", EnvName(name), name, source)
+			}
+			*value = env
+		}
+	}
+	return cfg, nil
+}
+
+type pinnedValue struct {
+	value string
+	path  string
+}
+
+// loadPinned returns the Secured options set by SystemPath and DefaultPath, the default file may
+// add to the system file but not change it
+func loadPinned() (map[string]pinnedValue, error) {
+	pinned := make(map[string]pinnedValue)
+	for _, path := range []string{SystemPath, DefaultPath()} {
+		cfg, err := readFile(path, false)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range cfg.Values() {
+			if !Secured[name] {
+				continue
+			}
+			if pin, ok := pinned[name]; ok {
+				if !SameValue(value, pin.value) {
+					return nil, fmt.Errorf("%s may not change %s set by %s", path, name, pin.path)
+				}
+				continue
+			}
+			pinned[name] = pinnedValue{value: value, path: path}
+		}
+	}
+	return pinned, nil
+}
+
+func readFile(path string, required bool) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		err = yaml.Unmarshal(data, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !required:
+	default:
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// SameValue reports whether two option values are equal, booleans in any spelling flag accepts
+func SameValue(a string, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	boolA, errA := strconv.ParseBool(a)
+	boolB, errB := strconv.ParseBool(b)
+	if errA == nil && errB == nil {
+		return boolA == boolB
+	}
+	return a == b
+}
+
+// EnvName returns the environment variable overriding the option name, e.g. log-level -> HIDNFC_LOG_LEVEL
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Values returns the configured values keyed by option name, options which are not set are omitted
+func (c *Config) Values() map[string]string {
+	values := make(map[string]string)
+	for name, value := range c.fields() {
+		if *value != "" {
+			values[name] = *value
+		}
+	}
+	return values
+}
+
+func (c *Config) fields() map[string]*string {
+	return map[string]*string{
+		"reader":              &c.Reader,
+		"reader-filter":       &c.ReaderFilter,
+		"transport":           &c.Transport,
+		"station":             &c.Station,
+		"operator":            &c.Operator,
+		"export":              &c.Export,
+		"export-template":     &c.ExportTemplate,
+		"key-schema":          &c.KeySchema,
+		"duplicates":          &c.Duplicates,
+		"actions":             &c.Actions,
+		"events-log":          &c.EventsLog,
+		"name-collision":      &c.NameCollision,
+		"name-pad":            &c.NamePad,
+		"db":                  &c.DB,
+		"wear-warn":           &c.WearWarn,
+		"summary":             &c.Summary,
+		"output":              &c.Output,
+		"read-chunk":          &c.ReadChunk,
+		"block-size":          &c.BlockSize,
+		"block-cache":         &c.BlockCache,
+		"rewrite-unchanged":   &c.Rewrite,
+		"allow-multiple-tags": &c.MultipleTags,
+		"afi":                 &c.AFI,
+		"beacon-types":        &c.BeaconTypes,
+		"allow-any-sku":       &c.AnySKU,
+		"timezone":            &c.TimeZone,
+		"time-format":         &c.TimeFormat,
+		"log-level":           &c.LogLevel,
+		"log-format":          &c.LogFormat,
+
+		"log-file":         &c.LogFile,
+		"log-max-size":     &c.LogMaxSize,
+		"log-rotate-daily": &c.LogRotateDaily,
+		"log-max-backups":  &c.LogMaxBackups,
+
+		"apdu-timeout": &c.APDUTimeout,
+		"op-timeout":   &c.OperationTimeout,
+
+		"reader-min-firmware":   &c.ReaderMinFirmware,
+		"reader-firmware-check": &c.ReaderFirmwareCheck,
+
+		"cm-mode":         &c.CMMode,
+		"cm-keystore":     &c.CMKeystore,
+		"cm-keystore-key": &c.CMKeystoreKey,
+
+		"export-checksums": &c.ExportChecksums,
+
+		"serve-tokens":       &c.ServeTokens,
+		"serve-tls":          &c.ServeTLS,
+		"serve-idle-timeout": &c.ServeIdleTimeout,
+		"transport-token":    &c.TransportToken,
+		"transport-tls":      &c.TransportTLS,
+
+		"network-server":       &c.NetworkServer,
+		"network-server-token": &c.NetworkServerToken,
+
+		"decommission-dir":    &c.DecommissionDir,
+		"decommission-key":    &c.DecommissionKey,
+		"decommission-delete": &c.DecommissionDelete,
+		"rekey-log":           &c.RekeyLog,
+		"rekey-records":       &c.RekeyRecords,
+
+		"operator-pin-hash":     &c.OperatorPinHash,
+		"approval-key":          &c.ApprovalKey,
+		"allow-unauthorized":    &c.AllowUnauthorized,
+		"audit-log":             &c.AuditLog,
+		"redact-keys":           &c.RedactKeys,
+		"config-key":            &c.ConfigKey,
+		"config-key-file":       &c.ConfigKeyFile,
+		"image-verify-key":      &c.ImageVerifyKey,
+		"allow-unsigned-images": &c.AllowUnsignedImages,
+		"fips":                  &c.FIPS,
+	}
+}
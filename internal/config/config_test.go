@@ -0,0 +1,74 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/config"
+)
+
+func writeConfig(t *testing.T, path string, content string) {
+	t.Helper()
+	if content == "" {
+		return
+	}
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPinsSecuredOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		system   string
+		home     string
+		explicit string
+		env      map[string]string
+		wantPin  string
+		wantErr  bool
+	}{
+		{name: "default file only", home: "operator-pin-hash: abc\n", wantPin: "abc"},
+		{name: "empty -config keeps the pin", home: "operator-pin-hash: abc\n", explicit: "reader: x\n", wantPin: "abc"},
+		{name: "-config may not change the pin", home: "operator-pin-hash: abc\n", explicit: "operator-pin-hash: def\n", wantErr: true},
+		{name: "-config repeating the pin", home: "operator-pin-hash: abc\n", explicit: "operator-pin-hash: abc\n", wantPin: "abc"},
+		{name: "-config sets an unpinned option", explicit: "operator-pin-hash: def\n", wantPin: "def"},
+		{name: "system file pins over the default file", system: "operator-pin-hash: abc\n", home: "operator-pin-hash: def\n", wantErr: true},
+		{name: "system file kept with empty -config", system: "operator-pin-hash: abc\n", explicit: "reader: x\n", wantPin: "abc"},
+		{name: "environment may not change the pin", home: "operator-pin-hash: abc\n", explicit: "reader: x\n", env: map[string]string{"HIDNFC_OPERATOR_PIN_HASH": ""}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			home := filepath.Join(dir, "home")
+			err := os.Mkdir(home, 0700)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("HOME", home)
+			saved := config.SystemPath
+			config.SystemPath = filepath.Join(dir, "system.yaml")
+			t.Cleanup(func() { config.SystemPath = saved })
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			writeConfig(t, config.SystemPath, tt.system)
+			writeConfig(t, config.DefaultPath(), tt.home)
+			path := config.DefaultPath()
+			if tt.explicit != "" {
+				path = filepath.Join(dir, "explicit.yaml")
+				writeConfig(t, path, tt.explicit)
+			}
+
+			cfg, err := config.Load(path, tt.explicit != "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.OperatorPinHash != tt.wantPin {
+				t.Errorf("operator-pin-hash = %q, want %q", cfg.OperatorPinHash, tt.wantPin)
+			}
+		})
+	}
+}
@@ -0,0 +1,425 @@
+package emulator
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/snapshot"
+)
+
+const (
+	// DefaultBlocks is the number of blocks of a new emulated tag
+	DefaultBlocks = 64
+	// BlockSize is the size of an M24LR block in bytes
+	BlockSize = 4
+	// MaxReadBlocks is the number of blocks the emulated reader returns for one read binary APDU
+	MaxReadBlocks = 32
+)
+
+// Status words returned by the emulated reader
+var (
+	swSuccess           = []byte{0x90, 0x00}
+	swWrongLength       = []byte{0x67, 0x00}
+	swSecurityStatus    = []byte{0x69, 0x82}
+	swWrongParameters   = []byte{0x6B, 0x00}
+	swInsNotSupported   = []byte{0x6D, 0x00}
+	swClassNotSupported = []byte{0x6E, 0x00}
+)
+
+// Image is the on disk representation of an emulated tag
+type Image struct {
+	UID    string   `json:"uid"`
+	AFI    string   `json:"afi"`
+	DSFID  string   `json:"dsfid"`
+	Blocks []string `json:"blocks"`
+	Locked []int    `json:"locked,omitempty"`
+	// Field lists further tags in the field of the reader, see FieldTag
+	Field []FieldTag `json:"field,omitempty"`
+	// Config is the M24LR configuration byte, F4 (energy harvesting on demand, RF BUSY) if empty
+	Config string `json:"config,omitempty"`
+}
+
+// Tag emulates an M24LR tag presented to a PC/SC reader. It implements nfc.CardTransport for the
+// pseudo APDUs used by the nfc package and persists its memory to disk after every write.
+type Tag struct {
+	mu     sync.Mutex
+	path   string
+	uid    []byte
+	afi    byte
+	dsfid  byte
+	memory []byte
+	locked map[int]bool
+	field  []fieldTag
+	// session is set while a transparent session is open
+	session bool
+	// config is the M24LR configuration byte, ehEnabled the energy harvesting bit of its control register
+	config    byte
+	ehEnabled bool
+	// snap is set for tags loaded from a snapshot file, they are saved back as snapshots
+	snap *snapshot.Snapshot
+}
+
+// defaultConfig is the M24LR configuration byte as delivered
+const defaultConfig = 0xF4
+
+// New creates an erased tag (all bytes 0xFF with a valid CRC) with a random ST UID
+func New(blocks int) (*Tag, error) {
+	if blocks <= nfc.ASSET_PLUS_CRC_BLOCK {
+		return nil, fmt.Errorf("tag needs more than %d blocks", nfc.ASSET_PLUS_CRC_BLOCK)
+	}
+	uid := make([]byte, 8)
+	_, err := rand.Read(uid[2:])
+	if err != nil {
+		return nil, err
+	}
+	uid[0], uid[1] = 0xE0, 0x02 // ISO15693, STMicroelectronics
+
+	t := &Tag{
+		uid:    uid,
+		memory: make([]byte, blocks*BlockSize),
+		locked: make(map[int]bool),
+		config: defaultConfig,
+	}
+	for i := range t.memory {
+		t.memory[i] = 0xFF
+	}
+	t.updateCRC()
+	return t, nil
+}
+
+// Load reads the image (JSON or snapshot, see package snapshot) at path, creating a new tag if the
+// file does not exist. The tag is saved back to path in the same format after every write.
+func Load(path string) (*Tag, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t, err := New(DefaultBlocks)
+		if err != nil {
+			return nil, err
+		}
+		t.path = path
+		return t, t.Save()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.IsSnapshot(data) {
+		snap, err := snapshot.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		t := FromSnapshot(snap)
+		t.path = path
+		return t, nil
+	}
+
+	image := Image{}
+	err = json.Unmarshal(data, &image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	t, err := FromImage(&image)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	t.path = path
+	return t, nil
+}
+
+// FromImage creates a tag from its image
+func FromImage(image *Image) (*Tag, error) {
+	uid, err := hex.DecodeString(image.UID)
+	if err != nil || len(uid) == 0 {
+		return nil, fmt.Errorf("invalid uid %q", image.UID)
+	}
+	t := &Tag{
+		uid:    uid,
+		afi:    parseByte(image.AFI),
+		dsfid:  parseByte(image.DSFID),
+		memory: make([]byte, 0, len(image.Blocks)*BlockSize),
+		locked: make(map[int]bool),
+	}
+	for i, block := range image.Blocks {
+		data, err := hex.DecodeString(block)
+		if err != nil || len(data) != BlockSize {
+			return nil, fmt.Errorf("invalid block %d: %q", i, block)
+		}
+		t.memory = append(t.memory, data...)
+	}
+	if t.blocks() <= nfc.ASSET_PLUS_CRC_BLOCK {
+		return nil, fmt.Errorf("image needs more than %d blocks, got %d", nfc.ASSET_PLUS_CRC_BLOCK, t.blocks())
+	}
+	for _, block := range image.Locked {
+		t.locked[block] = true
+	}
+	t.field, err = parseFieldTags(image.Field)
+	if err != nil {
+		return nil, err
+	}
+	t.config = defaultConfig
+	if image.Config != "" {
+		t.config = parseByte(image.Config)
+	}
+	t.ehEnabled = t.config&nfc.M24LR_CFG_EH_MODE == 0
+	return t, nil
+}
+
+// FromSnapshot creates a tag from a snapshot, the tag keeps the metadata of the snapshot
+func FromSnapshot(snap *snapshot.Snapshot) *Tag {
+	uid, _ := hex.DecodeString(snap.UID)
+	t := &Tag{
+		uid:    uid,
+		afi:    snap.AFI,
+		dsfid:  snap.DSFID,
+		memory: append([]byte(nil), snap.Memory...),
+		locked: make(map[int]bool),
+		config: defaultConfig,
+		snap:   snap,
+	}
+	for _, block := range snap.Locked {
+		t.locked[block] = true
+	}
+	if snap.Config != nil {
+		t.config = *snap.Config
+	}
+	t.ehEnabled = t.config&nfc.M24LR_CFG_EH_MODE == 0
+	return t
+}
+
+// Snapshot returns a snapshot of the current state of the tag, further tags in the field are not
+// part of it
+func (t *Tag) Snapshot() *snapshot.Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshot()
+}
+
+func (t *Tag) snapshot() *snapshot.Snapshot {
+	snap := &snapshot.Snapshot{Version: snapshot.Version, Source: t.path}
+	if t.snap != nil {
+		copied := *t.snap
+		snap = &copied
+	}
+	snap.Time = time.Now().UTC()
+	snap.UID = strings.ToLower(hex.EncodeToString(t.uid))
+	snap.AFI, snap.DSFID = t.afi, t.dsfid
+	snap.Memory = append([]byte(nil), t.memory...)
+	snap.Locked = nil
+	for block := range t.locked {
+		snap.Locked = append(snap.Locked, block)
+	}
+	sort.Ints(snap.Locked)
+	config := t.config
+	snap.Config = &config
+	snap.Decode()
+	return snap
+}
+
+// Image returns the current state of the tag
+func (t *Tag) Image() *Image {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.image()
+}
+
+func (t *Tag) image() *Image {
+	image := &Image{
+		UID:   strings.ToUpper(hex.EncodeToString(t.uid)),
+		AFI:   fmt.Sprintf("%02X", t.afi),
+		DSFID: fmt.Sprintf("%02X", t.dsfid),
+	}
+	if t.config != defaultConfig {
+		image.Config = fmt.Sprintf("%02X", t.config)
+	}
+	for block := 0; block < t.blocks(); block++ {
+		image.Blocks = append(image.Blocks, strings.ToUpper(hex.EncodeToString(t.block(block))))
+	}
+	for block := range t.locked {
+		image.Locked = append(image.Locked, block)
+	}
+	sort.Ints(image.Locked)
+	for _, tag := range t.field {
+		image.Field = append(image.Field, FieldTag{
+			UID:   strings.ToUpper(hex.EncodeToString(tag.uid)),
+			AFI:   fmt.Sprintf("%02X", tag.afi),
+			DSFID: fmt.Sprintf("%02X", tag.dsfid),
+		})
+	}
+	return image
+}
+
+// Save writes the tag image to the file it was loaded from, tags created with New are not persisted
+func (t *Tag) Save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.save()
+}
+
+func (t *Tag) save() error {
+	if t.path == "" {
+		return nil
+	}
+	if t.snap != nil {
+		t.snap = t.snapshot()
+		return snapshot.Save(t.path, t.snap)
+	}
+	data, err := json.MarshalIndent(t.image(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// Lock write protects a block
+func (t *Tag) Lock(block int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.locked[block] = true
+}
+
+// UpdateCRC recalculates the CRC block over the configuration blocks, as done by the tool after every write
+func (t *Tag) UpdateCRC() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.updateCRC()
+}
+
+func (t *Tag) updateCRC() {
+	crc := nfc.CalculateCRC(t.memory[:nfc.ASSET_PLUS_CONFIG_BLOCKS*BlockSize])
+	crcBlock := t.block(nfc.ASSET_PLUS_CRC_BLOCK)
+	crcBlock[0], crcBlock[1], crcBlock[2], crcBlock[3] = byte(crc), byte(crc>>8), 0, 0
+}
+
+// CRCValid reports whether the stored CRC matches the configuration blocks, i.e. whether
+// the device firmware would accept the configuration
+func (t *Tag) CRCValid() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	crc := nfc.CalculateCRC(t.memory[:nfc.ASSET_PLUS_CONFIG_BLOCKS*BlockSize])
+	crcBlock := t.block(nfc.ASSET_PLUS_CRC_BLOCK)
+	return binary.LittleEndian.Uint16(crcBlock[0:2]) == crc
+}
+
+// Apdu handles the PC/SC pseudo APDUs: get UID, read binary, update binary and get system info,
+// and the transparent exchange of raw ISO 15693 commands
+func (t *Tag) Apdu(cmd []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(cmd) < 5 {
+		return swWrongLength, nil
+	}
+	if cmd[0] != 0xFF {
+		return swClassNotSupported, nil
+	}
+	p1, p2 := cmd[2], cmd[3]
+	switch cmd[1] {
+	case 0xCA: // get data: UID
+		if p1 != 0x00 {
+			return swWrongParameters, nil
+		}
+		return respond(t.uid), nil
+
+	case 0xB0: // read binary
+		block := int(p1)<<8 | int(p2)
+		if block >= t.blocks() {
+			return swWrongParameters, nil
+		}
+		// Le is the number of bytes, whole blocks up to MaxReadBlocks, 0 requests 256 bytes
+		length := int(cmd[4])
+		if length == 0 {
+			length = 256
+		}
+		if length%BlockSize != 0 || length/BlockSize > MaxReadBlocks {
+			return swWrongLength, nil
+		}
+		if block+length/BlockSize > t.blocks() {
+			return swWrongParameters, nil
+		}
+		return respond(t.memory[block*BlockSize : block*BlockSize+length]), nil
+
+	case 0xD6: // update binary
+		block := int(p1)<<8 | int(p2)
+		if block >= t.blocks() {
+			return swWrongParameters, nil
+		}
+		if cmd[4] != BlockSize || len(cmd) != 5+BlockSize {
+			return swWrongLength, nil
+		}
+		if t.locked[block] {
+			return swSecurityStatus, nil
+		}
+		copy(t.block(block), cmd[5:])
+		err := t.save()
+		if err != nil {
+			return nil, fmt.Errorf("failed to persist emulated tag: %v", err)
+		}
+		return swSuccess, nil
+
+	case 0x30: // get system info
+		switch p1 {
+		case 0x02:
+			return respond([]byte{t.afi}), nil
+		case 0x03:
+			return respond([]byte{t.dsfid}), nil
+		case 0x04: // number of blocks - 1 (LE) and block size - 1
+			blocks := uint16(t.blocks() - 1)
+			return respond([]byte{byte(blocks), byte(blocks >> 8), BlockSize - 1}), nil
+		default:
+			return swWrongParameters, nil
+		}
+
+	case 0xC2: // manage session and transparent exchange
+		if p1 != 0x00 || int(cmd[4]) > len(cmd)-5 {
+			return swWrongParameters, nil
+		}
+		return t.transparent(p2, cmd[5:5+int(cmd[4])]), nil
+
+	default:
+		return swInsNotSupported, nil
+	}
+}
+
+func (t *Tag) DisconnectCard() error {
+	return t.Save()
+}
+
+func (t *Tag) DisconnectUnpowerCard() error {
+	return t.Save()
+}
+
+func (t *Tag) blocks() int {
+	return len(t.memory) / BlockSize
+}
+
+func (t *Tag) block(block int) []byte {
+	return t.memory[block*BlockSize : (block+1)*BlockSize]
+}
+
+func respond(data []byte) []byte {
+	resp := make([]byte, 0, len(data)+2)
+	resp = append(resp, data...)
+	return append(resp, swSuccess...)
+}
+
+func parseByte(s string) byte {
+	data, err := hex.DecodeString(s)
+	if err != nil || len(data) != 1 {
+		return 0
+	}
+	return data[0]
+}
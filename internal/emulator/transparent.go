@@ -0,0 +1,297 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// FieldTag is a further tag in the field of the emulated reader. It only answers inventory, get
+// system info and write AFI/DSFID requests, so stacked units can be emulated.
+type FieldTag struct {
+	UID   string `json:"uid"`
+	AFI   string `json:"afi,omitempty"`
+	DSFID string `json:"dsfid,omitempty"`
+}
+
+// fieldTag is a tag answering inventory requests
+type fieldTag struct {
+	uid   []byte
+	afi   byte
+	dsfid byte
+}
+
+func parseFieldTags(tags []FieldTag) ([]fieldTag, error) {
+	var field []fieldTag
+	for _, tag := range tags {
+		uid, err := hex.DecodeString(tag.UID)
+		if err != nil || len(uid) != 8 {
+			return nil, fmt.Errorf("invalid field tag uid %q", tag.UID)
+		}
+		field = append(field, fieldTag{uid: uid, afi: parseByte(tag.AFI), dsfid: parseByte(tag.DSFID)})
+	}
+	return field, nil
+}
+
+// icReference is the IC reference reported by the emulated tag, that of the M24LR04E-R
+const icReference = 0x5A
+
+// Data objects of the PC/SC 2.02 transparent exchange
+var (
+	doSuccess    = []byte{0xC0, 0x03, 0x00, 0x90, 0x00}
+	doNoResponse = []byte{0xC0, 0x03, 0x00, 0x64, 0x01}
+	doCollision  = []byte{0x96, 0x02, 0x02, 0x00}
+)
+
+// transparent handles the manage session (p2 0) and transparent exchange (p2 1) commands
+func (t *Tag) transparent(p2 byte, data []byte) []byte {
+	if len(data) < 2 {
+		return swWrongLength
+	}
+	switch {
+	case p2 == 0x00 && (data[0] == 0x81 || data[0] == 0x82):
+		t.session = data[0] == 0x81
+		return respond(doSuccess)
+	case p2 == 0x01 && data[0] == 0x95:
+		if !t.session {
+			return respond([]byte{0xC0, 0x03, 0x00, 0x69, 0x86})
+		}
+		length := int(data[1])
+		if len(data) < 2+length {
+			return swWrongLength
+		}
+		return respond(t.iso15693(data[2 : 2+length]))
+	default:
+		return swWrongParameters
+	}
+}
+
+// iso15693 answers a raw ISO 15693 request with the data objects of the transparent exchange
+func (t *Tag) iso15693(frame []byte) []byte {
+	if len(frame) < 2 {
+		return doNoResponse
+	}
+	flags, command := frame[0], frame[1]
+	if flags&nfc.ISO15693_FLAG_INVENTORY != 0 {
+		if command != nfc.ISO15693_CMD_INVENTORY {
+			return doNoResponse
+		}
+		params := frame[2:]
+		afi := byte(0)
+		if flags&nfc.ISO15693_FLAG_AFI != 0 {
+			if len(params) < 1 {
+				return doNoResponse
+			}
+			afi, params = params[0], params[1:]
+		}
+		return t.inventory(afi, params)
+	}
+	params := frame[2:]
+	custom := command >= 0xA0 && command <= 0xDF
+	if custom {
+		// the manufacturer code precedes the UID, tags of other manufacturers ignore the request
+		if len(params) < 1 || params[0] != nfc.M24LR_MANUFACTURER_CODE {
+			return doNoResponse
+		}
+		params = params[1:]
+	}
+	tag := &fieldTag{uid: t.uid, afi: t.afi, dsfid: t.dsfid}
+	addressed := flags&nfc.ISO15693_FLAG_ADDRESSED != 0
+	if addressed {
+		if len(params) < 8 {
+			return doNoResponse
+		}
+		tag = t.addressed(params[:8])
+		if tag == nil {
+			return doNoResponse
+		}
+		params = params[8:]
+	}
+	if custom && !bytes.Equal(tag.uid, t.uid) {
+		// field tags only answer the mandatory commands
+		return responseData([]byte{nfc.ISO15693_FLAG_ERROR, 0x01})
+	}
+	switch command {
+	case nfc.ISO15693_CMD_GET_SYSTEM_INFO:
+		resp := []byte{0x00, nfc.ISO15693_INFO_DSFID | nfc.ISO15693_INFO_AFI}
+		resp = append(resp, reversed(tag.uid)...)
+		resp = append(resp, tag.dsfid, tag.afi)
+		if bytes.Equal(tag.uid, t.uid) {
+			resp[1] |= nfc.ISO15693_INFO_MEMORY | nfc.ISO15693_INFO_IC_REFERENCE
+			blocks := t.blocks() - 1
+			switch {
+			case flags&nfc.ISO15693_FLAG_EXTENSION != 0:
+				resp = append(resp, byte(blocks), byte(blocks>>8))
+			case blocks > 0xFF:
+				// like the M24LR64E-R, larger tags need the protocol extension
+				return responseData([]byte{nfc.ISO15693_FLAG_ERROR, 0x03})
+			default:
+				resp = append(resp, byte(blocks))
+			}
+			resp = append(resp, BlockSize-1, icReference)
+		}
+		return responseData(resp)
+	case nfc.ISO15693_CMD_READ_SINGLE, nfc.ISO15693_CMD_WRITE_SINGLE:
+		if !bytes.Equal(tag.uid, t.uid) {
+			return responseData([]byte{nfc.ISO15693_FLAG_ERROR, 0x01})
+		}
+		// the block number takes 2 bytes (least significant first) with the protocol extension
+		block, size := -1, 1
+		if flags&nfc.ISO15693_FLAG_EXTENSION != 0 {
+			size = 2
+		}
+		if len(params) >= size {
+			block = int(params[0])
+			if size == 2 {
+				block |= int(params[1]) << 8
+			}
+			params = params[size:]
+		}
+		if block < 0 || block >= t.blocks() {
+			return responseData([]byte{nfc.ISO15693_FLAG_ERROR, 0x10})
+		}
+		if command == nfc.ISO15693_CMD_READ_SINGLE {
+			resp := []byte{0x00}
+			if flags&nfc.ISO15693_FLAG_OPTION != 0 {
+				// block security status, locked blocks report a write protected sector
+				status := byte(0)
+				if t.locked[block] {
+					status = nfc.M24LR_SSS_LOCK | nfc.M24LR_SSS_WRITE_LOCKED
+				}
+				resp = append(resp, status)
+			}
+			return responseData(append(resp, t.block(block)...))
+		}
+		if len(params) != BlockSize {
+			return responseData([]byte{nfc.ISO15693_FLAG_ERROR, 0x0F})
+		}
+		if t.locked[block] {
+			return responseData([]byte{nfc.ISO15693_FLAG_ERROR, 0x12})
+		}
+		copy(t.block(block), params)
+		err := t.save()
+		if err != nil {
+			return responseData([]byte{nfc.ISO15693_FLAG_ERROR, 0x13})
+		}
+		return responseData([]byte{0x00})
+	case nfc.ISO15693_CMD_WRITE_AFI, nfc.ISO15693_CMD_WRITE_DSFID:
+		if len(params) < 1 {
+			return responseData([]byte{nfc.ISO15693_FLAG_ERROR, 0x0F})
+		}
+		t.setSystemValue(tag.uid, command, params[0])
+		err := t.save()
+		if err != nil {
+			return responseData([]byte{nfc.ISO15693_FLAG_ERROR, 0x0F})
+		}
+		return responseData([]byte{0x00})
+	case nfc.M24LR_CMD_READ_CFG:
+		return responseData([]byte{0x00, t.config})
+	case nfc.M24LR_CMD_CHECK_EH_EN:
+		control := byte(nfc.M24LR_CTRL_FIELD_ON)
+		if t.ehEnabled {
+			control |= nfc.M24LR_CTRL_EH_EN | nfc.M24LR_CTRL_EH_ON
+		}
+		return responseData([]byte{0x00, control})
+	case nfc.M24LR_CMD_SET_RST_EH_EN:
+		if len(params) < 1 {
+			return responseData([]byte{nfc.ISO15693_FLAG_ERROR, 0x0F})
+		}
+		t.ehEnabled = params[0]&nfc.M24LR_CTRL_EH_EN != 0
+		return responseData([]byte{0x00})
+	case nfc.M24LR_CMD_WRITE_EH_CFG, nfc.M24LR_CMD_WRITE_DO_CFG:
+		if len(params) < 1 {
+			return responseData([]byte{nfc.ISO15693_FLAG_ERROR, 0x0F})
+		}
+		mask := byte(nfc.M24LR_CFG_EH_LOAD | nfc.M24LR_CFG_EH_MODE)
+		if command == nfc.M24LR_CMD_WRITE_DO_CFG {
+			mask = nfc.M24LR_CFG_RF_WIP
+		}
+		t.config = t.config&^mask | params[0]&mask
+		err := t.save()
+		if err != nil {
+			return responseData([]byte{nfc.ISO15693_FLAG_ERROR, 0x0F})
+		}
+		return responseData([]byte{0x00})
+	default:
+		// command not supported
+		return responseData([]byte{nfc.ISO15693_FLAG_ERROR, 0x01})
+	}
+}
+
+// addressed returns the tag with the UID of an addressed request (least significant byte first)
+func (t *Tag) addressed(uid []byte) *fieldTag {
+	uid = reversed(uid)
+	if bytes.Equal(uid, t.uid) {
+		return &fieldTag{uid: t.uid, afi: t.afi, dsfid: t.dsfid}
+	}
+	for i := range t.field {
+		if bytes.Equal(uid, t.field[i].uid) {
+			return &t.field[i]
+		}
+	}
+	return nil
+}
+
+// setSystemValue sets the AFI or DSFID of the main tag or a field tag
+func (t *Tag) setSystemValue(uid []byte, command byte, value byte) {
+	set := func(afi, dsfid *byte) {
+		if command == nfc.ISO15693_CMD_WRITE_AFI {
+			*afi = value
+		} else {
+			*dsfid = value
+		}
+	}
+	if bytes.Equal(uid, t.uid) {
+		set(&t.afi, &t.dsfid)
+		return
+	}
+	for i := range t.field {
+		if bytes.Equal(uid, t.field[i].uid) {
+			set(&t.field[i].afi, &t.field[i].dsfid)
+		}
+	}
+}
+
+// reversed returns a copy of data in reverse order, ISO 15693 frames carry UIDs least significant byte first
+func reversed(data []byte) []byte {
+	out := make([]byte, len(data))
+	for i := range data {
+		out[len(data)-1-i] = data[i]
+	}
+	return out
+}
+
+// inventory answers a single slot inventory request for the tags of an AFI (0 for all): the mask
+// length and the mask value
+func (t *Tag) inventory(afi byte, params []byte) []byte {
+	if len(params) < 1 {
+		return doNoResponse
+	}
+	maskBits := int(params[0])
+	mask := params[1:]
+	tags := append([]fieldTag{{uid: t.uid, afi: t.afi, dsfid: t.dsfid}}, t.field...)
+	var answering []fieldTag
+	for _, tag := range tags {
+		if nfc.AFIMatches(tag.afi, afi) && nfc.InventoryMatches(tag.uid, mask, maskBits) {
+			answering = append(answering, tag)
+		}
+	}
+	switch len(answering) {
+	case 0:
+		return doNoResponse
+	case 1:
+		resp := []byte{0x00, answering[0].dsfid}
+		return responseData(append(resp, reversed(answering[0].uid)...))
+	default:
+		return append(append([]byte{}, doSuccess...), doCollision...)
+	}
+}
+
+// responseData returns the data objects of a tag response
+func responseData(frame []byte) []byte {
+	resp := append([]byte{}, doSuccess...)
+	resp = append(resp, 0x97, byte(len(frame)))
+	return append(resp, frame...)
+}
@@ -0,0 +1,167 @@
+// Package events publishes what happens to tags (connects, block writes, CRC checks, finished tags)
+// to subscribers, so integrations such as logs, exporters and post-read actions can follow the
+// provisioning without patching the command code.
+package events
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Type identifies an event
+type Type string
+
+const (
+	// TagConnected is published when a tag is connected or a new tag is presented in a loop mode
+	TagConnected Type = "tagConnected"
+	// TagDisconnected is published when the card is disconnected
+	TagDisconnected Type = "tagDisconnected"
+	// BlockWritten is published after every block write, OK is false if the write failed
+	BlockWritten Type = "blockWritten"
+	// CRCValidated is published after the configuration CRC was checked, OK is false on a mismatch
+	CRCValidated Type = "crcValidated"
+	// ProvisionCompleted is published when a loop mode is done with a tag, OK is false if it failed
+	ProvisionCompleted Type = "provisionCompleted"
+	// FailureInjected is published when -inject-failure fails an APDU, Error describes the failure
+	FailureInjected Type = "failureInjected"
+)
+
+// Event is something that happened to a tag. Events never carry block data or keys.
+type Event struct {
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	UID  string    `json:"uid,omitempty"`
+	// Reader is the reader the tag is presented to, set by server mode
+	Reader string `json:"reader,omitempty"`
+	// Block is the written block of BlockWritten
+	Block *int `json:"block,omitempty"`
+	// Attempts is the number of times the block of BlockWritten was sent to the tag: 1, more if the
+	// reader failed the write or the block was rewritten because it did not read back as written
+	Attempts int `json:"attempts,omitempty"`
+	// Command is the command which completed the tag for ProvisionCompleted
+	Command string `json:"command,omitempty"`
+	// Failure is the injected failure of FailureInjected: crc, write or timeout
+	Failure string `json:"failure,omitempty"`
+	// Capabilities are the feature flags of the tag of TagConnected in server mode, e.g. supportsClassB
+	Capabilities []string `json:"capabilities,omitempty"`
+	OK           bool     `json:"ok"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// Handler receives the published events. Handlers run synchronously in the publishing goroutine
+// in the order they subscribed and must not block.
+type Handler func(Event)
+
+// Bus delivers published events to its subscribers
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []subscription
+	next     int
+}
+
+type subscription struct {
+	id      int
+	handler Handler
+}
+
+// NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers handler, the returned function unsubscribes it
+func (b *Bus) Subscribe(handler Handler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.next++
+	id := b.next
+	b.handlers = append(b.handlers, subscription{id: id, handler: handler})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, s := range b.handlers {
+			if s.id == id {
+				b.handlers = append(b.handlers[:i:i], b.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish delivers event to every subscriber, the time is set if it is zero
+func (b *Bus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+	for _, s := range handlers {
+		s.handler(event)
+	}
+}
+
+var defaultBus = NewBus()
+
+// Subscribe registers handler on the default bus the nfc package and the loop modes publish to
+func Subscribe(handler Handler) func() {
+	return defaultBus.Subscribe(handler)
+}
+
+// Publish publishes event on the default bus
+func Publish(event Event) {
+	defaultBus.Publish(event)
+}
+
+// ErrorString returns the error message of err, empty for nil
+func ErrorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// WriteAttempts collects the attempts per block of BlockWritten events
+type WriteAttempts map[int]int
+
+// Add records a BlockWritten event, a block written several times keeps its largest number of attempts
+func (a WriteAttempts) Add(event Event) {
+	if event.Type != BlockWritten || event.Block == nil {
+		return
+	}
+	if event.Attempts > a[*event.Block] {
+		a[*event.Block] = event.Attempts
+	}
+}
+
+// Retries returns the blocks which needed more than one attempt with their attempts, nil if none did.
+// Tags needing retries are marginal: weak tags or tags badly positioned on the fixture.
+func (a WriteAttempts) Retries() map[int]int {
+	var retries map[int]int
+	for block, attempts := range a {
+		if attempts > 1 {
+			if retries == nil {
+				retries = make(map[int]int)
+			}
+			retries[block] = attempts
+		}
+	}
+	return retries
+}
+
+// FormatRetries formats the result of Retries as block:attempts pairs ordered by block, e.g. "5:2 48:3"
+func FormatRetries(retries map[int]int) string {
+	blocks := make([]int, 0, len(retries))
+	for block := range retries {
+		blocks = append(blocks, block)
+	}
+	sort.Ints(blocks)
+	parts := make([]string, len(blocks))
+	for i, block := range blocks {
+		parts[i] = fmt.Sprintf("%d:%d", block, retries[block])
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,116 @@
+package events
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Summary counts the tags completed by the loop and batch modes of a session from their
+// ProvisionCompleted events. Subscribe Add to collect them.
+type Summary struct {
+	mu sync.Mutex
+
+	// Station is the provisioning station of the session, set by the caller
+	Station  string    `json:"station,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// Commands are the commands which completed tags, in the order they first did
+	Commands  []string `json:"commands"`
+	Attempted int      `json:"attempted"`
+	Succeeded int      `json:"succeeded"`
+	Failed    int      `json:"failed"`
+	// Failures counts the failed tags per error message, most frequent first
+	Failures []FailureCount `json:"failures,omitempty"`
+	// Duration is the session time in seconds, CycleTime the average time per attempted tag
+	Duration  float64 `json:"durationSeconds"`
+	CycleTime float64 `json:"averageCycleSeconds"`
+}
+
+// FailureCount is the number of tags which failed with Reason
+type FailureCount struct {
+	Reason string `json:"reason"`
+	Count  int    `json:"count"`
+}
+
+// NewSummary starts the summary of a session at the current time
+func NewSummary() *Summary {
+	return &Summary{Started: time.Now()}
+}
+
+// Add records a ProvisionCompleted event, other events are ignored. Completions without a UID
+// (e.g. an export failing after the tag was counted) are not tags and are ignored as well.
+func (s *Summary) Add(event Event) {
+	if event.Type != ProvisionCompleted || event.UID == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attempted++
+	if event.OK {
+		s.Succeeded++
+	} else {
+		s.Failed++
+		s.addFailure(event.Error)
+	}
+	if event.Command != "" && !contains(s.Commands, event.Command) {
+		s.Commands = append(s.Commands, event.Command)
+	}
+}
+
+func (s *Summary) addFailure(reason string) {
+	if reason == "" {
+		reason = "unknown"
+	}
+	for i := range s.Failures {
+		if s.Failures[i].Reason == reason {
+			s.Failures[i].Count++
+			sort.SliceStable(s.Failures, func(a, b int) bool { return s.Failures[a].Count > s.Failures[b].Count })
+			return
+		}
+	}
+	s.Failures = append(s.Failures, FailureCount{Reason: reason, Count: 1})
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Finish ends the summary at the current time and computes the duration and the average cycle time
+func (s *Summary) Finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Finished = time.Now()
+	duration := s.Finished.Sub(s.Started)
+	s.Duration = duration.Seconds()
+	if s.Attempted > 0 {
+		s.CycleTime = s.Duration / float64(s.Attempted)
+	}
+}
+
+// String formats the summary for the console, one failure reason per line
+func (s *Summary) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var text strings.Builder
+	fmt.Fprintf(&text, "Session summary (%s): %d tags attempted, %d succeeded, %d failed in %s",
+		strings.Join(s.Commands, ", "), s.Attempted, s.Succeeded, s.Failed, seconds(s.Duration).Round(time.Second))
+	if s.Attempted > 0 {
+		fmt.Fprintf(&text, ", average cycle time %s", seconds(s.CycleTime).Round(100*time.Millisecond))
+	}
+	for _, failure := range s.Failures {
+		fmt.Fprintf(&text, "\n  %dx %s", failure.Count, failure.Reason)
+	}
+	return text.String()
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
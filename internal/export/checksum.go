@@ -0,0 +1,88 @@
+package export
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumSuffix is appended to the path of an export to get its checksum file
+const ChecksumSuffix = ".sha256"
+
+// checksums enables the checksum files, see SetChecksums
+var checksums bool
+
+// SetChecksums enables a sidecar checksum file (path.sha256, in the format of sha256sum) for every
+// file written by AppendCSV, AppendLine and WriteFile. It is updated with every write under the
+// lock of the file, so it always matches the complete file, e.g. once it left the production
+// network it can be checked with sha256sum -c or VerifyChecksum.
+func SetChecksums(on bool) {
+	checksums = on
+}
+
+// writeChecksum writes the checksum file of the export at path holding data
+func writeChecksum(path string, data []byte) error {
+	if !checksums {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	line := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), filepath.Base(path))
+	return writeAtomic(path+ChecksumSuffix, []byte(line))
+}
+
+// updateChecksum writes the checksum file of the export at path from its current content
+func updateChecksum(path string) error {
+	if !checksums {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s for its checksum: %v", path, err)
+	}
+	return writeChecksum(path, data)
+}
+
+// WriteFile replaces the file at path with data atomically, e.g. a report, and writes its checksum
+// file when enabled
+func WriteFile(path string, data []byte) error {
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %v", path, err)
+	}
+	defer unlock()
+	err = writeAtomic(path, data)
+	if err != nil {
+		return err
+	}
+	return writeChecksum(path, data)
+}
+
+// VerifyChecksum checks the export at path against its checksum file
+func VerifyChecksum(path string) error {
+	file, err := os.Open(path + ChecksumSuffix)
+	if err != nil {
+		return fmt.Errorf("no checksum for %s: %v", path, err)
+	}
+	defer file.Close()
+	line, err := bufio.NewReader(file).ReadString('\n')
+	expected, name, ok := strings.Cut(strings.TrimSpace(line), "  ")
+	if err != nil || !ok || len(expected) != sha256.Size*2 {
+		return fmt.Errorf("invalid checksum file %s", path+ChecksumSuffix)
+	}
+	if name != filepath.Base(path) {
+		return fmt.Errorf("checksum file %s is for %s", path+ChecksumSuffix, name)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), expected) {
+		return fmt.Errorf("%s does not match its checksum, it was modified or damaged after it was written", path)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+// Package export writes the result files shared between provisioning stations
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// ErrHeaderMismatch is returned by AppendCSV when the file has other columns than the record, e.g.
+// one written by another version of the tool or with another template
+var ErrHeaderMismatch = errors.New("columns of the file differ")
+
+// AppendCSV appends record to the CSV file at path, writing header first when the file is new.
+//
+// Several stations may share the file, so the append holds an advisory lock on path.lock and the
+// row is appended in place with one synced write. A last line left partial by a crash is moved to
+// path.corrupt-<time> before the append. The first row of an existing file must be header, a file
+// with other columns is never rewritten: the append fails with ErrHeaderMismatch and the file has
+// to be moved away (or another path given) to start a file with the new columns.
+func AppendCSV(path string, header []string, record []string) error {
+	if len(record) != len(header) {
+		return fmt.Errorf("%s: record of %d fields for %d columns", path, len(record), len(header))
+	}
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %v", path, err)
+	}
+	defer unlock()
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	size, err := dropPartialLine(path, file)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{record}
+	if size == 0 {
+		rows = [][]string{header, record}
+	} else {
+		existing, err := csv.NewReader(io.NewSectionReader(file, 0, size)).Read()
+		if err != nil {
+			return fmt.Errorf("failed to read the header of %s: %v", path, err)
+		}
+		if !sameColumns(existing, header) {
+			return fmt.Errorf("%s: %w, it has %d columns (%s), the record %d (%s); move the file away or write to another file",
+				path, ErrHeaderMismatch, len(existing), strings.Join(existing, ","), len(header), strings.Join(header, ","))
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	err = writer.WriteAll(rows)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+	_, err = file.WriteAt(buf.Bytes(), size)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return updateChecksum(path)
+}
+
+// dropPartialLine truncates a file not ending with a newline after its last complete line, the
+// partial line is saved to path.corrupt-<time>. It returns the size of the file.
+func dropPartialLine(path string, file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	size := info.Size()
+	end := size
+	// search the last newline backwards, a block at a time
+	block := make([]byte, 4096)
+	for end > 0 {
+		n := int64(len(block))
+		if n > end {
+			n = end
+		}
+		_, err = file.ReadAt(block[:n], end-n)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		if i := bytes.LastIndexByte(block[:n], '\n'); i >= 0 {
+			end = end - n + int64(i) + 1
+			break
+		}
+		end -= n
+	}
+	if end == size {
+		return size, nil
+	}
+	partial := make([]byte, size-end)
+	_, err = file.ReadAt(partial, end)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	backup := fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102-150405"))
+	err = os.WriteFile(backup, partial, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to back up the partial line of %s: %v", path, err)
+	}
+	err = file.Truncate(end)
+	if err != nil {
+		return 0, fmt.Errorf("failed to truncate the partial line of %s: %v", path, err)
+	}
+	log.Warnf("%s: partial last line removed, saved to %s", path, backup)
+	return end, nil
+}
+
+// sameColumns reports whether the header of a file has the columns of header
+func sameColumns(existing []string, header []string) bool {
+	if len(existing) != len(header) {
+		return false
+	}
+	for i := range header {
+		if strings.TrimSpace(strings.TrimPrefix(existing[i], "\ufeff")) != strings.TrimSpace(header[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// AppendLine appends a line (e.g. a JSON record) to the file at path under the same lock as AppendCSV
+func AppendLine(path string, line []byte) error {
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %v", path, err)
+	}
+	defer unlock()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	if !bytes.HasSuffix(line, []byte("\n")) {
+		line = append(line, '\n')
+	}
+	_, err = file.Write(line)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return updateChecksum(path)
+}
+
+// writeAtomic replaces the file at path with data through a synced temp file in the same directory
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
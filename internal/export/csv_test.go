@@ -0,0 +1,76 @@
+package export
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendCSV(t *testing.T) {
+	header := []string{"UID", "Result"}
+	tests := []struct {
+		name     string
+		existing string
+		header   []string
+		want     string
+		err      error
+		corrupt  string
+	}{
+		{"new file", "", header, "UID,Result\nE002,PASS\n", nil, ""},
+		{"append", "UID,Result\nE001,FAIL\n", header, "UID,Result\nE001,FAIL\nE002,PASS\n", nil, ""},
+		{"other columns", "UID,Station,Result\nE001,st1,FAIL\n", header, "UID,Station,Result\nE001,st1,FAIL\n", ErrHeaderMismatch, ""},
+		{"renamed column", "UID,Status\nE001,FAIL\n", header, "UID,Status\nE001,FAIL\n", ErrHeaderMismatch, ""},
+		{"partial line", "UID,Result\nE001,FAIL\nE00", header, "UID,Result\nE001,FAIL\nE002,PASS\n", nil, "E00"},
+		{"partial header", "UI", header, "UID,Result\nE002,PASS\n", nil, "UI"},
+		{"quoted newline", "UID,Result\n\"E001\nx\",FAIL\n", header, "UID,Result\n\"E001\nx\",FAIL\nE002,PASS\n", nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "results.csv")
+			if test.existing != "" {
+				err := os.WriteFile(path, []byte(test.existing), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := AppendCSV(path, test.header, []string{"E002", "PASS"})
+			if !errors.Is(err, test.err) {
+				t.Fatalf("AppendCSV() error = %v, want %v", err, test.err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.want {
+				t.Errorf("file = %q, want %q", data, test.want)
+			}
+			corrupt, _ := filepath.Glob(path + ".corrupt-*")
+			if test.corrupt == "" {
+				if len(corrupt) != 0 {
+					t.Errorf("unexpected %v", corrupt)
+				}
+				return
+			}
+			if len(corrupt) != 1 {
+				t.Fatalf("corrupt files = %v, want 1", corrupt)
+			}
+			data, _ = os.ReadFile(corrupt[0])
+			if string(data) != test.corrupt {
+				t.Errorf("corrupt file = %q, want %q", data, test.corrupt)
+			}
+		})
+	}
+}
+
+func TestAppendCSVFieldCount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.csv")
+	err := AppendCSV(path, []string{"UID", "Result"}, []string{"E002"})
+	if err == nil {
+		t.Fatal("AppendCSV() accepted a record shorter than the header")
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file created for a refused record: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Column is one column of an export template. Value is a text/template executed with the record,
+// e.g. "{{.DevEUI}}", or a static text such as a batch ID.
+type Column struct {
+	Header string `yaml:"header"`
+	Value  string `yaml:"value"`
+}
+
+// Template selects and orders the columns of a CSV export, e.g. to match the import format of an ERP:
+//
+//	columns:
+//	  - header: Device EUI
+//	    value: '{{.DevEUI | replace ":" ""}}'
+//	  - header: Batch
+//	    value: B-2026-041
+//	  - header: Name
+//	    value: 'TRK-{{counter "trackers" | printf "%05d"}}'
+//
+// counter increments a persistent named counter, see SetCounter.
+type Template struct {
+	Columns []Column `yaml:"columns"`
+
+	values []*template.Template
+}
+
+// templateFuncs are available to column values in addition to the text/template builtins
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// replace is written for pipelines: {{.DevEUI | replace ":" ""}}
+	"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"counter": func(name string) (uint64, error) {
+		if nextCounter == nil {
+			return 0, errors.New("counter needs -counter-db")
+		}
+		return nextCounter(name)
+	},
+}
+
+// checkFuncs replace the functions with side effects when a template is checked
+var checkFuncs = template.FuncMap{
+	"counter": func(name string) (uint64, error) { return 0, nil },
+}
+
+// nextCounter increments a named counter and returns its new value, see SetCounter
+var nextCounter func(name string) (uint64, error)
+
+// SetCounter sets the function the counter function of templates increments a named counter with,
+// e.g. Next of store.Counters
+func SetCounter(next func(name string) (uint64, error)) {
+	nextCounter = next
+}
+
+// LoadTemplate reads an export template from a YAML file
+func LoadTemplate(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export template: %v", err)
+	}
+	t := &Template{}
+	err = yaml.Unmarshal(data, t)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse export template %s: %v", path, err)
+	}
+	err = t.compile()
+	if err != nil {
+		return nil, fmt.Errorf("export template %s: %v", path, err)
+	}
+	return t, nil
+}
+
+func (t *Template) compile() error {
+	if len(t.Columns) == 0 {
+		return errors.New("no columns")
+	}
+	t.values = make([]*template.Template, len(t.Columns))
+	for i, column := range t.Columns {
+		if column.Header == "" {
+			return fmt.Errorf("column %d without header", i+1)
+		}
+		value, err := template.New(column.Header).Funcs(templateFuncs).Option("missingkey=error").Parse(column.Value)
+		if err != nil {
+			return fmt.Errorf("column %q: %v", column.Header, err)
+		}
+		t.values[i] = value
+	}
+	return nil
+}
+
+// Header returns the column headers
+func (t *Template) Header() []string {
+	header := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		header[i] = column.Header
+	}
+	return header
+}
+
+// Row executes the column values with data, a value referring to a field data does not have is an error
+func (t *Template) Row(data any) ([]string, error) {
+	row := make([]string, len(t.values))
+	var buf bytes.Buffer
+	for i, value := range t.values {
+		buf.Reset()
+		err := value.Execute(&buf, data)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %v", t.Columns[i].Header, err)
+		}
+		row[i] = buf.String()
+	}
+	return row, nil
+}
+
+// Check executes the column values with data without side effects (counters are not incremented),
+// to find a misspelled field before the first row
+func (t *Template) Check(data any) error {
+	for i, value := range t.values {
+		check, err := value.Clone()
+		if err != nil {
+			return err
+		}
+		err = check.Funcs(checkFuncs).Execute(io.Discard, data)
+		if err != nil {
+			return fmt.Errorf("column %q: %v", t.Columns[i].Header, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// legacyLayout is the local time layout of the files written by older versions
+const legacyLayout = "2006-01-02 15:04:05"
+
+// timeFormats are the names accepted by SetTimeFormat besides a Go layout
+var timeFormats = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"datetime":    legacyLayout,
+}
+
+var (
+	location   = time.UTC
+	timeLayout = time.RFC3339
+)
+
+// SetTimeFormat sets the time zone ("UTC", "Local" or an IANA name such as "Europe/Berlin") and the
+// layout of exported timestamps. The layout is rfc3339, rfc3339nano, datetime (2006-01-02 15:04:05)
+// or a Go reference layout. The default is RFC3339 in UTC.
+func SetTimeFormat(zone, format string) error {
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return fmt.Errorf("invalid time zone %q: %v", zone, err)
+	}
+	layout, ok := timeFormats[strings.ToLower(format)]
+	if !ok {
+		layout = format
+	}
+	if layout == "" || time.Unix(0, 0).Format(layout) == layout {
+		return fmt.Errorf("invalid time format %q, expected rfc3339, rfc3339nano, datetime or a Go layout", format)
+	}
+	location, timeLayout = loc, layout
+	return nil
+}
+
+// Now returns the current time in the export time zone
+func Now() time.Time {
+	return time.Now().In(location)
+}
+
+// Location returns the export time zone
+func Location() *time.Location {
+	return location
+}
+
+// FormatTime formats t in the export time zone and layout
+func FormatTime(t time.Time) string {
+	return t.In(location).Format(timeLayout)
+}
+
+// ParseTime parses a timestamp of an export, written with the current format, as RFC3339 or in the
+// local time layout of older versions
+func ParseTime(s string) (time.Time, error) {
+	t, err := time.ParseInLocation(timeLayout, s, location)
+	if err == nil {
+		return t, nil
+	}
+	if t, rfcErr := time.Parse(time.RFC3339Nano, s); rfcErr == nil {
+		return t, nil
+	}
+	if t, legacyErr := time.ParseInLocation(legacyLayout, s, time.Local); legacyErr == nil {
+		return t, nil
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,11 @@
+//go:build boringcrypto
+
+package fips
+
+import (
+	"crypto/boring"
+	// restricts TLS (server mode) to FIPS approved versions, cipher suites and curves
+	_ "crypto/tls/fipsonly"
+)
+
+var validated = boring.Enabled()
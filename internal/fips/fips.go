@@ -0,0 +1,54 @@
+// Package fips restricts key generation and hashing to FIPS 140-3 approved primitives, for
+// provisioning environments which require it. FIPS mode is enabled at runtime with Enable, and is
+// always enabled in binaries built with GOEXPERIMENT=boringcrypto, whose cryptography is the
+// validated BoringCrypto module (see Validated).
+package fips
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotApproved is returned in FIPS mode for primitives which are not FIPS approved
+var ErrNotApproved = errors.New("not FIPS approved")
+
+// Primitives checked by Check
+const (
+	SHA256  = "SHA-256"
+	AESGCM  = "AES-GCM"
+	P256    = "ECDH P-256"
+	X25519  = "X25519"
+	Ed25519 = "Ed25519"
+	// Argon2id hashes operator PINs, PBKDF2 with HMAC-SHA256 in FIPS mode
+	Argon2id = "Argon2id"
+	PBKDF2   = "PBKDF2-HMAC-SHA256"
+)
+
+// approved are the primitives of the tool which the validated module provides
+var approved = map[string]bool{SHA256: true, AESGCM: true, P256: true, PBKDF2: true}
+
+var enabled = validated
+
+// Enable enables FIPS mode
+func Enable() {
+	enabled = true
+}
+
+// Enabled reports whether FIPS mode is enabled
+func Enabled() bool {
+	return enabled
+}
+
+// Validated reports whether the cryptography of the binary is the validated BoringCrypto module.
+// Without it FIPS mode restricts the primitives, but their implementation is not validated.
+func Validated() bool {
+	return validated
+}
+
+// Check returns ErrNotApproved for a primitive which is not approved when FIPS mode is enabled
+func Check(primitive string) error {
+	if enabled && !approved[primitive] {
+		return fmt.Errorf("%s: %w, not available in FIPS mode", primitive, ErrNotApproved)
+	}
+	return nil
+}
@@ -0,0 +1,5 @@
+//go:build !boringcrypto
+
+package fips
+
+const validated = false
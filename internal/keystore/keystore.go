@@ -0,0 +1,212 @@
+// Package keystore writes the keys programmed at a contract manufacturer into a sealed keystore file
+// which only the holder of the keystore key can open. Every record is sealed on its own with an
+// ephemeral key of the curve of the keystore key (X25519, P-256 in FIPS mode), so the station
+// writing the keystore needs the public key only and can not read back what it wrote.
+package keystore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/fips"
+)
+
+// magic prefixes every sealed line, it is authenticated as additional data
+const magic = "HIDNFCK1:"
+
+// keyLabel separates the record keys from other uses of the ECDH shared secret
+const keyLabel = "hidnfc-keystore\x00"
+
+// p256Prefix marks P-256 keys in key files, keys without it are X25519
+const p256Prefix = "p256:"
+
+// ErrOpen is returned for records which can not be opened: a wrong key or a tampered line
+var ErrOpen = errors.New("failed to open keystore record (wrong key or tampered file)")
+
+// GenerateKey writes a new keystore key (hex X25519 private key, "p256:" and a hex P-256 private key
+// in FIPS mode) to path and its public key to path.pub. The public key goes to the stations, the
+// private key stays with whoever opens the keystore.
+func GenerateKey(path string) error {
+	curve, prefix := ecdh.X25519(), ""
+	if fips.Enabled() {
+		curve, prefix = ecdh.P256(), p256Prefix
+	}
+	private, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path, []byte(prefix+hex.EncodeToString(private.Bytes())+"\n"), 0600)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+".pub", []byte(prefix+hex.EncodeToString(private.PublicKey().Bytes())+"\n"), 0644)
+}
+
+// LoadPublicKey reads a public key written by GenerateKey
+func LoadPublicKey(path string) (*ecdh.PublicKey, error) {
+	curve, data, err := readHexKey(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := curve.NewPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return key, nil
+}
+
+// LoadPrivateKey reads a keystore key written by GenerateKey
+func LoadPrivateKey(path string) (*ecdh.PrivateKey, error) {
+	curve, data, err := readHexKey(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := curve.NewPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return key, nil
+}
+
+// readHexKey reads a key file, X25519 keys are refused in FIPS mode
+func readHexKey(path string) (ecdh.Curve, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	text, p256 := strings.CutPrefix(strings.TrimSpace(string(data)), p256Prefix)
+	curve, primitive := ecdh.X25519(), fips.X25519
+	if p256 {
+		curve, primitive = ecdh.P256(), fips.P256
+	}
+	err = fips.Check(primitive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w, generate a new keystore key in FIPS mode", path, err)
+	}
+	key, err := hex.DecodeString(text)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: expected a hex encoded key", path)
+	}
+	return curve, key, nil
+}
+
+// recordAEAD derives the AES-256-GCM key of a record from the ECDH shared secret
+func recordAEAD(shared []byte, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write([]byte(keyLabel))
+	h.Write(shared)
+	h.Write(ephemeral.Bytes())
+	h.Write(recipient.Bytes())
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts a record for the holder of the private key of recipient. The line is magic followed
+// by the base64 of the ephemeral public key, the nonce and the sealed record.
+func Seal(recipient *ecdh.PublicKey, record []byte) ([]byte, error) {
+	ephemeral, err := recipient.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := recordAEAD(shared, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := append(ephemeral.PublicKey().Bytes(), nonce...)
+	sealed = aead.Seal(sealed, nonce, record, []byte(magic))
+	return []byte(magic + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Open decrypts a line written by Seal
+func Open(private *ecdh.PrivateKey, line []byte) ([]byte, error) {
+	text, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte(magic))
+	if !ok {
+		return nil, fmt.Errorf("not a keystore record")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore record: %v", err)
+	}
+	// the ephemeral public key has the size of the keystore public key
+	size := len(private.PublicKey().Bytes())
+	if len(sealed) < size+12 {
+		return nil, ErrOpen
+	}
+	ephemeral, err := private.Curve().NewPublicKey(sealed[:size])
+	if err != nil {
+		return nil, ErrOpen
+	}
+	shared, err := private.ECDH(ephemeral)
+	if err != nil {
+		return nil, ErrOpen
+	}
+	aead, err := recordAEAD(shared, ephemeral, private.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	header := size + aead.NonceSize()
+	if len(sealed) < header+aead.Overhead() {
+		return nil, ErrOpen
+	}
+	record, err := aead.Open(nil, sealed[size:header], sealed[header:], []byte(magic))
+	if err != nil {
+		return nil, ErrOpen
+	}
+	return record, nil
+}
+
+// Append seals a record and appends it to the keystore file at path
+func Append(path string, recipient *ecdh.PublicKey, record []byte) error {
+	line, err := Seal(recipient, record)
+	if err != nil {
+		return fmt.Errorf("failed to seal keystore record: %w", err)
+	}
+	return export.AppendLine(path, line)
+}
+
+// ReadFile opens every record of the keystore file at path, in file order
+func ReadFile(path string, private *ecdh.PrivateKey) ([][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records [][]byte
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		record, err := Open(private, scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
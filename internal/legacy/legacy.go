@@ -0,0 +1,224 @@
+// Package legacy reads the device exports of the legacy .NET provisioning application (XML or CSV)
+// so the devices it programmed can be reworked with this tool.
+package legacy
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Device is a device of a legacy export, the hex fields are normalized to upper case without
+// separators. Fields missing from the export are empty.
+type Device struct {
+	// Line is the CSV line or the number of the XML record, for error messages
+	Line       int
+	Serial     string
+	UID        string
+	DevEUI     string
+	JoinEUI    string
+	JoinKey    string
+	NwkKey     string
+	DevAddr    string
+	Region     string
+	Firmware   string
+	Programmed time.Time
+	// Config is the configuration image (blocks 0-47) if the export holds it
+	Config []byte
+}
+
+// aliases maps the normalized column and element names of the legacy exports (see normalize) to the
+// fields of Device. The legacy application used LoRaWAN 1.0 names, AppEUI and AppKey are the JoinEUI
+// and the JoinKey.
+var aliases = map[string]string{
+	"serial": "serial", "serialnumber": "serial", "serialno": "serial",
+	"uid": "uid", "taguid": "uid", "nfcuid": "uid",
+	"deveui": "deveui", "deviceeui": "deveui",
+	"joineui": "joineui", "appeui": "joineui",
+	"joinkey": "joinkey", "appkey": "joinkey",
+	"nwkkey":  "nwkkey",
+	"devaddr": "devaddr", "deviceaddress": "devaddr",
+	"region": "region", "loraregion": "region", "band": "region",
+	"firmware": "firmware", "firmwareversion": "firmware", "fwversion": "firmware",
+	"programmed": "programmed", "programmedon": "programmed", "programdate": "programmed", "date": "programmed", "timestamp": "programmed",
+	"config": "config", "configuration": "config", "confighex": "config", "configdata": "config",
+}
+
+// timeLayouts are the date formats written by the legacy application, depending on the Windows locale
+var timeLayouts = []string{
+	time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "01/02/2006 15:04:05", "1/2/2006 3:04:05 PM",
+	"02.01.2006 15:04:05", "2006-01-02",
+}
+
+// normalize lower cases a column name and drops spaces, underscores and dashes
+func normalize(name string) string {
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+}
+
+// Read reads a legacy export, .xml files as XML and everything else as CSV
+func Read(path string) ([]Device, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".xml") {
+		return readXML(data)
+	}
+	return readCSV(data)
+}
+
+// readCSV reads a CSV export, the separator is a semicolon if the header has no comma (German and
+// French Windows locales)
+func readCSV(data []byte) ([]Device, error) {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	if !bytes.Contains(header, []byte(",")) && bytes.Contains(header, []byte(";")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	var devices []Device
+	for i, row := range rows[1:] {
+		values := make(map[string]string)
+		for column, name := range rows[0] {
+			if column < len(row) {
+				values[name] = row[column]
+			}
+		}
+		device, err := newDevice(i+2, values)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// xmlNode is any XML element
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",chardata"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+// readXML reads an XML export: every child of the root element is a device, its attributes and
+// child elements are the fields (as written by the .NET DataSet and XmlSerializer)
+func readXML(data []byte) ([]Device, error) {
+	var root xmlNode
+	err := xml.Unmarshal(data, &root)
+	if err != nil {
+		return nil, err
+	}
+	var devices []Device
+	for i, node := range root.Nodes {
+		values := make(map[string]string)
+		for _, attr := range node.Attrs {
+			values[attr.Name.Local] = attr.Value
+		}
+		for _, child := range node.Nodes {
+			values[child.XMLName.Local] = child.Content
+		}
+		device, err := newDevice(i+1, values)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// newDevice maps the fields of a record to a Device, unknown fields are ignored
+func newDevice(line int, values map[string]string) (Device, error) {
+	device := Device{Line: line}
+	for name, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		hexValue := strings.ToUpper(strings.NewReplacer("-", "", ":", "", " ", "", "0x", "", "0X", "").Replace(value))
+		switch aliases[normalize(name)] {
+		case "serial":
+			device.Serial = value
+		case "uid":
+			device.UID = strings.ToLower(hexValue)
+		case "deveui":
+			device.DevEUI = hexValue
+		case "joineui":
+			device.JoinEUI = hexValue
+		case "joinkey":
+			device.JoinKey = hexValue
+		case "nwkkey":
+			device.NwkKey = hexValue
+		case "devaddr":
+			device.DevAddr = hexValue
+		case "region":
+			device.Region = value
+		case "firmware":
+			device.Firmware = value
+		case "programmed":
+			t, err := parseTime(value)
+			if err != nil {
+				return device, fmt.Errorf("record %d: %v", line, err)
+			}
+			device.Programmed = t
+		case "config":
+			config, err := hex.DecodeString(hexValue)
+			if err != nil {
+				return device, fmt.Errorf("record %d: invalid configuration hex: %v", line, err)
+			}
+			device.Config = config
+		}
+	}
+	return device, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown date format %q", value)
+}
+
+// Validate checks the identity of the device: a DevEUI and JoinEUI of 16 and a JoinKey of 32 hex
+// characters, an optional NwkKey of 32 and DevAddr of 8
+func (d *Device) Validate() error {
+	fields := []struct {
+		name     string
+		value    string
+		size     int
+		optional bool
+	}{
+		{"DevEUI", d.DevEUI, 8, false},
+		{"JoinEUI", d.JoinEUI, 8, false},
+		{"JoinKey", d.JoinKey, 16, false},
+		{"NwkKey", d.NwkKey, 16, true},
+		{"DevAddr", d.DevAddr, 4, true},
+	}
+	for _, field := range fields {
+		if field.value == "" && field.optional {
+			continue
+		}
+		data, err := hex.DecodeString(field.value)
+		if err != nil || len(data) != field.size {
+			return fmt.Errorf("record %d: invalid %s %q, expected %d hex characters", d.Line, field.name, field.value, field.size*2)
+		}
+	}
+	return nil
+}
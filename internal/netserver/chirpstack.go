@@ -0,0 +1,77 @@
+package netserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// chirpStack is the REST API of ChirpStack v4 (chirpstack-rest-api)
+type chirpStack struct {
+	url   string
+	token string
+}
+
+func (c *chirpStack) Name() string {
+	return "ChirpStack " + c.url
+}
+
+func (c *chirpStack) headers() map[string]string {
+	// the REST API proxy hands Grpc-Metadata-* headers on as gRPC metadata
+	return map[string]string{
+		"Grpc-Metadata-Authorization": "Bearer " + c.token,
+		"Authorization":               "Bearer " + c.token,
+	}
+}
+
+func (c *chirpStack) Device(devEUI string) (*Device, error) {
+	devEUI = strings.ToLower(devEUI)
+	var device struct {
+		Device struct {
+			DevEUI  string `json:"devEui"`
+			JoinEUI string `json:"joinEui"`
+		} `json:"device"`
+	}
+	err := getJSON(fmt.Sprintf("%s/api/devices/%s", c.url, devEUI), c.headers(), &device)
+	if err != nil {
+		return nil, err
+	}
+	d := &Device{DevEUI: strings.ToUpper(device.Device.DevEUI), JoinEUI: strings.ToUpper(device.Device.JoinEUI)}
+
+	var keys struct {
+		DeviceKeys struct {
+			NwkKey string `json:"nwkKey"`
+			AppKey string `json:"appKey"`
+		} `json:"deviceKeys"`
+	}
+	err = getJSON(fmt.Sprintf("%s/api/devices/%s/keys", c.url, devEUI), c.headers(), &keys)
+	if errors.Is(err, ErrNotFound) {
+		// an ABP device or one whose keys were not set yet
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the keys: %w", err)
+	}
+	// ChirpStack keeps the root key of LoRaWAN 1.0 devices in nwkKey, appKey is only set for 1.1
+	d.AppKey, d.NwkKey = key(keys.DeviceKeys.AppKey), key(keys.DeviceKeys.NwkKey)
+	if d.AppKey == "" {
+		d.AppKey, d.NwkKey = d.NwkKey, ""
+	}
+	return d, nil
+}
+
+func (c *chirpStack) UpdateKeys(devEUI string, appKey string, nwkKey string) error {
+	// the root key of LoRaWAN 1.0 devices goes to nwkKey, see Device
+	keys := map[string]string{"nwkKey": strings.ToLower(nwkKey), "appKey": strings.ToLower(appKey)}
+	if nwkKey == "" {
+		keys["nwkKey"], keys["appKey"] = strings.ToLower(appKey), strings.Repeat("0", 32)
+	}
+	devEUI = strings.ToLower(devEUI)
+	body := map[string]any{"deviceKeys": keys}
+	return request(http.MethodPut, fmt.Sprintf("%s/api/devices/%s/keys", c.url, devEUI), c.headers(), body, nil)
+}
+
+func (c *chirpStack) DeleteDevice(devEUI string) error {
+	return request(http.MethodDelete, fmt.Sprintf("%s/api/devices/%s", c.url, strings.ToLower(devEUI)), c.headers(), nil, nil)
+}
@@ -0,0 +1,130 @@
+// Package netserver looks up the devices registered on a LoRaWAN network server over its REST API,
+// ChirpStack v4 or The Things Stack v3 (TTN), so the identity programmed into a tag can be checked
+// against its registration, updates the root keys of rekeyed tags and deletes the devices of
+// decommissioned tags.
+package netserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when the network server has no device with the DevEUI
+var ErrNotFound = errors.New("device not registered")
+
+// Device is a device registered on a network server, EUIs and keys as upper case hex. AppKey is the
+// root key LoRaWAN 1.0 devices join with (the AppKey of LoRaWAN 1.1), NwkKey the LoRaWAN 1.1 NwkKey.
+// Keys are empty when the token may not read them.
+type Device struct {
+	DevEUI  string
+	JoinEUI string
+	AppKey  string
+	NwkKey  string
+}
+
+// Server is a network server devices are looked up on
+type Server interface {
+	// Name describes the server, e.g. "ChirpStack https://ns.example.com:8090"
+	Name() string
+	// Device looks up the device with a DevEUI (16 hex characters), ErrNotFound if there is none
+	Device(devEUI string) (*Device, error)
+	// UpdateKeys sets the root keys of the device with a DevEUI, ErrNotFound if there is none. appKey
+	// and nwkKey are as in Device, nwkKey is empty for LoRaWAN 1.0 devices.
+	UpdateKeys(devEUI string, appKey string, nwkKey string) error
+	// DeleteDevice deletes the device with a DevEUI, ErrNotFound if there is none
+	DeleteDevice(devEUI string) error
+}
+
+// client is the HTTP client of the servers, an unreachable server fails the lookup after the timeout
+var client = &http.Client{Timeout: 15 * time.Second}
+
+// Open returns the network server of a -network-server spec: chirpstack:<url> for the REST API of
+// ChirpStack v4, or ttn:<url>,<application ID> for an application on The Things Stack v3. token is
+// the API key sent with every request.
+func Open(spec string, token string) (Server, error) {
+	kind, address, ok := strings.Cut(spec, ":")
+	if !ok || address == "" {
+		return nil, fmt.Errorf("invalid network server %q, expected chirpstack:<url> or ttn:<url>,<application ID>", spec)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("no API key for network server %s", spec)
+	}
+	switch strings.ToLower(kind) {
+	case "chirpstack":
+		return &chirpStack{url: strings.TrimRight(address, "/"), token: token}, nil
+	case "ttn":
+		url, application, ok := strings.Cut(address, ",")
+		if !ok || application == "" {
+			return nil, fmt.Errorf("invalid network server %q, expected ttn:<url>,<application ID>", spec)
+		}
+		return &thingsStack{url: strings.TrimRight(url, "/"), application: application, token: token}, nil
+	default:
+		return nil, fmt.Errorf("unknown network server %q, expected chirpstack or ttn", kind)
+	}
+}
+
+// getJSON gets url with the headers and decodes the JSON response into v, a 404 is ErrNotFound
+func getJSON(url string, headers map[string]string, v any) error {
+	return request(http.MethodGet, url, headers, nil, v)
+}
+
+// request sends a request to url, with body as JSON unless it is nil, and decodes the JSON response
+// into v unless v is nil, a 404 is ErrNotFound
+func request(method string, url string, headers map[string]string, body any, v any) error {
+	var content io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, content)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	response, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %s: %s", resp.Status, strings.TrimSpace(string(response)))
+	}
+	if v == nil {
+		return nil
+	}
+	err = json.Unmarshal(response, v)
+	if err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	return nil
+}
+
+// key normalizes a key of a response, a key of zeros is a key which is not set
+func key(value string) string {
+	if strings.Trim(value, "0") == "" {
+		return ""
+	}
+	return strings.ToUpper(value)
+}
@@ -0,0 +1,147 @@
+package netserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ttnPageSize is the number of devices listed per request when looking up a DevEUI
+const ttnPageSize = 500
+
+// thingsStack is an application on The Things Stack v3 (TTN). Devices are identified by a device
+// ID, the DevEUI is looked up in the device list of the application and the root keys are read
+// from the Join Server.
+type thingsStack struct {
+	url         string
+	application string
+	token       string
+}
+
+func (t *thingsStack) Name() string {
+	return fmt.Sprintf("The Things Stack %s application %s", t.url, t.application)
+}
+
+func (t *thingsStack) headers() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + t.token}
+}
+
+type ttnIdentifiers struct {
+	DeviceID string `json:"device_id"`
+	DevEUI   string `json:"dev_eui"`
+	JoinEUI  string `json:"join_eui"`
+}
+
+// find looks up the identifiers of the device with a DevEUI in the device list of the application
+func (t *thingsStack) find(devEUI string) (*ttnIdentifiers, error) {
+	application := url.PathEscape(t.application)
+	var ids *ttnIdentifiers
+	for page := 1; ids == nil; page++ {
+		var list struct {
+			EndDevices []struct {
+				IDs ttnIdentifiers `json:"ids"`
+			} `json:"end_devices"`
+		}
+		err := getJSON(fmt.Sprintf("%s/api/v3/applications/%s/devices?field_mask=ids&limit=%d&page=%d", t.url, application, ttnPageSize, page), t.headers(), &list)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the devices of application %s: %w", t.application, err)
+		}
+		for i := range list.EndDevices {
+			if strings.EqualFold(list.EndDevices[i].IDs.DevEUI, devEUI) {
+				ids = &list.EndDevices[i].IDs
+				break
+			}
+		}
+		if ids == nil && len(list.EndDevices) < ttnPageSize {
+			return nil, ErrNotFound
+		}
+	}
+	return ids, nil
+}
+
+func (t *thingsStack) Device(devEUI string) (*Device, error) {
+	ids, err := t.find(devEUI)
+	if err != nil {
+		return nil, err
+	}
+	application := url.PathEscape(t.application)
+	d := &Device{DevEUI: strings.ToUpper(ids.DevEUI), JoinEUI: strings.ToUpper(ids.JoinEUI)}
+
+	var keys struct {
+		RootKeys struct {
+			AppKey struct {
+				Key string `json:"key"`
+			} `json:"app_key"`
+			NwkKey struct {
+				Key string `json:"key"`
+			} `json:"nwk_key"`
+		} `json:"root_keys"`
+	}
+	err = getJSON(fmt.Sprintf("%s/api/v3/js/applications/%s/devices/%s?field_mask=root_keys.app_key.key,root_keys.nwk_key.key",
+		t.url, application, url.PathEscape(ids.DeviceID)), t.headers(), &keys)
+	if errors.Is(err, ErrNotFound) {
+		// the device joins through an external Join Server, or is an ABP device
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the keys of device %s: %w", ids.DeviceID, err)
+	}
+	d.AppKey, d.NwkKey = key(keys.RootKeys.AppKey.Key), key(keys.RootKeys.NwkKey.Key)
+	return d, nil
+}
+
+// UpdateKeys sets the root keys on the Join Server, the devices of the application must use the Join
+// Server of the cluster
+func (t *thingsStack) UpdateKeys(devEUI string, appKey string, nwkKey string) error {
+	ids, err := t.find(devEUI)
+	if err != nil {
+		return err
+	}
+	rootKeys := map[string]any{"app_key": map[string]string{"key": strings.ToUpper(appKey)}}
+	paths := []string{"root_keys.app_key.key"}
+	if nwkKey != "" {
+		rootKeys["nwk_key"] = map[string]string{"key": strings.ToUpper(nwkKey)}
+		paths = append(paths, "root_keys.nwk_key.key")
+	}
+	body := map[string]any{
+		"end_device": map[string]any{
+			"ids": map[string]any{
+				"device_id":       ids.DeviceID,
+				"dev_eui":         ids.DevEUI,
+				"join_eui":        ids.JoinEUI,
+				"application_ids": map[string]string{"application_id": t.application},
+			},
+			"root_keys": rootKeys,
+		},
+		"field_mask": map[string]any{"paths": paths},
+	}
+	err = request(http.MethodPut, fmt.Sprintf("%s/api/v3/js/applications/%s/devices/%s", t.url, url.PathEscape(t.application), url.PathEscape(ids.DeviceID)),
+		t.headers(), body, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update the keys of device %s: %w", ids.DeviceID, err)
+	}
+	return nil
+}
+
+// DeleteDevice deletes the device from the Join Server, Network Server and Application Server first
+// and from the Identity Server last, the order the console uses. A server without the device (e.g.
+// no Join Server registration for ABP devices) is skipped.
+func (t *thingsStack) DeleteDevice(devEUI string) error {
+	ids, err := t.find(devEUI)
+	if err != nil {
+		return err
+	}
+	application, device := url.PathEscape(t.application), url.PathEscape(ids.DeviceID)
+	for _, server := range []string{"js/", "ns/", "as/", ""} {
+		err = request(http.MethodDelete, fmt.Sprintf("%s/api/v3/%sapplications/%s/devices/%s", t.url, server, application, device), t.headers(), nil, nil)
+		if errors.Is(err, ErrNotFound) && server != "" {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete device %s: %w", ids.DeviceID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package nfc
+
+import (
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultBeaconTypes is the beacon type table built into the tool
+//
+//go:embed beacontypes.yaml
+var defaultBeaconTypes []byte
+
+type beaconTypeFile struct {
+	BeaconTypes []beaconTypeEntry `yaml:"beaconTypes"`
+}
+
+type beaconTypeEntry struct {
+	Type     string   `yaml:"type"`
+	Name     string   `yaml:"name"`
+	Image    string   `yaml:"image"`
+	Writable *bool    `yaml:"writable"`
+	Products []string `yaml:"products"`
+	Fields   []string `yaml:"fields"`
+	Required []string `yaml:"required"`
+	Blocks   string   `yaml:"blocks"`
+	Features []string `yaml:"features"`
+	BLE      bool     `yaml:"ble"`
+}
+
+var (
+	beaconTypesMu sync.RWMutex
+	beaconTypes   = map[string]BeaconInfo{}
+)
+
+func init() {
+	err := addBeaconTypes(defaultBeaconTypes)
+	if err != nil {
+		panic(fmt.Sprintf("built in beacon types: %v", err))
+	}
+}
+
+// AddBeaconType adds a beacon type, or replaces the one with the same code, so tags of a new SKU are
+// recognized. Writable types can be written with WriteSKU and are listed in BeaconTypes. Products,
+// Fields and Blocks restrict what is written to tags of the type, see SetBeaconTypeCheck.
+func AddBeaconType(info BeaconInfo, writable bool) error {
+	code, err := hex.DecodeString(info.BeaconType)
+	if err != nil || len(code) != 1 {
+		return fmt.Errorf("invalid beacon type %q, expected 2 hex characters", info.BeaconType)
+	}
+	if info.Name == "" {
+		return fmt.Errorf("beacon type %s has no name", info.BeaconType)
+	}
+	info.BeaconType = strings.ToUpper(info.BeaconType)
+	for _, field := range append(append([]string{}, info.Fields...), info.Required...) {
+		if !containsFold(identityFields, field) {
+			return fmt.Errorf("beacon type %s: unknown field %q, expected %s", info.BeaconType, field, strings.Join(identityFields, ", "))
+		}
+	}
+	for _, field := range info.Required {
+		if !info.HasField(field) {
+			return fmt.Errorf("beacon type %s: required field %s is not one of its fields", info.BeaconType, field)
+		}
+	}
+	for _, feature := range info.Features {
+		if !containsFold(beaconFeatures, feature) {
+			return fmt.Errorf("beacon type %s: unknown feature %q, expected %s", info.BeaconType, feature, strings.Join(beaconFeatures, ", "))
+		}
+	}
+	info.blocks, err = parseBlockRanges(info.Blocks)
+	if err != nil {
+		return fmt.Errorf("beacon type %s: %v", info.BeaconType, err)
+	}
+
+	beaconTypesMu.Lock()
+	defer beaconTypesMu.Unlock()
+	beaconTypes[info.BeaconType] = info
+	for i, beaconType := range BeaconTypes {
+		if beaconType == info.BeaconType {
+			if !writable {
+				BeaconTypes = append(BeaconTypes[:i:i], BeaconTypes[i+1:]...)
+			}
+			return nil
+		}
+	}
+	if writable {
+		BeaconTypes = append(BeaconTypes[:len(BeaconTypes):len(BeaconTypes)], info.BeaconType)
+	}
+	return nil
+}
+
+// LoadBeaconTypes adds the beacon types of a YAML file to the built in ones, replacing those with
+// the same code:
+//
+//	beaconTypes:
+//	  - type: "1A"
+//	    name: Sense Asset Mini
+//	    image: Sense_BLE_Small
+//	    writable: true
+//	    products: [Asset+]          # product command sets running on the type
+//	    fields: [deveui, joineui]   # LoRa identity fields: deveui, joineui, joinkey
+//	    required: [deveui]          # fields run-manifest must provision
+//	    blocks: 0-47,64-            # blocks settings may be written to
+//	    features: [gnss]            # classb, gnss, see Capabilities
+//	    ble: false                  # BLE beacon, takes iBeacon and Eddystone identities
+//
+// products, fields, blocks and features left out do not restrict, an empty list allows none.
+func LoadBeaconTypes(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	err = addBeaconTypes(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+func addBeaconTypes(data []byte) error {
+	file := beaconTypeFile{}
+	err := yaml.Unmarshal(data, &file)
+	if err != nil {
+		return fmt.Errorf("failed to parse beacon types: %v", err)
+	}
+	if len(file.BeaconTypes) == 0 {
+		return fmt.Errorf("no beaconTypes")
+	}
+	for _, entry := range file.BeaconTypes {
+		writable := entry.Writable == nil || *entry.Writable
+		info := BeaconInfo{BeaconType: entry.Type, Name: entry.Name, Image: entry.Image, Products: entry.Products,
+			Fields: entry.Fields, Required: entry.Required, Blocks: entry.Blocks, Features: entry.Features, BLEBeacon: entry.BLE}
+		err = AddBeaconType(info, writable)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getBeaconInfo(beaconType string) (*BeaconInfo, error) {
+	beaconTypesMu.RLock()
+	defer beaconTypesMu.RUnlock()
+	info, ok := beaconTypes[strings.ToUpper(beaconType)]
+	if !ok {
+		return nil, fmt.Errorf("unknown beacon type: %s", beaconType)
+	}
+	return &info, nil
+}
@@ -0,0 +1,97 @@
+package nfc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// namePadding fills the BLE local name blocks after the name, see SetNamePadding
+var namePadding byte = 0x00
+
+// SetNamePadding selects the byte padding BLE local names to the size of the name blocks: 0x00 (the
+// default, what ReadBLELocalName trims) or '0' (0x30) for installations relying on names padded with
+// '0' characters
+func SetNamePadding(padding byte) {
+	namePadding = padding
+}
+
+// NamePadding returns the byte BLE local names are padded with, see SetNamePadding
+func NamePadding() byte {
+	return namePadding
+}
+
+// ParseNamePadding parses the hex byte of -name-pad, e.g. 0x00 or 0x30
+func ParseNamePadding(value string) (byte, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), "0x"))
+	if err != nil || len(raw) != 1 {
+		return 0, fmt.Errorf("invalid name padding %q, expected a hex byte such as 0x00 or 0x30", value)
+	}
+	return raw[0], nil
+}
+
+// ParseText decodes the parameter of name and ID writes: hex:<hex digits> for raw bytes (e.g.
+// hex:53503430), a double quoted string with Go escapes or a single quoted string to keep spaces
+// at the ends, or plain text taken as is
+func ParseText(param string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(strings.ToLower(param), "hex:"):
+		raw, err := hex.DecodeString(strings.NewReplacer(" ", "", ":", "").Replace(param[len("hex:"):]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex text %q: %v", param, err)
+		}
+		return raw, nil
+	case len(param) >= 2 && param[0] == '"' && param[len(param)-1] == '"':
+		text, err := strconv.Unquote(param)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted text %s: %v", param, err)
+		}
+		return []byte(text), nil
+	case len(param) >= 2 && param[0] == '\'' && param[len(param)-1] == '\'':
+		return []byte(param[1 : len(param)-1]), nil
+	}
+	return []byte(param), nil
+}
+
+// FormatText is the inverse of ParseText: printable ASCII as is, anything else as hex:<hex digits>
+func FormatText(text []byte) string {
+	for _, c := range text {
+		if c < 0x20 || c > 0x7E {
+			return "hex:" + hex.EncodeToString(text)
+		}
+	}
+	return string(text)
+}
+
+// ReadBLELocalNameBytes reads the BLE local name blocks 22-23 as stored, padding included
+func (m *NfcCard) ReadBLELocalNameBytes() ([]byte, error) {
+	raw, err := m.ReadBlocks(22, 2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read BLE local name: %w", err)
+	}
+	return raw, nil
+}
+
+// WriteBLELocalNameBytes writes the BLE local name to blocks 22-23, padded with the name padding byte
+// (see SetNamePadding), and updates the CRC
+func (m *NfcCard) WriteBLELocalNameBytes(name []byte) error {
+	if len(name) == 0 {
+		return fmt.Errorf("empty BLE local name: %w", ErrInvalidLength)
+	}
+	if len(name) > BLE_NAME_MAX {
+		return fmt.Errorf("name too long, maximum %d bytes allowed", BLE_NAME_MAX)
+	}
+	padded := bytes.Repeat([]byte{namePadding}, BLE_NAME_MAX)
+	copy(padded, name)
+	m.log.Infof("BLE local name: %s\n", hex.EncodeToString(padded))
+
+	for i, block := range []int{22, 23} {
+		_, err := m.WriteBlock(block, hex.EncodeToString(padded[i*4:i*4+4]))
+		if err != nil {
+			return fmt.Errorf("failed to write block %d: %w", block, err)
+		}
+	}
+	return m.CalculateAndWriteCRC()
+}
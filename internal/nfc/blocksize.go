@@ -0,0 +1,46 @@
+package nfc
+
+import "fmt"
+
+// DEFAULT_BLOCK_SIZE is the block size of the M24LR tags of the Asset+ family in bytes
+const DEFAULT_BLOCK_SIZE = 4
+
+// MAX_BLOCK_SIZE is the largest block size ISO 15693 system information can report
+const MAX_BLOCK_SIZE = 32
+
+// blockSizeSetting is the block size set by SetBlockSize, 0 detects it per tag
+var blockSizeSetting = DEFAULT_BLOCK_SIZE
+
+// SetBlockSize sets the block size in bytes the block primitives (ReadBlock, WriteBlock,
+// ReadBlocks) use, e.g. 8 for ISO 15693 tags with 8 byte blocks. 0 uses the block size the system
+// information of every tag reports.
+func SetBlockSize(size int) error {
+	if size < 0 || size > MAX_BLOCK_SIZE {
+		return fmt.Errorf("invalid block size %d, expected 1 to %d bytes or 0 to detect it", size, MAX_BLOCK_SIZE)
+	}
+	blockSizeSetting = size
+	return nil
+}
+
+// BlockSize returns the block size of the tag in bytes: the size set with SetBlockSize, or the one
+// reported by the system information of the tag, read once per tag. Tags without system information
+// get DEFAULT_BLOCK_SIZE.
+func (m *NfcCard) BlockSize() int {
+	if blockSizeSetting > 0 {
+		return blockSizeSetting
+	}
+	if m.blockSize > 0 {
+		return m.blockSize
+	}
+	m.blockSize = DEFAULT_BLOCK_SIZE
+	info, err := m.ReadSystemInfo()
+	if err != nil {
+		m.log.Debugf("Block size unknown, using %d bytes: %v", DEFAULT_BLOCK_SIZE, err)
+		return m.blockSize
+	}
+	if info.BlockSize > 0 && info.BlockSize <= MAX_BLOCK_SIZE {
+		m.blockSize = info.BlockSize
+	}
+	m.log.Debugf("Block size %d bytes", m.blockSize)
+	return m.blockSize
+}
@@ -0,0 +1,81 @@
+package nfc
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// blockCache keeps the blocks a card read and wrote, see SetBlockCache
+var blockCache bool
+
+// SetBlockCache enables the block cache: every card keeps the blocks it read from and wrote to its
+// tag, so CalculateAndWriteCRC computes the CRC from the cache instead of reading the 48
+// configuration blocks again after every field write. Only blocks missing from the cache are read.
+// ValidateCRC always reads the tag.
+func SetBlockCache(on bool) {
+	blockCache = on
+}
+
+// cacheBlock records the content of a block read from or written to the tag
+func (m *NfcCard) cacheBlock(blockNumber int, data []byte) {
+	if !blockCache {
+		return
+	}
+	if m.cache == nil {
+		m.cache = make(map[int][]byte)
+	}
+	m.cache[blockNumber] = append([]byte(nil), data...)
+}
+
+// cacheHexBlock records a block given as hex, a block which does not decode to a full block is dropped
+func (m *NfcCard) cacheHexBlock(blockNumber int, block string) {
+	data, err := hex.DecodeString(block)
+	if err != nil || len(data) != m.BlockSize() {
+		m.uncacheBlock(blockNumber)
+		return
+	}
+	m.cacheBlock(blockNumber, data)
+}
+
+// uncacheBlock drops a block whose content is unknown, e.g. after a failed write
+func (m *NfcCard) uncacheBlock(blockNumber int) {
+	delete(m.cache, blockNumber)
+}
+
+// resetCache drops every cached block, e.g. when another tag is presented
+func (m *NfcCard) resetCache() {
+	m.cache = nil
+}
+
+// configurationForCRC returns blocks 0-47 for the CRC, from the cache when it is enabled. The range
+// spanning the blocks missing from the cache is read from the tag.
+func (m *NfcCard) configurationForCRC() ([]byte, error) {
+	if !blockCache {
+		return m.ReadConfigurationForCRC()
+	}
+	first, last := -1, -1
+	for block := 0; block < ASSET_PLUS_CONFIG_BLOCKS; block++ {
+		if _, ok := m.cache[block]; !ok {
+			if first < 0 {
+				first = block
+			}
+			last = block
+		}
+	}
+	if first >= 0 {
+		m.log.Debugf("Block cache misses blocks %d-%d, reading them", first, last)
+		_, err := m.ReadBlocks(first, last-first+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	data := make([]byte, 0, ASSET_PLUS_CONFIG_BLOCKS*4)
+	for block := 0; block < ASSET_PLUS_CONFIG_BLOCKS; block++ {
+		cached, ok := m.cache[block]
+		if !ok {
+			return nil, fmt.Errorf("block %d missing from the block cache", block)
+		}
+		data = append(data, cached...)
+	}
+	return data, nil
+}
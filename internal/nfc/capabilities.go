@@ -0,0 +1,156 @@
+package nfc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Features of a beacon type, see BeaconInfo.Features
+const (
+	FEATURE_CLASS_B = "classb" // LoRaWAN Class B (ping slots), blocks 29-30 of the Asset+ schema
+	FEATURE_GNSS    = "gnss"   // GNSS fixes, the GNSS settings of block 14
+)
+
+var beaconFeatures = []string{FEATURE_CLASS_B, FEATURE_GNSS}
+
+// BLE_NAME_MAX is the length of a BLE local name in bytes, blocks 22-23
+const BLE_NAME_MAX = 8
+
+// firmwareVersion returns the firmware version (x10) in byte 1 of a block 15, 0 when the
+// configuration is blank
+func firmwareVersion(block15 []byte) int {
+	if len(block15) < 2 || block15[1] == 0xFF {
+		return 0
+	}
+	return int(block15[1])
+}
+
+// Capabilities are the features of a tag, from its beacon type (SKU), firmware version and memory size
+type Capabilities struct {
+	// BeaconType is the beacon type of the tag, nil for blank tags and types not in the table
+	BeaconType *BeaconInfo `json:"-"`
+	// FirmwareVersion is the firmware version x10 (byte 1 of block 15), 0 for a blank configuration
+	FirmwareVersion int `json:"firmwareVersion"`
+	// Blocks is the memory size of the tag, 0 if the tag does not report it
+	Blocks int `json:"blocks"`
+
+	SupportsClassB bool `json:"supportsClassB"`
+	SupportsGNSS   bool `json:"supportsGNSS"`
+	// SenseRange is set for tags holding the Sense Range settings (range threshold, sensor period and
+	// offset) in blocks 9-10, see LoRaSettings.SenseRange
+	SenseRange bool `json:"senseRange"`
+}
+
+// Flags returns the names of the supported features, e.g. "supportsClassB"
+func (c *Capabilities) Flags() []string {
+	var flags []string
+	for _, f := range []struct {
+		name string
+		on   bool
+	}{
+		{"supportsClassB", c.SupportsClassB},
+		{"supportsGNSS", c.SupportsGNSS},
+		{"senseRange", c.SenseRange},
+	} {
+		if f.on {
+			flags = append(flags, f.name)
+		}
+	}
+	return flags
+}
+
+// HasFeature reports whether tags of the beacon type have a feature, e.g. FEATURE_CLASS_B
+func (b *BeaconInfo) HasFeature(feature string) bool {
+	return b.Features == nil || containsFold(b.Features, feature)
+}
+
+// SenseRange reports whether tags of the beacon type hold the Sense Range settings in blocks 9-10,
+// which other types use for their own settings. Only types listing the Sense Range product do, a type
+// without a products list, e.g. a blank tag, does not.
+func (b *BeaconInfo) SenseRange() bool {
+	return b.Products != nil && b.HasProduct("Sense Range")
+}
+
+// Capabilities probes the features of the tag: the beacon type and the firmware version of block 15
+// and the memory size of the system information. Beacon types left out of the table, and blank
+// tags, are assumed to have every feature their firmware and memory allow, but not the Sense Range
+// settings, which would reinterpret blocks 9-10 of another product.
+func (m *NfcCard) Capabilities() (*Capabilities, error) {
+	block, err := m.ReadBlocks(15, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read firmware version: %w", err)
+	}
+	caps := &Capabilities{FirmwareVersion: firmwareVersion(block)}
+	caps.BeaconType, err = m.TagBeaconType()
+	if err != nil {
+		return nil, err
+	}
+	caps.Blocks, err = m.Blocks()
+	if err != nil {
+		m.log.Debugf("Capabilities without the memory size: %v", err)
+		caps.Blocks = 0
+	}
+
+	feature := func(name string) bool {
+		return caps.BeaconType == nil || caps.BeaconType.HasFeature(name)
+	}
+	// the Class B settings are part of the Asset+ configuration, blocks 0-47 and the CRC
+	caps.SupportsClassB = feature(FEATURE_CLASS_B) && caps.Blocks > ASSET_PLUS_CRC_BLOCK
+	caps.SupportsGNSS = feature(FEATURE_GNSS)
+	caps.SenseRange = caps.BeaconType != nil && caps.BeaconType.SenseRange()
+	return caps, nil
+}
+
+// ValidateCapabilities checks Asset+ settings against the capabilities of the tag they are written
+// to and returns a SettingsConflict for each setting the tag does not support
+func ValidateCapabilities(s *DittoSettings, caps *Capabilities) []error {
+	var conflicts []error
+	name := "the tag"
+	if caps.BeaconType != nil {
+		name = fmt.Sprintf("beacon type %s (%s)", caps.BeaconType.BeaconType, caps.BeaconType.Name)
+	}
+	if s.ClassSelect == "Class B" && !caps.SupportsClassB {
+		conflicts = append(conflicts, &SettingsConflict{Fields: []string{"ClassSelect"},
+			Problem: "Class B selected, " + name + " does not support Class B", Fix: "select Class A or C"})
+	}
+	if (s.GNSSMin != 0 || s.GNSSMax != 0) && !caps.SupportsGNSS {
+		conflicts = append(conflicts, &SettingsConflict{Fields: []string{"GNSSMin", "GNSSMax"},
+			Problem: fmt.Sprintf("GNSS times %d/%d set, %s has no GNSS", s.GNSSMin, s.GNSSMax, name), Fix: "set GNSS min and max to 0"})
+	}
+	return conflicts
+}
+
+// ValidateImageCapabilities decodes the settings of a configuration image and checks them with
+// ValidateCapabilities
+func ValidateImageCapabilities(image []byte, caps *Capabilities) ([]error, error) {
+	settings, err := decodeImageSettings(image)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateCapabilities(settings, caps), nil
+}
+
+// String describes the capabilities, e.g. "firmware 8.1, 64 blocks: supportsClassB, supportsGNSS"
+func (c *Capabilities) String() string {
+	flags := c.Flags()
+	if len(flags) == 0 {
+		flags = []string{"none"}
+	}
+	return fmt.Sprintf("firmware %.1f, %d blocks: %s", float64(c.FirmwareVersion)/10, c.Blocks, strings.Join(flags, ", "))
+}
+
+// ProbeCapabilities probes the capabilities of the tag connected through transport without an
+// NfcCard of its own, for server mode where the cards live on the client side. No events are
+// published and the transport is left connected.
+func ProbeCapabilities(transport CardTransport) (*Capabilities, error) {
+	m24lr := &NfcCard{
+		Reader:      transport,
+		log:         defaultLogger,
+		apduTimeout: DefaultAPDUTimeout,
+	}
+	err := m24lr.getUID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get UID: %w", err)
+	}
+	return m24lr.Capabilities()
+}
@@ -0,0 +1,119 @@
+package nfc
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// readChunks are the read strategies in blocks per APDU, largest first
+var readChunks = []int{32, 4, 1}
+
+// readChunk is the number of blocks read per APDU set by SetReadChunk, 0 negotiates it per card
+var readChunk int
+
+// SetReadChunk forces the blocks read per APDU for multi-block reads to 1, 4 or 32, 0 detects the
+// largest read the reader supports
+func SetReadChunk(blocks int) error {
+	switch blocks {
+	case 0, 1, 4, 32:
+		readChunk = blocks
+		return nil
+	default:
+		return fmt.Errorf("invalid read chunk %d, expected 1, 4 or 32 blocks", blocks)
+	}
+}
+
+// ReadChunk returns the number of blocks the card reads per APDU, negotiating it with the reader on
+// first use: the largest strategy whose probe read from block 0 returns the full length wins. Readers
+// without ReadMultiple read single blocks.
+func (m *NfcCard) ReadChunk() int {
+	if readChunk > 0 && m.Quirks().ReadMultiple != "" {
+		return readChunk
+	}
+	if m.readChunk > 0 {
+		return m.readChunk
+	}
+	m.readChunk = 1
+	if m.Quirks().ReadMultiple == "" {
+		return m.readChunk
+	}
+	for _, blocks := range readChunks {
+		if blocks == 1 {
+			break
+		}
+		_, err := m.readMultiple(0, blocks)
+		if err == nil {
+			m.readChunk = blocks
+			break
+		}
+		m.log.Debugf("Reader does not read %d blocks per APDU: %v", blocks, err)
+	}
+	m.log.Debugf("Reading %d blocks per APDU", m.readChunk)
+	return m.readChunk
+}
+
+// ReadBlocks reads count blocks from first in chunks of ReadChunk blocks, a chunk which fails is
+// read again block by block
+func (m *NfcCard) ReadBlocks(first int, count int) ([]byte, error) {
+	data := make([]byte, 0, count*m.BlockSize())
+	chunk := m.ReadChunk()
+	for block := first; block < first+count; block += chunk {
+		n := chunk
+		if remaining := first + count - block; n > remaining {
+			n = remaining
+		}
+		if n > 1 {
+			blocks, err := m.readMultiple(block, n)
+			if err == nil {
+				data = append(data, blocks...)
+				continue
+			}
+			m.log.Debugf("Failed to read blocks %d-%d at once, reading them one by one: %v", block, block+n-1, err)
+		}
+		for i := block; i < block+n; i++ {
+			hexBlock, err := m.ReadBlock(i)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read block %d: %w", i, err)
+			}
+			bytes, err := hex.DecodeString(hexBlock)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode block %d data: %w", i, err)
+			}
+			data = append(data, bytes...)
+		}
+	}
+	return data, nil
+}
+
+// readMultiple reads blocks blocks from first with a single APDU
+func (m *NfcCard) readMultiple(first int, blocks int) ([]byte, error) {
+	quirks := m.Quirks()
+	last := first + blocks - 1
+	if first < 0 || quirks.MaxBlocks > 0 && last >= quirks.MaxBlocks {
+		return nil, fmt.Errorf("blocks %d-%d cannot be addressed by %s: %w", first, last, quirks.Name, ErrNotSupported)
+	}
+	if last >= EXTENDED_ADDRESS_BLOCK && quirks.Transparent {
+		// read one by one with the protocol extension, see extendedBlock
+		return nil, fmt.Errorf("blocks %d-%d need extended addressing: %w", first, last, ErrNotSupported)
+	}
+	size := m.BlockSize()
+	if blocks*size > 256 {
+		return nil, fmt.Errorf("%d blocks of %d bytes exceed a single read: %w", blocks, size, ErrNotSupported)
+	}
+	// Le is a single byte, 0 requests 256 bytes
+	resp, err := m.transmit(fmt.Sprintf(quirks.ReadMultiple, first, byte(blocks*size)), 0x9000)
+	if err != nil {
+		return nil, err
+	}
+	data, err := hex.DecodeString(resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != blocks*size {
+		return nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidLength, blocks*size, len(data))
+	}
+	for i := 0; i < blocks; i++ {
+		m.cacheBlock(first+i, data[i*size:(i+1)*size])
+	}
+	return data, nil
+}
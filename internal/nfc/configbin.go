@@ -0,0 +1,243 @@
+package nfc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CONFIG_BIN_SIZE is the size of a plain configuration image: 48 blocks of 4 bytes
+const CONFIG_BIN_SIZE = 192
+
+// CONFIG_BIN_MAGIC starts an encrypted configuration image. It is followed by the 12 byte
+// AES-GCM nonce and the sealed plain image, the magic is authenticated as additional data.
+const CONFIG_BIN_MAGIC = "HIDNFCE1"
+
+// configBinSignaturePrefix separates signatures of configuration images from other Ed25519 signatures
+const configBinSignaturePrefix = "hidnfc-config-image\x00"
+
+// ErrBadSignature is returned for configuration images whose signature does not verify
+var ErrBadSignature = errors.New("configuration image signature is invalid")
+
+// ErrWrongProduct is returned when a configuration image is made for another product than the tag
+var ErrWrongProduct = errors.New("configuration image is for another product")
+
+// ErrConfigKeyRequired is returned when reading an encrypted configuration image without a key
+var ErrConfigKeyRequired = errors.New("configuration image is encrypted, a configuration key is required")
+
+// configBinKey encrypts generated configuration images and decrypts encrypted ones, see SetConfigBinKey
+var configBinKey []byte
+
+// SetConfigBinKey sets the AES key (16, 24 or 32 bytes) of encrypted configuration images. With a key
+// set GenerateConfigBin writes encrypted images, nil writes plain ones.
+func SetConfigBinKey(key []byte) error {
+	if key != nil {
+		_, err := aes.NewCipher(key)
+		if err != nil {
+			return fmt.Errorf("invalid configuration key: %w", err)
+		}
+	}
+	configBinKey = key
+	return nil
+}
+
+// IsEncryptedConfigBin reports whether data is an encrypted configuration image
+func IsEncryptedConfigBin(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(CONFIG_BIN_MAGIC))
+}
+
+func configBinAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptConfigBin seals a plain configuration image with key
+func EncryptConfigBin(data []byte, key []byte) ([]byte, error) {
+	aead, err := configBinAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out := append([]byte(CONFIG_BIN_MAGIC), nonce...)
+	return aead.Seal(out, nonce, data, []byte(CONFIG_BIN_MAGIC)), nil
+}
+
+// DecryptConfigBin opens an encrypted configuration image with key
+func DecryptConfigBin(data []byte, key []byte) ([]byte, error) {
+	if key == nil {
+		return nil, ErrConfigKeyRequired
+	}
+	aead, err := configBinAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := len(CONFIG_BIN_MAGIC) + aead.NonceSize()
+	if !IsEncryptedConfigBin(data) || len(data) < header+aead.Overhead() {
+		return nil, fmt.Errorf("not an encrypted configuration image: %w", ErrInvalidLength)
+	}
+	plain, err := aead.Open(nil, data[len(CONFIG_BIN_MAGIC):header], data[header:], []byte(CONFIG_BIN_MAGIC))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt configuration image (wrong key or tampered file): %w", err)
+	}
+	return plain, nil
+}
+
+// LoadConfigBin reads a configuration image file, decrypting it with the key of SetConfigBinKey if
+// it is encrypted
+func LoadConfigBin(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return DecodeConfigBin(data)
+}
+
+// SaveConfigBin writes a plain configuration image to a file, encrypted with the key of
+// SetConfigBinKey if one is set
+func SaveConfigBin(path string, image []byte) error {
+	if len(image) != CONFIG_BIN_SIZE {
+		return fmt.Errorf("configuration image has %d bytes, expected %d: %w", len(image), CONFIG_BIN_SIZE, ErrInvalidLength)
+	}
+	var err error
+	if configBinKey != nil {
+		image, err = EncryptConfigBin(image, configBinKey)
+		if err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, image, 0644)
+}
+
+// BlankDeviceBlocks returns a copy of a configuration image with the device specific blocks set to
+// 0xFF, as ReadConfigBin reads them, so the image can be programmed into other tags
+func BlankDeviceBlocks(image []byte) ([]byte, error) {
+	if len(image) != CONFIG_BIN_SIZE {
+		return nil, fmt.Errorf("configuration image has %d bytes, expected %d: %w", len(image), CONFIG_BIN_SIZE, ErrInvalidLength)
+	}
+	out := make([]byte, CONFIG_BIN_SIZE)
+	copy(out, image)
+	for block := 0; block < ASSET_PLUS_CONFIG_BLOCKS; block++ {
+		if IsDeviceSpecificBlock(block) {
+			copy(out[block*4:block*4+4], []byte{0xFF, 0xFF, 0xFF, 0xFF})
+		}
+	}
+	// the first two bytes of the BLE MAC LSB block hold the end of the BLE MAC
+	out[ASSET_PLUS_BLE_MAC_LSB*4], out[ASSET_PLUS_BLE_MAC_LSB*4+1] = 0xFF, 0xFF
+	return out, nil
+}
+
+// DecodeConfigBin returns the plain image of the contents of a configuration image file
+func DecodeConfigBin(data []byte) ([]byte, error) {
+	var err error
+	if IsEncryptedConfigBin(data) {
+		data, err = DecryptConfigBin(data, configBinKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(data) != CONFIG_BIN_SIZE {
+		return nil, fmt.Errorf("invalid file size: expected %d bytes, got %d bytes", CONFIG_BIN_SIZE, len(data))
+	}
+	return data, nil
+}
+
+// SignConfigBin returns the signature of a configuration image file (plain or encrypted)
+func SignConfigBin(data []byte, key ed25519.PrivateKey) []byte {
+	return ed25519.Sign(key, append([]byte(configBinSignaturePrefix), data...))
+}
+
+// VerifyConfigBin verifies the signature of a configuration image file
+func VerifyConfigBin(data []byte, signature []byte, key ed25519.PublicKey) error {
+	if !ed25519.Verify(key, append([]byte(configBinSignaturePrefix), data...), signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// WriteSignature writes the signature of a configuration image to path as hex
+func WriteSignature(path string, signature []byte) error {
+	return os.WriteFile(path, []byte(hex.EncodeToString(signature)+"\n"), 0644)
+}
+
+// ReadSignature reads a signature written by WriteSignature
+func ReadSignature(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("%s: expected a hex encoded signature", path)
+	}
+	return signature, nil
+}
+
+// WriteConfigBin applies a plain configuration image to the tag and updates the CRC. Device specific
+// blocks (see IsDeviceSpecificBlock) and block 15 (hardware and firmware version, device ID) are
+// kept, blocks already holding the image data are not rewritten. The image must be for the product
+// (device ID) of the tag unless the tag is blank.
+// Returns the blocks written.
+func (m *NfcCard) WriteConfigBin(config []byte) ([]int, error) {
+	if len(config) != CONFIG_BIN_SIZE {
+		return nil, fmt.Errorf("configuration image has %d bytes, expected %d: %w", len(config), CONFIG_BIN_SIZE, ErrInvalidLength)
+	}
+	current, err := m.readBlocks(configBlocks())
+	if err != nil {
+		return nil, err
+	}
+	version, err := hex.DecodeString(current[ASSET_PLUS_VERSION_BLOCK])
+	if err != nil || len(version) != 4 {
+		return nil, fmt.Errorf("invalid block %d %q", ASSET_PLUS_VERSION_BLOCK, current[ASSET_PLUS_VERSION_BLOCK])
+	}
+	err = checkImageProduct(version, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var written []int
+	for block := 0; block < ASSET_PLUS_CONFIG_BLOCKS; block++ {
+		if IsDeviceSpecificBlock(block) || block == ASSET_PLUS_VERSION_BLOCK {
+			continue
+		}
+		data := fmt.Sprintf("%X", config[block*4:block*4+4])
+		if block == ASSET_PLUS_BLE_MAC_LSB {
+			// keep the end of the BLE MAC in the first two bytes
+			data = strings.ToUpper(current[block][:4]) + data[4:]
+		}
+		if strings.EqualFold(current[block], data) {
+			continue
+		}
+		_, err = m.WriteBlock(block, data)
+		if err != nil {
+			return written, fmt.Errorf("failed to write block %d: %w", block, err)
+		}
+		written = append(written, block)
+	}
+	if len(written) == 0 {
+		return written, nil
+	}
+	return written, m.CalculateAndWriteCRC()
+}
+
+// configBlocks returns the blocks covered by the CRC
+func configBlocks() []int {
+	blocks := make([]int, ASSET_PLUS_CONFIG_BLOCKS)
+	for i := range blocks {
+		blocks[i] = i
+	}
+	return blocks
+}
@@ -0,0 +1,119 @@
+package nfc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ParseMode selects how fields which cannot be decoded are handled by the settings parsers
+type ParseMode int
+
+const (
+	// ParseLenient decodes every field it can and reports the failing ones as warnings
+	ParseLenient ParseMode = iota
+	// ParseStrict fails on the first field which cannot be decoded
+	ParseStrict
+)
+
+// blockDecoder extracts fields from the hex strings of the blocks read from a tag and records
+// every decode failure with its block number and offset
+type blockDecoder struct {
+	blocks   map[int]string
+	mode     ParseMode
+	err      error
+	warnings []error
+}
+
+func newBlockDecoder(blocks map[int]string, mode ParseMode) *blockDecoder {
+	return &blockDecoder{blocks: blocks, mode: mode}
+}
+
+func (d *blockDecoder) fail(block, offset int, err error) {
+	parseErr := &ParseError{Block: block, Offset: offset, Err: err}
+	if d.mode == ParseStrict {
+		if d.err == nil {
+			d.err = parseErr
+		}
+		return
+	}
+	d.warnings = append(d.warnings, parseErr)
+}
+
+// field returns the hex characters [start:end] of block
+func (d *blockDecoder) field(block, start, end int) (string, bool) {
+	if d.err != nil {
+		return "", false
+	}
+	data, ok := d.blocks[block]
+	if !ok {
+		d.fail(block, start, errors.New("block not read"))
+		return "", false
+	}
+	if start < 0 || start > end || end > len(data) {
+		d.fail(block, start, fmt.Errorf("%w: field [%d:%d] of %d characters", ErrInvalidLength, start, end, len(data)))
+		return "", false
+	}
+	return data[start:end], true
+}
+
+// str returns the raw hex characters [start:end] of block, or "" if they are not available
+func (d *blockDecoder) str(block, start, end int) string {
+	s, _ := d.field(block, start, end)
+	return s
+}
+
+// hex decodes the characters [start:end] of block as a big endian hex number
+func (d *blockDecoder) hex(block, start, end int) int64 {
+	s, ok := d.field(block, start, end)
+	if !ok {
+		return 0
+	}
+	value, err := strconv.ParseInt(s, 16, 64)
+	if err != nil {
+		d.fail(block, start, err)
+		return 0
+	}
+	return value
+}
+
+// hexLE decodes the characters [start:end] of block as a little endian hex number
+func (d *blockDecoder) hexLE(block, start, end int) int64 {
+	s, ok := d.field(block, start, end)
+	if !ok {
+		return 0
+	}
+	if len(s)%2 != 0 {
+		d.fail(block, start, fmt.Errorf("%w: odd number of hex characters", ErrInvalidLength))
+		return 0
+	}
+	reversed := make([]byte, 0, len(s))
+	for i := len(s) - 2; i >= 0; i -= 2 {
+		reversed = append(reversed, s[i], s[i+1])
+	}
+	value, err := strconv.ParseInt(string(reversed), 16, 64)
+	if err != nil {
+		d.fail(block, start, err)
+		return 0
+	}
+	return value
+}
+
+// dec decodes the characters [start:end] of block as a decimal number
+func (d *blockDecoder) dec(block, start, end int) int {
+	s, ok := d.field(block, start, end)
+	if !ok {
+		return 0
+	}
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		d.fail(block, start, err)
+		return 0
+	}
+	return value
+}
+
+// result returns the collected warnings, or the first failure in strict mode
+func (d *blockDecoder) result() ([]error, error) {
+	return d.warnings, d.err
+}
@@ -0,0 +1,283 @@
+package nfc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// blockEncoder is the inverse of blockDecoder, it writes fields into a copy of the hex strings of
+// the blocks read from a tag. Bits which are not covered by a field keep their original value.
+type blockEncoder struct {
+	blocks map[int]string
+	err    error
+}
+
+func newBlockEncoder(base map[int]string) *blockEncoder {
+	blocks := make(map[int]string, len(base))
+	for block, data := range base {
+		blocks[block] = strings.ToLower(data)
+	}
+	return &blockEncoder{blocks: blocks}
+}
+
+func (e *blockEncoder) fail(block, offset int, err error) {
+	if e.err == nil {
+		e.err = &ParseError{Block: block, Offset: offset, Err: err}
+	}
+}
+
+// current returns the hex characters [start:end] of block, blocks missing from the base are zero
+func (e *blockEncoder) current(block, start, end int) string {
+	data, ok := e.blocks[block]
+	if !ok {
+		data = "00000000"
+		e.blocks[block] = data
+	}
+	if start < 0 || start > end || end > len(data) {
+		e.fail(block, start, fmt.Errorf("%w: field [%d:%d] of %d characters", ErrInvalidLength, start, end, len(data)))
+		return ""
+	}
+	return data[start:end]
+}
+
+// str replaces the hex characters [start:end] of block with s
+func (e *blockEncoder) str(block, start, end int, s string) {
+	e.current(block, start, end)
+	if e.err != nil {
+		return
+	}
+	if len(s) != end-start {
+		e.fail(block, start, fmt.Errorf("%w: %q does not fit field [%d:%d]", ErrInvalidLength, s, start, end))
+		return
+	}
+	data := e.blocks[block]
+	e.blocks[block] = data[:start] + strings.ToLower(s) + data[end:]
+}
+
+// hex writes value into the characters [start:end] of block as a big endian hex number
+func (e *blockEncoder) hex(block, start, end int, value int64) {
+	width := end - start
+	if value < 0 || width < 16 && value >= int64(1)<<(4*width) {
+		e.fail(block, start, fmt.Errorf("value %d does not fit in %d hex characters", value, width))
+		return
+	}
+	e.str(block, start, end, fmt.Sprintf("%0*x", width, value))
+}
+
+// hexLE writes value into the characters [start:end] of block as a little endian hex number
+func (e *blockEncoder) hexLE(block, start, end int, value int64) {
+	width := end - start
+	if width%2 != 0 {
+		e.fail(block, start, fmt.Errorf("%w: odd number of hex characters", ErrInvalidLength))
+		return
+	}
+	if value < 0 || width < 16 && value >= int64(1)<<(4*width) {
+		e.fail(block, start, fmt.Errorf("value %d does not fit in %d hex characters", value, width))
+		return
+	}
+	s := ""
+	for i := 0; i < width; i += 2 {
+		s += fmt.Sprintf("%02x", byte(value>>(4*i)))
+	}
+	e.str(block, start, end, s)
+}
+
+// mapped writes the field [start:end] of block so that decode returns want. The current value is
+// kept when it already decodes to want, otherwise the lowest hex value which does is used.
+func (e *blockEncoder) mapped(block, start, end int, want string, decode func(string) string) {
+	current := e.current(block, start, end)
+	if e.err != nil || decode(current) == want {
+		return
+	}
+	width := end - start
+	for value := int64(0); value < int64(1)<<(4*width); value++ {
+		candidate := fmt.Sprintf("%0*x", width, value)
+		if decode(candidate) == want {
+			e.str(block, start, end, candidate)
+			return
+		}
+	}
+	e.fail(block, start, fmt.Errorf("cannot encode %q", want))
+}
+
+// bits sets the flag bits [first:first+len(want)] of the byte [start:start+2] of block so that
+// decode, which receives the bits least significant first, returns want
+func (e *blockEncoder) bits(block, start, first, count int, want string, decode func(string) string) {
+	current := e.current(block, start, start+2)
+	if e.err != nil {
+		return
+	}
+	flags, _ := strconv.ParseUint(current, 16, 8)
+	lsbFirst := func(value uint64) string {
+		s := ""
+		for i := 0; i < count; i++ {
+			s += strconv.FormatUint(value>>(first+i)&1, 10)
+		}
+		return s
+	}
+	if decode(lsbFirst(flags)) == want {
+		return
+	}
+	mask := uint64(1)<<count - 1
+	for value := uint64(0); value <= mask; value++ {
+		candidate := flags&^(mask<<first) | value<<first
+		if decode(lsbFirst(candidate)) == want {
+			e.str(block, start, start+2, fmt.Sprintf("%02x", candidate))
+			return
+		}
+	}
+	e.fail(block, start, fmt.Errorf("cannot encode %q", want))
+}
+
+func (e *blockEncoder) result() (map[int]string, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.blocks, nil
+}
+
+// EncodeDittoSettings is the inverse of DecodeDittoSettings: it writes settings into a copy of the
+// base blocks (as returned by ReadBlock) and returns the result. Bits which are not represented in
+// DittoSettings, and fields whose value already decodes to the requested setting, keep their
+// original content so decoding and encoding again reproduces the base blocks.
+func EncodeDittoSettings(settings *DittoSettings, base map[int]string) (map[int]string, error) {
+	e := newBlockEncoder(base)
+
+	// Block 15
+	if settings.HardwareVersion != "" {
+		e.str(15, 1, 2, settings.HardwareVersion)
+	}
+	fw, err := strconv.ParseFloat(settings.FirmwareVersion, 64)
+	if err != nil {
+		e.fail(15, 2, fmt.Errorf("invalid firmware version %q", settings.FirmwareVersion))
+	}
+	e.hex(15, 2, 4, int64(math.Round(fw*10)))
+	e.hex(15, 4, 6, int64(settings.BeaconType))
+
+	// Block 13
+	e.mapped(13, 2, 3, settings.SleepState, parseSleepState)
+	e.mapped(13, 3, 4, settings.DebugOption, parseDebugOption)
+	e.mapped(13, 4, 5, settings.MACOption, parseMACOption)
+
+	// Block 8
+	e.mapped(8, 0, 2, settings.SpreadingFactor, decodeHex(parseSpreadingFactor))
+	e.hex(8, 2, 4, int64(settings.DownlinkBitRate))
+	e.hex(8, 4, 6, int64(settings.UplinkBitRate))
+	e.hex(8, 6, 8, int64(settings.HighTemperature+127))
+
+	// Block 9
+	e.hex(9, 0, 2, int64(settings.LowTemperature+127))
+	e.hex(9, 2, 4, int64(settings.Accelerometer))
+
+	// Block 14
+	e.hex(14, 0, 2, settings.GNSSMin)
+	e.hex(14, 2, 4, settings.GNSSMax)
+	e.hex(14, 4, 6, int64(math.Round(settings.DOP*10)))
+	e.hex(14, 6, 8, settings.OperationalMode)
+
+	// Block 7
+	e.mapped(7, 0, 2, settings.LoRaEnable, decodeHex(parseLoRaEnable))
+	e.mapped(7, 2, 4, settings.LoRaRegion, decodeHex(parseLoRaRegion))
+
+	// Block 19
+	e.hex(19, 4, 6, int64(settings.ABR2))
+	e.mapped(19, 6, 8, settings.BLEGain, parseBLEGain)
+
+	// Blocks 20 and 21
+	e.hexLE(20, 4, 8, int64(settings.MotionMoved))
+	e.hexLE(21, 0, 4, int64(settings.MotionStationary))
+	e.hex(21, 4, 6, settings.MotionAccelActivity)
+	e.hex(21, 6, 8, settings.MotionAccelActivityThreshold)
+
+	// Blocks 24-29
+	e.hexLE(24, 0, 4, settings.BLEAdvertisingInterval)
+	e.hexLE(24, 4, 8, settings.BLERefScanInterval)
+	if settings.BLERefRSSI < -128 || settings.BLERefRSSI > 127 {
+		e.fail(25, 0, fmt.Errorf("reference RSSI %d out of range", settings.BLERefRSSI))
+	} else {
+		e.hex(25, 0, 2, int64(byte(int8(settings.BLERefRSSI))))
+	}
+	refFilter, err := encodeRefFilter(settings.BLERefFilter)
+	if err != nil {
+		e.fail(25, 2, err)
+	} else {
+		e.str(25, 2, 8, refFilter[0:6])
+		e.str(26, 0, 8, refFilter[6:14])
+		e.str(27, 0, 8, refFilter[14:22])
+		e.str(28, 0, 8, refFilter[22:30])
+		e.str(29, 0, 2, refFilter[30:32])
+	}
+	e.mapped(29, 2, 4, settings.BLEAdvertisingType, parseBLEAdvertisingType)
+	e.mapped(29, 4, 6, settings.PressUplink, parsePressUplink)
+	e.mapped(29, 6, 8, settings.PingSlotPeriod, decodeHex(parsePingSlotPeriod))
+
+	// Blocks 30 and 31
+	e.hex(30, 0, 2, settings.Timeout)
+	e.bits(30, 6, 0, 2, settings.BLERefMode, parseBLERefMode)
+	e.bits(30, 6, 4, 2, settings.ClassSelect, parseClassSelect)
+	e.bits(31, 0, 0, 1, settings.ConfirmedUplinks, parseConfirmedUplinks)
+	e.bits(31, 0, 4, 1, settings.Hopping, parseHopping)
+
+	return e.result()
+}
+
+// refFilterSize is the number of bytes of the BLE reference filter in blocks 25-29
+const refFilterSize = 16
+
+func encodeRefFilter(filter string) (string, error) {
+	encoded := ""
+	for _, r := range filter {
+		if r > 0xFF {
+			return "", fmt.Errorf("reference filter character %q cannot be encoded", r)
+		}
+		encoded += fmt.Sprintf("%02x", r)
+	}
+	if len(encoded) > refFilterSize*2 {
+		return "", errors.New("reference filter too long")
+	}
+	return encoded + strings.Repeat("00", refFilterSize-len(encoded)/2), nil
+}
+
+// decodeHex adapts the parsers of numeric fields to blockEncoder.mapped
+func decodeHex(parse func(int64) string) func(string) string {
+	return func(s string) string {
+		value, err := strconv.ParseInt(s, 16, 64)
+		if err != nil {
+			return ""
+		}
+		return parse(value)
+	}
+}
+
+// VerifyDittoSettings reads the settings blocks of an Asset+ tag and checks that they survive a
+// decode/encode round trip, see DittoSettingsRoundTrip
+func (m *NfcCard) VerifyDittoSettings() ([]int, error) {
+	blocks, err := m.readBlocks(dittoSettingsBlocks)
+	if err != nil {
+		return nil, err
+	}
+	return DittoSettingsRoundTrip(blocks)
+}
+
+// DittoSettingsRoundTrip decodes the settings blocks, encodes the result again and returns the blocks
+// which do not reproduce the original content. An empty result means the parsing is lossless for these blocks.
+func DittoSettingsRoundTrip(blocks map[int]string) ([]int, error) {
+	settings, _, err := DecodeDittoSettings(blocks, ParseStrict)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := EncodeDittoSettings(settings, blocks)
+	if err != nil {
+		return nil, err
+	}
+	var mismatches []int
+	for _, block := range dittoSettingsBlocks {
+		if !strings.EqualFold(encoded[block], blocks[block]) {
+			mismatches = append(mismatches, block)
+		}
+	}
+	return mismatches, nil
+}
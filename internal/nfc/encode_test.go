@@ -0,0 +1,82 @@
+package nfc_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// tagBlocks are the blocks of a tag as read, the hex strings of 64 random blocks
+type tagBlocks [64][4]byte
+
+func (b *tagBlocks) hex() map[int]string {
+	blocks := make(map[int]string, len(b))
+	for i, block := range b {
+		blocks[i] = fmt.Sprintf("%X", block[:])
+	}
+	return blocks
+}
+
+// TestDittoSettingsRoundTrip checks with random tag contents that settings encoded by
+// EncodeDittoSettings decode to the same settings, on the blocks they were read from and on blank
+// blocks, and that encoding the settings read from a tag reproduces its blocks
+func TestDittoSettingsRoundTrip(t *testing.T) {
+	decoded := 0
+	property := func(tag tagBlocks) bool {
+		blocks := tag.hex()
+		settings, _, err := nfc.DecodeDittoSettings(blocks, nfc.ParseStrict)
+		if err != nil {
+			// fields such as the spreading factor have invalid values
+			return true
+		}
+		decoded++
+
+		mismatches, err := nfc.DittoSettingsRoundTrip(blocks)
+		if err != nil || len(mismatches) != 0 {
+			t.Logf("blocks %v: mismatches %v, error %v", blocks, mismatches, err)
+			return false
+		}
+		for _, base := range []map[int]string{blocks, (&tagBlocks{}).hex()} {
+			encoded, err := nfc.EncodeDittoSettings(settings, base)
+			if err != nil {
+				t.Logf("EncodeDittoSettings(%+v) = %v", settings, err)
+				return false
+			}
+			again, _, err := nfc.DecodeDittoSettings(encoded, nfc.ParseStrict)
+			if err != nil || !reflect.DeepEqual(again, settings) {
+				t.Logf("settings %+v encoded to %v decode to %+v, error %v", settings, encoded, again, err)
+				return false
+			}
+		}
+		return true
+	}
+	err := quick.Check(property, &quick.Config{MaxCount: 2000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded < 100 {
+		t.Errorf("only %d of the random tags decoded, the property was hardly checked", decoded)
+	}
+}
+
+func TestDittoSettingsRoundTripProgrammedTag(t *testing.T) {
+	var tag tagBlocks
+	for block, data := range map[int]string{7: "01000000", 8: "070A0F80", 13: "00010000", 15: "034A1504", 19: "0000011E", 24: "A0000000"} {
+		raw, err := hex.DecodeString(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(tag[block][:], raw)
+	}
+	mismatches, err := nfc.DittoSettingsRoundTrip(tag.hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("blocks %v changed by the round trip", mismatches)
+	}
+}
@@ -0,0 +1,55 @@
+package nfc
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrShortResponse is returned when a reader response is too short to hold the expected data
+	ErrShortResponse = errors.New("response too short")
+	// ErrInvalidLength is returned when a response or field does not have the expected length
+	ErrInvalidLength = errors.New("invalid length")
+	// ErrUnexpectedTag is returned when a TLV response carries an unknown tag
+	ErrUnexpectedTag = errors.New("unexpected response tag")
+	// ErrTagSwapped is returned when the tag presented to the reader is not the one an operation started on
+	ErrTagSwapped = errors.New("tag swapped")
+	// ErrAPDUTimeout is returned when the reader does not answer an APDU within the APDU timeout
+	ErrAPDUTimeout = errors.New("APDU timed out")
+	// ErrOperationTimeout is returned when an operation does not complete before its deadline
+	ErrOperationTimeout = errors.New("operation deadline exceeded")
+	// ErrVerifyFailed is returned when blocks read back from the tag differ from the data written
+	ErrVerifyFailed = errors.New("verification failed")
+	// ErrUserDataFull is returned when user data records do not fit in the user data area of the tag
+	ErrUserDataFull = errors.New("user data area full")
+	// ErrBlockLocked is returned when the tag refuses a write because the block is write protected
+	ErrBlockLocked = errors.New("block is write protected")
+)
+
+// StatusError is returned when the reader answers with an unexpected status word
+type StatusError struct {
+	SW uint16
+}
+
+// swSecurityStatus is the status word of a write refused by the security status of the block
+const swSecurityStatus = 0x6982
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unsuccessful processing: SW1SW2 = %04X", e.SW)
+}
+
+// ParseError reports a field of a block which could not be decoded.
+// Offset is the position of the field within the block's hex string.
+type ParseError struct {
+	Block  int
+	Offset int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("block %d offset %d: %v", e.Block, e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,226 @@
+package nfc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldExplanation is the meaning of one field of a block, see ExplainBlock
+type FieldExplanation struct {
+	// Position is the place of the field in the block, e.g. "byte 1" or "byte 0 bit 4"
+	Position string
+	Name     string
+	// Raw is the hex (or for flags binary) content of the field
+	Raw     string
+	Meaning string
+}
+
+// blockField is a field of the block schema: the hex characters [start:end] of a block, or with
+// count > 0 the bits [first:first+count] of the byte starting at start
+type blockField struct {
+	start, end   int
+	first, count int
+	name         string
+	explain      func(raw string) string
+}
+
+// schemaField, schemaFlag and schemaKey build the entries of blockSchema
+func schemaField(start, end int, name string, explain func(string) string) blockField {
+	return blockField{start: start, end: end, name: name, explain: explain}
+}
+
+func schemaFlag(start, first, count int, name string, explain func(string) string) blockField {
+	return blockField{start: start, end: start + 2, first: first, count: count, name: name, explain: explain}
+}
+
+func schemaKey(name string, part, parts int) []blockField {
+	return []blockField{schemaField(0, 8, name, func(string) string {
+		return fmt.Sprintf("%s bytes %d-%d of %d", name, part*4, part*4+3, parts*4)
+	})}
+}
+
+// explainNumber explains a big endian hex field as transform(value) printed with format
+func explainNumber(format string, transform func(int64) any) func(string) string {
+	return func(raw string) string {
+		value, err := strconv.ParseInt(raw, 16, 64)
+		if err != nil {
+			return "invalid hex"
+		}
+		return fmt.Sprintf(format, transform(value))
+	}
+}
+
+// explainLE explains a little endian hex field as a decimal number with a unit
+func explainLE(unit string) func(string) string {
+	return func(raw string) string {
+		data, err := hex.DecodeString(raw)
+		if err != nil {
+			return "invalid hex"
+		}
+		var value int64
+		for i := len(data) - 1; i >= 0; i-- {
+			value = value<<8 | int64(data[i])
+		}
+		return strings.TrimSpace(fmt.Sprintf("%d %s", value, unit))
+	}
+}
+
+func explainASCII(raw string) string {
+	data, err := hex.DecodeString(raw)
+	if err != nil {
+		return "invalid hex"
+	}
+	return fmt.Sprintf("%q", strings.TrimRight(string(data), "\x00"))
+}
+
+func asIs(v int64) any { return v }
+
+// blockSchema describes the blocks of an Asset+ tag, the offsets follow DecodeDittoSettings
+var blockSchema = map[int][]blockField{
+	0: schemaKey("LoRa JoinEUI", 0, 2),
+	1: schemaKey("LoRa JoinEUI", 1, 2),
+	3: schemaKey("LoRa JoinKey", 0, 4),
+	4: schemaKey("LoRa JoinKey", 1, 4),
+	5: schemaKey("LoRa JoinKey", 2, 4),
+	6: schemaKey("LoRa JoinKey", 3, 4),
+	7: {
+		schemaField(0, 2, "LoRa Enable", decodeHex(parseLoRaEnable)),
+		schemaField(2, 4, "LoRa Region", decodeHex(parseLoRaRegion)),
+		schemaField(4, 8, "LoRa DevNonce", explainLE("")),
+	},
+	8: {
+		schemaField(0, 2, "Spreading Factor", decodeHex(parseSpreadingFactor)),
+		schemaField(2, 4, "Downlink Bit Rate (HBR)", explainNumber("%d hours", asIs)),
+		schemaField(4, 6, "Uplink Bit Rate", explainNumber("%d", asIs)),
+		schemaField(6, 8, "High Temperature", explainNumber("%d °C", func(v int64) any { return v - 127 })),
+	},
+	9: {
+		schemaField(0, 2, "Low Temperature", explainNumber("%d °C", func(v int64) any { return v - 127 })),
+		schemaField(2, 4, "Accelerometer (Motion Threshold)", explainNumber("%d", asIs)),
+	},
+	11: schemaKey("LoRa DevEUI", 0, 2),
+	12: schemaKey("LoRa DevEUI", 1, 2),
+	13: {
+		schemaField(2, 3, "Sleep State", parseSleepState),
+		schemaField(3, 4, "Debug Tones", parseDebugOption),
+		schemaField(4, 5, "MAC Option", parseMACOption),
+	},
+	14: {
+		schemaField(0, 2, "GNSS Min", explainNumber("%d minutes", asIs)),
+		schemaField(2, 4, "GNSS Max Lock Time", explainNumber("%d minutes", asIs)),
+		schemaField(4, 6, "DOP Threshold", explainNumber("%.1f", func(v int64) any { return float64(v) / 10 })),
+		schemaField(6, 8, "Operational Mode", explainNumber("%d", asIs)),
+	},
+	15: {
+		schemaField(1, 2, "Hardware Version", func(raw string) string { return raw }),
+		schemaField(2, 4, "Firmware Version", explainNumber("%.1f", func(v int64) any { return float64(v) / 10 })),
+		schemaField(4, 6, "Beacon Type", func(raw string) string { return "SKU " + strings.ToUpper(raw) }),
+	},
+	18: {schemaField(0, 8, "BLE MAC", func(string) string { return "BLE MAC bytes 0-3" })},
+	19: {
+		schemaField(0, 4, "BLE MAC", func(string) string { return "BLE MAC bytes 4-5" }),
+		schemaField(4, 6, "ABR", explainNumber("%d minutes", asIs)),
+		schemaField(6, 8, "BLE TX Power", parseBLEGain),
+	},
+	20: {schemaField(4, 8, "Stationary -> Moved Threshold", explainLE(""))},
+	21: {
+		schemaField(0, 4, "Moved -> Stationary Threshold", explainLE("")),
+		schemaField(4, 6, "Accel Activity Window", explainNumber("%d seconds", asIs)),
+		schemaField(6, 8, "Accel Activity Threshold", explainNumber("%d events", asIs)),
+	},
+	22: {schemaField(0, 8, "BLE Local Name", explainASCII)},
+	23: {schemaField(0, 8, "BLE Local Name (continued)", explainASCII)},
+	24: {
+		schemaField(0, 4, "BLE Advertising Interval", explainLE("ms")),
+		schemaField(4, 8, "BLE Reference Scan Interval", explainLE("ms")),
+	},
+	25: {
+		schemaField(0, 2, "BLE Reference RSSI Threshold", explainNumber("%d dBm", func(v int64) any { return complementToDec(v) })),
+		schemaField(2, 8, "BLE Reference Filter", explainASCII),
+	},
+	26: {schemaField(0, 8, "BLE Reference Filter (continued)", explainASCII)},
+	27: {schemaField(0, 8, "BLE Reference Filter (continued)", explainASCII)},
+	28: {schemaField(0, 8, "BLE Reference Filter (continued)", explainASCII)},
+	29: {
+		schemaField(0, 2, "BLE Reference Filter (end)", explainASCII),
+		schemaField(2, 4, "BLE Advertising Type", parseBLEAdvertisingType),
+		schemaField(4, 6, "Button Press Uplink", parsePressUplink),
+		schemaField(6, 8, "Class B Ping Slot Period", decodeHex(parsePingSlotPeriod)),
+	},
+	30: {
+		schemaField(0, 2, "Class B Timeout", explainNumber("%d minutes", asIs)),
+		schemaFlag(6, 0, 2, "BLE Reference Mode", parseBLERefMode),
+		schemaFlag(6, 4, 2, "LoRaWAN Class", parseClassSelect),
+	},
+	31: {
+		schemaFlag(0, 0, 1, "Confirmed Uplinks", parseConfirmedUplinks),
+		schemaFlag(0, 4, 1, "Sub-band Hopping", parseHopping),
+	},
+	48: {schemaField(0, 8, "Configuration CRC", func(string) string { return "CRC of blocks 0-47, see validateCrc" })},
+}
+
+// secretBlocks hold key material, their content is redacted like JoinKeys (see SetRedactKeys)
+var secretBlocks = map[int]bool{3: true, 4: true, 5: true, 6: true}
+
+// IsSecretBlock reports whether a block holds key material
+func IsSecretBlock(block int) bool {
+	return secretBlocks[block]
+}
+
+// ExplainBlock decodes the 8 hex characters of a block according to the Asset+ block schema, e.g.
+// block 13 "10100000", without a tag. Blocks outside the schema are reported as unused.
+func ExplainBlock(block int, data string) ([]FieldExplanation, error) {
+	data = strings.ToUpper(strings.TrimSpace(data))
+	raw, err := hex.DecodeString(data)
+	if err != nil || len(raw) != 4 {
+		return nil, fmt.Errorf("invalid block data %q, expected 8 hex characters", data)
+	}
+	if block >= USERDATA_BLOCK_FIRST && block <= USERDATA_BLOCK_LAST {
+		return []FieldExplanation{{Position: "bytes 0-3", Name: "User Data", Raw: data,
+			Meaning: fmt.Sprintf("user data area bytes %d-%d of %d, covered by the CRC, see readuserdata", (block-USERDATA_BLOCK_FIRST)*4, (block-USERDATA_BLOCK_FIRST)*4+3, USERDATA_SIZE)}}, nil
+	}
+	fields, ok := blockSchema[block]
+	if !ok {
+		return []FieldExplanation{{Position: "bytes 0-3", Name: "Unused", Raw: data, Meaning: "not part of the Asset+ schema"}}, nil
+	}
+	var explanations []FieldExplanation
+	for _, f := range fields {
+		explanation := FieldExplanation{Name: f.name, Raw: data[f.start:f.end]}
+		if f.count > 0 {
+			flags, _ := strconv.ParseUint(explanation.Raw, 16, 8)
+			bits := ""
+			for i := 0; i < f.count; i++ {
+				// least significant bit first, as DecodeDittoSettings hands them to the parsers
+				bits += strconv.FormatUint(flags>>(f.first+i)&1, 10)
+			}
+			explanation.Raw = bits
+			explanation.Position = fmt.Sprintf("byte %d bit %d", f.start/2, f.first)
+			if f.count > 1 {
+				explanation.Position = fmt.Sprintf("byte %d bits %d-%d", f.start/2, f.first, f.first+f.count-1)
+			}
+		} else {
+			explanation.Position = bytePosition(f.start, f.end)
+		}
+		explanation.Meaning = f.explain(explanation.Raw)
+		if secretBlocks[block] {
+			explanation.Raw = RedactKey(explanation.Raw)
+		} else if cmMode && IsHiddenBlock(block) {
+			explanation.Raw = HIDDEN
+		}
+		explanations = append(explanations, explanation)
+	}
+	return explanations, nil
+}
+
+// bytePosition names the bytes of the hex characters [start:end], nibbles are named as such
+func bytePosition(start, end int) string {
+	if start%2 != 0 || end%2 != 0 {
+		return fmt.Sprintf("nibble %d", start)
+	}
+	if end-start == 2 {
+		return fmt.Sprintf("byte %d", start/2)
+	}
+	return fmt.Sprintf("bytes %d-%d", start/2, end/2-1)
+}
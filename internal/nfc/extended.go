@@ -0,0 +1,115 @@
+package nfc
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// EXTENDED_ADDRESS_BLOCK is the first block which needs the 2 byte block numbers of the ISO 15693
+// protocol extension, e.g. the upper sectors of the M24LR16E-R and M24LR64E-R
+const EXTENDED_ADDRESS_BLOCK = 256
+
+// Blocks returns the number of blocks of the tag reported by its system information, read once
+// per tag. Tags with more than 256 blocks report it with the protocol extension, see GetSystemInfo.
+func (m *NfcCard) Blocks() (int, error) {
+	if m.blocks > 0 {
+		return m.blocks, nil
+	}
+	info, err := m.ReadSystemInfo()
+	if err != nil {
+		return 0, fmt.Errorf("failed to detect memory size: %w", err)
+	}
+	if info.Blocks <= 0 {
+		return 0, fmt.Errorf("tag does not report its memory size")
+	}
+	m.blocks = info.Blocks
+	m.log.Debugf("Memory size %d blocks", m.blocks)
+	return m.blocks, nil
+}
+
+// extendedBlock reports whether a block is read and written with raw ISO 15693 commands using the
+// protocol extension. The pseudo APDUs of the readers map the block number to the single byte of
+// the plain commands, so blocks from EXTENDED_ADDRESS_BLOCK on need the transparent exchange.
+// Blocks beyond the memory of the tag are refused.
+func (m *NfcCard) extendedBlock(blockNumber int) (bool, error) {
+	if blockNumber < EXTENDED_ADDRESS_BLOCK {
+		return false, nil
+	}
+	blocks, err := m.Blocks()
+	if err != nil {
+		m.log.Debugf("Addressing block %d without the memory size: %v", blockNumber, err)
+	} else if blockNumber >= blocks {
+		return false, fmt.Errorf("block %d is beyond the %d blocks of the tag", blockNumber, blocks)
+	}
+	return m.Quirks().Transparent, nil
+}
+
+// blockRequest sends an addressed ISO 15693 block command with the extra request flags, e.g.
+// ISO15693_FLAG_OPTION. Blocks from EXTENDED_ADDRESS_BLOCK on are addressed with the protocol
+// extension, their block number takes 2 bytes (least significant first). Returns the response
+// without its flags.
+func (m *NfcCard) blockRequest(flags byte, command byte, blockNumber int, data []byte) ([]byte, error) {
+	uid, err := m.transmit("FFCA000000", 0x9000)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get UID: %w", err)
+	}
+	uidBytes, err := hex.DecodeString(uid)
+	if err != nil || len(uidBytes) != 8 {
+		return nil, fmt.Errorf("invalid UID %q", uid)
+	}
+	flags |= ISO15693_FLAG_HIGH_DATA_RATE | ISO15693_FLAG_ADDRESSED
+	if blockNumber >= EXTENDED_ADDRESS_BLOCK {
+		flags |= ISO15693_FLAG_EXTENSION
+	}
+	frame := []byte{flags, command}
+	for i := len(uidBytes) - 1; i >= 0; i-- {
+		frame = append(frame, uidBytes[i])
+	}
+	frame = append(frame, byte(blockNumber))
+	if flags&ISO15693_FLAG_EXTENSION != 0 {
+		frame = append(frame, byte(blockNumber>>8))
+	}
+	frame = append(frame, data...)
+	resp, err := m.Transceive(frame)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) == 0 {
+		return nil, ErrShortResponse
+	}
+	if resp[0]&ISO15693_FLAG_ERROR != 0 {
+		if len(resp) < 2 {
+			return nil, ErrShortResponse
+		}
+		if resp[1] == ISO15693_ERROR_BLOCK_LOCKED {
+			return nil, fmt.Errorf("block %d: %w (tag error code %02X)", blockNumber, ErrBlockLocked, resp[1])
+		}
+		return nil, fmt.Errorf("block %d: tag error code %02X", blockNumber, resp[1])
+	}
+	return resp[1:], nil
+}
+
+// readExtendedBlock reads a block with the protocol extension, see extendedBlock
+func (m *NfcCard) readExtendedBlock(blockNumber int) (string, error) {
+	data, err := m.blockRequest(0, ISO15693_CMD_READ_SINGLE, blockNumber, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to read block %d: %w", blockNumber, err)
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// writeExtendedBlock writes a block with the protocol extension, see extendedBlock
+func (m *NfcCard) writeExtendedBlock(blockNumber int, block string) error {
+	data, err := hex.DecodeString(block)
+	if err != nil {
+		return fmt.Errorf("invalid block %d data %q: %w", blockNumber, block, err)
+	}
+	if size := m.BlockSize(); len(data) != size {
+		return fmt.Errorf("block %d data %q: %w: expected %d bytes", blockNumber, block, ErrInvalidLength, size)
+	}
+	_, err = m.blockRequest(0, ISO15693_CMD_WRITE_SINGLE, blockNumber, data)
+	if err != nil {
+		return fmt.Errorf("failed to write block %d: %w", blockNumber, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package nfc
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrWrongFamily is returned when a tag is about to be written whose AFI is not of the family set
+// with SetFamilyAFI
+var ErrWrongFamily = errors.New("tag of another application family")
+
+// familyAFI is the application family tags are restricted to, nil for every tag
+var familyAFI *byte
+
+// SetFamilyAFI restricts the tool to the tags of an application family, given as the hex AFI: only
+// they answer Inventory and tags of other families are not written (ErrWrongFamily). A family of
+// 0 (high nibble) or a subfamily of 0 (low nibble) matches any, see AFIMatches. An empty afi
+// accepts every tag.
+func SetFamilyAFI(afi string) error {
+	if afi == "" {
+		familyAFI = nil
+		return nil
+	}
+	value, err := hex.DecodeString(afi)
+	if err != nil || len(value) != 1 {
+		return fmt.Errorf("invalid AFI %q, expected 2 hex characters", afi)
+	}
+	familyAFI = &value[0]
+	return nil
+}
+
+// AFIMatches reports whether a tag with tagAFI answers a request for requestAFI: the family (high
+// nibble) and the subfamily (low nibble) of the request must be 0 or equal those of the tag
+func AFIMatches(tagAFI, requestAFI byte) bool {
+	family, subfamily := requestAFI>>4, requestAFI&0x0F
+	return (family == 0 || family == tagAFI>>4) && (subfamily == 0 || subfamily == tagAFI&0x0F)
+}
+
+// checkFamily fails with ErrWrongFamily when the tag is not of the family set with SetFamilyAFI
+func (m *NfcCard) checkFamily() error {
+	if familyAFI == nil {
+		return nil
+	}
+	resp, err := m.AFI()
+	if err != nil {
+		return fmt.Errorf("failed to read AFI: %w", err)
+	}
+	afi, err := hex.DecodeString(resp)
+	if err != nil || len(afi) != 1 {
+		return fmt.Errorf("failed to read AFI: %w", ErrInvalidLength)
+	}
+	if !AFIMatches(afi[0], *familyAFI) {
+		m.log.Errorf("Tag AFI %02X is not of family %02X", afi[0], *familyAFI)
+		return fmt.Errorf("%w: AFI %02X, expected %02X", ErrWrongFamily, afi[0], *familyAFI)
+	}
+	return nil
+}
+
+// WriteAFI writes the Application Family Identifier of the tag
+func (m *NfcCard) WriteAFI(afi byte) error {
+	err := m.writeSystemValue(ISO15693_CMD_WRITE_AFI, afi)
+	if err != nil {
+		return fmt.Errorf("failed to write AFI: %w", err)
+	}
+	m.log.Infof("AFI set to %02X", afi)
+	return nil
+}
+
+// WriteDSFID writes the Data Storage Format Identifier of the tag
+func (m *NfcCard) WriteDSFID(dsfid byte) error {
+	err := m.writeSystemValue(ISO15693_CMD_WRITE_DSFID, dsfid)
+	if err != nil {
+		return fmt.Errorf("failed to write DSFID: %w", err)
+	}
+	m.log.Infof("DSFID set to %02X", dsfid)
+	return nil
+}
+
+// writeSystemValue sends an addressed write AFI or write DSFID request to the presented tag, the
+// readers have no pseudo APDU for them
+func (m *NfcCard) writeSystemValue(command byte, value byte) error {
+	err := m.verifyPinnedUID()
+	if err != nil {
+		return err
+	}
+	uid, err := m.transmit("FFCA000000", 0x9000)
+	if err != nil {
+		return fmt.Errorf("failed to get UID: %w", err)
+	}
+	uidBytes, err := hex.DecodeString(uid)
+	if err != nil || len(uidBytes) != 8 {
+		return fmt.Errorf("invalid UID %q", uid)
+	}
+	frame := []byte{ISO15693_FLAG_HIGH_DATA_RATE | ISO15693_FLAG_ADDRESSED, command}
+	for i := len(uidBytes) - 1; i >= 0; i-- {
+		frame = append(frame, uidBytes[i])
+	}
+	frame = append(frame, value)
+	resp, err := m.Transceive(frame)
+	if err != nil {
+		return err
+	}
+	if len(resp) == 0 {
+		return ErrShortResponse
+	}
+	if resp[0]&ISO15693_FLAG_ERROR != 0 {
+		if len(resp) < 2 {
+			return ErrShortResponse
+		}
+		return fmt.Errorf("tag error code %02X", resp[1])
+	}
+	m.log.Debugf("Wrote %02X with command %02X to %s", value, command, strings.ToLower(uid))
+	return nil
+}
@@ -0,0 +1,117 @@
+package nfc_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// FuzzParseAPDU checks that no reader response crashes the parser: a response is either parsed or
+// refused with an error
+func FuzzParseAPDU(f *testing.F) {
+	f.Add([]byte{0xBD, 0x08, 0x02, 0x06, 'O', 'K', '5', '4', '2', 0x00, 0x90, 0x00})
+	f.Add([]byte{0x9D, 0x04, 0x92, 0x02, 0x12, 0x34, 0x90, 0x00})
+	f.Add([]byte{0xBD, 0xFF, 0x02, 0xFF, 0x90, 0x00})
+	f.Add([]byte{0x6A, 0x82})
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, apdu []byte) {
+		info, err := nfc.ParseAPDU(apdu)
+		if err != nil {
+			return
+		}
+		if info.StatusWords != [2]byte{0x90, 0x00} {
+			t.Errorf("ParseAPDU(% X) accepted status %X", apdu, info.StatusWords)
+		}
+		if len(info.ValueRaw) > len(apdu) {
+			t.Errorf("ParseAPDU(% X) returned a value of %d bytes", apdu, len(info.ValueRaw))
+		}
+	})
+}
+
+// fuzzBlocks splits blocks at ',' into the hex strings of the blocks 0, 1, 2... as passed to the
+// decoders, so the fuzzer produces blocks of any length and content
+func fuzzBlocks(blocks string) map[int]string {
+	parsed := make(map[int]string)
+	for i, block := range strings.Split(blocks, ",") {
+		parsed[i] = block
+	}
+	return parsed
+}
+
+// settingsSeeds are the blocks 0-24 of a programmed tag, a blank tag and truncated blocks
+var settingsSeeds = []string{
+	"53706563,00000000,00000000,00112233,44556677,8899AABB,CCDDEEFF,01000000,070A0F80,00000000,00000000,70B3D57E,D0000001,00010000,00000000,034A1504,1E000000,00000000,AABBCCDDEEFF0000,00000000,00000000,00000000,54524B30,30313000,00000000",
+	strings.Repeat("FFFFFFFF,", 24) + "FFFFFFFF",
+	"5,0,,ZZZZZZZZ,0,0,0,0,0,0,0,0,0,0,0,034",
+	"",
+}
+
+func FuzzDecodeLoRaSettings(f *testing.F) {
+	for _, seed := range settingsSeeds {
+		f.Add(seed, false)
+	}
+	f.Fuzz(func(t *testing.T, blocks string, strict bool) {
+		mode := nfc.ParseLenient
+		if strict {
+			mode = nfc.ParseStrict
+		}
+		settings, warnings, err := nfc.DecodeLoRaSettings(fuzzBlocks(blocks), mode)
+		checkDecoded(t, settings, warnings, err, strict)
+	})
+}
+
+func FuzzDecodeDittoSettings(f *testing.F) {
+	for _, seed := range settingsSeeds {
+		f.Add(seed, false)
+	}
+	f.Fuzz(func(t *testing.T, blocks string, strict bool) {
+		mode := nfc.ParseLenient
+		if strict {
+			mode = nfc.ParseStrict
+		}
+		settings, warnings, err := nfc.DecodeDittoSettings(fuzzBlocks(blocks), mode)
+		checkDecoded(t, settings, warnings, err, strict)
+	})
+}
+
+// checkDecoded checks the results of a settings decoder: settings unless strict mode failed, and
+// only ParseErrors as warnings and errors
+func checkDecoded[S any](t *testing.T, settings *S, warnings []error, err error, strict bool) {
+	t.Helper()
+	if err == nil && settings == nil {
+		t.Fatal("no settings and no error")
+	}
+	if !strict && err != nil {
+		t.Fatalf("lenient decoding failed: %v", err)
+	}
+	var parseErr *nfc.ParseError
+	if err != nil && !errors.As(err, &parseErr) {
+		t.Errorf("error %v is not a ParseError", err)
+	}
+	if strict && len(warnings) != 0 {
+		t.Errorf("strict decoding returned warnings %v", warnings)
+	}
+	for _, warning := range warnings {
+		if !errors.As(warning, &parseErr) {
+			t.Errorf("warning %v is not a ParseError", warning)
+		}
+	}
+}
+
+func FuzzDecodeUserData(f *testing.F) {
+	f.Add([]byte{0x01, 0x04, 'A', '-', '4', '2', 0x00})
+	f.Add([]byte{0x01, 0x10, 'A'})
+	f.Add([]byte{0xFF, 0xFF, 0xFF, 0xFF})
+	f.Fuzz(func(t *testing.T, area []byte) {
+		records, err := nfc.DecodeUserData(area)
+		size := 0
+		for _, record := range records {
+			size += 2 + len(record.Value)
+		}
+		if size > len(area) {
+			t.Errorf("DecodeUserData(% X) = %d bytes of records, err %v", area, size, err)
+		}
+	})
+}
@@ -0,0 +1,387 @@
+package nfc
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ISO 15693 request flags and command codes used with Transceive
+const (
+	ISO15693_FLAG_HIGH_DATA_RATE = 0x02
+	ISO15693_FLAG_INVENTORY      = 0x04
+	ISO15693_FLAG_EXTENSION      = 0x08 // protocol extension, 2 byte block numbers
+	ISO15693_FLAG_AFI            = 0x10 // with ISO15693_FLAG_INVENTORY
+	ISO15693_FLAG_ONE_SLOT       = 0x20 // with ISO15693_FLAG_INVENTORY
+	ISO15693_FLAG_ADDRESSED      = 0x20 // without ISO15693_FLAG_INVENTORY
+	ISO15693_FLAG_OPTION         = 0x40 // without ISO15693_FLAG_INVENTORY, e.g. block security status
+	ISO15693_FLAG_ERROR          = 0x01 // response flag
+
+	ISO15693_CMD_INVENTORY       = 0x01
+	ISO15693_CMD_READ_SINGLE     = 0x20
+	ISO15693_CMD_WRITE_SINGLE    = 0x21
+	ISO15693_CMD_WRITE_AFI       = 0x27
+	ISO15693_CMD_WRITE_DSFID     = 0x29
+	ISO15693_CMD_GET_SYSTEM_INFO = 0x2B
+
+	// information flags of the get system info response
+	ISO15693_INFO_DSFID        = 0x01
+	ISO15693_INFO_AFI          = 0x02
+	ISO15693_INFO_MEMORY       = 0x04
+	ISO15693_INFO_IC_REFERENCE = 0x08
+
+	// error code of a write to a locked block
+	ISO15693_ERROR_BLOCK_LOCKED = 0x12
+)
+
+var (
+	// ErrNoResponse is returned by Transceive when no tag answered the request
+	ErrNoResponse = errors.New("no tag answered")
+	// ErrCollision is returned by Transceive when several tags answered the request at once
+	ErrCollision = errors.New("collision of tag responses")
+	// ErrMultipleTags is returned when a tag is about to be written while other tags are in the field
+	ErrMultipleTags = errors.New("more than one tag in the field")
+	// ErrFieldUnchecked is returned when a tag is about to be written and the reader cannot run the
+	// inventory checking the field for other tags
+	ErrFieldUnchecked = errors.New("cannot check the field for other tags")
+)
+
+// PC/SC 2.02 part 3 data objects of the manage session and transparent exchange commands
+const (
+	tagStartSession    = 0x81
+	tagEndSession      = 0x82
+	tagTransceive      = 0x95
+	tagResponseStatus  = 0x96
+	tagResponseData    = 0x97
+	tagGenericError    = 0xC0
+	responseCollision  = 0x02 // bit of the first response status byte
+	swNoResponseFromIC = 0x6401
+)
+
+// InventoryTag is a tag answering an inventory request
+type InventoryTag struct {
+	// UID is the UID in hex, most significant byte first like UID()
+	UID   string
+	DSFID byte
+}
+
+// SystemInfo is the answer of a tag to get system info, fields the tag does not report are nil or 0
+type SystemInfo struct {
+	// UID is the UID in hex, most significant byte first like UID()
+	UID         string
+	DSFID       *byte
+	AFI         *byte
+	Blocks      int
+	BlockSize   int
+	ICReference *byte
+}
+
+// singleTagCheck refuses writes while other tags are in the field, see SetSingleTagCheck
+var singleTagCheck = true
+
+// SetSingleTagCheck enables or disables the inventory run before the first write to a pinned tag.
+// With the check enabled (the default) writes fail with ErrMultipleTags when several tags are in
+// the field, since the pseudo APDUs then address an arbitrary one of them, and with
+// ErrFieldUnchecked when the reader cannot run the inventory.
+func SetSingleTagCheck(on bool) {
+	singleTagCheck = on
+}
+
+// Transceive sends a raw ISO 15693 request frame (flags, command code and parameters, without CRC)
+// to the tag through the transparent exchange of the reader and returns the response frame, flags
+// first. The reader adds and checks the CRC.
+func (m *NfcCard) Transceive(frame []byte) ([]byte, error) {
+	if !m.Quirks().Transparent {
+		return nil, fmt.Errorf("raw ISO 15693 commands on %s: %w", m.Quirks().Name, ErrNotSupported)
+	}
+	_, err := m.manageSession(tagStartSession)
+	if err != nil {
+		// the reader (or its firmware) does not know the transparent exchange
+		return nil, fmt.Errorf("failed to start transparent session: %w: %w", ErrNotSupported, err)
+	}
+	defer func() {
+		_, err := m.manageSession(tagEndSession)
+		if err != nil {
+			m.log.Warnf("Failed to end transparent session: %v", err)
+		}
+	}()
+
+	data := append([]byte{tagTransceive}, berLength(len(frame))...)
+	data = append(data, frame...)
+	objects, err := m.transparentCommand(0x01, data)
+	if err != nil {
+		return nil, err
+	}
+	if status, ok := objects[tagResponseStatus]; ok && len(status) > 0 && status[0]&responseCollision != 0 {
+		return nil, ErrCollision
+	}
+	resp, ok := objects[tagResponseData]
+	if !ok {
+		return nil, fmt.Errorf("transparent exchange: %w", ErrShortResponse)
+	}
+	return resp, nil
+}
+
+func (m *NfcCard) manageSession(tag byte) (map[byte][]byte, error) {
+	return m.transparentCommand(0x00, []byte{tag, 0x00})
+}
+
+// transparentCommand sends FF C2 00 p2 with the data objects and returns the data objects of the
+// response, failing on a generic error status
+func (m *NfcCard) transparentCommand(p2 byte, data []byte) (map[byte][]byte, error) {
+	if len(data) > 255 {
+		return nil, fmt.Errorf("transparent command of %d bytes: %w", len(data), ErrInvalidLength)
+	}
+	resp, err := m.transmit(fmt.Sprintf("FFC200%02X%02X%X00", p2, len(data), data), 0x9000)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(resp)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := parseDataObjects(raw)
+	if err != nil {
+		return nil, err
+	}
+	if status, ok := objects[tagGenericError]; ok && len(status) == 3 && (status[1] != 0x90 || status[2] != 0x00) {
+		sw := uint16(status[1])<<8 | uint16(status[2])
+		if sw == swNoResponseFromIC {
+			return nil, ErrNoResponse
+		}
+		return nil, fmt.Errorf("transparent exchange failed at data object %d: %w", status[0], &StatusError{SW: sw})
+	}
+	return objects, nil
+}
+
+// parseDataObjects parses the BER-TLV data objects of a transparent exchange response, tags are
+// single bytes
+func parseDataObjects(data []byte) (map[byte][]byte, error) {
+	objects := make(map[byte][]byte)
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, fmt.Errorf("data object: %w", ErrShortResponse)
+		}
+		tag, length, header := data[0], int(data[1]), 2
+		switch data[1] {
+		case 0x81:
+			if len(data) < 3 {
+				return nil, fmt.Errorf("data object %02X: %w", tag, ErrShortResponse)
+			}
+			length, header = int(data[2]), 3
+		case 0x82:
+			if len(data) < 4 {
+				return nil, fmt.Errorf("data object %02X: %w", tag, ErrShortResponse)
+			}
+			length, header = int(data[2])<<8|int(data[3]), 4
+		}
+		if len(data) < header+length {
+			return nil, fmt.Errorf("data object %02X: %w", tag, ErrShortResponse)
+		}
+		objects[tag] = data[header : header+length]
+		data = data[header+length:]
+	}
+	return objects, nil
+}
+
+// berLength encodes the length of a BER-TLV data object
+func berLength(length int) []byte {
+	if length < 0x80 {
+		return []byte{byte(length)}
+	}
+	return []byte{0x81, byte(length)}
+}
+
+// Inventory lists the tags in the field. It runs single slot inventory rounds and resolves
+// collisions by extending the UID mask one bit at a time, so it finds every tag without relying on
+// the reader's slot handling. With a family AFI (see SetFamilyAFI) only tags of the family answer.
+func (m *NfcCard) Inventory() ([]InventoryTag, error) {
+	return m.inventoryAFI(familyAFI)
+}
+
+// inventoryAFI lists the tags in the field whose AFI matches afi, every tag when afi is nil
+func (m *NfcCard) inventoryAFI(afi *byte) ([]InventoryTag, error) {
+	var tags []InventoryTag
+	err := m.inventory(afi, nil, 0, &tags)
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// inventory queries the tags whose UID ends with the maskBits least significant bits of mask
+func (m *NfcCard) inventory(afi *byte, mask []byte, maskBits int, tags *[]InventoryTag) error {
+	frame := []byte{ISO15693_FLAG_HIGH_DATA_RATE | ISO15693_FLAG_INVENTORY | ISO15693_FLAG_ONE_SLOT, ISO15693_CMD_INVENTORY}
+	if afi != nil {
+		frame[0] |= ISO15693_FLAG_AFI
+		frame = append(frame, *afi)
+	}
+	frame = append(frame, byte(maskBits))
+	frame = append(frame, mask[:(maskBits+7)/8]...)
+	resp, err := m.Transceive(frame)
+	switch {
+	case errors.Is(err, ErrNoResponse):
+		return nil
+	case errors.Is(err, ErrCollision):
+		if maskBits == 64 {
+			return fmt.Errorf("tags with the same UID answered: %w", ErrCollision)
+		}
+		for bit := 0; bit < 2; bit++ {
+			next := make([]byte, maskBits/8+1)
+			copy(next, mask)
+			next[maskBits/8] |= byte(bit) << (maskBits % 8)
+			err = m.inventory(afi, next, maskBits+1, tags)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("inventory failed: %w", err)
+	}
+	// flags, DSFID and the UID least significant byte first
+	if len(resp) != 10 || resp[0]&ISO15693_FLAG_ERROR != 0 {
+		return fmt.Errorf("inventory response % X: %w", resp, ErrInvalidLength)
+	}
+	uid := make([]byte, 8)
+	for i := range uid {
+		uid[i] = resp[9-i]
+	}
+	*tags = append(*tags, InventoryTag{UID: strings.ToLower(hex.EncodeToString(uid)), DSFID: resp[1]})
+	return nil
+}
+
+// GetSystemInfo sends get system info addressed to the tag with uid (most significant byte first).
+// Tags with more than 256 blocks (e.g. the M24LR64E-R) reject it without the protocol extension
+// flag, it is sent again with the flag when the tag answers with an error.
+func (m *NfcCard) GetSystemInfo(uid string) (*SystemInfo, error) {
+	uidBytes, err := hex.DecodeString(uid)
+	if err != nil || len(uidBytes) != 8 {
+		return nil, fmt.Errorf("invalid UID %q, expected 16 hex characters", uid)
+	}
+	frame := []byte{ISO15693_FLAG_HIGH_DATA_RATE | ISO15693_FLAG_ADDRESSED, ISO15693_CMD_GET_SYSTEM_INFO}
+	for i := len(uidBytes) - 1; i >= 0; i-- {
+		frame = append(frame, uidBytes[i])
+	}
+	resp, err := m.Transceive(frame)
+	if err != nil {
+		return nil, fmt.Errorf("get system info failed: %w", err)
+	}
+	if len(resp) >= 1 && resp[0]&ISO15693_FLAG_ERROR != 0 {
+		frame[0] |= ISO15693_FLAG_EXTENSION
+		extended, err := m.Transceive(frame)
+		if err == nil && len(extended) >= 1 && extended[0]&ISO15693_FLAG_ERROR == 0 {
+			return parseSystemInfo(extended, true)
+		}
+	}
+	return parseSystemInfo(resp, false)
+}
+
+// parseSystemInfo decodes a get system info response: flags, information flags, UID (least
+// significant byte first) and the fields announced by the information flags. The number of blocks
+// takes 2 bytes (least significant first) in the response to a request with protocol extension.
+func parseSystemInfo(resp []byte, extended bool) (*SystemInfo, error) {
+	if len(resp) >= 2 && resp[0]&ISO15693_FLAG_ERROR != 0 {
+		return nil, fmt.Errorf("get system info: tag error code %02X", resp[1])
+	}
+	if len(resp) < 10 {
+		return nil, fmt.Errorf("get system info: %w", ErrShortResponse)
+	}
+	info := &SystemInfo{}
+	uid := make([]byte, 8)
+	for i := range uid {
+		uid[i] = resp[9-i]
+	}
+	info.UID = strings.ToLower(hex.EncodeToString(uid))
+	flags, data := resp[1], resp[10:]
+	field := func(size int) ([]byte, error) {
+		if len(data) < size {
+			return nil, fmt.Errorf("get system info: %w", ErrShortResponse)
+		}
+		value := data[:size]
+		data = data[size:]
+		return value, nil
+	}
+	if flags&ISO15693_INFO_DSFID != 0 {
+		value, err := field(1)
+		if err != nil {
+			return nil, err
+		}
+		info.DSFID = &value[0]
+	}
+	if flags&ISO15693_INFO_AFI != 0 {
+		value, err := field(1)
+		if err != nil {
+			return nil, err
+		}
+		info.AFI = &value[0]
+	}
+	if flags&ISO15693_INFO_MEMORY != 0 {
+		// number of blocks - 1 and block size - 1 in the low 5 bits
+		size := 2
+		if extended {
+			size = 3
+		}
+		value, err := field(size)
+		if err != nil {
+			return nil, err
+		}
+		info.Blocks = int(value[0]) + 1
+		if extended {
+			info.Blocks += int(value[1]) << 8
+		}
+		info.BlockSize = int(value[size-1]&0x1F) + 1
+	}
+	if flags&ISO15693_INFO_IC_REFERENCE != 0 {
+		value, err := field(1)
+		if err != nil {
+			return nil, err
+		}
+		info.ICReference = &value[0]
+	}
+	return info, nil
+}
+
+// checkSingleTag fails with ErrMultipleTags when other tags than the pinned one are in the field,
+// and with ErrFieldUnchecked when the reader refuses the inventory (no transparent exchange): a
+// write could address any of the tags in the field then. Tags of other families count as well, the
+// pseudo APDUs may address them all the same.
+func (m *NfcCard) checkSingleTag() error {
+	if !singleTagCheck {
+		return nil
+	}
+	tags, err := m.inventoryAFI(nil)
+	if errors.Is(err, ErrNotSupported) {
+		m.log.Errorf("Cannot check for other tags in the field: %v", err)
+		return fmt.Errorf("%w on %s (%v), present a single tag and disable the check to write anyway", ErrFieldUnchecked, m.Quirks().Name, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check for other tags in the field: %w", err)
+	}
+	if len(tags) > 1 {
+		uids := make([]string, len(tags))
+		for i, tag := range tags {
+			uids[i] = tag.UID
+		}
+		m.log.Errorf("%d tags in the field (%s), remove all but one", len(tags), strings.Join(uids, ", "))
+		return fmt.Errorf("%w: %s", ErrMultipleTags, strings.Join(uids, ", "))
+	}
+	return nil
+}
+
+// InventoryMatches reports whether a tag with uid (most significant byte first) answers an
+// inventory request whose UID mask is the maskBits least significant bits of mask, for emulated readers
+func InventoryMatches(uid []byte, mask []byte, maskBits int) bool {
+	if maskBits > 8*len(uid) || len(mask) < (maskBits+7)/8 {
+		return false
+	}
+	for bit := 0; bit < maskBits; bit++ {
+		uidBit := uid[len(uid)-1-bit/8] >> (bit % 8) & 1
+		maskBit := mask[bit/8] >> (bit % 8) & 1
+		if uidBit != maskBit {
+			return false
+		}
+	}
+	return true
+}
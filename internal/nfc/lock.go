@@ -0,0 +1,11 @@
+package nfc
+
+// Transaction runs fn with exclusive access to the card. Every APDU is already exchanged atomically,
+// but operations made of several APDUs (read-modify-write of a block, writes followed by the CRC
+// update) must run inside a transaction when the card is shared between goroutines, e.g. by
+// concurrent server requests for the same reader. Transactions do not nest.
+func (m *NfcCard) Transaction(fn func(card *NfcCard) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+	return fn(m)
+}
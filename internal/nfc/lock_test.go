@@ -0,0 +1,76 @@
+package nfc_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// TestSessionConcurrentWrites writes the JoinEUI and validates the CRC from 8 goroutines sharing one
+// session, run it with -race: the operations must run one after another, so every CRC check sees the
+// CRC of the JoinEUI written before it
+func TestSessionConcurrentWrites(t *testing.T) {
+	tag, err := emulator.New(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := nfc.NewSession(func() (nfc.CardTransport, error) { return tag, nil })
+	defer session.Close()
+
+	const goroutines, writes = 8, 10
+	written := make(map[string]bool)
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*writes)
+	for g := 0; g < goroutines; g++ {
+		for i := 0; i < writes; i++ {
+			written[fmt.Sprintf("70B3D57ED00%02X%03X", g, i)] = true
+		}
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				joinEUI := fmt.Sprintf("70B3D57ED00%02X%03X", g, i)
+				errs <- session.Do(func(card *nfc.NfcCard) error {
+					err := card.WriteLoraJoinEui(joinEUI)
+					if err != nil {
+						return err
+					}
+					read, err := card.ReadLoraJoinEui()
+					if err != nil {
+						return err
+					}
+					if !strings.EqualFold(strings.ReplaceAll(read, ":", ""), joinEUI) {
+						return fmt.Errorf("read JoinEUI %s after writing %s", read, joinEUI)
+					}
+					return card.ValidateCRC()
+				})
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	card, err := session.Card()
+	if err != nil {
+		t.Fatal(err)
+	}
+	final, err := card.ReadLoraJoinEui()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !written[strings.ToUpper(strings.ReplaceAll(final, ":", ""))] {
+		t.Errorf("final JoinEUI %s was never written", final)
+	}
+	if !tag.CRCValid() {
+		t.Error("CRC invalid after the concurrent writes")
+	}
+}
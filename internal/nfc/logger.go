@@ -0,0 +1,35 @@
+package nfc
+
+import (
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// Logger is the logging interface used by the nfc package. The internal log package satisfies it,
+// applications embedding the package can provide their own implementation via SetLogger.
+type Logger interface {
+	Debugf(string, ...interface{})
+	Info(...interface{})
+	Infof(string, ...interface{})
+	Warn(...interface{})
+	Warnf(string, ...interface{})
+	Error(...interface{})
+	Errorf(string, ...interface{})
+}
+
+// defaultLogger is used by new cards and by the package level print helpers
+var defaultLogger Logger = log.WithFields(nil)
+
+// SetDefaultLogger replaces the logger used by cards created afterwards and by the package level helpers
+func SetDefaultLogger(l Logger) {
+	defaultLogger = l
+}
+
+// SetLogger replaces the logger of this card
+func (m *NfcCard) SetLogger(l Logger) {
+	m.log = l
+}
+
+// Logger returns the logger of this card
+func (m *NfcCard) Logger() Logger {
+	return m.log
+}
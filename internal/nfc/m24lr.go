@@ -0,0 +1,165 @@
+package nfc
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// M24LR custom commands (M24LRxxE-R datasheet), sent with the ST manufacturer code
+const (
+	M24LR_CMD_READ_CFG      = 0xA0
+	M24LR_CMD_WRITE_EH_CFG  = 0xA1
+	M24LR_CMD_SET_RST_EH_EN = 0xA2
+	M24LR_CMD_CHECK_EH_EN   = 0xA3
+	M24LR_CMD_WRITE_DO_CFG  = 0xA4
+	M24LR_MANUFACTURER_CODE = 0x02
+
+	// bits of the configuration byte, kept in EEPROM
+	M24LR_CFG_EH_LOAD = 0x03 // energy harvesting output, see EHLoads
+	M24LR_CFG_EH_MODE = 0x04 // 0: energy harvesting enabled at power up, 1: on demand (SetEnergyHarvesting)
+	M24LR_CFG_RF_WIP  = 0x08 // 0: RF BUSY on the digital output, 1: RF WIP
+
+	// bits of the volatile control register
+	M24LR_CTRL_EH_EN    = 0x01
+	M24LR_CTRL_EH_ON    = 0x02
+	M24LR_CTRL_FIELD_ON = 0x04
+	M24LR_CTRL_T_PROG   = 0x08
+)
+
+// EHLoads are the energy harvesting output currents by M24LR_CFG_EH_LOAD value
+var EHLoads = []string{"6 mA", "3 mA", "1 mA", "300 uA"}
+
+// M24LRConfig is the energy harvesting and digital output configuration of an M24LR
+type M24LRConfig struct {
+	// Config is the configuration byte (EEPROM)
+	Config byte
+	// Control is the control register (volatile)
+	Control byte
+}
+
+// EHLoad returns the energy harvesting output setting, an index of EHLoads
+func (c *M24LRConfig) EHLoad() int {
+	return int(c.Config & M24LR_CFG_EH_LOAD)
+}
+
+// EHAtPowerUp reports whether energy harvesting is enabled when the tag powers up
+func (c *M24LRConfig) EHAtPowerUp() bool {
+	return c.Config&M24LR_CFG_EH_MODE == 0
+}
+
+// RFWIP reports whether the digital output signals RF WIP (write in progress) rather than RF BUSY
+func (c *M24LRConfig) RFWIP() bool {
+	return c.Config&M24LR_CFG_RF_WIP != 0
+}
+
+// EHEnabled reports whether energy harvesting is enabled now
+func (c *M24LRConfig) EHEnabled() bool {
+	return c.Control&M24LR_CTRL_EH_EN != 0
+}
+
+// ReadM24LRConfig reads the configuration byte and the control register of an M24LR
+func (m *NfcCard) ReadM24LRConfig() (*M24LRConfig, error) {
+	config, err := m.m24lrCommand(M24LR_CMD_READ_CFG, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration byte: %w", err)
+	}
+	control, err := m.m24lrCommand(M24LR_CMD_CHECK_EH_EN, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read control register: %w", err)
+	}
+	if len(config) != 1 || len(control) != 1 {
+		return nil, fmt.Errorf("M24LR configuration: %w", ErrInvalidLength)
+	}
+	return &M24LRConfig{Config: config[0], Control: control[0]}, nil
+}
+
+// WriteEHConfig writes the energy harvesting bits of the configuration byte: the output setting
+// (an index of EHLoads) and whether energy harvesting is enabled at power up
+func (m *NfcCard) WriteEHConfig(load int, atPowerUp bool) error {
+	if load < 0 || load >= len(EHLoads) {
+		return fmt.Errorf("invalid energy harvesting load %d, expected 0-%d", load, len(EHLoads)-1)
+	}
+	value := byte(load)
+	if !atPowerUp {
+		value |= M24LR_CFG_EH_MODE
+	}
+	err := m.verifyPinnedUID()
+	if err != nil {
+		return err
+	}
+	_, err = m.m24lrCommand(M24LR_CMD_WRITE_EH_CFG, []byte{value})
+	if err != nil {
+		return fmt.Errorf("failed to write energy harvesting configuration: %w", err)
+	}
+	m.log.Infof("Energy harvesting configuration set to %s, at power up: %t", EHLoads[load], atPowerUp)
+	return nil
+}
+
+// SetEnergyHarvesting enables or disables energy harvesting until the tag powers down
+func (m *NfcCard) SetEnergyHarvesting(on bool) error {
+	value := byte(0)
+	if on {
+		value = M24LR_CTRL_EH_EN
+	}
+	err := m.verifyPinnedUID()
+	if err != nil {
+		return err
+	}
+	_, err = m.m24lrCommand(M24LR_CMD_SET_RST_EH_EN, []byte{value})
+	if err != nil {
+		return fmt.Errorf("failed to set energy harvesting: %w", err)
+	}
+	return nil
+}
+
+// WriteRFWIP configures the digital output of the tag as RF WIP (true) or RF BUSY (false)
+func (m *NfcCard) WriteRFWIP(wip bool) error {
+	value := byte(0)
+	if wip {
+		value = M24LR_CFG_RF_WIP
+	}
+	err := m.verifyPinnedUID()
+	if err != nil {
+		return err
+	}
+	_, err = m.m24lrCommand(M24LR_CMD_WRITE_DO_CFG, []byte{value})
+	if err != nil {
+		return fmt.Errorf("failed to write RF WIP/BUSY configuration: %w", err)
+	}
+	return nil
+}
+
+// m24lrCommand sends an M24LR custom command addressed to the presented tag and returns the
+// response after the flags. Tags of other manufacturers do not know the commands.
+func (m *NfcCard) m24lrCommand(command byte, data []byte) ([]byte, error) {
+	uid, err := m.transmit("FFCA000000", 0x9000)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get UID: %w", err)
+	}
+	uidBytes, err := hex.DecodeString(uid)
+	if err != nil || len(uidBytes) != 8 {
+		return nil, fmt.Errorf("invalid UID %q", uid)
+	}
+	if uidBytes[1] != M24LR_MANUFACTURER_CODE {
+		return nil, fmt.Errorf("tag %s is not an ST M24LR: %w", uid, ErrNotSupported)
+	}
+	frame := []byte{ISO15693_FLAG_HIGH_DATA_RATE | ISO15693_FLAG_ADDRESSED, command, M24LR_MANUFACTURER_CODE}
+	for i := len(uidBytes) - 1; i >= 0; i-- {
+		frame = append(frame, uidBytes[i])
+	}
+	frame = append(frame, data...)
+	resp, err := m.Transceive(frame)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) == 0 {
+		return nil, ErrShortResponse
+	}
+	if resp[0]&ISO15693_FLAG_ERROR != 0 {
+		if len(resp) < 2 {
+			return nil, ErrShortResponse
+		}
+		return nil, fmt.Errorf("tag error code %02X", resp[1])
+	}
+	return resp[1:], nil
+}
@@ -0,0 +1,102 @@
+package nfc
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+)
+
+// MOBILE_BUNDLE_FORMAT identifies the layout of the bundle consumed by the mobile NFC app, bump it
+// when the meaning of the fields changes
+const MOBILE_BUNDLE_FORMAT = "hidnfc-config/1"
+
+// MOBILE_DEEP_LINK is the deep link prefix handled by the mobile NFC app, the bundle follows as
+// base64url(deflate(JSON))
+const MOBILE_DEEP_LINK = "hidnfc://apply?d="
+
+// MobileBlock is one block the mobile app writes. Mask is set for blocks which are written only in
+// part: the app keeps the bytes of the tag where the mask is 00.
+type MobileBlock struct {
+	Block int    `json:"block"`
+	Data  string `json:"data"`
+	Mask  string `json:"mask,omitempty"`
+}
+
+// MobileCRC tells the mobile app how to update the configuration CRC after writing the blocks
+type MobileCRC struct {
+	Block     int    `json:"block"`
+	FirstData int    `json:"firstBlock"`
+	LastData  int    `json:"lastBlock"`
+	Algorithm string `json:"algorithm"`
+	// Layout of the CRC block, the CRC is stored least significant byte first
+	Layout string `json:"layout"`
+}
+
+// MobileBundle is the configuration of a tag in the format of the mobile NFC app. Device specific
+// blocks (see IsDeviceSpecificBlock) are not part of the bundle.
+type MobileBundle struct {
+	Format string         `json:"format"`
+	Tool   buildinfo.Info `json:"tool"`
+	Source string         `json:"source,omitempty"`
+	Blocks []MobileBlock  `json:"blocks"`
+	CRC    MobileCRC      `json:"crc"`
+}
+
+// NewMobileBundle creates the mobile bundle of a configuration image as written by GenerateConfigBin,
+// source names the tag UID or file the image came from
+func NewMobileBundle(config []byte, source string) (*MobileBundle, error) {
+	if len(config) != ASSET_PLUS_CRC_BLOCK*4 {
+		return nil, fmt.Errorf("configuration image has %d bytes, expected %d: %w", len(config), ASSET_PLUS_CRC_BLOCK*4, ErrInvalidLength)
+	}
+	bundle := &MobileBundle{
+		Format: MOBILE_BUNDLE_FORMAT,
+		Tool:   buildinfo.Version(),
+		Source: source,
+		CRC: MobileCRC{
+			Block:     ASSET_PLUS_CRC_BLOCK,
+			FirstData: 0,
+			LastData:  ASSET_PLUS_CRC_BLOCK - 1,
+			Algorithm: "CRC-16/CCITT-FALSE",
+			Layout:    "LSB MSB 00 00",
+		},
+	}
+	for block := 0; block < ASSET_PLUS_CRC_BLOCK; block++ {
+		if IsDeviceSpecificBlock(block) {
+			continue
+		}
+		b := MobileBlock{Block: block, Data: strings.ToUpper(hex.EncodeToString(config[block*4 : block*4+4]))}
+		if block == ASSET_PLUS_BLE_MAC_LSB {
+			// the first two bytes hold the end of the BLE MAC
+			b.Mask = "0000FFFF"
+		}
+		bundle.Blocks = append(bundle.Blocks, b)
+	}
+	return bundle, nil
+}
+
+// DeepLink returns the bundle as a deep link of the mobile app, e.g. for rendering as a QR code
+func (b *MobileBundle) DeepLink() (string, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode mobile bundle: %w", err)
+	}
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", fmt.Errorf("failed to compress mobile bundle: %w", err)
+	}
+	_, err = w.Write(data)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to compress mobile bundle: %w", err)
+	}
+	return MOBILE_DEEP_LINK + base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
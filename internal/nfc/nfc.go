@@ -8,17 +8,36 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf16"
 
-	"bitbucket.org/bluvision-cloud/kit/log"
 	"bitbucket.org/bluvision/pcsc/pcsc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
 )
 
 // NfcCard represents a M24LR series RFID tag
 type NfcCard struct {
-	uid    string
-	Reader pcsc.Card
+	uid       string
+	pinnedUID string
+	// fieldChecked is the pinned UID for which no other tags were found in the field, see SetSingleTagCheck
+	fieldChecked string
+	Reader       CardTransport
+	log          Logger
+	quirks       *ReaderQuirks
+	readChunk    int            // blocks per read APDU, 0 until negotiated, see ReadChunk
+	blockSize    int            // block size of the tag in bytes, 0 until detected, see BlockSize
+	blocks       int            // number of blocks of the tag, 0 until detected, see Blocks
+	cache        map[int][]byte // blocks read and written, see SetBlockCache
+	// beaconType is the beacon type of the tag once beaconTypeRead, see TagBeaconType
+	beaconType     *BeaconInfo
+	beaconTypeRead bool
+
+	apduMu      sync.Mutex // serializes the exchanges with the transport
+	txMu        sync.Mutex // held by Transaction for multi-APDU operations
+	apduTimeout time.Duration
+	deadline    time.Time
+	stalled     bool // an exchange timed out and may still be pending
 }
 
 // BeaconType represents the type of beacon
@@ -57,47 +76,67 @@ type LoRaSettings struct {
 	GNSSMin         int
 	GNSSMax         int
 	DOP             float64
-	RangeThreshold  int
-	SensorPeriod    int
-	RangeOffset     int
-	MaximumRange    int
+	// SenseRange is set when the beacon type of the tag holds the Sense Range settings, the Range
+	// fields below are zero otherwise
+	SenseRange     bool
+	RangeThreshold int
+	SensorPeriod   int
+	RangeOffset    int
+	MaximumRange   int
 }
 
-// ReadLoRaSettings reads all LoRa settings from the RFID tag
-func (m *NfcCard) ReadLoRaSettings(tagType int) (*LoRaSettings, error) {
-	settings := &LoRaSettings{}
+// ReadLoRaSettings reads all LoRa settings from the RFID tag, fields which cannot be decoded are logged and left empty
+func (m *NfcCard) ReadLoRaSettings() (*LoRaSettings, error) {
+	settings, warnings, err := m.ReadLoRaSettingsMode(ParseLenient)
+	for _, warning := range warnings {
+		m.log.Warnf("LoRa settings: %v", warning)
+	}
+	return settings, err
+}
 
+// ReadLoRaSettingsMode reads all LoRa settings from the RFID tag and decodes them with the given mode
+func (m *NfcCard) ReadLoRaSettingsMode(mode ParseMode) (*LoRaSettings, []error, error) {
 	blocks := make(map[int]string)
 	for i := 8; i <= 15; i++ {
 		block, err := m.ReadBlock(i)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read block %d: %v", i, err)
+			return nil, nil, fmt.Errorf("failed to read block %d: %w", i, err)
 		}
 		blocks[i] = block
 	}
+	return DecodeLoRaSettings(blocks, mode)
+}
+
+// DecodeLoRaSettings decodes the LoRa settings from the hex strings of blocks 8-15. The Range fields
+// are decoded for the Sense Range beacon types of block 15 only, see BeaconInfo.SenseRange, and are
+// zero for types not in the table.
+// In lenient mode the returned warnings hold a ParseError for every field which could not be decoded.
+func DecodeLoRaSettings(blocks map[int]string, mode ParseMode) (*LoRaSettings, []error, error) {
+	settings := &LoRaSettings{}
+	d := newBlockDecoder(blocks, mode)
 
 	// Parse Block 15
-	settings.HardwareVersion = blocks[15][1:2]
-	fwInt, _ := strconv.ParseInt(blocks[15][2:4], 16, 0)
+	settings.HardwareVersion = strings.ToUpper(d.str(15, 1, 2))
+	fwInt := d.hex(15, 2, 4)
 	settings.FirmwareVersion = fmt.Sprintf("%.1f", float64(fwInt)/10)
-	settings.BeaconType, _ = strconv.Atoi(blocks[15][4:6])
+	settings.BeaconType = int(d.hex(15, 4, 6))
 
 	// Parse Block 13
-	switch blocks[13][2:3] {
+	switch d.str(13, 2, 3) {
 	case "1":
 		settings.SleepState = "Asleep"
 	case "0":
 		settings.SleepState = "Awake"
 	}
 
-	switch blocks[13][4:5] {
+	switch d.str(13, 4, 5) {
 	case "0":
 		settings.MinMaxThreshold = "Below"
 	case "1":
 		settings.MinMaxThreshold = "Above"
 	}
 
-	switch blocks[13][6:7] {
+	switch d.str(13, 6, 7) {
 	case "0":
 		settings.RangeType = "Short 1.3m"
 	case "1":
@@ -105,60 +144,39 @@ func (m *NfcCard) ReadLoRaSettings(tagType int) (*LoRaSettings, error) {
 	}
 
 	// Parse Block 8
-	sfInt, _ := strconv.ParseInt(blocks[8][:2], 16, 0)
-	if sfInt == 255 {
-		settings.SpreadingFactor = "ADR"
-	} else {
-		settings.SpreadingFactor = fmt.Sprintf("%d", sfInt)
-	}
-	settings.DownlinkBitRate, _ = strconv.Atoi(blocks[8][2:4])
-	settings.UplinkBitRate, _ = strconv.Atoi(blocks[8][4:6])
-	tempHigh, _ := strconv.ParseInt(blocks[8][6:], 16, 0)
-	settings.HighTemperature = int(tempHigh) - 127
+	settings.SpreadingFactor = parseSpreadingFactor(d.hex(8, 0, 2))
+	settings.DownlinkBitRate = d.dec(8, 2, 4)
+	settings.UplinkBitRate = d.dec(8, 4, 6)
+	settings.HighTemperature = int(d.hex(8, 6, 8)) - 127
 
 	// Parse Block 9
-	tempLow, _ := strconv.ParseInt(blocks[9][:2], 16, 0)
-	settings.LowTemperature = int(tempLow) - 127
-	settings.Accelerometer, _ = strconv.Atoi(blocks[9][2:4])
-
-	if tagType == 3 { // Sense Range
-		settings.RangeThreshold, _ = strconv.Atoi(blocks[9][4:])
-		settings.SensorPeriod, _ = strconv.Atoi(blocks[10][:2])
-		settings.RangeOffset, _ = strconv.Atoi(blocks[10][4:6])
-		maxRange, _ := strconv.Atoi(blocks[10][6:])
-		settings.MaximumRange = maxRange * 10
+	settings.LowTemperature = int(d.hex(9, 0, 2)) - 127
+	settings.Accelerometer = d.dec(9, 2, 4)
+
+	// blocks 9-10 of unknown and blank beacon types are not assumed to hold the Sense Range settings
+	if block15 := blocks[15]; len(block15) >= 6 {
+		info, err := getBeaconInfo(block15[4:6])
+		if err == nil {
+			settings.SenseRange = info.SenseRange()
+		}
+	}
+	if settings.SenseRange {
+		settings.RangeThreshold = d.dec(9, 4, 8)
+		settings.SensorPeriod = d.dec(10, 0, 2)
+		settings.RangeOffset = d.dec(10, 4, 6)
+		settings.MaximumRange = d.dec(10, 6, 8) * 10
 	}
 
 	// Parse Block 14
-	settings.GNSSMin, _ = strconv.Atoi(blocks[14][:2])
-	settings.GNSSMax, _ = strconv.Atoi(blocks[14][2:4])
-	dop, _ := strconv.ParseFloat(blocks[14][4:6], 64)
-	settings.DOP = dop / 10
-
-	return settings, nil
-}
-
-func printLoRaSettings(settings *LoRaSettings) {
-	fmt.Println("LoRa Settings:")
-	log.Infof("Beacon Type: %d\n", settings.BeaconType)
-	log.Infof("Hardware Version: %s\n", settings.HardwareVersion)
-	log.Infof("Firmware Version: %s\n", settings.FirmwareVersion)
-	log.Infof("Sleep State: %s\n", settings.SleepState)
-	log.Infof("Min/Max Threshold: %s\n", settings.MinMaxThreshold)
-	log.Infof("Range Type: %s\n", settings.RangeType)
-	log.Infof("Spreading Factor: %s\n", settings.SpreadingFactor)
-	log.Infof("Downlink Bit Rate: %d\n", settings.DownlinkBitRate)
-	log.Infof("Uplink Bit Rate: %d\n", settings.UplinkBitRate)
-	log.Infof("High Temperature: %d\n", settings.HighTemperature)
-	log.Infof("Low Temperature: %d\n", settings.LowTemperature)
-	log.Infof("Accelerometer: %d\n", settings.Accelerometer)
-	log.Infof("GNSS Min: %d\n", settings.GNSSMin)
-	log.Infof("GNSS Max: %d\n", settings.GNSSMax)
-	log.Infof("DOP: %.1f\n", settings.DOP)
-	log.Infof("Range Threshold: %d\n", settings.RangeThreshold)
-	log.Infof("Sensor Period: %d\n", settings.SensorPeriod)
-	log.Infof("Range Offset: %d\n", settings.RangeOffset)
-	log.Infof("Maximum Range: %d\n", settings.MaximumRange)
+	settings.GNSSMin = d.dec(14, 0, 2)
+	settings.GNSSMax = d.dec(14, 2, 4)
+	settings.DOP = float64(d.dec(14, 4, 6)) / 10
+
+	warnings, err := d.result()
+	if err != nil {
+		return nil, nil, err
+	}
+	return settings, warnings, nil
 }
 
 // BeaconInfo holds information about the beacon type
@@ -166,6 +184,20 @@ type BeaconInfo struct {
 	BeaconType string
 	Name       string
 	Image      string // In Go, we'll just store the image name/path
+	// Products are the product command sets (e.g. "Asset+") whose commands run on the type, nil for all
+	Products []string
+	// Fields are the LoRa identity fields the type holds (see FIELD_DEVEUI), nil for all
+	Fields []string
+	// Required are the fields run-manifest must provision on tags of the type
+	Required []string
+	// Blocks are the blocks settings may be written to, e.g. "0-47,64-", empty for all
+	Blocks string
+	// Features are the features of the type (see FEATURE_CLASS_B), nil for all, see Capabilities
+	Features []string
+	// BLEBeacon is set for BLE beacons, the only types whose blocks 10-14 hold an iBeacon or Eddystone
+	// identity. On LoRa types these blocks hold the DevEUI, the sleep/debug byte and GNSS settings.
+	BLEBeacon bool
+	blocks    []blockRange
 }
 
 // APDUInfo holds the parsed information from an APDU response
@@ -180,7 +212,7 @@ func ParseAPDU(apdu []uint8) (APDUInfo, error) {
 	info := APDUInfo{}
 
 	if len(apdu) < 5 {
-		return info, fmt.Errorf("APDU too short")
+		return info, fmt.Errorf("APDU of %d bytes: %w", len(apdu), ErrShortResponse)
 	}
 
 	// Extract status words (last two bytes)
@@ -188,18 +220,18 @@ func ParseAPDU(apdu []uint8) (APDUInfo, error) {
 
 	// Check for successful processing (SW1SW2 = 9000)
 	if info.StatusWords != [2]byte{0x90, 0x00} {
-		return info, fmt.Errorf("unsuccessful processing: SW1SW2 = %02X%02X", info.StatusWords[0], info.StatusWords[1])
+		return info, &StatusError{SW: uint16(info.StatusWords[0])<<8 | uint16(info.StatusWords[1])}
 	}
 
 	// Check for the response tag (BD or 9D)
 	if apdu[0] != 0xBD && apdu[0] != 0x9D {
-		return info, fmt.Errorf("unexpected response tag: %02X", apdu[0])
+		return info, fmt.Errorf("%w: %02X", ErrUnexpectedTag, apdu[0])
 	}
 
 	// Extract the inner TLV
 	innerLength := int(apdu[1])
 	if len(apdu) < innerLength+2 {
-		return info, fmt.Errorf("APDU length mismatch")
+		return info, fmt.Errorf("APDU length mismatch, inner length %d: %w", innerLength, ErrInvalidLength)
 	}
 
 	info.Tag = apdu[2]
@@ -207,7 +239,7 @@ func ParseAPDU(apdu []uint8) (APDUInfo, error) {
 	valueEnd := 4 + valueLength
 
 	if valueEnd > len(apdu)-2 {
-		return info, fmt.Errorf("invalid value length")
+		return info, fmt.Errorf("value length %d exceeds response: %w", valueLength, ErrInvalidLength)
 	}
 
 	// Extract the value based on the tag
@@ -230,100 +262,106 @@ func ParseAPDU(apdu []uint8) (APDUInfo, error) {
 func (m *NfcCard) ReadSKU() (*BeaconInfo, error) {
 	block, err := m.ReadBlock(15)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read block 15: %v", err)
+		return nil, fmt.Errorf("failed to read block 15: %w", err)
 	}
 
-	beaconType := block[4:6]
+	beaconType := strings.ToUpper(block[4:6])
 	currentBeaconType, err = strconv.ParseUint(beaconType, 16, 16)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse beacon type: %v", err)
+		return nil, fmt.Errorf("failed to parse beacon type: %w", err)
 	}
 	return getBeaconInfo(beaconType)
 }
 
-func getBeaconInfo(beaconType string) (*BeaconInfo, error) {
-	info := &BeaconInfo{BeaconType: beaconType}
+// BeaconTypes lists the beacon types (SKUs) which can be written by WriteSKU, the writable types of
+// the built in table followed by those added with AddBeaconType or LoadBeaconTypes
+var BeaconTypes []string
 
-	switch beaconType {
-	case "00", "01", "FF":
-		info.Name = "Please select the tag type"
-		info.Image = ""
-	case "0D":
-		info.Name = "Sense Asset BLE"
-		info.Image = "Sense_BLE_Small"
-	case "12":
-		info.Name = "Sense Asset XL"
-		info.Image = "Asset_Small"
-	case "09":
-		info.Name = "Sense Condition Range Finder"
-		info.Image = "Range_Small"
-	case "08":
-		info.Name = "Sense Condition Alert"
-		info.Image = "Button_Small"
-	case "14":
-		info.Name = "Sense Shield/Badge/Lite"
-		info.Image = "Social2"
-	case "15":
-		info.Name = "Sense Asset +"
-		info.Image = "Ditto_correct_200_trans"
-	case "13":
-		info.Name = "Sense Asset Temp"
-		info.Image = "Sense_BLE_Small"
-	case "16":
-		info.Name = "Sense Asset"
-		info.Image = "Sense_BLE_Small"
-	case "17":
-		info.Name = "Sense Wirepass"
-		info.Image = "Social2"
-	default:
-		return nil, fmt.Errorf("unknown beacon type: %s", beaconType)
+// WriteSKU writes the beacon type (SKU) to block 15, giving a blank tag its product personality.
+// sku is the beacon type in hex (e.g. "15") or its name (e.g. "Sense Asset +").
+func (m *NfcCard) WriteSKU(sku string) (*BeaconInfo, error) {
+	info, err := FindBeaconType(sku)
+	if err != nil {
+		return nil, err
 	}
 
-	return info, nil
+	block, err := m.ReadBlock(15)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read block 15: %w", err)
+	}
+	m.log.Infof("Writing beacon type %s (%s), block 15: %s", info.BeaconType, info.Name, block)
+	_, err = m.WriteBlock(15, block[0:4]+info.BeaconType+block[6:8])
+	if err != nil {
+		return nil, fmt.Errorf("failed to write block 15: %w", err)
+	}
+	currentBeaconType, _ = strconv.ParseUint(info.BeaconType, 16, 16)
+	return info, m.CalculateAndWriteCRC()
 }
 
-func printBeaconInfo(info *BeaconInfo) {
-	fmt.Println("Beacon Information:")
-	log.Infof("Type: %s\n", info.BeaconType)
-	log.Infof("Name: %s\n", info.Name)
-	log.Infof("Image: %s\n", info.Image)
+// FindBeaconType looks up a writable beacon type by hex code or name, e.g. "15" or "Sense Asset +"
+func FindBeaconType(sku string) (*BeaconInfo, error) {
+	beaconTypesMu.RLock()
+	writable := BeaconTypes
+	beaconTypesMu.RUnlock()
+	var names []string
+	for _, beaconType := range writable {
+		info, err := getBeaconInfo(beaconType)
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(sku, beaconType) || strings.EqualFold(sku, info.Name) {
+			return info, nil
+		}
+		names = append(names, fmt.Sprintf("%s (%s)", beaconType, info.Name))
+	}
+	return nil, fmt.Errorf("unknown beacon type '%s', expected one of: %s", sku, strings.Join(names, ", "))
 }
 
 // LoraInfo Structure to hold LoRa information
 type LoraInfo struct {
-	Timestamp string
+	// Time is when the tag was read, exports format it with -timezone and -time-format
+	Time      time.Time
 	DevEUI    string
 	JoinEUI   string
 	JoinKey   string
 	CRCStatus string
+	// AssetID is the customer asset ID, empty if none was written (see WriteAssetID)
+	AssetID string
 }
 
 func (m *NfcCard) ReadLoraInfo() (*LoraInfo, error) {
 	info := &LoraInfo{
-		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		Time: time.Now(),
 	}
 
 	// Read DevEUI
 	devEui, err := m.ReadLoraDevEui()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read DevEUI: %v", err)
+		return nil, fmt.Errorf("failed to read DevEUI: %w", err)
 	}
 	info.DevEUI = strings.ToUpper(devEui)
 
 	// Read JoinEUI
 	joinEui, err := m.ReadLoraJoinEui()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read JoinEUI: %v", err)
+		return nil, fmt.Errorf("failed to read JoinEUI: %w", err)
 	}
 	info.JoinEUI = strings.ToUpper(joinEui)
 
 	// Read JoinKey
 	joinKey, err := m.ReadLoraJoinKey()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read JoinKey: %v", err)
+		return nil, fmt.Errorf("failed to read JoinKey: %w", err)
 	}
 	info.JoinKey = strings.ToUpper(joinKey)
 
+	// best effort: blocks 32-47 were spare before the user data area, older tags may hold other data there
+	info.AssetID, err = m.ReadAssetID()
+	if err != nil {
+		m.log.Warnf("Failed to read asset ID: %v", err)
+		info.AssetID = ""
+	}
+
 	// Validate CRC
 	err = m.ValidateCRC()
 	if err != nil {
@@ -336,29 +374,52 @@ func (m *NfcCard) ReadLoraInfo() (*LoraInfo, error) {
 }
 
 func (m *NfcCard) EraseTag() error {
-	log.Info("Starting NFC tag erasure by writing 0xFFFFFFFF to all blocks...")
+	m.log.Info("Starting NFC tag erasure by writing 0xFFFFFFFF to all blocks...")
 
 	// Write 0xFFFFFFFF to blocks 0-48
 	zeroBlock := "ffffffff" // 4 bytes of Fs in hex
 
 	for block := 0; block <= 48; block++ {
-		log.Infof("Erasing block %d...", block)
+		m.log.Infof("Erasing block %d...", block)
 		_, err := m.WriteBlock(block, zeroBlock)
 		if err != nil {
-			return fmt.Errorf("failed to erase block %d: %v", block, err)
+			return fmt.Errorf("failed to erase block %d: %w", block, err)
 		}
 	}
 
 	// Calculate and write CRC for the zeroed configuration
 	err := m.CalculateAndWriteCRC()
 	if err != nil {
-		return fmt.Errorf("failed to write CRC after erasure: %v", err)
+		return fmt.Errorf("failed to write CRC after erasure: %w", err)
 	}
 
-	log.Info("NFC tag erasure completed successfully")
+	m.log.Info("NFC tag erasure completed successfully")
 	return nil
 }
 
+// EraseKeys writes 0xFFFFFFFF to the blocks holding key material (the JoinKey, see IsSecretBlock)
+// and rewrites the CRC. Unlike EraseTag the factory BLE MAC (blocks 18-19), the EUIs and the rest
+// of the configuration are kept, so a decommissioned tag stays identifiable.
+// Returns the erased blocks.
+func (m *NfcCard) EraseKeys() ([]int, error) {
+	var erased []int
+	for block := 0; block < ASSET_PLUS_CONFIG_BLOCKS; block++ {
+		if !IsSecretBlock(block) {
+			continue
+		}
+		_, err := m.WriteBlock(block, "ffffffff")
+		if err != nil {
+			return erased, fmt.Errorf("failed to erase block %d: %w", block, err)
+		}
+		erased = append(erased, block)
+	}
+	err := m.CalculateAndWriteCRC()
+	if err != nil {
+		return erased, fmt.Errorf("failed to write CRC after erasing the keys: %w", err)
+	}
+	return erased, nil
+}
+
 // ReadUUID reads the UUID and related information based on the beacon type
 func (m *NfcCard) ReadUUID(beaconType uint64) (*UUIDInfo, error) {
 	blocks := make([]string, 6)
@@ -367,7 +428,7 @@ func (m *NfcCard) ReadUUID(beaconType uint64) (*UUIDInfo, error) {
 	for i := 10; i <= 15; i++ {
 		blocks[i-10], err = m.ReadBlock(i)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read block %d: %v", i, err)
+			return nil, fmt.Errorf("failed to read block %d: %w", i, err)
 		}
 	}
 
@@ -390,25 +451,72 @@ func (m *NfcCard) ReadUUID(beaconType uint64) (*UUIDInfo, error) {
 	return info, nil
 }
 
-// NewCardReader creates a new NfcCard instance
-func NewCardReader(reader pcsc.Reader) (*NfcCard, error) {
+// WriteIBeaconIdentity writes the iBeacon proximity UUID to blocks 10-13 and the major and minor
+// to block 14, the layout read by ReadUUID for beacon type 1, and updates the CRC.
+// uuid is 32 hex characters, dashes are allowed (e.g. "f7826da6-4fa2-4e98-8024-bc5b71e0893e").
+// Tags which are not BLE beacons are refused, see CheckBLEBeacon.
+func (m *NfcCard) WriteIBeaconIdentity(uuid string, major, minor uint16) error {
+	uuidHex := strings.ReplaceAll(uuid, "-", "")
+	uuidBytes, err := hex.DecodeString(uuidHex)
+	if err != nil || len(uuidBytes) != 16 {
+		return fmt.Errorf("invalid iBeacon UUID '%s', expected 16 bytes in hex", uuid)
+	}
+	uuidHex = strings.ToUpper(uuidHex)
+	err = m.CheckBLEBeacon()
+	if err != nil {
+		return err
+	}
 
-	card, err := reader.ConnectCardPCSC()
+	m.log.Infof("Writing iBeacon identity: UUID %s, major %d, minor %d", uuid, major, minor)
+	for i := 0; i < 4; i++ {
+		_, err = m.WriteBlock(10+i, uuidHex[i*8:(i+1)*8])
+		if err != nil {
+			return fmt.Errorf("failed to write block %d: %w", 10+i, err)
+		}
+	}
+	_, err = m.WriteBlock(14, fmt.Sprintf("%04X%04X", major, minor))
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to card: %v", err)
+		return fmt.Errorf("failed to write block 14: %w", err)
 	}
+	return m.CalculateAndWriteCRC()
+}
+
+// WriteEddystoneIdentity writes the Eddystone-UID namespace (10 bytes) and instance (6 bytes) to
+// blocks 10-13, the layout read by ReadUUID for beacon type 2, and updates the CRC. Tags which are
+// not BLE beacons are refused, see CheckBLEBeacon.
+func (m *NfcCard) WriteEddystoneIdentity(namespace string, instance uint64) error {
+	namespaceBytes, err := hex.DecodeString(namespace)
+	if err != nil || len(namespaceBytes) != 10 {
+		return fmt.Errorf("invalid Eddystone namespace '%s', expected 10 bytes in hex", namespace)
+	}
+	if instance > 0xFFFFFFFFFFFF {
+		return fmt.Errorf("invalid Eddystone instance %d, must fit in 6 bytes", instance)
+	}
+	err = m.CheckBLEBeacon()
+	if err != nil {
+		return err
+	}
+	identity := fmt.Sprintf("%X%012X", namespaceBytes, instance)
 
-	m24lr := &NfcCard{
-		Reader: card,
+	m.log.Infof("Writing Eddystone identity: namespace %s, instance %012X", namespace, instance)
+	for i := 0; i < 4; i++ {
+		_, err = m.WriteBlock(10+i, identity[i*8:(i+1)*8])
+		if err != nil {
+			return fmt.Errorf("failed to write block %d: %w", 10+i, err)
+		}
 	}
+	return m.CalculateAndWriteCRC()
+}
 
-	err = m24lr.getUID()
+// NewCardReader creates a new NfcCard instance
+func NewCardReader(reader pcsc.Reader) (*NfcCard, error) {
+
+	cardTransport, err := ConnectPCSC(reader)
 	if err != nil {
-		card.DisconnectCard()
-		return nil, fmt.Errorf("failed to get UID: %v", err)
+		return nil, err
 	}
 
-	return m24lr, nil
+	return NewCard(cardTransport)
 }
 
 // UID returns the UID of the tag
@@ -418,14 +526,89 @@ func (m *NfcCard) UID() string {
 
 // ReadBlock reads a block from the tag
 func (m *NfcCard) ReadBlock(blockNumber int) (string, error) {
-	cmd := fmt.Sprintf("FFB0%04X04", blockNumber)
-	return m.transmit(cmd, 0x9000)
+	extended, err := m.extendedBlock(blockNumber)
+	if err != nil {
+		return "", err
+	}
+	var block string
+	if extended {
+		block, err = m.readExtendedBlock(blockNumber)
+	} else {
+		var cmd string
+		cmd, err = m.blockCommand(blockNumber, "")
+		if err != nil {
+			return "", err
+		}
+		block, err = m.transmit(cmd, 0x9000)
+	}
+	if err != nil {
+		return "", err
+	}
+	// every block parser slices the hex characters of a full block, never hand out anything else
+	if size := m.BlockSize(); len(block) != size*2 {
+		return "", &ParseError{Block: blockNumber, Offset: 0, Err: fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidLength, size, len(block)/2)}
+	}
+	m.cacheHexBlock(blockNumber, block)
+	return block, nil
 }
 
-// WriteBlock writes a block to the tag
+// WriteBlock writes a block to the tag. When the card is pinned the UID is verified first.
 func (m *NfcCard) WriteBlock(blockNumber int, block string) (string, error) {
-	cmd := fmt.Sprintf("FFD6%04X04%s", blockNumber, block)
-	return m.transmit(cmd, 0x9000)
+	err := m.verifyPinnedUID()
+	if err != nil {
+		return "", err
+	}
+	err = m.checkBlock(blockNumber, block)
+	if err != nil {
+		return "", err
+	}
+	return m.writeBlock(blockNumber, block)
+}
+
+// writeBlock writes a block without verifying the pinned UID
+func (m *NfcCard) writeBlock(blockNumber int, block string) (string, error) {
+	resp, _, err := m.rewriteBlock(blockNumber, block, 0)
+	return resp, err
+}
+
+// rewriteBlock writes a block which already took previous attempts, e.g. one rewritten because
+// it did not read back as written. BlockWritten reports the attempts of the block in total, which
+// are returned as well.
+func (m *NfcCard) rewriteBlock(blockNumber int, block string, previous int) (string, int, error) {
+	extended, err := m.extendedBlock(blockNumber)
+	if err != nil {
+		return "", previous, err
+	}
+	var resp string
+	attempts := 1
+	if extended {
+		err = m.writeExtendedBlock(blockNumber, block)
+	} else {
+		var cmd string
+		cmd, err = m.blockCommand(blockNumber, block)
+		if err != nil {
+			return "", previous, err
+		}
+		resp, attempts, err = m.transmitAttempts(cmd, 0x9000)
+	}
+	var status *StatusError
+	if errors.As(err, &status) && status.SW == swSecurityStatus {
+		err = fmt.Errorf("%w (%v)", ErrBlockLocked, err)
+	}
+	if err != nil {
+		m.uncacheBlock(blockNumber)
+	} else {
+		m.cacheHexBlock(blockNumber, block)
+	}
+	if blockNumber == 15 {
+		// the beacon type may have changed
+		m.beaconTypeRead = false
+	}
+	if attempts > 1 {
+		m.log.Warnf("Block %d needed %d write attempts", blockNumber, previous+attempts)
+	}
+	events.Publish(events.Event{Type: events.BlockWritten, UID: m.uid, Block: &blockNumber, Attempts: previous + attempts, OK: err == nil, Error: events.ErrorString(err)})
+	return resp, previous + attempts, err
 }
 
 // AFI returns the Application Family Identifier
@@ -444,52 +627,73 @@ func (m *NfcCard) MemorySize() (uint16, error) {
 	if err != nil {
 		return 0, err
 	}
+	if len(response) < 4 {
+		return 0, fmt.Errorf("memory size: %w", ErrShortResponse)
+	}
 	size, err := strconv.ParseUint(response[2:4], 16, 16)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse memory size: %v", err)
+		return 0, fmt.Errorf("failed to parse memory size: %w", err)
 	}
 	return uint16(size), nil
 }
 
-// ICReference is not supported
-func (m *NfcCard) ICReference() error {
-	return fmt.Errorf("ICReference: Instruction not supported")
+// ICReference returns the IC reference of the tag from get system info
+func (m *NfcCard) ICReference() (byte, error) {
+	info, err := m.ReadSystemInfo()
+	if err != nil {
+		return 0, err
+	}
+	if info.ICReference == nil {
+		return 0, fmt.Errorf("tag does not report an IC reference")
+	}
+	return *info.ICReference, nil
 }
 
 func (m *NfcCard) transmit(cmdHex string, expectedSW uint16) (string, error) {
-	var retries int
-	var resp []byte
-	var cmd []byte
-	var err error
-	cmd, err = hex.DecodeString(cmdHex)
-	if err != nil {
-		return "", err
-	}
-	for retries < 3 {
-		retries++
+	resp, _, err := m.transmitAttempts(cmdHex, expectedSW)
+	return resp, err
+}
 
-		resp, err = m.Reader.Apdu(cmd)
+// transmitAttempts is transmit, it also returns the number of times the APDU was sent
+func (m *NfcCard) transmitAttempts(cmdHex string, expectedSW uint16) (string, int, error) {
+	cmd, err := hex.DecodeString(cmdHex)
+	if err != nil {
+		return "", 0, err
+	}
+	m.apduMu.Lock()
+	defer m.apduMu.Unlock()
+	attempts := 0
+	for retries := 0; retries < 3; retries++ {
+		attempts++
+		var resp []byte
+		resp, err = m.exchange(cmd)
+		if errors.Is(err, ErrAPDUTimeout) || errors.Is(err, ErrOperationTimeout) {
+			break
+		}
 		if err != nil {
 			time.Sleep(50 * time.Millisecond)
 			continue
 		}
 		if len(resp) < 2 {
+			err = fmt.Errorf("%d byte response: %w", len(resp), ErrShortResponse)
 			time.Sleep(5 * time.Millisecond)
 			continue
 		}
 		sw := uint16(resp[len(resp)-2])<<8 | uint16(resp[len(resp)-1])
 		if sw != expectedSW {
-			log.Warnf("nfc error, response 0x% X", resp)
+			m.log.Warnf("nfc error, response 0x% X", resp)
+			err = &StatusError{SW: sw}
+			if sw == swSecurityStatus {
+				// the security status of the block does not change by retrying
+				break
+			}
 			time.Sleep(5 * time.Millisecond)
 			continue
 		}
-		break
-	}
-	if retries == 3 {
-		return "", fmt.Errorf("error in nfc card operation: %v", err)
+		return hex.EncodeToString(resp[:len(resp)-2]), attempts, nil
 	}
 
-	return hex.EncodeToString(resp[:len(resp)-2]), nil
+	return "", attempts, fmt.Errorf("error in nfc card operation: %w", err)
 }
 
 // ReadBleMac reads the LoRa and BLE MAC addresses from the tag
@@ -498,11 +702,11 @@ func (m *NfcCard) ReadBleMac() (string, error) {
 	// Read BLE MAC
 	bleDword1, err := m.ReadBlock(18)
 	if err != nil {
-		return "", fmt.Errorf("failed to read BLE block 18: %v", err)
+		return "", fmt.Errorf("failed to read BLE block 18: %w", err)
 	}
 	bleDword2, err := m.ReadBlock(19)
 	if err != nil {
-		return "", fmt.Errorf("failed to read BLE block 19: %v", err)
+		return "", fmt.Errorf("failed to read BLE block 19: %w", err)
 	}
 
 	BLEMacParts := []string{
@@ -514,16 +718,56 @@ func (m *NfcCard) ReadBleMac() (string, error) {
 	return BleMac, nil
 }
 
+// WriteBleMac writes the BLE MAC address (AA:BB:CC:DD:EE:FF) to blocks 18 and 19, stored least
+// significant byte first as read by ReadBleMac. The MAC is assigned in production, so the write
+// is only done if confirmUID matches the UID of the presented tag. The upper half of block 19 is kept.
+func (m *NfcCard) WriteBleMac(mac string, confirmUID string) error {
+	if !strings.EqualFold(confirmUID, m.uid) {
+		return fmt.Errorf("UID confirmation %q does not match tag UID %s", confirmUID, m.uid)
+	}
+	macBytes, err := hex.DecodeString(strings.NewReplacer(":", "", "-", "").Replace(mac))
+	if err != nil || len(macBytes) != 6 {
+		return fmt.Errorf("invalid BLE MAC '%s', expected 6 bytes in hex", mac)
+	}
+
+	bleDword2, err := m.ReadBlock(19)
+	if err != nil {
+		return fmt.Errorf("failed to read BLE block 19: %w", err)
+	}
+	block18 := fmt.Sprintf("%02X%02X%02X%02X", macBytes[5], macBytes[4], macBytes[3], macBytes[2])
+	block19 := fmt.Sprintf("%02X%02X%s", macBytes[1], macBytes[0], bleDword2[4:8])
+	m.log.Infof("Writing BLE MAC %s to tag %s: block 18 %s, block 19 %s", mac, m.uid, block18, block19)
+
+	_, err = m.WriteBlock(18, block18)
+	if err != nil {
+		return fmt.Errorf("failed to write BLE block 18: %w", err)
+	}
+	_, err = m.WriteBlock(19, block19)
+	if err != nil {
+		return fmt.Errorf("failed to write BLE block 19: %w", err)
+	}
+	return m.CalculateAndWriteCRC()
+}
+
+// ReadBLETxPower reads the BLE transmit power (e.g. "-4dBm") from block 19
+func (m *NfcCard) ReadBLETxPower() (string, error) {
+	block19, err := m.ReadBlock(19)
+	if err != nil {
+		return "", fmt.Errorf("failed to read BLE block 19: %w", err)
+	}
+	return parseBLEGain(block19[6:8]), nil
+}
+
 // ReadMACAddress reads the MAC address from blocks 11 and 12
 func (m *NfcCard) ReadMACAddress() (string, error) {
 	LoRa_Dword1, err := m.ReadBlock(11)
 	if err != nil {
-		return "", fmt.Errorf("failed to read block 11: %v", err)
+		return "", fmt.Errorf("failed to read block 11: %w", err)
 	}
 
 	LoRa_Dword2, err := m.ReadBlock(12)
 	if err != nil {
-		return "", fmt.Errorf("failed to read block 12: %v", err)
+		return "", fmt.Errorf("failed to read block 12: %w", err)
 	}
 
 	if len(LoRa_Dword1) != 8 || len(LoRa_Dword2) != 8 {
@@ -551,17 +795,17 @@ func (m *NfcCard) ReadLocalName() (string, error) {
 	for block := 3; block <= 6; block++ {
 		blockData, err := m.ReadBlock(block)
 		if err != nil {
-			return "", fmt.Errorf("failed to read block %d: %v", block, err)
+			return "", fmt.Errorf("failed to read block %d: %w", block, err)
 		}
-		log.Infof("blockData: %s\n", blockData)
+		m.log.Infof("blockData: %s\n", blockData)
 		readLocalValue.WriteString(blockData)
 	}
 
-	log.Infof("readLocalValue: %s\n", readLocalValue.String())
+	m.log.Infof("readLocalValue: %s\n", readLocalValue.String())
 	// Convert hex string to UTF-16 and then to string
 	hexBytes, err := hex.DecodeString(readLocalValue.String())
 	if err != nil {
-		return "", fmt.Errorf("failed to decode hex string: %v", err)
+		return "", fmt.Errorf("failed to decode hex string: %w", err)
 	}
 
 	utf16Ints := make([]uint16, len(hexBytes)/2)
@@ -579,36 +823,40 @@ func (m *NfcCard) ReadLocalName() (string, error) {
 
 // Close disconnects the card
 func (m *NfcCard) Close() error {
-	return m.Reader.DisconnectUnpowerCard()
+	m.apduMu.Lock()
+	defer m.apduMu.Unlock()
+	err := m.Reader.DisconnectUnpowerCard()
+	m.resetCache()
+	m.blockSize = 0
+	m.blocks = 0
+	m.beaconType, m.beaconTypeRead = nil, false
+	events.Publish(events.Event{Type: events.TagDisconnected, UID: m.uid, OK: err == nil, Error: events.ErrorString(err)})
+	return err
 }
 
 func (m *NfcCard) ReadBLELocalName() (string, error) {
-	log.Info("Reading BLE local name: ")
-	block22, err := m.ReadBlock(22)
+	m.log.Info("Reading BLE local name: ")
+	raw, err := m.ReadBLELocalNameBytes()
 	if err != nil {
 		return "", err
 	}
-	block23, err := m.ReadBlock(23)
-	if err != nil {
-		return "", err
-	}
-	localBleName := fmt.Sprintf("%s%s", block22, block23)
-	ascii, err := decodeHexToASCII(localBleName)
-	if err != nil {
-		return "", err
-	}
-	log.Infof("RawBlockData: %s, ASCII: %s\n", localBleName, ascii)
+	ascii := strings.TrimRight(string(raw), "\x00")
+	m.log.Infof("RawBlockData: %X, ASCII: %s\n", raw, ascii)
 	return ascii, nil
 }
 
 // WriteLoraJoinEui writes the LoRa Join EUI to blocks 0 and 1, this is the unique 64 bits EUI from the network (eg. Senet)
 func (m *NfcCard) WriteLoraJoinEui(joinEui string) error {
+	err := m.CheckFields(FIELD_JOINEUI)
+	if err != nil {
+		return err
+	}
 
 	//Split the key into 2 blocks
 	block1 := joinEui[:8]
 	block2 := joinEui[8:]
 
-	_, err := m.WriteBlock(0, block1)
+	_, err = m.WriteBlock(0, block1)
 	if err != nil {
 		return err
 	}
@@ -631,133 +879,90 @@ func (m *NfcCard) ReadLoraJoinEui() (string, error) {
 	return fmt.Sprintf("%s%s", joinEuiWord1, joinEuiWord2), nil
 }
 
-// WriteLoraJoinKey writes the LoRa App Key to blocks 3, 4, 5, and 6, this is the random 128 bits key
+// WriteLoraJoinKey writes the LoRa App Key (32 hex characters) to blocks 3, 4, 5, and 6, this is the
+// random 128 bits key. The key is read back to verify it and the CRC is updated.
 func (m *NfcCard) WriteLoraJoinKey(loraAppKey string) error {
-	if len(loraAppKey) > 32 {
-		return fmt.Errorf("invalid LoRa App Key length, should be 32 characters in hex")
+	key, err := decodeKey("JoinKey", loraAppKey, 16)
+	if err != nil {
+		return err
 	}
-	var block [4]string
-
-	//Split the key into 4 blocks
-	block[0] = loraAppKey[:8]
-	block[1] = loraAppKey[8:16]
-	block[2] = loraAppKey[16:24]
-	block[3] = loraAppKey[24:]
+	defer ZeroKey(key)
+	return m.WriteLoraJoinKeyBytes(key)
+}
 
-	for i := 3; i <= 6; i++ {
-		_, err := m.WriteBlock(i, block[i-3])
-		if err != nil {
-			return fmt.Errorf("failed to read block %d: %v", i, err)
-		}
+// WriteLoraJoinKeyBytes writes the 16 byte LoRa Join (App) Key to blocks 3-6, verifies it and
+// updates the CRC. The caller clears the key.
+func (m *NfcCard) WriteLoraJoinKeyBytes(key []byte) error {
+	if len(key) != 16 {
+		return fmt.Errorf("invalid LoRa JoinKey, expected 16 bytes")
+	}
+	err := m.CheckFields(FIELD_JOINKEY)
+	if err != nil {
+		return err
+	}
+	err = m.writeKey("JoinKey", ASSET_PLUS_LORA_JOIN_KEY_BLOCK_MSB1, key)
+	if err != nil {
+		return err
 	}
 	return m.CalculateAndWriteCRC()
 }
 
-// ReadLoraJoinKey reads the LoRa network key from blocks 7, 8, 9, and 10, this is the random 128 bits key
+// ReadLoraJoinKey reads the LoRa Join (App) Key from blocks 3-6 as hex, this is the random 128 bits key
 func (m *NfcCard) ReadLoraJoinKey() (string, error) {
-	blocks := make(map[int]string)
-	for i := 3; i <= 6; i++ {
-		block, err := m.ReadBlock(i)
-		if err != nil {
-			return "", fmt.Errorf("failed to read block %d: %v", i, err)
-		}
-		blocks[i] = block
+	key, err := m.ReadLoraJoinKeyBytes()
+	if err != nil {
+		return "", err
 	}
-	joinKey := fmt.Sprintf("%s%s%s%s", blocks[3], blocks[4], blocks[5], blocks[6])
-	return joinKey, nil
+	defer ZeroKey(key)
+	return hex.EncodeToString(key), nil
 }
 
-// decodeHexToASCII decodes a hex string to ASCII
-func decodeHexToASCII(hexString string) (string, error) {
-	// Remove any spaces from the hex string
-	hexString = strings.ReplaceAll(hexString, " ", "")
-
-	// Decode hex to bytes
-	bytes, err := hex.DecodeString(hexString)
+// ReadLoraJoinKeyBytes reads the LoRa Join (App) Key from blocks 3-6, clear it with ZeroKey after use
+func (m *NfcCard) ReadLoraJoinKeyBytes() ([]byte, error) {
+	key, err := m.ReadBlocks(ASSET_PLUS_LORA_JOIN_KEY_BLOCK_MSB1, ASSET_PLUS_LORA_JOIN_KEY_BLOCK_LSB0-ASSET_PLUS_LORA_JOIN_KEY_BLOCK_MSB1+1)
 	if err != nil {
-		return "", fmt.Errorf("error decoding hex string: %v", err)
+		return nil, fmt.Errorf("failed to read JoinKey: %w", err)
 	}
-
-	// Convert bytes to string (ASCII)
-	asciiString := string(bytes)
-
-	// Remove null characters from the end of the string
-	asciiString = strings.TrimRight(asciiString, "\x00")
-
-	return asciiString, nil
+	return key, nil
 }
 
 func (m *NfcCard) WriteTagSleepBit(bitValue bool) error {
-	log.Infof("Writing Tag Sleep Bit: %t", bitValue)
+	m.log.Infof("Writing Tag Sleep Bit: %t", bitValue)
 	block13, err := m.ReadBlock(13)
 	if err != nil {
-		log.Errorf("Failed to read block 13: %v", err)
+		m.log.Errorf("Failed to read block 13: %v", err)
 		return err
 	}
 	finalBlock13 := ""
-	log.Infof("Read Block 9: %s", block13)
+	m.log.Infof("Read Block 9: %s", block13)
 	if bitValue {
 		finalBlock13 = fmt.Sprintf("%s%s%s", block13[0:2], "0", block13[3:])
 	} else {
 		finalBlock13 = fmt.Sprintf("%s%s%s", block13[0:2], "1", block13[3:])
 	}
-	log.Infof("Final Block 13: %s", finalBlock13)
+	m.log.Infof("Final Block 13: %s", finalBlock13)
 	_, err = m.WriteBlock(13, finalBlock13)
 	if err != nil {
-		log.Errorf("Failed to write block 13: %v", err)
+		m.log.Errorf("Failed to write block 13: %v", err)
 		return err
 	}
 	return m.CalculateAndWriteCRC()
 }
 
+// WriteBLELocalName writes the BLE local name, see WriteBLELocalNameBytes
 func (m *NfcCard) WriteBLELocalName(name string) error {
-	// Convert ASCII to asciiToHex
-	asciiToHex := encodeASCIIToHex(name)
-	log.Infof("bleLocalName asciiToHex: %s\n", asciiToHex)
-	// Ensure the asciiToHex string is exactly 8 bytes (16 characters)
-	if len(asciiToHex) > 16 {
-		return fmt.Errorf("name too long, maximum 8 bytes allowed")
-	}
-	asciiToHex = fmt.Sprintf("%-16s", asciiToHex)         // Pad with spaces at the end if shorter
-	asciiToHex = strings.ReplaceAll(asciiToHex, " ", "0") // Replace spaces with zeroes, :(
-
-	// Split the asciiToHex string into two 8-byte blocks
-	block22 := asciiToHex[:8]
-	block23 := asciiToHex[8:]
-	fmt.Printf("WriteBLELocalName blockData: %s | %s\n", block22, block23)
-	// Write to block 22
-	_, err := m.WriteBlock(22, block22)
-	if err != nil {
-		return fmt.Errorf("failed to write block 22: %w", err)
-	}
-
-	// Write to block 23
-	_, err = m.WriteBlock(23, block23)
-	if err != nil {
-		return fmt.Errorf("failed to write block 23: %w", err)
-	}
-
-	return m.CalculateAndWriteCRC()
-}
-
-// Helper function to encode ASCII to hex
-func encodeASCIIToHex(s string) string {
-	var hexString string
-	for _, c := range s {
-		hexString += fmt.Sprintf("%02x", c)
-	}
-	return hexString
+	return m.WriteBLELocalNameBytes([]byte(name))
 }
 
 func (m *NfcCard) ReadLoraDevEui() (string, error) {
 	// Read LoRa MAC
 	loraDword1, err := m.ReadBlock(11)
 	if err != nil {
-		return "", fmt.Errorf("failed to read LoRa block 11: %v", err)
+		return "", fmt.Errorf("failed to read LoRa block 11: %w", err)
 	}
 	loraDword2, err := m.ReadBlock(12)
 	if err != nil {
-		return "", fmt.Errorf("failed to read LoRa block 12: %v", err)
+		return "", fmt.Errorf("failed to read LoRa block 12: %w", err)
 	}
 
 	devEuiParts := []string{
@@ -772,27 +977,27 @@ func (m *NfcCard) ReadLoraDevEui() (string, error) {
 }
 
 func (m *NfcCard) WriteLoraDwnTrgL(value uint8) error {
-	log.Warnf("Writing LoRa DwnTrgL: %d", value)
+	m.log.Warnf("Writing LoRa DwnTrgL: %d", value)
 	block9, err := m.ReadBlock(0x09)
 	if err != nil {
-		log.Errorf("Failed to read block 9: %v", err)
+		m.log.Errorf("Failed to read block 9: %v", err)
 		return err
 	}
-	log.Infof("Read Block 9: %s", block9)
+	m.log.Infof("Read Block 9: %s", block9)
 
 	block9bytes, err := extractBytes(block9)
 	if err != nil {
-		log.Errorf("Failed to extract bytes: %v", err)
+		m.log.Errorf("Failed to extract bytes: %v", err)
 		return err
 	}
 
 	finalBlock9 := ""
 	finalBlock9 = fmt.Sprintf("%02X%02X%02X%02X", block9bytes[0], block9bytes[1], value, block9bytes[3])
 
-	log.Infof("Final Block 9: %s", finalBlock9[:8])
+	m.log.Infof("Final Block 9: %s", finalBlock9[:8])
 	_, err = m.WriteBlock(0x09, finalBlock9[:8])
 	if err != nil {
-		log.Errorf("Failed to write block 9: %v", err)
+		m.log.Errorf("Failed to write block 9: %v", err)
 		return err
 	}
 
@@ -800,18 +1005,18 @@ func (m *NfcCard) WriteLoraDwnTrgL(value uint8) error {
 }
 
 func (m *NfcCard) WriteTagUplinkBit(bitValue bool) error {
-	log.Infof("Writing Tag Uplink Bit, %v", bitValue)
+	m.log.Infof("Writing Tag Uplink Bit, %v", bitValue)
 	block9, err := m.ReadBlock(0x09)
 	if err != nil {
-		log.Errorf("Failed to read block 9: %v", err)
+		m.log.Errorf("Failed to read block 9: %v", err)
 		return err
 	}
-	log.Infof("Read Block 9: %s", block9)
+	m.log.Infof("Read Block 9: %s", block9)
 
 	finalBlock9 := ""
 	bytes, err := extractBytes(block9)
 	if err != nil {
-		log.Errorf("Failed to extract bytes: %v", err)
+		m.log.Errorf("Failed to extract bytes: %v", err)
 		return err
 	}
 	if bitValue {
@@ -821,28 +1026,28 @@ func (m *NfcCard) WriteTagUplinkBit(bitValue bool) error {
 		bytes[0] = ClearBit(bytes[0], 1)
 		finalBlock9 = fmt.Sprintf("%02X%s", bytes[0], block9[2:])
 	}
-	log.Infof("Final Block 9: %s", finalBlock9[:8])
+	m.log.Infof("Final Block 9: %s", finalBlock9[:8])
 	_, err = m.WriteBlock(0x09, finalBlock9[:8])
 	if err != nil {
-		log.Errorf("Failed to write block 9: %v", err)
+		m.log.Errorf("Failed to write block 9: %v", err)
 		return err
 	}
 	return m.CalculateAndWriteCRC()
 }
 
 func (m *NfcCard) WriteTagPostBit(bitValue bool) error {
-	log.Infof("Writing Tag Post Bit, %v", bitValue)
+	m.log.Infof("Writing Tag Post Bit, %v", bitValue)
 	block9, err := m.ReadBlock(0x09)
 	if err != nil {
-		log.Errorf("Failed to read block 9: %v", err)
+		m.log.Errorf("Failed to read block 9: %v", err)
 		return err
 	}
-	log.Infof("Read Block 9: %s", block9)
+	m.log.Infof("Read Block 9: %s", block9)
 
 	finalBlock9 := ""
 	bytes, err := extractBytes(block9)
 	if err != nil {
-		log.Errorf("Failed to extract bytes: %v", err)
+		m.log.Errorf("Failed to extract bytes: %v", err)
 		return err
 	}
 	if bitValue {
@@ -852,10 +1057,10 @@ func (m *NfcCard) WriteTagPostBit(bitValue bool) error {
 		bytes[0] = ClearBit(bytes[0], 0)
 		finalBlock9 = fmt.Sprintf("%02X%s", bytes[0], block9[2:])
 	}
-	fmt.Printf("Final Block 9: %s", finalBlock9[:8])
+	m.log.Infof("Final Block 9: %s", finalBlock9[:8])
 	_, err = m.WriteBlock(0x09, finalBlock9[:8])
 	if err != nil {
-		log.Errorf("Failed to write block 9: %v", err)
+		m.log.Errorf("Failed to write block 9: %v", err)
 		return err
 	}
 	return m.CalculateAndWriteCRC()
@@ -866,29 +1071,61 @@ func (m *NfcCard) getUID() error {
 	if err != nil {
 		return err
 	}
+	if !strings.EqualFold(uid, m.uid) {
+		m.resetCache()
+		m.blockSize = 0
+		m.blocks = 0
+		m.beaconType, m.beaconTypeRead = nil, false
+	}
 	m.uid = uid
 	return nil
 }
 
-// ReadDittoSettings reads all settings from an Asset+ tag
+// ReadDittoSettings reads all settings from an Asset+ tag, fields which cannot be decoded are logged and left empty
 func (m *NfcCard) ReadDittoSettings() (*DittoSettings, error) {
-	settings := &DittoSettings{}
+	settings, warnings, err := m.ReadDittoSettingsMode(ParseLenient)
+	for _, warning := range warnings {
+		m.log.Warnf("Asset+ settings: %v", warning)
+	}
+	return settings, err
+}
+
+// dittoSettingsBlocks are the blocks decoded by DecodeDittoSettings
+var dittoSettingsBlocks = []int{7, 8, 9, 13, 14, 15, 19, 20, 21, 24, 25, 26, 27, 28, 29, 30, 31}
 
-	// Read required blocks
+// ReadDittoSettingsMode reads all settings from an Asset+ tag and decodes them with the given mode
+func (m *NfcCard) ReadDittoSettingsMode(mode ParseMode) (*DittoSettings, []error, error) {
+	blocks, err := m.readBlocks(dittoSettingsBlocks)
+	if err != nil {
+		return nil, nil, err
+	}
+	return DecodeDittoSettings(blocks, mode)
+}
+
+// readBlocks reads the given blocks and returns their hex strings by block number
+func (m *NfcCard) readBlocks(blockNums []int) (map[int]string, error) {
 	blocks := make(map[int]string)
-	for _, blockNum := range []int{7, 8, 9, 13, 14, 15, 19, 20, 21, 24, 25, 26, 27, 28, 29, 30, 31} {
+	for _, blockNum := range blockNums {
 		block, err := m.ReadBlock(blockNum)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read block %d: %v", blockNum, err)
+			return nil, fmt.Errorf("failed to read block %d: %w", blockNum, err)
 		}
 		blocks[blockNum] = block
 	}
+	return blocks, nil
+}
+
+// DecodeDittoSettings decodes the Asset+ settings from the hex strings of the settings blocks.
+// In lenient mode the returned warnings hold a ParseError for every field which could not be decoded.
+func DecodeDittoSettings(blocks map[int]string, mode ParseMode) (*DittoSettings, []error, error) {
+	settings := &DittoSettings{}
+	d := newBlockDecoder(blocks, mode)
 
 	// Parse Block 15: 034A1504
-	settings.HardwareVersion = blocks[15][1:2]
-	fwInt, _ := strconv.ParseInt(blocks[15][2:4], 16, 0)
+	settings.HardwareVersion = strings.ToUpper(d.str(15, 1, 2))
+	fwInt := d.hex(15, 2, 4)
 	settings.FirmwareVersion = fmt.Sprintf("%.1f", float64(fwInt)/10)
-	settings.BeaconType, _ = strconv.Atoi(blocks[15][4:6])
+	settings.BeaconType = int(d.hex(15, 4, 6))
 
 	/*
 	   Block 0: 53706563
@@ -922,72 +1159,62 @@ func (m *NfcCard) ReadDittoSettings() (*DittoSettings, error) {
 	*/
 
 	// Parse Block 13: 10100000
-	settings.SleepState = parseSleepState(blocks[13][2:3])
-	settings.DebugOption = parseDebugOption(blocks[13][3:4])
-	settings.MACOption = parseMACOption(blocks[13][4:5])
+	settings.SleepState = parseSleepState(d.str(13, 2, 3))
+	settings.DebugOption = parseDebugOption(d.str(13, 3, 4))
+	settings.MACOption = parseMACOption(d.str(13, 4, 5))
 
 	// Parse Block 8: 00010000
-	settings.SpreadingFactor = parseSpreadingFactor(blocks[8][:2])
-	settings.DownlinkBitRate, _ = strconv.Atoi(blocks[8][2:4])
-	settings.UplinkBitRate, _ = strconv.Atoi(blocks[8][4:6])
-	tempHigh, _ := strconv.ParseInt(blocks[8][6:], 16, 0)
-	settings.HighTemperature = int(tempHigh) - 127
+	settings.SpreadingFactor = parseSpreadingFactor(d.hex(8, 0, 2))
+	settings.DownlinkBitRate = int(d.hex(8, 2, 4))
+	settings.UplinkBitRate = int(d.hex(8, 4, 6))
+	settings.HighTemperature = int(d.hex(8, 6, 8)) - 127
 
 	// Parse Block 9: 00050000
-	tempLow, _ := strconv.ParseInt(blocks[9][:2], 16, 0)
-	settings.LowTemperature = int(tempLow) - 127
-	settings.Accelerometer, _ = strconv.Atoi(blocks[9][2:4])
+	settings.LowTemperature = int(d.hex(9, 0, 2)) - 127
+	settings.Accelerometer = int(d.hex(9, 2, 4))
 
 	// Parse Block 14: 000F3200
-	// Parse Block 14: 000F3200
-	settings.GNSSMin, _ = strconv.ParseInt(blocks[14][:2], 16, 64)
-	settings.GNSSMax, _ = strconv.ParseInt(blocks[14][2:4], 16, 64)
-	dop, _ := strconv.ParseInt(blocks[14][4:6], 16, 64)
-	settings.DOP = float64(dop / 10)
-	settings.OperationalMode, _ = strconv.ParseInt(blocks[14][6:], 16, 64)
+	settings.GNSSMin = d.hex(14, 0, 2)
+	settings.GNSSMax = d.hex(14, 2, 4)
+	settings.DOP = float64(d.hex(14, 4, 6)) / 10
+	settings.OperationalMode = d.hex(14, 6, 8)
 
 	// Parse Block 7: 01080000
-	settings.LoRaEnable = parseLoRaEnable(blocks[7][:2])
-	settings.LoRaRegion = parseLoRaRegion(blocks[7][2:4])
+	settings.LoRaEnable = parseLoRaEnable(d.hex(7, 0, 2))
+	settings.LoRaRegion = parseLoRaRegion(d.hex(7, 2, 4))
 
 	// Parse Block 19: A6CE00F4
-	settings.ABR2, _ = strconv.Atoi(blocks[19][4:6])
-	settings.BLEGain = parseBLEGain(blocks[19][6:])
+	settings.ABR2 = int(d.hex(19, 4, 6))
+	settings.BLEGain = parseBLEGain(d.str(19, 6, 8))
 
-	// Parse Block 20: 00000200
-	motionMovedHex := fmt.Sprintf("%04s", blocks[20][4:])            // This is equivalent to Substring(4, 4).PadLeft(4, "0")
-	motionMovedRearranged := motionMovedHex[2:] + motionMovedHex[:2] // Rearrange the string
-	motionMoved, _ := strconv.ParseInt(motionMovedRearranged, 16, 64)
-	settings.MotionMoved = int(motionMoved)
+	// Parse Block 20: 00000200, the motion thresholds are little endian
+	settings.MotionMoved = int(d.hexLE(20, 4, 8))
 
 	// Parse Block 21: 05000A04
-	motionStationaryHex := fmt.Sprintf("%04s", blocks[21][:4]) // This pads the string to 4 characters if needed
-	motionStationaryRearranged := motionStationaryHex[2:] + motionStationaryHex[:2]
-	motionStationary, _ := strconv.ParseInt(motionStationaryRearranged, 16, 64)
-	settings.MotionStationary = int(motionStationary)
-	settings.MotionAccelActivity, _ = strconv.ParseInt(blocks[21][4:6], 16, 64)
-	settings.MotionAccelActivityThreshold, _ = strconv.ParseInt(blocks[21][6:], 16, 64)
+	settings.MotionStationary = int(d.hexLE(21, 0, 4))
+	settings.MotionAccelActivity = d.hex(21, 4, 6)
+	settings.MotionAccelActivityThreshold = d.hex(21, 6, 8)
 
 	// Parse Blocks 24-29: C4091027, A64F6D6E, 692D4944, 00000000, 00000000, 00000007
-	BleAdvRateHex := fmt.Sprintf("%04s", blocks[24][:4])
-	BleAdvRateHexRearranged := BleAdvRateHex[2:] + BleAdvRateHex[:2]
-	settings.BLEAdvertisingInterval, _ = strconv.ParseInt(BleAdvRateHexRearranged, 16, 64)
-
-	BleRfScanIntervalHex := fmt.Sprintf("%04s", blocks[24][4:])
-	BleRfScanInterValRearranged := BleRfScanIntervalHex[2:] + BleRfScanIntervalHex[:2]
-	settings.BLERefScanInterval, _ = strconv.ParseInt(BleRfScanInterValRearranged, 16, 64)
-	settings.BLERefRSSI = complementToDec(blocks[25][:2])
-	settings.BLERefFilter = parseRefFilter(blocks[25][2:] + blocks[26] + blocks[27] + blocks[28] + blocks[29][:2])
-	settings.BLEAdvertisingType = parseBLEAdvertisingType(blocks[29][2:4])
-	settings.PressUplink = parsePressUplink(blocks[29][4:6])
-	settings.PingSlotPeriod = parsePingSlotPeriod(blocks[29][6:])
+	settings.BLEAdvertisingInterval = d.hexLE(24, 0, 4)
+	settings.BLERefScanInterval = d.hexLE(24, 4, 8)
+	settings.BLERefRSSI = complementToDec(d.hex(25, 0, 2))
+	refFilter := d.str(25, 2, 8) + d.str(26, 0, 8) + d.str(27, 0, 8) + d.str(28, 0, 8) + d.str(29, 0, 2)
+	refFilterBytes, err := hex.DecodeString(refFilter)
+	if err != nil {
+		d.fail(25, 2, err)
+	}
+	settings.BLERefFilter = parseRefFilter(refFilterBytes)
+	settings.BLEAdvertisingType = parseBLEAdvertisingType(d.str(29, 2, 4))
+	settings.PressUplink = parsePressUplink(d.str(29, 4, 6))
+	settings.PingSlotPeriod = parsePingSlotPeriod(d.hex(29, 6, 8))
 
 	// Parse Block 30: 3C000002
-	settings.Timeout, _ = strconv.ParseInt(blocks[30][:2], 16, 8)
+	settings.Timeout = d.hex(30, 0, 2)
 
 	// Parse Flags from Block 30 and 31
-	flags1, _ := strconv.ParseUint(blocks[30][6:], 16, 8)
-	flags2, _ := strconv.ParseUint(blocks[31][:2], 16, 8)
+	flags1 := d.hex(30, 6, 8)
+	flags2 := d.hex(31, 0, 2)
 	flags1Bin := fmt.Sprintf("%08b", flags1) // Convert to 8-bit binary string
 	flags2Bin := fmt.Sprintf("%08b", flags2) // Convert to 8-bit binary string
 
@@ -1007,22 +1234,24 @@ func (m *NfcCard) ReadDittoSettings() (*DittoSettings, error) {
 	settings.ConfirmedUplinks = parseConfirmedUplinks(reversedFlags2Bin[:1])
 	settings.Hopping = parseHopping(reversedFlags2Bin[4:5])
 
-	return settings, nil
+	warnings, err := d.result()
+	if err != nil {
+		return nil, nil, err
+	}
+	return settings, warnings, nil
 }
 
-func (m *NfcCard) ReadAllBlocks(isDebug bool) ([]byte, error) {
+// ReadAllBlocks reads the configuration blocks 0-47
+func (m *NfcCard) ReadAllBlocks() ([]byte, error) {
 	var blocks []byte
 	for i := 0; i <= 47; i++ {
 		blockData, err := m.ReadBlock(i)
 		if err != nil {
 			return nil, err
 		}
-		if isDebug {
-			fmt.Printf("Block %02d: %s\n", i, strings.ToUpper(blockData))
-		}
 		bytes, err := hex.DecodeString(blockData)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode block %d data: %v", i, err)
+			return nil, fmt.Errorf("failed to decode block %d data: %w", i, err)
 		}
 		blocks = append(blocks, bytes...)
 	}
@@ -1030,25 +1259,29 @@ func (m *NfcCard) ReadAllBlocks(isDebug bool) ([]byte, error) {
 	return blocks, nil
 }
 
-// PrintConfigFields reads the binary configuration file and prints fields with their descriptions
-func (m *NfcCard) PrintConfigFields(filePath string, ifFile bool) error {
+// ConfigField is a decoded field of a configuration image, Description explains the value
+type ConfigField struct {
+	Name        string
+	Value       string
+	Description string
+}
+
+// ConfigFields decodes the fields of the binary configuration file, or of the tag if ifFile is not
+// set, with their descriptions
+func (m *NfcCard) ConfigFields(filePath string, ifFile bool) ([]ConfigField, error) {
 	// Read the binary file
 	var data []byte
 	var err error
 	if ifFile {
-		data, err = os.ReadFile(filePath)
+		// Checks the size (192 bytes = 48 blocks * 4 bytes) and decrypts encrypted images
+		data, err = LoadConfigBin(filePath)
 		if err != nil {
-			return fmt.Errorf("failed to read file: %v", err)
-		}
-
-		// Check if file size is correct (192 bytes = 48 blocks * 4 bytes)
-		if len(data) != 192 {
-			return fmt.Errorf("invalid file size: expected 192 bytes, got %d bytes", len(data))
+			return nil, err
 		}
 	} else {
-		data, err = m.ReadAllBlocks(false)
+		data, err = m.ReadAllBlocks()
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 	// Helper function to extract bytes for a position range
@@ -1056,32 +1289,28 @@ func (m *NfcCard) PrintConfigFields(filePath string, ifFile bool) error {
 		return data[start : end+1]
 	}
 
-	// Define a print function that prints field name, value and additional info
-	printField := func(name string, value interface{}, additional string) {
-		if additional != "" {
-			fmt.Printf("%s%-35s%s: %s%-20v (%s)%s\n", colorCyan, name, colorReset, colorYellow, value, additional, colorReset)
-		} else {
-			fmt.Printf("%s%-35s%s: %s%v%s\n", colorCyan, name, colorReset, colorYellow, value, colorReset)
-		}
+	var fields []ConfigField
+	addField := func(name string, value interface{}, description string) {
+		fields = append(fields, ConfigField{Name: name, Value: fmt.Sprint(value), Description: description})
 	}
 
-	// Extract and print all fields
+	// Extract all fields
 	// LORA Related Fields
 	joinEUI := getBytes(0, 7)
-	printField("LORA JoinEUI", hex.EncodeToString(joinEUI), "JoinEui")
+	addField("LORA JoinEUI", RedactJoinEUI(hex.EncodeToString(joinEUI)), "JoinEui")
 
 	devAddr := getBytes(8, 11)
-	printField("LORA DevAddr", hex.EncodeToString(devAddr), "LoraDevAddr(unSupported)")
+	addField("LORA DevAddr", hex.EncodeToString(devAddr), "LoraDevAddr(unSupported)")
 
 	appKey := getBytes(12, 27)
-	printField("LORA JoinKey", hex.EncodeToString(appKey), "JoinKey")
+	addField("LORA JoinKey", RedactKey(hex.EncodeToString(appKey)), "JoinKey, Fingerprint "+KeyFingerprint(hex.EncodeToString(appKey)))
 
 	loraEnable := data[28]
 	enableStatus := "Disabled"
 	if loraEnable == 1 {
 		enableStatus = "Enabled"
 	}
-	printField("LORA Enable", loraEnable, enableStatus)
+	addField("LORA Enable", loraEnable, enableStatus)
 
 	loraRegion := data[29]
 	regionMap := map[uint8]string{
@@ -1089,90 +1318,90 @@ func (m *NfcCard) PrintConfigFields(filePath string, ifFile bool) error {
 		6: "KR920", 7: "IN865", 8: "US915",
 		10: "AS923_GRP2", 11: "AS923_GRP3",
 	}
-	printField("LORA Region", loraRegion, regionMap[loraRegion])
+	addField("LORA Region", loraRegion, regionMap[loraRegion])
 
 	devNonce := binary.LittleEndian.Uint16(getBytes(30, 31))
-	printField("LORA DevNonce", devNonce, "")
+	addField("LORA DevNonce", devNonce, "")
 
 	dataRate := data[32]
 	drMap := map[uint8]string{
 		0: "DR0", 1: "DR1", 2: "DR2", 3: "DR3", 4: "DR4",
 	}
 	if dataRate >= 5 {
-		printField("LORA Data Rate", dataRate, "ADR")
+		addField("LORA Data Rate", dataRate, "ADR")
 	} else {
-		printField("LORA Data Rate", dataRate, drMap[dataRate])
+		addField("LORA Data Rate", dataRate, drMap[dataRate])
 	}
 
 	beaconRate := data[33]
-	printField("LORA Beacon Rate (DBR)", beaconRate, "hours")
+	addField("LORA Beacon Rate (DBR)", beaconRate, "hours")
 
 	// Accelerometer
 	accelSensitivity := data[37]
-	printField("Accelerometer Sensitivity", accelSensitivity, "0=Off, 10=Most Sensitive")
+	addField("Accelerometer Sensitivity", accelSensitivity, "0=Off, 10=Most Sensitive")
 
 	// DevEUI
 	devEUI := getBytes(44, 51)
-	printField("LORA DevEUI", hex.EncodeToString(devEUI), "DevEui")
+	addField("LORA DevEUI", hex.EncodeToString(devEUI), "DevEui")
 
 	// Tag Status (Flags)
 	flags := data[53]
 	tagEnabled := (flags >> 4) & 1
 	debugTones := flags & 1
-	printField("Tag Status", tagEnabled, fmt.Sprintf("Tag %s, Debug Tones %s",
+	addField("Tag Status", tagEnabled, fmt.Sprintf("Tag %s, Debug Tones %s",
 		map[uint8]string{0: "Disabled", 1: "Enabled"}[tagEnabled],
 		map[uint8]string{0: "Disabled", 1: "Enabled"}[debugTones]))
 
 	// Device Information
 	hwID := data[60]
-	printField("Hardware ID", hwID, "")
+	addField("Hardware ID", hwID, "")
 
 	fwID := float64(data[61]) / 10.0
-	printField("Firmware Version", fwID, "")
+	addField("Firmware Version", fwID, "")
 
 	devID := data[62]
-	printField("Device ID", devID, "Project 21 (Ditto)")
+	addField("Device ID", devID, "Project 21 (Ditto)")
 
 	settingsVer := data[63]
-	printField("Settings Version", settingsVer, "")
+	addField("Settings Version", settingsVer, "")
 
 	// ... Continue with other fields based on the positions
 	// Add additional fields as needed following the same pattern
 	// Alert Buzzer Configuration
 	buzzerDuty := binary.LittleEndian.Uint16(getBytes(64, 65))
-	printField("Alert Buzzer Duty", buzzerDuty, "MS between tone switch")
+	addField("Alert Buzzer Duty", buzzerDuty, "MS between tone switch")
 
 	buzzerFreqOn := binary.LittleEndian.Uint16(getBytes(66, 67))
-	printField("Alert Buzzer Freq On", buzzerFreqOn, "Hz")
+	addField("Alert Buzzer Freq On", buzzerFreqOn, "Hz")
 
 	buzzerFreqOff := binary.LittleEndian.Uint16(getBytes(68, 69))
-	printField("Alert Buzzer Freq Off", buzzerFreqOff, "Hz")
+	addField("Alert Buzzer Freq Off", buzzerFreqOff, "Hz")
 
 	alertDuration := binary.LittleEndian.Uint16(getBytes(70, 71))
-	printField("Alert Duration", alertDuration, "Seconds")
+	addField("Alert Duration", alertDuration, "Seconds")
 
 	// BLE Configuration
 	bleMac := getBytes(72, 77)
-	printField("Nordic BLE MAC Address", hex.EncodeToString(bleMac), "")
+	addField("Nordic BLE MAC Address", hex.EncodeToString(bleMac), "")
 
 	alarmBeaconRate := data[78]
-	printField("Alarm Beacon Rate", alarmBeaconRate, "")
+	addField("Alarm Beacon Rate", alarmBeaconRate, "")
 
 	bleGain := int8(data[79]) // signed 8-bit value
-	printField("BLE Tx Pwr", bleGain, "dBm")
+	addField("BLE Tx Pwr", bleGain, "dBm")
 
 	// Motion Detection
 	stationaryThreshold := binary.LittleEndian.Uint16(getBytes(82, 83))
-	printField("Stationary Threshold", stationaryThreshold, "Range 0 to 15240")
+	addField("Stationary Threshold", stationaryThreshold, "Range 0 to 15240")
 
 	movingThreshold := binary.LittleEndian.Uint16(getBytes(84, 85))
-	printField("Moving Threshold", movingThreshold, "Range 0 to 15240")
+	addField("Moving Threshold", movingThreshold, "Range 0 to 15240")
 
 	accelWindow := data[86]
-	printField("Accel Activity Window", accelWindow, "Seconds (Default 20)")
+	addField("Accel Activity Window", accelWindow, "Seconds (Default 20)")
 
 	accelThreshold := data[87]
-	printField("Accel Activity Threshold", accelThreshold, "Events (Default 2)")
+	addField("Accel Activity Threshold", accelThreshold, "Events (Default 2)")
 
 	// BLE Local Name
 	var bleName strings.Builder
@@ -1185,17 +1414,17 @@ func (m *NfcCard) PrintConfigFields(filePath string, ifFile bool) error {
 	if bleNameStr == "" {
 		bleNameStr = "SenseA+" // default name
 	}
-	printField("BLE Local Name", bleNameStr, "")
+	addField("BLE Local Name", bleNameStr, "")
 
 	// BLE Configuration
 	bleAdvRate := binary.LittleEndian.Uint16(getBytes(96, 97))
-	printField("BLE Advertising Beacon Rate", bleAdvRate, "Seconds")
+	addField("BLE Advertising Beacon Rate", bleAdvRate, "Seconds")
 
 	bleScanWindow := binary.LittleEndian.Uint16(getBytes(98, 99))
-	printField("BLE Reference Tag Scan Window", bleScanWindow, "ms")
+	addField("BLE Reference Tag Scan Window", bleScanWindow, "ms")
 
 	bleRssiThreshold := int8(data[100])
-	printField("BLE Reference Tag RSSI Threshold", bleRssiThreshold, "")
+	addField("BLE Reference Tag RSSI Threshold", bleRssiThreshold, "")
 
 	// BLE Reference Tag Filter
 	var result []byte
@@ -1211,39 +1440,39 @@ func (m *NfcCard) PrintConfigFields(filePath string, ifFile bool) error {
 	} else {
 		output = hex.EncodeToString(result)
 	}
-	printField("BLE Reference Tag Filter ID", output, "")
+	addField("BLE Reference Tag Filter ID", output, "")
 
 	// Advertisement Type
 	bleAdvType := data[117] & 0x01
-	printField("BLE Advertisement Type", bleAdvType, map[uint8]string{
+	addField("BLE Advertisement Type", bleAdvType, map[uint8]string{
 		0: "Default advertisement",
 		1: "sBeacon",
 	}[bleAdvType])
 
 	// Button Press Behavior
 	btnPressBehavior := data[118] & 0x01
-	printField("Button Press Behavior", btnPressBehavior, map[uint8]string{
+	addField("Button Press Behavior", btnPressBehavior, map[uint8]string{
 		0: "Standard behavior/Enable Uplink",
 		1: "Disable uplink, led, buzzer",
 	}[btnPressBehavior])
 
 	// LoRaWAN Class B Configuration
 	pingSlotPeriod := data[119]
-	printField("LoRaWAN Class B Ping Slot Period", pingSlotPeriod, "Seconds")
+	addField("LoRaWAN Class B Ping Slot Period", pingSlotPeriod, "Seconds")
 
 	timeoutBeacons := data[120]
-	printField("LoRaWAN Class B Timeout", timeoutBeacons, "Minutes")
+	addField("LoRaWAN Class B Timeout", timeoutBeacons, "Minutes")
 
 	// Flags for positioning and class selection
 	posFlags := data[123]
 	bleRefTagEnable := posFlags & 0x03
 	loraWanClass := (posFlags >> 4) & 0x03
-	printField("BLE Reference Tag/Blufi Positioning", bleRefTagEnable, map[uint8]string{
+	addField("BLE Reference Tag/Blufi Positioning", bleRefTagEnable, map[uint8]string{
 		0: "Deactivate",
 		1: "Reference tags",
 		2: "Blufis",
 	}[bleRefTagEnable])
-	printField("LoRaWAN Class", loraWanClass, map[uint8]string{
+	addField("LoRaWAN Class", loraWanClass, map[uint8]string{
 		0: "Class A",
 		1: "Class B",
 		2: "Class C",
@@ -1253,16 +1482,16 @@ func (m *NfcCard) PrintConfigFields(filePath string, ifFile bool) error {
 	loraFlags := data[124]
 	confirmedUplinks := loraFlags & 0x01
 	subBandHopping := (loraFlags >> 4) & 0x01
-	printField("LoRaWAN Confirmed Uplinks", confirmedUplinks, map[uint8]string{
+	addField("LoRaWAN Confirmed Uplinks", confirmedUplinks, map[uint8]string{
 		0: "Deactivated",
 		1: "Activated",
 	}[confirmedUplinks])
-	printField("LoRaWAN Sub-band Hopping", subBandHopping, map[uint8]string{
+	addField("LoRaWAN Sub-band Hopping", subBandHopping, map[uint8]string{
 		0: "Deactivated",
 		1: "Activated",
 	}[subBandHopping])
 
-	return nil
+	return fields, nil
 }
 
 const (
@@ -1274,34 +1503,38 @@ const (
 	ASSET_PLUS_LORA_JOIN_KEY_BLOCK_LSB0 = 6
 	ASSET_PLUS_LORA_DEV_EUI_BLOCK_MSB   = 11
 	ASSET_PLUS_LORA_DEV_EUI_BLOCK_LSB   = 12
+	ASSET_PLUS_VERSION_BLOCK            = 15 // hardware and firmware version, device ID (beacon type)
 	ASSET_PLUS_BLE_MAC_MSB              = 18
 	ASSET_PLUS_BLE_MAC_LSB              = 19
 	ASSET_PLUS_BLE_LOCAL_NAME_MSB       = 22
 	ASSET_PLUS_BLE_LOCAL_NAME_LSB       = 23
+	ASSET_PLUS_CONFIG_BLOCKS            = 48 // blocks 0-47 are covered by the CRC
+	ASSET_PLUS_CRC_BLOCK                = 48
 )
 
-// GenerateConfigBin generates the configuration data for the tag and generates a binary file
-func (m *NfcCard) GenerateConfigBin(parameters string) error {
+// IsDeviceSpecificBlock reports whether a configuration block holds per device data (LoRa keys and EUIs,
+// BLE MAC and local name, user data) which is left out of configuration images
+func IsDeviceSpecificBlock(block int) bool {
+	return (block >= ASSET_PLUS_LORA_JOIN_EUI_BLOCK_MSB && block <= ASSET_PLUS_LORA_JOIN_EUI_BLOCK_LSB) ||
+		(block >= ASSET_PLUS_LORA_JOIN_KEY_BLOCK_MSB1 && block <= ASSET_PLUS_LORA_JOIN_KEY_BLOCK_LSB0) ||
+		(block >= ASSET_PLUS_LORA_DEV_EUI_BLOCK_MSB && block <= ASSET_PLUS_LORA_DEV_EUI_BLOCK_LSB) ||
+		(block == ASSET_PLUS_BLE_MAC_MSB) ||
+		(block >= ASSET_PLUS_BLE_LOCAL_NAME_MSB && block <= ASSET_PLUS_BLE_LOCAL_NAME_LSB) ||
+		(block >= USERDATA_BLOCK_FIRST && block <= USERDATA_BLOCK_LAST)
+}
 
+// ReadConfigBin reads the configuration image of the tag: blocks 0-47 with the device specific
+// blocks set to 0xFF
+func (m *NfcCard) ReadConfigBin() ([]byte, error) {
 	nfcData := make([]byte, 0, 192) // 48 blocks * 4 bytes per block = 192 bytes
-	var blockData string
-	var err error
 	// Read blocks 0 to 47
 	for block := 0; block <= 47; block++ {
-		//We need to skip some blocks
-		blockData = "FFFFFFFF"
-		err = nil
-		if (block >= ASSET_PLUS_LORA_JOIN_EUI_BLOCK_MSB && block <= ASSET_PLUS_LORA_JOIN_EUI_BLOCK_LSB) ||
-			(block >= ASSET_PLUS_LORA_JOIN_KEY_BLOCK_MSB1 && block <= ASSET_PLUS_LORA_JOIN_KEY_BLOCK_LSB0) ||
-			(block >= ASSET_PLUS_LORA_DEV_EUI_BLOCK_MSB && block <= ASSET_PLUS_LORA_DEV_EUI_BLOCK_LSB) ||
-			(block == ASSET_PLUS_BLE_MAC_MSB) ||
-			(block >= ASSET_PLUS_BLE_LOCAL_NAME_MSB && block <= ASSET_PLUS_BLE_LOCAL_NAME_LSB) {
-			//fmt.Println("Skipping block in GenerateConfigBin", block)
-			blockData = "FFFFFFFF"
-		} else {
+		blockData := "FFFFFFFF"
+		if !IsDeviceSpecificBlock(block) {
+			var err error
 			blockData, err = m.ReadBlock(block)
 			if err != nil {
-				return fmt.Errorf("failed to read block %d: %v", block, err)
+				return nil, fmt.Errorf("failed to read block %d: %w", block, err)
 			}
 			if block == ASSET_PLUS_BLE_MAC_LSB { //This needs to be handled differently because only first two bytes are occupied here
 				blockData = fmt.Sprintf("%s%s", "FFFF", blockData[4:])
@@ -1312,13 +1545,29 @@ func (m *NfcCard) GenerateConfigBin(parameters string) error {
 		// Each block contains 8 hex chars representing 4 bytes
 		bytes, err := hex.DecodeString(blockData)
 		if err != nil {
-			return fmt.Errorf("failed to decode block %d data: %v", block, err)
+			return nil, fmt.Errorf("failed to decode block %d data: %w", block, err)
 		}
-		fmt.Printf("\tBlock %02d: %X\n", block, bytes)
+		m.log.Debugf("Block %02d: %X", block, bytes)
 
 		// Append the 4 bytes to nfcData
 		nfcData = append(nfcData, bytes...)
 	}
+	return nfcData, nil
+}
+
+// GenerateConfigBin generates the configuration data for the tag and generates a binary file,
+// encrypted if a key was set with SetConfigBinKey
+func (m *NfcCard) GenerateConfigBin(parameters string) error {
+	nfcData, err := m.ReadConfigBin()
+	if err != nil {
+		return err
+	}
+	if configBinKey != nil {
+		nfcData, err = EncryptConfigBin(nfcData, configBinKey)
+		if err != nil {
+			return err
+		}
+	}
 
 	// remove file if exists
 	if _, err := os.Stat(parameters); err == nil {
@@ -1336,7 +1585,7 @@ func (m *NfcCard) GenerateConfigBin(parameters string) error {
 	defer func(file *os.File) {
 		err := file.Close()
 		if err != nil {
-			log.Fatal(err)
+			m.log.Errorf("failed to close %s: %v", parameters, err)
 		}
 	}(file)
 
@@ -1354,27 +1603,13 @@ func (m *NfcCard) GenerateConfigBin(parameters string) error {
 
 // ReadConfigurationForCRC reads blocks 0-47 and prepares data for CRC calculation
 func (m *NfcCard) ReadConfigurationForCRC() ([]byte, error) {
-	nfcData := make([]byte, 0, 192) // 48 blocks * 4 bytes per block = 192 bytes
-
-	// Read blocks 0 to 47
-	for block := 0; block <= 47; block++ {
-		blockData, err := m.ReadBlock(block)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read block %d: %v", block, err)
-		}
-
-		// Convert hex string to bytes
-		// Each block contains 8 hex chars representing 4 bytes
-		bytes, err := hex.DecodeString(blockData)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode block %d data: %v", block, err)
-		}
-
-		// Append the 4 bytes to nfcData
-		nfcData = append(nfcData, bytes...)
-	}
+	// Read blocks 0 to 47, 48 blocks * 4 bytes per block = 192 bytes
+	return m.ReadBlocks(0, ASSET_PLUS_CONFIG_BLOCKS)
+}
 
-	return nfcData, nil
+// CalculateCRC returns the CRC-16 CCITT of the configuration data as stored in the CRC block
+func CalculateCRC(data []byte) uint16 {
+	return calculateCRC(data)
 }
 
 // calculateCRC implements the CRC-16 CCITT algorithm
@@ -1396,10 +1631,10 @@ func calculateCRC(data []byte) uint16 {
 
 // CalculateAndWriteCRC calculates CRC for all configuration blocks and writes it
 func (m *NfcCard) CalculateAndWriteCRC() error {
-	// Read all configuration data
-	nfcData, err := m.ReadConfigurationForCRC()
+	// Read all configuration data, from the block cache when it is enabled
+	nfcData, err := m.configurationForCRC()
 	if err != nil {
-		return fmt.Errorf("failed to read configuration: %v", err)
+		return fmt.Errorf("failed to read configuration: %w", err)
 	}
 
 	// Calculate CRC
@@ -1407,12 +1642,12 @@ func (m *NfcCard) CalculateAndWriteCRC() error {
 
 	// Reverse the CRC bytes and format for writing
 	reversedCRCHex := reverseCRC(crc)
-	log.Infof("Calculated CRC: 0x%04X, Reversed for storage: 0x%s", crc, reversedCRCHex)
+	m.log.Infof("Calculated CRC: 0x%04X, Reversed for storage: 0x%s", crc, reversedCRCHex)
 
 	// Write reversed CRC to designated block
 	_, err = m.WriteBlock(48, reversedCRCHex)
 	if err != nil {
-		return fmt.Errorf("failed to write CRC: %v", err)
+		return fmt.Errorf("failed to write CRC: %w", err)
 	}
 
 	return nil
@@ -1420,47 +1655,79 @@ func (m *NfcCard) CalculateAndWriteCRC() error {
 
 // ValidateCRC reads the configuration and validates against stored CRC
 func (m *NfcCard) ValidateCRC() error {
-	log.Info("Validating CRC...")
+	m.log.Info("Validating CRC...")
+	image, err := m.ReadCRCImage()
+	if err != nil {
+		events.Publish(events.Event{Type: events.CRCValidated, UID: m.uid, Error: err.Error()})
+		return err
+	}
+	return image.Validate()
+}
+
+// CRCImage is the configuration and the stored CRC read from a tag. Validate only computes, so
+// batch runs can check the CRC of one tag while the RF exchanges of the next tag proceed.
+type CRCImage struct {
+	UID    string
+	Config []byte
+	Stored uint16
+
+	log Logger
+}
+
+// FirmwareVersion returns the firmware version of the configuration (byte 1 of block 15)
+func (c *CRCImage) FirmwareVersion() string {
+	if len(c.Config) < 16*4 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f", float64(c.Config[15*4+1])/10)
+}
+
+// ReadCRCImage reads the configuration blocks and the CRC block
+func (m *NfcCard) ReadCRCImage() (*CRCImage, error) {
 	// Read configuration data
 	nfcData, err := m.ReadConfigurationForCRC()
 	if err != nil {
-		return fmt.Errorf("failed to read configuration: %v", err)
+		return nil, fmt.Errorf("failed to read configuration: %w", err)
 	}
 
-	// Calculate CRC
-	calculatedCRC := calculateCRC(nfcData)
-
 	// Read stored CRC from block 48
 	storedCRCBlock, err := m.ReadBlock(48)
 	if err != nil {
-		return fmt.Errorf("failed to read CRC block: %v", err)
+		return nil, fmt.Errorf("failed to read CRC block: %w", err)
 	}
 
 	// Extract and reverse the stored CRC bytes
 	lsb, _ := strconv.ParseUint(storedCRCBlock[0:2], 16, 8)
 	msb, _ := strconv.ParseUint(storedCRCBlock[2:4], 16, 8)
-	storedCRC := uint16(msb)<<8 | uint16(lsb)
+	return &CRCImage{UID: m.uid, Config: nfcData, Stored: uint16(msb)<<8 | uint16(lsb), log: m.log}, nil
+}
 
-	log.Infof("Calculated CRC: 0x%04X, Stored CRC: 0x%04X", calculatedCRC, storedCRC)
+// Validate compares the CRC of the configuration with the stored CRC and publishes CRCValidated
+func (img *CRCImage) Validate() error {
+	calculatedCRC := calculateCRC(img.Config)
+	img.log.Infof("Calculated CRC: 0x%04X, Stored CRC: 0x%04X", calculatedCRC, img.Stored)
 
-	// Compare CRCs
-	if calculatedCRC != storedCRC {
-		return fmt.Errorf("CRC validation failed: calculated=0x%04X, stored=0x%04X",
-			calculatedCRC, storedCRC)
+	var err error
+	if calculatedCRC != img.Stored {
+		err = fmt.Errorf("CRC validation failed: calculated=0x%04X, stored=0x%04X",
+			calculatedCRC, img.Stored)
 	}
-
-	return nil
+	events.Publish(events.Event{Type: events.CRCValidated, UID: img.UID, OK: err == nil, Error: events.ErrorString(err)})
+	return err
 }
 
 // WriteLoraDevEui Modified write methods to update CRC
 func (m *NfcCard) WriteLoraDevEui(loraDevEui string) error {
 	// Write DevEUI
 	if len(loraDevEui) != 16 {
-		fmt.Printf("entered lora dev eui length is not 16, for '%s' it is == %d\n", loraDevEui, len(loraDevEui))
-		return errors.New("invalid lora deveui, len must be 16")
+		return fmt.Errorf("invalid lora deveui '%s', len must be 16, got %d", loraDevEui, len(loraDevEui))
+	}
+	err := m.CheckFields(FIELD_DEVEUI)
+	if err != nil {
+		return err
 	}
 
-	_, err := m.WriteBlock(11, loraDevEui[0:8])
+	_, err = m.WriteBlock(11, loraDevEui[0:8])
 	if err != nil {
 		return err
 	}
@@ -1545,30 +1812,27 @@ func parseMACOption(option string) string {
 	}
 }
 
-func parseSpreadingFactor(sf string) string {
-	sfInt, _ := strconv.ParseInt(sf, 16, 0)
-	if sfInt == 255 {
+func parseSpreadingFactor(sf int64) string {
+	if sf == 255 {
 		return "ADR"
 	}
-	return fmt.Sprintf("%d", sfInt)
+	return fmt.Sprintf("%d", sf)
 }
 
-func parseLoRaEnable(enable string) string {
-	enableInt, _ := strconv.Atoi(enable)
-	if enableInt == 1 {
+func parseLoRaEnable(enable int64) string {
+	if enable == 1 {
 		return "Enabled"
 	}
 	return "Disabled"
 }
 
-func parseLoRaRegion(region string) string {
-	regionInt, _ := strconv.Atoi(region)
-	regions := map[int]string{
+func parseLoRaRegion(region int64) string {
+	regions := map[int64]string{
 		0: "AS 923MHz_GRP1", 1: "AU 915MHz", 5: "EU 868MHz",
 		6: "SK 930MHz", 7: "IN 865MHz", 8: "US 915MHz",
 		10: "AS923_GRP2", 11: "AS923_GRP3",
 	}
-	if r, ok := regions[regionInt]; ok {
+	if r, ok := regions[region]; ok {
 		return r
 	}
 	return "Not Selected"
@@ -1580,16 +1844,15 @@ func parseBLEGain(gain string) string {
 		"F4": "-12dBm", "F8": "-8dBm", "FC": "-4dBm",
 		"00": "0dBm", "03": "3dBm", "04": "4dBm",
 	}
-	if g, ok := gains[gain]; ok {
+	if g, ok := gains[strings.ToUpper(gain)]; ok {
 		return g
 	}
 	return "Unknown"
 }
 
-func parseRefFilter(filter string) string {
+func parseRefFilter(filter []byte) string {
 	decoded := ""
-	for i := 0; i < len(filter); i += 2 {
-		b, _ := strconv.ParseInt(filter[i:i+2], 16, 0)
+	for _, b := range filter {
 		decoded += string(rune(b))
 	}
 	return decoded
@@ -1609,13 +1872,12 @@ func parsePressUplink(uplink string) string {
 	return "Enabled"
 }
 
-func parsePingSlotPeriod(period string) string {
-	periodInt, _ := strconv.Atoi(period)
-	periods := map[int]string{
+func parsePingSlotPeriod(period int64) string {
+	periods := map[int64]string{
 		0: "1 s", 1: "2 s", 2: "4 s", 3: "8 s",
 		4: "16 s", 5: "32 s", 6: "64 s", 7: "128 s",
 	}
-	if p, ok := periods[periodInt]; ok {
+	if p, ok := periods[period]; ok {
 		return p
 	}
 	return "Unknown"
@@ -1669,122 +1931,50 @@ func parseHopping(flags string) string {
 	}
 }
 
-func complementToDec(hex string) int {
-	i, _ := strconv.ParseInt(hex, 16, 0)
+func complementToDec(i int64) int {
 	if i > 127 {
 		return int(i - 256)
 	}
 	return int(i)
 }
 
-const (
-	colorReset  = "\033[0m"
-	colorRed    = "\033[31m"
-	colorGreen  = "\033[32m"
-	colorYellow = "\033[33m"
-	colorBlue   = "\033[34m"
-	colorPurple = "\033[35m"
-	colorCyan   = "\033[36m"
-	colorWhite  = "\033[37m"
-)
-
-func printStructuredOutput(m24lr *NfcCard) {
-	fmt.Println(colorGreen + "=== RFID Tag Reader Output ===" + colorReset)
-
-	printSection("Basic Information", func() {
-		printField("UID", m24lr.UID())
-	})
-
-	printSection("Beacon Information", func() {
-		beaconInfo, _ := m24lr.ReadSKU()
-		printField("Type", beaconInfo.BeaconType)
-		printField("Name", beaconInfo.Name)
-		printField("Image", beaconInfo.Image)
-	})
-
-	printSection("Ditto MACs", func() {
-		BleMac, _ := m24lr.ReadBleMac()
-		LoRaMAC, _ := m24lr.ReadLoraDevEui()
-		printField("LoRa MAC", strings.ToUpper(LoRaMAC))
-		printField("BLE MAC", strings.ToUpper(BleMac))
-	})
-
-	printSection("Local BLE Name", func() {
-		localName, _ := m24lr.ReadLocalName()
-		printField("Name", localName)
-	})
-
-	printSection("LoRa Settings", func() {
-		settings, _ := m24lr.ReadLoRaSettings(3) // Assuming tag type 3 (Sense Range)
-		printField("Beacon Type", fmt.Sprintf("%d", settings.BeaconType))
-		printField("Hardware Version", settings.HardwareVersion)
-		printField("Firmware Version", settings.FirmwareVersion)
-		printField("Sleep State", settings.SleepState)
-		printField("Min/Max Threshold", settings.MinMaxThreshold)
-		printField("Range Type", settings.RangeType)
-		printField("Spreading Factor", settings.SpreadingFactor)
-		printField("Downlink Bit Rate", fmt.Sprintf("%d", settings.DownlinkBitRate))
-		printField("Uplink Bit Rate", fmt.Sprintf("%d", settings.UplinkBitRate))
-		printField("High Temperature", fmt.Sprintf("%d°C", settings.HighTemperature))
-		printField("Low Temperature", fmt.Sprintf("%d°C", settings.LowTemperature))
-		printField("Accelerometer", fmt.Sprintf("%d", settings.Accelerometer))
-		printField("GNSS Min", fmt.Sprintf("%d", settings.GNSSMin))
-		printField("GNSS Max", fmt.Sprintf("%d", settings.GNSSMax))
-		printField("DOP", fmt.Sprintf("%.1f", settings.DOP))
-		printField("Range Threshold", fmt.Sprintf("%d", settings.RangeThreshold))
-		printField("Sensor Period", fmt.Sprintf("%d", settings.SensorPeriod))
-		printField("Range Offset", fmt.Sprintf("%d", settings.RangeOffset))
-		printField("Maximum Range", fmt.Sprintf("%d m", settings.MaximumRange))
-	})
-}
-
-func printSection(title string, content func()) {
-	log.Infof("\n%s=== %s ===%s\n", colorCyan, title, colorReset)
-	content()
-}
-
-func printField(label, value string) {
-	log.Infof("%s%s:%s %s\n", colorYellow, label, colorReset, value)
-}
-
-func PrintMappedDittoSettings(settings *DittoSettings) {
-	fmt.Println()
-	fmt.Println(colorGreen + "=== Asset+ Tag Settings ===" + colorReset)
-
-	printMappedField("Hardware Version", settings.HardwareVersion)
-	printMappedField("Firmware Version", settings.FirmwareVersion)
-	printMappedField("Beacon Type", strconv.Itoa(settings.BeaconType))
-	printMappedField("Debug Tones", settings.DebugOption)
-	printMappedField("BLE TX PWR", settings.BLEGain)
-	printMappedField("LoRa Region", settings.LoRaRegion)
-	printMappedField("Stationary -> Moved Threshold", fmt.Sprintf("%d", settings.MotionMoved))
-	printMappedField("Moved ->Stationary Threshold", fmt.Sprintf("%d", settings.MotionStationary))
-	printMappedField("Activity Window", fmt.Sprintf("%d", settings.MotionAccelActivity))
-	printMappedField("Activity Threshold", fmt.Sprintf("%d", settings.MotionAccelActivityThreshold))
-	printMappedField("Motion Threshold", fmt.Sprintf("%d", settings.Accelerometer))
-	printMappedField("HBR in Hours", fmt.Sprintf("%d", settings.DownlinkBitRate)) // Assuming HBR is stored in DownlinkBitRate
-	printMappedField("ABR in minutes", fmt.Sprintf("%d", settings.ABR2))
-	printMappedField("Tag Status", settings.SleepState)
-	printMappedField("GNSS Max Lock Time in Minutes", fmt.Sprintf("%d", settings.GNSSMax))
-	printMappedField("DOP Threshold", fmt.Sprintf("%.1f", settings.DOP))
-	printMappedField("Post Movement/DR", "0") // Not clear where this is stored, using a default value
-	printMappedField("LoRa Enable", settings.LoRaEnable)
-	printMappedField("Button Press Uplink", settings.PressUplink)
-	printMappedField("BLE Advertising Type", settings.BLEAdvertisingType)
-	printMappedField("BLE Advertising rate in mS", fmt.Sprintf("%d", settings.BLEAdvertisingInterval))
-	printMappedField("Position Engine BLE Scan", settings.BLERefMode)
-	printMappedField("Position Engine BLE Scan duration in mS", fmt.Sprintf("%d", settings.BLERefScanInterval))
-	printMappedField("BLE Reference Tag Filter ID", settings.BLERefFilter)
-	printMappedField("BLE Scan RSSI Threshold", fmt.Sprintf("%d", settings.BLERefRSSI))
-	printMappedField("LoRaWAN Class B Ping Slot", settings.PingSlotPeriod)
-	printMappedField("LoRaWAN Class B Timeout", fmt.Sprintf("%d", settings.Timeout))
-	printMappedField("LoRaWAN Class select", settings.ClassSelect)
-	printMappedField("LoRaWAN Confirmed Uplinks", settings.ConfirmedUplinks)
-	printMappedField("LoRaWAN Sub-band Hopping", settings.Hopping)
-}
-
-func printMappedField(label, value string) {
-	fmt.Printf("\t%s%s:%s %s\n", colorYellow, label, colorReset, value)
+// MappedDittoSettings returns the Asset+ settings with the names of the configuration tool
+func MappedDittoSettings(settings *DittoSettings) []ConfigField {
+	var fields []ConfigField
+	addField := func(label, value string) {
+		fields = append(fields, ConfigField{Name: label, Value: value})
+	}
+	addField("Hardware Version", settings.HardwareVersion)
+	addField("Firmware Version", settings.FirmwareVersion)
+	addField("Beacon Type", fmt.Sprintf("%02X", settings.BeaconType))
+	addField("Debug Tones", settings.DebugOption)
+	addField("BLE TX PWR", settings.BLEGain)
+	addField("LoRa Region", settings.LoRaRegion)
+	addField("Stationary -> Moved Threshold", fmt.Sprintf("%d", settings.MotionMoved))
+	addField("Moved ->Stationary Threshold", fmt.Sprintf("%d", settings.MotionStationary))
+	addField("Activity Window", fmt.Sprintf("%d", settings.MotionAccelActivity))
+	addField("Activity Threshold", fmt.Sprintf("%d", settings.MotionAccelActivityThreshold))
+	addField("Motion Threshold", fmt.Sprintf("%d", settings.Accelerometer))
+	addField("HBR in Hours", fmt.Sprintf("%d", settings.DownlinkBitRate)) // Assuming HBR is stored in DownlinkBitRate
+	addField("ABR in minutes", fmt.Sprintf("%d", settings.ABR2))
+	addField("Tag Status", settings.SleepState)
+	addField("GNSS Max Lock Time in Minutes", fmt.Sprintf("%d", settings.GNSSMax))
+	addField("DOP Threshold", fmt.Sprintf("%.1f", settings.DOP))
+	addField("Post Movement/DR", "0") // Not clear where this is stored, using a default value
+	addField("LoRa Enable", settings.LoRaEnable)
+	addField("Button Press Uplink", settings.PressUplink)
+	addField("BLE Advertising Type", settings.BLEAdvertisingType)
+	addField("BLE Advertising rate in mS", fmt.Sprintf("%d", settings.BLEAdvertisingInterval))
+	addField("Position Engine BLE Scan", settings.BLERefMode)
+	addField("Position Engine BLE Scan duration in mS", fmt.Sprintf("%d", settings.BLERefScanInterval))
+	addField("BLE Reference Tag Filter ID", settings.BLERefFilter)
+	addField("BLE Scan RSSI Threshold", fmt.Sprintf("%d", settings.BLERefRSSI))
+	addField("LoRaWAN Class B Ping Slot", settings.PingSlotPeriod)
+	addField("LoRaWAN Class B Timeout", fmt.Sprintf("%d", settings.Timeout))
+	addField("LoRaWAN Class select", settings.ClassSelect)
+	addField("LoRaWAN Confirmed Uplinks", settings.ConfirmedUplinks)
+	addField("LoRaWAN Sub-band Hopping", settings.Hopping)
+	return fields
 }
 
 // Helper function to reverse CRC bytes
@@ -1806,7 +1996,7 @@ func extractBytes(hexString string) ([]byte, error) {
 	// Decode hex string to bytes
 	bytes, err := hex.DecodeString(hexString)
 	if err != nil {
-		return nil, fmt.Errorf("invalid hex string: %v", err)
+		return nil, fmt.Errorf("invalid hex string: %w", err)
 	}
 
 	return bytes, nil
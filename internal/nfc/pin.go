@@ -0,0 +1,78 @@
+package nfc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
+)
+
+// PinUID binds the card to the tag with uid: every following write first reads the UID of the
+// presented tag and fails with ErrTagSwapped if it differs, so a tag swapped by the operator in
+// the middle of a multi-step operation is never written with the remaining data. Before the first
+// write the field is checked for other tags, see SetSingleTagCheck, and the family of the tag, see
+// SetFamilyAFI.
+// An empty uid pins the card to the UID read when it was connected.
+func (m *NfcCard) PinUID(uid string) {
+	if uid == "" {
+		uid = m.uid
+	}
+	m.pinnedUID = uid
+}
+
+// UnpinUID stops verifying the UID before writes
+func (m *NfcCard) UnpinUID() {
+	m.pinnedUID = ""
+}
+
+// PinnedUID returns the UID writes are verified against, empty if the card is not pinned
+func (m *NfcCard) PinnedUID() string {
+	return m.pinnedUID
+}
+
+func (m *NfcCard) verifyPinnedUID() error {
+	if m.pinnedUID == "" {
+		return nil
+	}
+	uid, err := m.transmit("FFCA000000", 0x9000)
+	if err != nil {
+		return fmt.Errorf("failed to verify UID: %w", err)
+	}
+	if !strings.EqualFold(uid, m.pinnedUID) {
+		m.log.Errorf("Tag swapped: operation started on %s, presented tag is %s", m.pinnedUID, uid)
+		return fmt.Errorf("%w: expected UID %s, got %s", ErrTagSwapped, m.pinnedUID, uid)
+	}
+	return m.checkField()
+}
+
+// checkField checks the family of the pinned tag and the field for other tags, once per pinned tag
+func (m *NfcCard) checkField() error {
+	if m.fieldChecked == m.pinnedUID {
+		return nil
+	}
+	err := m.checkFamily()
+	if err != nil {
+		return err
+	}
+	err = m.checkSingleTag()
+	if err != nil {
+		return err
+	}
+	m.fieldChecked = m.pinnedUID
+	return nil
+}
+
+// NextTag reads the UID of the tag now presented to the reader and pins the card to it. Loop modes
+// call it for every tag the operator presents, so each tag is protected against swaps while written.
+func (m *NfcCard) NextTag() (string, error) {
+	previous := m.uid
+	err := m.getUID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get UID: %w", err)
+	}
+	if !strings.EqualFold(m.uid, previous) {
+		events.Publish(events.Event{Type: events.TagConnected, UID: m.uid, OK: true})
+	}
+	m.PinUID(m.uid)
+	return m.uid, nil
+}
@@ -0,0 +1,205 @@
+package nfc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrWrongBeaconType is returned when a command, a LoRa identity field or a block is not valid for
+// the beacon type (SKU) of the tag, see BeaconInfo.Products
+var ErrWrongBeaconType = errors.New("not valid for the beacon type of the tag")
+
+// LoRa identity fields of a beacon type, see BeaconInfo.Fields
+const (
+	FIELD_DEVEUI  = "deveui"
+	FIELD_JOINEUI = "joineui"
+	FIELD_JOINKEY = "joinkey"
+)
+
+var identityFields = []string{FIELD_DEVEUI, FIELD_JOINEUI, FIELD_JOINKEY}
+
+// beaconTypeCheck refuses commands, fields and blocks the beacon type of the tag does not have, see
+// SetBeaconTypeCheck
+var beaconTypeCheck = true
+
+// SetBeaconTypeCheck enables or disables the checks of the beacon type (SKU) of the tag: with the
+// check enabled (the default) product commands, LoRa identity fields and blocks the beacon type
+// does not have fail with ErrWrongBeaconType. Blank tags and beacon types without restrictions are
+// not refused, except by CheckBLEBeacon.
+func SetBeaconTypeCheck(on bool) {
+	beaconTypeCheck = on
+}
+
+// blockRange is a range of blocks of BeaconInfo.Blocks, last is -1 for the end of the memory
+type blockRange struct {
+	first, last int
+}
+
+// parseBlockRanges parses a comma separated list of blocks and ranges, e.g. "0-47,64-" (to the end)
+func parseBlockRanges(s string) ([]blockRange, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var ranges []blockRange
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		first, last, isRange := strings.Cut(item, "-")
+		r := blockRange{}
+		var err error
+		r.first, err = strconv.Atoi(first)
+		if err != nil || r.first < 0 {
+			return nil, fmt.Errorf("invalid blocks %q, expected blocks and ranges such as 0-47,64-", item)
+		}
+		switch {
+		case !isRange:
+			r.last = r.first
+		case last == "":
+			r.last = -1
+		default:
+			r.last, err = strconv.Atoi(last)
+			if err != nil || r.last < r.first {
+				return nil, fmt.Errorf("invalid blocks %q, expected blocks and ranges such as 0-47,64-", item)
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// HasProduct reports whether the commands of a product command set (e.g. "Asset+") run on tags of
+// the beacon type
+func (b *BeaconInfo) HasProduct(product string) bool {
+	return b.Products == nil || containsFold(b.Products, product)
+}
+
+// HasField reports whether tags of the beacon type hold a LoRa identity field, e.g. FIELD_DEVEUI
+func (b *BeaconInfo) HasField(field string) bool {
+	return b.Fields == nil || containsFold(b.Fields, field)
+}
+
+// HasBlock reports whether settings may be written to block on tags of the beacon type
+func (b *BeaconInfo) HasBlock(block int) bool {
+	if b.blocks == nil {
+		return true
+	}
+	for _, r := range b.blocks {
+		if block >= r.first && (r.last < 0 || block <= r.last) {
+			return true
+		}
+	}
+	return false
+}
+
+// TagBeaconType returns the beacon type of the tag from block 15, nil for blank tags and beacon
+// types which are not in the table. It is read once per tag.
+func (m *NfcCard) TagBeaconType() (*BeaconInfo, error) {
+	if m.beaconTypeRead {
+		return m.beaconType, nil
+	}
+	block, err := m.ReadBlock(15)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read beacon type: %w", err)
+	}
+	code := strings.ToUpper(block[4:6])
+	info, err := getBeaconInfo(code)
+	if err != nil {
+		m.log.Debugf("Beacon type %s is not in the beacon type table, not checked", code)
+		info = nil
+	}
+	m.beaconType, m.beaconTypeRead = info, true
+	return info, nil
+}
+
+// CheckProduct returns ErrWrongBeaconType if the commands of a product command set do not run on the
+// beacon type of the tag
+func (m *NfcCard) CheckProduct(product string) error {
+	if !beaconTypeCheck {
+		return nil
+	}
+	info, err := m.TagBeaconType()
+	if err != nil || info == nil {
+		return err
+	}
+	if !info.HasProduct(product) {
+		return fmt.Errorf("%w: %s commands on beacon type %s (%s), see -beacon-types", ErrWrongBeaconType, product, info.BeaconType, info.Name)
+	}
+	return nil
+}
+
+// CheckBLEBeacon returns ErrWrongBeaconType unless the tag is a BLE beacon (BeaconInfo.BLEBeacon),
+// so iBeacon and Eddystone identities do not overwrite the DevEUI and settings in blocks 10-14 of
+// LoRa tags. Unlike the other checks, blank tags and beacon types which are not in the table are
+// refused as well: write the SKU first.
+func (m *NfcCard) CheckBLEBeacon() error {
+	if !beaconTypeCheck {
+		return nil
+	}
+	info, err := m.TagBeaconType()
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return fmt.Errorf("%w: the beacon type of the tag is unknown, write the SKU of a BLE beacon first", ErrWrongBeaconType)
+	}
+	if !info.BLEBeacon {
+		return fmt.Errorf("%w: beacon type %s (%s) is not a BLE beacon, blocks 10-14 hold its LoRa identity and settings", ErrWrongBeaconType, info.BeaconType, info.Name)
+	}
+	return nil
+}
+
+// CheckFields returns ErrWrongBeaconType if the tag does not hold one of the LoRa identity fields
+func (m *NfcCard) CheckFields(fields ...string) error {
+	if !beaconTypeCheck {
+		return nil
+	}
+	info, err := m.TagBeaconType()
+	if err != nil || info == nil {
+		return err
+	}
+	for _, field := range fields {
+		if !info.HasField(field) {
+			return fmt.Errorf("%w: beacon type %s (%s) has no %s", ErrWrongBeaconType, info.BeaconType, info.Name, field)
+		}
+	}
+	return nil
+}
+
+// RequiredFields returns the LoRa identity fields which must be provisioned on the tag according to
+// its beacon type, see BeaconInfo.Required
+func (m *NfcCard) RequiredFields() ([]string, error) {
+	if !beaconTypeCheck {
+		return nil, nil
+	}
+	info, err := m.TagBeaconType()
+	if err != nil || info == nil {
+		return nil, err
+	}
+	return info.Required, nil
+}
+
+// checkBlock returns ErrWrongBeaconType if data may not be written to block on the beacon type of the
+// tag. Erasing (writing 0xFF) is always allowed.
+func (m *NfcCard) checkBlock(block int, data string) error {
+	if !beaconTypeCheck || strings.Trim(strings.ToUpper(data), "F") == "" {
+		return nil
+	}
+	info, err := m.TagBeaconType()
+	if err != nil || info == nil {
+		return err
+	}
+	if !info.HasBlock(block) {
+		return fmt.Errorf("%w: block %d on beacon type %s (%s), which has blocks %s", ErrWrongBeaconType, block, info.BeaconType, info.Name, info.Blocks)
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package nfc_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+func TestBeaconIdentityNeedsBLEBeacon(t *testing.T) {
+	tests := []struct {
+		name string
+		sku  string
+		ok   bool
+	}{
+		{"blank tag", "", false},
+		{"Sense Asset BLE", "0D", true},
+		{"Shield/Badge/Lite", "14", true},
+		{"Asset+", "15", false},
+		{"Range Finder", "09", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tag, err := emulator.New(64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			card, err := nfc.NewCard(tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.sku != "" {
+				_, err = card.WriteSKU(test.sku)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			before, err := card.ReadBlock(11)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			errIBeacon := card.WriteIBeaconIdentity("f7826da6-4fa2-4e98-8024-bc5b71e0893e", 1, 2)
+			errEddystone := card.WriteEddystoneIdentity("00112233445566778899", 42)
+			for _, err := range []error{errIBeacon, errEddystone} {
+				if test.ok && err != nil {
+					t.Errorf("identity write on %s: %v", test.name, err)
+				}
+				if !test.ok && !errors.Is(err, nfc.ErrWrongBeaconType) {
+					t.Errorf("identity write on %s = %v, want ErrWrongBeaconType", test.name, err)
+				}
+			}
+			after, err := card.ReadBlock(11)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.ok && after != before {
+				t.Errorf("block 11 changed from %s to %s on a refused write", before, after)
+			}
+		})
+	}
+}
+
+func TestCapabilitiesSenseRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		sku        string
+		senseRange bool
+	}{
+		{"blank tag", "", false},
+		{"Range Finder", "09", true},
+		{"Asset+", "15", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tag, err := emulator.New(64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			card, err := nfc.NewCard(tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.sku != "" {
+				_, err = card.WriteSKU(test.sku)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			caps, err := card.Capabilities()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if caps.SenseRange != test.senseRange {
+				t.Errorf("SenseRange = %v, want %v", caps.SenseRange, test.senseRange)
+			}
+		})
+	}
+}
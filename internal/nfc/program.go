@@ -0,0 +1,211 @@
+package nfc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// programAttempts is the number of times ProgramTag writes a block which does not read back as written
+const programAttempts = 3
+
+// rewriteUnchanged makes ProgramTag write the blocks already holding the image, see SetRewriteUnchanged
+var rewriteUnchanged bool
+
+// SetRewriteUnchanged makes ProgramTag write every block of the image and the CRC, even those which
+// already hold the data. By default only the blocks which change are written, sparing the EEPROM
+// (1M write cycles per block) and programming time.
+func SetRewriteUnchanged(on bool) {
+	rewriteUnchanged = on
+}
+
+// ProgramTag writes a complete configuration image (blocks 0-47) to the tag in a single pass: the
+// configuration is read once, the blocks which change are written back to back without reading the
+// tag in between, then the configuration is read once more to verify them and the CRC is written.
+// Blocks which do not read back as written are rewritten and verified again, up to programAttempts
+// times. Blocks and a CRC already holding the image are not written unless SetRewriteUnchanged.
+// Device specific blocks holding 0xFFFFFFFF in the image (see ReadConfigBin) are kept, so images of
+// generateConfigBin keep the keys and EUIs of the tag; the factory BLE MAC and block 15 (hardware
+// and firmware version, device ID) are always kept. The image must be for the product (device ID)
+// of the tag unless the tag is blank.
+// Returns the blocks written.
+func (m *NfcCard) ProgramTag(image []byte) ([]int, error) {
+	if len(image) != CONFIG_BIN_SIZE {
+		return nil, fmt.Errorf("configuration image has %d bytes, expected %d: %w", len(image), CONFIG_BIN_SIZE, ErrInvalidLength)
+	}
+	err := m.verifyPinnedUID()
+	if err != nil {
+		return nil, err
+	}
+
+	// a single read for the device ID (byte 2 of block 15), the BLE MAC (blocks 18-19) and the blocks
+	// which already hold the image
+	current, err := m.ReadBlocks(0, ASSET_PLUS_CRC_BLOCK+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration: %w", err)
+	}
+	version := ASSET_PLUS_VERSION_BLOCK * 4
+	err = checkImageProduct(current[version:version+4], image)
+	if err != nil {
+		return nil, err
+	}
+	expected := make([]byte, CONFIG_BIN_SIZE)
+	copy(expected, image)
+	copy(expected[version:version+4], current[version:version+4])
+	copy(expected[ASSET_PLUS_BLE_MAC_MSB*4:], current[ASSET_PLUS_BLE_MAC_MSB*4:ASSET_PLUS_BLE_MAC_MSB*4+4])
+	// the end of the BLE MAC is in the first two bytes of its block
+	copy(expected[ASSET_PLUS_BLE_MAC_LSB*4:], current[ASSET_PLUS_BLE_MAC_LSB*4:ASSET_PLUS_BLE_MAC_LSB*4+2])
+
+	var written []int
+	kept := make(map[int]bool)
+	unchanged := 0
+	for block := 0; block < ASSET_PLUS_CONFIG_BLOCKS; block++ {
+		data := expected[block*4 : block*4+4]
+		if keepsBlock(block, data) {
+			kept[block] = true
+			continue
+		}
+		// blocks are compared in constant time, they may hold key material
+		if !rewriteUnchanged && KeysEqual(current[block*4:block*4+4], data) {
+			unchanged++
+			continue
+		}
+		err = m.checkBlock(block, hex.EncodeToString(data))
+		if err != nil {
+			return nil, err
+		}
+		written = append(written, block)
+	}
+
+	// pending are the blocks still to be verified, with their write attempts so far
+	pending := make(map[int]int, len(written))
+	for _, block := range written {
+		pending[block] = 0
+	}
+	config := current[:CONFIG_BIN_SIZE]
+	for round := 1; len(written) > 0; round++ {
+		for _, block := range written {
+			previous, ok := pending[block]
+			if !ok {
+				continue
+			}
+			var attempts int
+			_, attempts, err = m.rewriteBlock(block, strings.ToUpper(hex.EncodeToString(expected[block*4:block*4+4])), previous)
+			if err != nil {
+				return written, fmt.Errorf("failed to write block %d: %w", block, err)
+			}
+			pending[block] = attempts
+		}
+
+		config, err = m.ReadConfigurationForCRC()
+		if err != nil {
+			return written, fmt.Errorf("failed to read configuration: %w", err)
+		}
+		var mismatched []int
+		for _, block := range written {
+			if _, ok := pending[block]; !ok {
+				continue
+			}
+			if KeysEqual(config[block*4:block*4+4], expected[block*4:block*4+4]) {
+				delete(pending, block)
+				continue
+			}
+			mismatched = append(mismatched, block)
+		}
+		if len(mismatched) == 0 {
+			break
+		}
+		if round == programAttempts {
+			return written, fmt.Errorf("%w: blocks %v differ from the image after %d attempts, CRC not written", ErrVerifyFailed, mismatched, round)
+		}
+		m.log.Warnf("Blocks %v did not read back as written, writing them again", mismatched)
+	}
+
+	crc := calculateCRC(config)
+	m.log.Infof("Programmed %d blocks (%d kept, %d unchanged), CRC: 0x%04X", len(written), len(kept), unchanged, crc)
+	crcBlock := current[ASSET_PLUS_CRC_BLOCK*4 : ASSET_PLUS_CRC_BLOCK*4+4]
+	if !rewriteUnchanged && strings.EqualFold(hex.EncodeToString(crcBlock), reverseCRC(crc)) {
+		return written, nil
+	}
+	_, err = m.WriteBlock(ASSET_PLUS_CRC_BLOCK, reverseCRC(crc))
+	if err != nil {
+		return written, fmt.Errorf("failed to write CRC: %w", err)
+	}
+	return written, nil
+}
+
+// keepsBlock reports whether ProgramTag keeps the content of a block of the tag instead of writing
+// data, the block of an image: the BLE MAC, block 15 and device specific blocks left 0xFFFFFFFF in
+// the image
+func keepsBlock(block int, data []byte) bool {
+	return block == ASSET_PLUS_BLE_MAC_MSB || block == ASSET_PLUS_VERSION_BLOCK ||
+		IsDeviceSpecificBlock(block) && bytes.Equal(data, []byte{0xFF, 0xFF, 0xFF, 0xFF})
+}
+
+// checkImageProduct returns ErrWrongProduct unless the device ID (byte 2 of block 15) of an image
+// is the one of the tag, version is block 15 of the tag. Only a blank tag (device ID 0xFF) takes
+// images of any product; an image without a device ID is refused on other tags.
+func checkImageProduct(version []byte, image []byte) error {
+	tagProduct := version[2]
+	imageProduct := image[ASSET_PLUS_VERSION_BLOCK*4+2]
+	if tagProduct != 0xFF && tagProduct != imageProduct {
+		return fmt.Errorf("%w: image device ID %02X, tag device ID %02X", ErrWrongProduct, imageProduct, tagProduct)
+	}
+	return nil
+}
+
+// ImageMismatches compares the configuration blocks (0-47) of a tag with a configuration image and
+// returns the blocks which differ. Blocks ProgramTag keeps, the BLE MAC and the device specific
+// blocks left 0xFFFFFFFF in the image, are not compared, so an image of generateConfigBin matches
+// every tag programmed with it.
+func ImageMismatches(config []byte, image []byte) ([]int, error) {
+	if len(image) != CONFIG_BIN_SIZE || len(config) < CONFIG_BIN_SIZE {
+		return nil, fmt.Errorf("configuration has %d bytes and image %d, expected %d: %w", len(config), len(image), CONFIG_BIN_SIZE, ErrInvalidLength)
+	}
+	var mismatches []int
+	for block := 0; block < ASSET_PLUS_CONFIG_BLOCKS; block++ {
+		data := image[block*4 : block*4+4]
+		if keepsBlock(block, data) {
+			continue
+		}
+		tag := config[block*4 : block*4+4]
+		if block == ASSET_PLUS_BLE_MAC_LSB {
+			// the end of the BLE MAC is in the first two bytes of its block
+			tag, data = tag[2:], data[2:]
+		}
+		if !KeysEqual(tag, data) {
+			mismatches = append(mismatches, block)
+		}
+	}
+	return mismatches, nil
+}
+
+// SetLoraIdentity returns a copy of a configuration image holding a LoRa identity: the DevEUI,
+// JoinEUI and JoinKey as hex strings of 16, 16 and 32 characters
+func SetLoraIdentity(image []byte, devEui, joinEui, joinKey string) ([]byte, error) {
+	if len(image) != CONFIG_BIN_SIZE {
+		return nil, fmt.Errorf("configuration image has %d bytes, expected %d: %w", len(image), CONFIG_BIN_SIZE, ErrInvalidLength)
+	}
+	fields := []struct {
+		name  string
+		value string
+		block int
+		size  int
+	}{
+		{"DevEUI", devEui, ASSET_PLUS_LORA_DEV_EUI_BLOCK_MSB, 8},
+		{"JoinEUI", joinEui, ASSET_PLUS_LORA_JOIN_EUI_BLOCK_MSB, 8},
+		{"JoinKey", joinKey, ASSET_PLUS_LORA_JOIN_KEY_BLOCK_MSB1, 16},
+	}
+	out := make([]byte, CONFIG_BIN_SIZE)
+	copy(out, image)
+	for _, field := range fields {
+		data, err := decodeKey(field.name, field.value, field.size)
+		if err != nil {
+			return nil, err
+		}
+		copy(out[field.block*4:], data)
+		ZeroKey(data)
+	}
+	return out, nil
+}
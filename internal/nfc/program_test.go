@@ -0,0 +1,83 @@
+package nfc_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+func TestImageKeepsBlock15(t *testing.T) {
+	writers := map[string]func(card *nfc.NfcCard, image []byte) ([]int, error){
+		"ProgramTag":     (*nfc.NfcCard).ProgramTag,
+		"WriteConfigBin": (*nfc.NfcCard).WriteConfigBin,
+	}
+	tests := []struct {
+		name         string
+		tagSKU       string
+		imageBlock15 string
+		err          error
+	}{
+		{"same product, other firmware", "15", "02521500", nil},
+		{"blank tag", "", "02521500", nil},
+		{"other product", "15", "02520D00", nfc.ErrWrongProduct},
+		{"image without device ID", "15", "0252FF00", nfc.ErrWrongProduct},
+	}
+	for writerName, write := range writers {
+		for _, test := range tests {
+			t.Run(writerName+"/"+test.name, func(t *testing.T) {
+				tag, err := emulator.New(64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				card, err := nfc.NewCard(tag)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if test.tagSKU != "" {
+					_, err = card.WriteBlock(15, "0148"+test.tagSKU+"00")
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+				before, err := card.ReadBlock(15)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				image := make([]byte, nfc.CONFIG_BIN_SIZE)
+				for i := range image {
+					image[i] = 0xFF
+				}
+				copy(image[7*4:], []byte{0x01, 0x05, 0x00, 0x00})
+				block15, err := hex.DecodeString(test.imageBlock15)
+				if err != nil {
+					t.Fatal(err)
+				}
+				copy(image[15*4:], block15)
+
+				_, err = write(card, image)
+				if !errors.Is(err, test.err) {
+					t.Fatalf("%s() error = %v, want %v", writerName, err, test.err)
+				}
+				after, err := card.ReadBlock(15)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if after != before {
+					t.Errorf("block 15 changed from %s to %s", before, after)
+				}
+				block7, err := card.ReadBlock(7)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if wantWritten := test.err == nil; strings.EqualFold(block7, "01050000") != wantWritten {
+					t.Errorf("block 7 = %s, written %v", block7, wantWritten)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package nfc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotSupported is returned for operations the connected reader cannot perform
+var ErrNotSupported = errors.New("not supported by this reader")
+
+// ReaderQuirks describes how a reader model differs from the HID Omnikey readers the tool was written for
+type ReaderQuirks struct {
+	Name string
+	// Match holds lowercase substrings of the PC/SC reader names of the model
+	Match []string
+	// ISO15693 is false for readers which cannot talk to M24LR (ISO 15693) tags at all
+	ISO15693 bool
+	// ReadBinary and UpdateBinary format the pseudo APDUs from the block number, the block size in
+	// bytes (see NfcCard.BlockSize) and the block data
+	ReadBinary   string
+	UpdateBinary string
+	// ReadMultiple formats the read binary APDU of several blocks from the first block and the length
+	// in bytes, empty if the reader reads single blocks only. See NfcCard.ReadChunk.
+	ReadMultiple string
+	// Transparent is set for readers supporting the PC/SC 2.02 transparent exchange (FF C2) of raw
+	// ISO 15693 commands, see NfcCard.Transceive
+	Transparent bool
+	// MaxBlocks is the number of blocks the pseudo APDUs can address, 0 means no limit
+	MaxBlocks int
+}
+
+// omnikeyQuirks is used for HID Omnikey and every reader not in readerQuirks
+var omnikeyQuirks = ReaderQuirks{
+	Name:         "HID Omnikey",
+	Match:        []string{"omnikey"},
+	ISO15693:     true,
+	ReadBinary:   "FFB0%04X%02X",
+	UpdateBinary: "FFD6%04X%02X%s",
+	ReadMultiple: "FFB0%04X%02X",
+	Transparent:  true,
+}
+
+var readerQuirks = []ReaderQuirks{
+	omnikeyQuirks,
+	{
+		// ISO 14443 and FeliCa only
+		Name:     "ACS ACR122U",
+		Match:    []string{"acr122"},
+		ISO15693: false,
+	},
+	{
+		// ISO 14443, MIFARE and FeliCa only
+		Name:     "ACS ACR1252U",
+		Match:    []string{"acr1252"},
+		ISO15693: false,
+	},
+	{
+		// the block number is passed in P2 only
+		Name:         "ACS ACR1552U",
+		Match:        []string{"acr1552"},
+		ISO15693:     true,
+		ReadBinary:   "FFB000%02X%02X",
+		UpdateBinary: "FFD600%02X%02X%s",
+		ReadMultiple: "FFB000%02X%02X",
+		Transparent:  true,
+		MaxBlocks:    256,
+	},
+}
+
+// QuirksFor returns the quirks of the reader with the given PC/SC name, the Omnikey behaviour is the default
+func QuirksFor(readerName string) *ReaderQuirks {
+	name := strings.ToLower(readerName)
+	for i := range readerQuirks {
+		for _, match := range readerQuirks[i].Match {
+			if strings.Contains(name, match) {
+				return &readerQuirks[i]
+			}
+		}
+	}
+	quirks := omnikeyQuirks
+	return &quirks
+}
+
+// SetQuirks selects the reader specific behaviour, cards use the Omnikey behaviour until it is set
+func (m *NfcCard) SetQuirks(quirks *ReaderQuirks) error {
+	if !quirks.ISO15693 {
+		return fmt.Errorf("%s cannot read ISO 15693 (M24LR) tags: %w", quirks.Name, ErrNotSupported)
+	}
+	m.quirks = quirks
+	m.readChunk = 0
+	return nil
+}
+
+// Quirks returns the reader specific behaviour used by the card
+func (m *NfcCard) Quirks() *ReaderQuirks {
+	if m.quirks == nil {
+		return &omnikeyQuirks
+	}
+	return m.quirks
+}
+
+// blockCommand formats the read (data empty) or update binary APDU of a block
+func (m *NfcCard) blockCommand(blockNumber int, data string) (string, error) {
+	quirks := m.Quirks()
+	if blockNumber < 0 || quirks.MaxBlocks > 0 && blockNumber >= quirks.MaxBlocks {
+		return "", fmt.Errorf("block %d cannot be addressed by %s: %w", blockNumber, quirks.Name, ErrNotSupported)
+	}
+	size := m.BlockSize()
+	if data == "" {
+		return fmt.Sprintf(quirks.ReadBinary, blockNumber, size), nil
+	}
+	if len(data) != size*2 {
+		return "", fmt.Errorf("block %d data %q: %w: expected %d bytes", blockNumber, data, ErrInvalidLength, size)
+	}
+	return fmt.Sprintf(quirks.UpdateBinary, blockNumber, size, data), nil
+}
@@ -0,0 +1,44 @@
+package nfc_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+func TestReaderQuirks(t *testing.T) {
+	tests := []struct {
+		reader    string
+		name      string
+		supported bool
+	}{
+		{"HID Global OMNIKEY 5422 Smartcard Reader 01 00", "HID Omnikey", true},
+		{"ACS ACR122U PICC Interface 00 00", "ACS ACR122U", false},
+		{"ACS ACR1252 Dual Reader PICC 00 00", "ACS ACR1252U", false},
+		{"ACS ACR1552 1S CL Reader PICC 00 00", "ACS ACR1552U", true},
+		{"Generic CCID Reader 00 00", "HID Omnikey", true},
+	}
+	tag, err := emulator.New(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	card, err := nfc.NewCard(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		quirks := nfc.QuirksFor(test.reader)
+		if quirks.Name != test.name {
+			t.Errorf("QuirksFor(%q) = %s, want %s", test.reader, quirks.Name, test.name)
+		}
+		err := card.SetQuirks(quirks)
+		if test.supported && err != nil {
+			t.Errorf("SetQuirks(%s) = %v", quirks.Name, err)
+		}
+		if !test.supported && !errors.Is(err, nfc.ErrNotSupported) {
+			t.Errorf("SetQuirks(%s) = %v, want ErrNotSupported", quirks.Name, err)
+		}
+	}
+}
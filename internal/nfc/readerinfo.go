@@ -0,0 +1,227 @@
+package nfc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"bitbucket.org/bluvision/pcsc/pcsc"
+)
+
+// Proprietary HID get reader information APDUs, answered by the SAM slot (T=0) of OMNIKEY readers.
+// The byte after A0 02 is the tag of the reader capability.
+var (
+	apduGetProductName     = []byte{0xFF, 0x70, 0x07, 0x6B, 0x08, 0xA2, 0x06, 0xA0, 0x04, 0xA0, 0x02, 0x82, 0x00, 0x00}
+	apduGetFirmwareVersion = []byte{0xFF, 0x70, 0x07, 0x6B, 0x08, 0xA2, 0x06, 0xA0, 0x04, 0xA0, 0x02, 0x85, 0x00, 0x00}
+	apduGetSerialNumber    = []byte{0xFF, 0x70, 0x07, 0x6B, 0x08, 0xA2, 0x06, 0xA0, 0x04, 0xA0, 0x02, 0x92, 0x00, 0x00}
+)
+
+// ReaderInfo identifies a PC/SC reader, Error is set when its SAM slot could not be queried and
+// the fields which could not be read are empty
+type ReaderInfo struct {
+	Name            string `json:"name"`
+	ProductName     string `json:"productName,omitempty"`
+	SerialNumber    string `json:"serialNumber,omitempty"`
+	FirmwareVersion string `json:"firmwareVersion,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// ReaderInventory queries all PC/SC readers concurrently and returns their information in the
+// order of pcsc.ListReaders. Every reader is queried with its own PC/SC context, as contexts must
+// not be shared between threads.
+func ReaderInventory() ([]ReaderInfo, error) {
+	ctx, err := pcsc.NewContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PC/SC context: %w", err)
+	}
+	defer ctx.Release()
+	names, err := pcsc.ListReaders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list readers: %w", err)
+	}
+
+	readers := make([]ReaderInfo, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			readers[i] = ReadReaderInfo(name)
+		}(i, name)
+	}
+	wg.Wait()
+	return readers, nil
+}
+
+// ReadReaderInfo queries the product name, serial number and firmware version of a reader
+func ReadReaderInfo(name string) ReaderInfo {
+	info := ReaderInfo{Name: name}
+	ctx, err := pcsc.NewContext()
+	if err != nil {
+		info.Error = fmt.Sprintf("failed to create PC/SC context: %v", err)
+		return info
+	}
+	defer ctx.Release()
+
+	reader := pcsc.NewReader(ctx, name)
+	sam, err := reader.ConnectSamCard_T0()
+	if err != nil {
+		info.Error = fmt.Sprintf("failed to connect to SAM slot: %v", err)
+		return info
+	}
+	defer sam.DisconnectCard()
+
+	var failures []string
+	for _, value := range []struct {
+		name string
+		apdu []byte
+		dest *string
+	}{
+		{"product name", apduGetProductName, &info.ProductName},
+		{"serial number", apduGetSerialNumber, &info.SerialNumber},
+		{"firmware version", apduGetFirmwareVersion, &info.FirmwareVersion},
+	} {
+		resp, err := sam.Apdu(value.apdu)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", value.name, err))
+			continue
+		}
+		apdu, err := ParseAPDU(resp)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: % X: %v", value.name, resp, err))
+			continue
+		}
+		*value.dest = readerInfoText(apdu.ValueRaw)
+	}
+	info.Error = strings.Join(failures, "; ")
+	return info
+}
+
+// readerInfoText returns the printable ASCII of a reader information value up to the null
+// terminator, other bytes escaped as \xNN
+func readerInfoText(data []byte) string {
+	var text strings.Builder
+	for _, b := range data {
+		if b >= 32 && b <= 126 {
+			text.WriteByte(b)
+		} else if b == 0 {
+			break
+		} else {
+			text.WriteString(fmt.Sprintf("\\x%02x", b))
+		}
+	}
+	return text.String()
+}
+
+// ErrReaderFirmware is returned for readers whose firmware is older than required for ISO 15693
+// writes, see CheckFirmware
+var ErrReaderFirmware = errors.New("reader firmware older than required")
+
+// FirmwareRequirement is the minimum firmware version of the readers whose PC/SC or product name
+// contains Model (case insensitive), e.g. versions with known ISO 15693 write bugs are excluded
+type FirmwareRequirement struct {
+	Model      string
+	MinVersion string
+}
+
+// ParseFirmwareRequirements parses "<model>=<minimum version>" entries separated by commas, e.g.
+// "OMNIKEY 5022=1.14,OMNIKEY 5422=2.1"
+func ParseFirmwareRequirements(spec string) ([]FirmwareRequirement, error) {
+	var requirements []FirmwareRequirement
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		model, version, ok := strings.Cut(entry, "=")
+		model, version = strings.TrimSpace(model), strings.TrimSpace(version)
+		if !ok || model == "" {
+			return nil, fmt.Errorf("invalid reader firmware requirement %q, expected <model>=<version>", entry)
+		}
+		_, err := parseVersion(version)
+		if err != nil {
+			return nil, fmt.Errorf("reader model %s: %v", model, err)
+		}
+		requirements = append(requirements, FirmwareRequirement{Model: model, MinVersion: version})
+	}
+	return requirements, nil
+}
+
+// MatchFirmwareRequirement returns the first requirement matching one of the names, nil if none does
+func MatchFirmwareRequirement(requirements []FirmwareRequirement, names ...string) *FirmwareRequirement {
+	for i := range requirements {
+		for _, name := range names {
+			if name != "" && strings.Contains(strings.ToLower(name), strings.ToLower(requirements[i].Model)) {
+				return &requirements[i]
+			}
+		}
+	}
+	return nil
+}
+
+// CheckFirmware checks the firmware version of the reader against the first matching requirement.
+// It returns an error wrapping ErrReaderFirmware for older firmware and nil when no requirement
+// matches.
+func (r ReaderInfo) CheckFirmware(requirements []FirmwareRequirement) error {
+	requirement := MatchFirmwareRequirement(requirements, r.Name, r.ProductName)
+	if requirement == nil {
+		return nil
+	}
+	if r.FirmwareVersion == "" {
+		return fmt.Errorf("firmware version of %s unknown, %s needs %s or newer", r.Name, requirement.Model, requirement.MinVersion)
+	}
+	older, err := versionOlder(r.FirmwareVersion, requirement.MinVersion)
+	if err != nil {
+		return fmt.Errorf("firmware version of %s: %v", r.Name, err)
+	}
+	if older {
+		return fmt.Errorf("%s has firmware %s, %s needs %s or newer: %w", r.Name, r.FirmwareVersion, requirement.Model,
+			requirement.MinVersion, ErrReaderFirmware)
+	}
+	return nil
+}
+
+// parseVersion parses the numbers of a version such as "1.14", "01.14.02" or "V2.1"
+func parseVersion(version string) ([]int, error) {
+	fields := strings.FieldsFunc(strings.TrimLeft(strings.ToLower(strings.TrimSpace(version)), "v"), func(r rune) bool {
+		return r == '.' || r == '-' || r == ' '
+	})
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+	numbers := make([]int, len(fields))
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", version)
+		}
+		numbers[i] = n
+	}
+	return numbers, nil
+}
+
+// versionOlder reports whether version is older than minimum, missing numbers count as 0
+func versionOlder(version, minimum string) (bool, error) {
+	v, err := parseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	m, err := parseVersion(minimum)
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < max(len(v), len(m)); i++ {
+		var a, b int
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(m) {
+			b = m[i]
+		}
+		if a != b {
+			return a < b, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,71 @@
+package nfc_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+func TestParseFirmwareRequirements(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []nfc.FirmwareRequirement
+		ok   bool
+	}{
+		{"OMNIKEY 5022=1.14, OMNIKEY 5422 = V2.1", []nfc.FirmwareRequirement{{Model: "OMNIKEY 5022", MinVersion: "1.14"}, {Model: "OMNIKEY 5422", MinVersion: "V2.1"}}, true},
+		{"", nil, true},
+		{"OMNIKEY 5022=1.14,", []nfc.FirmwareRequirement{{Model: "OMNIKEY 5022", MinVersion: "1.14"}}, true},
+		{"OMNIKEY 5022", nil, false},
+		{"=1.14", nil, false},
+		{"OMNIKEY 5022=1.x", nil, false},
+		{"OMNIKEY 5022=", nil, false},
+	}
+	for _, test := range tests {
+		requirements, err := nfc.ParseFirmwareRequirements(test.spec)
+		if (err == nil) != test.ok {
+			t.Errorf("ParseFirmwareRequirements(%q) = %v, want ok %v", test.spec, err, test.ok)
+			continue
+		}
+		if !reflect.DeepEqual(requirements, test.want) {
+			t.Errorf("ParseFirmwareRequirements(%q) = %v, want %v", test.spec, requirements, test.want)
+		}
+	}
+}
+
+func TestCheckFirmware(t *testing.T) {
+	requirements, err := nfc.ParseFirmwareRequirements("omnikey 5022=1.14,OMNIKEY 5422=2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		reader  nfc.ReaderInfo
+		err     error
+		anyErr  bool
+		matched bool
+	}{
+		{"newer", nfc.ReaderInfo{Name: "HID Global OMNIKEY 5022 Smart Card Reader 0", FirmwareVersion: "1.15"}, nil, false, true},
+		{"numeric comparison", nfc.ReaderInfo{Name: "HID Global OMNIKEY 5022 Smart Card Reader 0", FirmwareVersion: "1.9"}, nfc.ErrReaderFirmware, true, true},
+		{"same", nfc.ReaderInfo{Name: "HID Global OMNIKEY 5022 Smart Card Reader 0", FirmwareVersion: "01.14"}, nil, false, true},
+		{"missing numbers are 0", nfc.ReaderInfo{Name: "OMNIKEY 5422CL", FirmwareVersion: "V2.1.0"}, nil, false, true},
+		{"older patch", nfc.ReaderInfo{Name: "OMNIKEY 5422CL", FirmwareVersion: "2.0.9"}, nfc.ErrReaderFirmware, true, true},
+		{"matched by product name", nfc.ReaderInfo{Name: "Generic CCID 00 00", ProductName: "OMNIKEY 5422", FirmwareVersion: "1.0"}, nfc.ErrReaderFirmware, true, true},
+		{"unknown firmware", nfc.ReaderInfo{Name: "OMNIKEY 5422CL"}, nil, true, true},
+		{"invalid firmware", nfc.ReaderInfo{Name: "OMNIKEY 5422CL", FirmwareVersion: "beta"}, nil, true, true},
+		{"no requirement", nfc.ReaderInfo{Name: "ACS ACR1552 1S CL Reader", FirmwareVersion: "0.1"}, nil, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.reader.CheckFirmware(requirements)
+			if (err != nil) != test.anyErr || test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("CheckFirmware() = %v, want %v (error %v)", err, test.err, test.anyErr)
+			}
+			matched := nfc.MatchFirmwareRequirement(requirements, test.reader.Name, test.reader.ProductName) != nil
+			if matched != test.matched {
+				t.Errorf("MatchFirmwareRequirement() matched %v, want %v", matched, test.matched)
+			}
+		})
+	}
+}
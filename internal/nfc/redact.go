@@ -0,0 +1,101 @@
+package nfc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// redactKeys masks the LoRa JoinKeys (AppKeys) in everything the package prints, see SetRedactKeys
+var redactKeys bool
+
+// SetRedactKeys enables masking of JoinKeys in console output and logs
+func SetRedactKeys(on bool) {
+	redactKeys = on
+}
+
+// RedactKeys reports whether JoinKeys are masked
+func RedactKeys() bool {
+	return redactKeys
+}
+
+// cmMode hides the LoRa keys and the JoinEUI entirely, see SetCMMode
+var cmMode bool
+
+// HIDDEN replaces the values hidden in CM mode in console output
+const HIDDEN = "(hidden)"
+
+// SetCMMode enables the contract manufacturer mode: JoinKeys, NwkKeys and JoinEUIs are replaced by
+// HIDDEN in everything the package prints instead of being masked, key fingerprints stay visible.
+// CM mode implies SetRedactKeys(true).
+func SetCMMode(on bool) {
+	cmMode = on
+	if on {
+		redactKeys = true
+	}
+}
+
+// CMMode reports whether the contract manufacturer mode is enabled
+func CMMode() bool {
+	return cmMode
+}
+
+// RedactKey masks the key (see MaskKey) when redaction is enabled, in CM mode it is hidden
+func RedactKey(key string) string {
+	if cmMode {
+		return HIDDEN
+	}
+	if !redactKeys {
+		return key
+	}
+	return MaskKey(key)
+}
+
+// RedactJoinEUI hides the JoinEUI in CM mode
+func RedactJoinEUI(joinEUI string) string {
+	if cmMode {
+		return HIDDEN
+	}
+	return joinEUI
+}
+
+// MaskKey masks all but the last 4 characters of a key, so operators can still tell keys apart
+func MaskKey(key string) string {
+	if len(key) <= 4 {
+		return strings.Repeat("*", len(key))
+	}
+	return strings.Repeat("*", len(key)-4) + key[len(key)-4:]
+}
+
+// RedactBlock returns block data for display, masked like ReadAllBlocks does when the keys are
+// redacted or the block is hidden in CM mode
+func RedactBlock(blockNumber int, data string) string {
+	return redactBlock(blockNumber, data)
+}
+
+// redactBlock masks the data of the JoinKey blocks when redaction is enabled, in CM mode the data
+// of every block holding key material or the JoinEUI
+func redactBlock(blockNumber int, data string) string {
+	if blockNumber >= ASSET_PLUS_LORA_JOIN_KEY_BLOCK_MSB1 && blockNumber <= ASSET_PLUS_LORA_JOIN_KEY_BLOCK_LSB0 && redactKeys ||
+		cmMode && IsHiddenBlock(blockNumber) {
+		return fmt.Sprintf("%s (redacted)", strings.Repeat("*", len(data)))
+	}
+	return data
+}
+
+// IsHiddenBlock reports whether CM mode hides the block: key material and the JoinEUI (blocks 0-1)
+func IsHiddenBlock(block int) bool {
+	return block == 0 || block == 1 || IsSecretBlock(block)
+}
+
+// KeyFingerprint returns the first 8 hex characters of the SHA-256 of a hex key, which identifies
+// the key without exposing it. The case of the hex key does not matter.
+func KeyFingerprint(key string) string {
+	data, err := hex.DecodeString(key)
+	if err != nil {
+		data = []byte(strings.ToUpper(key))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:8]
+}
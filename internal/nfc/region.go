@@ -0,0 +1,95 @@
+package nfc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LoRa region of the tag: the region code in byte 1 of block 7. The firmware memory map does not
+// define where a frequency sub-band of US915/AU915 would go, the tag uses all channels or sub-band
+// hopping (bit 4 of the LoRaWAN flags, byte 0 of block 31).
+const (
+	ASSET_PLUS_LORA_REGION_BLOCK = 7
+	ASSET_PLUS_LORA_FLAGS_BLOCK  = 31
+	LORA_FLAG_SUB_BAND_HOPPING   = 0x10
+
+	LORA_REGION_AU915 = 1
+	LORA_REGION_US915 = 8
+)
+
+// loraRegions are the region codes of the firmware, in the order of RegionPresets
+var loraRegions = []struct {
+	name string
+	code byte
+}{
+	{"EU868", 5}, {"US915", LORA_REGION_US915}, {"AU915", LORA_REGION_AU915}, {"AS923_GRP1", 0},
+	{"AS923_GRP2", 10}, {"AS923_GRP3", 11}, {"KR920", 6}, {"IN865", 7},
+}
+
+// RegionPreset is a LoRa region, e.g. US915
+type RegionPreset struct {
+	Region string
+	Code   byte
+}
+
+func (p RegionPreset) String() string {
+	return p.Region
+}
+
+// HasSubBands reports whether the region divides its channels into sub-bands
+func (p RegionPreset) HasSubBands() bool {
+	return p.Code == LORA_REGION_US915 || p.Code == LORA_REGION_AU915
+}
+
+// RegionPresets returns the names accepted by ParseRegionPreset
+func RegionPresets() []string {
+	var presets []string
+	for _, region := range loraRegions {
+		presets = append(presets, region.name)
+	}
+	return presets
+}
+
+// ParseRegionPreset parses a region name, e.g. EU868 or US915
+func ParseRegionPreset(name string) (RegionPreset, error) {
+	region := strings.ToUpper(strings.TrimSpace(name))
+	for _, r := range loraRegions {
+		if r.name == region {
+			return RegionPreset{Region: r.name, Code: r.code}, nil
+		}
+	}
+	return RegionPreset{}, fmt.Errorf("unknown region %q, expected one of %s", name, strings.Join(RegionPresets(), ", "))
+}
+
+// ReadRegionPreset reads the region of the tag, hopping reports whether sub-band hopping is enabled
+func (m *NfcCard) ReadRegionPreset() (preset RegionPreset, hopping bool, err error) {
+	region, err := m.ReadBlocks(ASSET_PLUS_LORA_REGION_BLOCK, 1)
+	if err != nil {
+		return RegionPreset{}, false, fmt.Errorf("failed to read block %d: %w", ASSET_PLUS_LORA_REGION_BLOCK, err)
+	}
+	flags, err := m.ReadBlocks(ASSET_PLUS_LORA_FLAGS_BLOCK, 1)
+	if err != nil {
+		return RegionPreset{}, false, fmt.Errorf("failed to read block %d: %w", ASSET_PLUS_LORA_FLAGS_BLOCK, err)
+	}
+	preset.Code = region[1]
+	preset.Region = fmt.Sprintf("Unknown (%d)", region[1])
+	for _, r := range loraRegions {
+		if r.code == region[1] {
+			preset.Region = r.name
+		}
+	}
+	return preset, flags[0]&LORA_FLAG_SUB_BAND_HOPPING != 0, nil
+}
+
+// WriteRegionPreset writes the region and updates the CRC
+func (m *NfcCard) WriteRegionPreset(preset RegionPreset) error {
+	region, err := m.ReadBlock(ASSET_PLUS_LORA_REGION_BLOCK)
+	if err != nil {
+		return fmt.Errorf("failed to read block %d: %w", ASSET_PLUS_LORA_REGION_BLOCK, err)
+	}
+	_, err = m.WriteBlock(ASSET_PLUS_LORA_REGION_BLOCK, fmt.Sprintf("%s%02X%s", region[:2], preset.Code, region[4:]))
+	if err != nil {
+		return fmt.Errorf("failed to write block %d: %w", ASSET_PLUS_LORA_REGION_BLOCK, err)
+	}
+	return m.CalculateAndWriteCRC()
+}
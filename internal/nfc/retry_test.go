@@ -0,0 +1,82 @@
+package nfc_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// flakyTag is an emulated tag whose writes of a block fail (status 6581) or are dropped silently
+// (answered 9000 without writing) a number of times
+type flakyTag struct {
+	*emulator.Tag
+	fail map[int]int
+	drop map[int]int
+}
+
+func (t *flakyTag) Apdu(cmd []byte) ([]byte, error) {
+	if len(cmd) == 9 && cmd[1] == 0xD6 {
+		block := int(cmd[2])<<8 | int(cmd[3])
+		if t.fail[block] > 0 {
+			t.fail[block]--
+			return []byte{0x65, 0x81}, nil
+		}
+		if t.drop[block] > 0 {
+			t.drop[block]--
+			return []byte{0x90, 0x00}, nil
+		}
+	}
+	return t.Tag.Apdu(cmd)
+}
+
+func TestProgramTagRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		fail    map[int]int
+		drop    map[int]int
+		retries map[int]int
+		err     error
+	}{
+		{"no retries", nil, nil, nil, nil},
+		{"failed write", map[int]int{5: 1}, nil, map[int]int{5: 2}, nil},
+		{"dropped write", nil, map[int]int{7: 1}, map[int]int{7: 2}, nil},
+		{"failed and dropped writes", map[int]int{5: 1}, map[int]int{7: 1}, map[int]int{5: 2, 7: 2}, nil},
+		{"write dropped every time", nil, map[int]int{7: 3}, map[int]int{7: 3}, nfc.ErrVerifyFailed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tag, err := emulator.New(64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			card, err := nfc.NewCard(&flakyTag{Tag: tag, fail: test.fail, drop: test.drop})
+			if err != nil {
+				t.Fatal(err)
+			}
+			image := make([]byte, nfc.CONFIG_BIN_SIZE)
+			for i := range image {
+				image[i] = 0xFF
+			}
+			copy(image[5*4:], []byte{0x11, 0x22, 0x33, 0x44})
+			copy(image[7*4:], []byte{0x01, 0x05, 0x00, 0x00})
+
+			attempts := make(events.WriteAttempts)
+			unsubscribe := events.Subscribe(attempts.Add)
+			_, err = card.ProgramTag(image)
+			unsubscribe()
+			if !errors.Is(err, test.err) {
+				t.Fatalf("ProgramTag() error = %v, want %v", err, test.err)
+			}
+			if retries := attempts.Retries(); !reflect.DeepEqual(retries, test.retries) {
+				t.Errorf("retries = %v (%s), want %v", retries, events.FormatRetries(retries), test.retries)
+			}
+			if test.err == nil && !tag.CRCValid() {
+				t.Error("CRC invalid after programming")
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package nfc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultRFCheckReads is the number of reads RFCheck performs per payload size
+const DefaultRFCheckReads = 20
+
+// RFSample is the outcome of the reads of one payload size
+type RFSample struct {
+	Blocks int
+	Reads  int
+	// Attempts counts every APDU sent, a read the reader had to repeat counts more than once
+	Attempts int
+	// Failures are the reads which failed after all retries
+	Failures int
+	Min      time.Duration
+	Avg      time.Duration
+	Max      time.Duration
+}
+
+// ErrorRate returns the share of APDUs which failed, 0 to 1
+func (s RFSample) ErrorRate() float64 {
+	if s.Attempts == 0 {
+		return 0
+	}
+	return float64(s.Attempts-(s.Reads-s.Failures)) / float64(s.Attempts)
+}
+
+// RFReport is the coupling quality between the reader and the tag measured by RFCheck
+type RFReport struct {
+	Samples []RFSample
+	// Score rates the coupling from 0 (no reliable communication) to 100
+	Score int
+	// Quality is good, fair or poor
+	Quality string
+}
+
+// RFCheck estimates the quality of the RF coupling: it reads from block 0 reads times with every
+// payload size the reader supports (1 block, then the multi-block sizes up to ReadChunk) and
+// measures the error rate and the latency. Errors and latency jitter lower the score, larger
+// payloads are more sensitive to a weak field, so they show a badly positioned tag first.
+func (m *NfcCard) RFCheck(reads int) (*RFReport, error) {
+	if reads <= 0 {
+		reads = DefaultRFCheckReads
+	}
+	report := &RFReport{Score: 100}
+	chunk := m.ReadChunk()
+	for i := len(readChunks) - 1; i >= 0; i-- {
+		blocks := readChunks[i]
+		if blocks > chunk {
+			break
+		}
+		sample, err := m.rfSample(blocks, reads)
+		if err != nil {
+			return nil, err
+		}
+		report.Samples = append(report.Samples, sample)
+	}
+
+	for _, sample := range report.Samples {
+		// every percent of failed APDUs costs 2 points, reads which failed altogether 5 more
+		report.Score -= int(sample.ErrorRate()*200 + 0.5)
+		report.Score -= 5 * sample.Failures
+		// a field at the edge of the range answers with very uneven delays
+		if sample.Min > 0 && sample.Max > 3*sample.Min && sample.Max-sample.Min > 20*time.Millisecond {
+			report.Score -= 10
+		}
+	}
+	if report.Score < 0 {
+		report.Score = 0
+	}
+	switch {
+	case report.Score >= 90:
+		report.Quality = "good"
+	case report.Score >= 60:
+		report.Quality = "fair"
+	default:
+		report.Quality = "poor"
+	}
+	m.log.Debugf("RF check: score %d (%s)", report.Score, report.Quality)
+	return report, nil
+}
+
+// rfSample reads blocks blocks from block 0 reads times
+func (m *NfcCard) rfSample(blocks int, reads int) (RFSample, error) {
+	sample := RFSample{Blocks: blocks, Reads: reads}
+	var total time.Duration
+	for i := 0; i < reads; i++ {
+		attempts, latency, err := m.probeRead(blocks)
+		sample.Attempts += attempts
+		if errors.Is(err, ErrNotSupported) {
+			return sample, err
+		}
+		if err != nil {
+			m.log.Debugf("RF check read of %d blocks failed: %v", blocks, err)
+			sample.Failures++
+			continue
+		}
+		total += latency
+		if sample.Min == 0 || latency < sample.Min {
+			sample.Min = latency
+		}
+		if latency > sample.Max {
+			sample.Max = latency
+		}
+	}
+	if ok := reads - sample.Failures; ok > 0 {
+		sample.Avg = total / time.Duration(ok)
+	}
+	return sample, nil
+}
+
+// ProbeRead reads block 0 once, it returns the number of APDUs sent (more than 1 when the reader
+// had to repeat the read) and the latency of the read. Used to calibrate fixtures.
+func (m *NfcCard) ProbeRead() (int, time.Duration, error) {
+	return m.probeRead(1)
+}
+
+// probeRead reads blocks blocks from block 0 with a single read
+func (m *NfcCard) probeRead(blocks int) (int, time.Duration, error) {
+	cmd, err := m.blockCommand(0, "")
+	if err != nil {
+		return 0, 0, err
+	}
+	if blocks > 1 {
+		cmd = fmt.Sprintf(m.Quirks().ReadMultiple, 0, byte(blocks*4))
+	}
+	start := time.Now()
+	resp, attempts, err := m.transmitAttempts(cmd, 0x9000)
+	latency := time.Since(start)
+	if err == nil && len(resp) != blocks*8 {
+		err = fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidLength, blocks*4, len(resp)/2)
+	}
+	return attempts, latency, err
+}
@@ -0,0 +1,65 @@
+package nfc_test
+
+import (
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// weakFieldTag is an emulated tag failing every nth read of more than one block (status 6581), as a
+// tag at the edge of the field fails the larger payloads first
+type weakFieldTag struct {
+	*emulator.Tag
+	every int
+	reads int
+}
+
+func (t *weakFieldTag) Apdu(cmd []byte) ([]byte, error) {
+	if t.every > 0 && len(cmd) == 5 && cmd[1] == 0xB0 && cmd[4] > 4 {
+		t.reads++
+		if t.reads%t.every == 0 {
+			return []byte{0x65, 0x81}, nil
+		}
+	}
+	return t.Tag.Apdu(cmd)
+}
+
+func TestRFCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		every    int
+		minScore int
+		maxScore int
+		quality  string
+	}{
+		{"good coupling", 0, 100, 100, "good"},
+		{"every 20th large read fails", 20, 60, 89, "fair"},
+		{"every third large read fails", 3, 0, 59, "poor"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tag, err := emulator.New(64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			card, err := nfc.NewCard(&weakFieldTag{Tag: tag, every: test.every})
+			if err != nil {
+				t.Fatal(err)
+			}
+			report, err := card.RFCheck(nfc.DefaultRFCheckReads)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Score < test.minScore || report.Score > test.maxScore || report.Quality != test.quality {
+				t.Errorf("RFCheck() = score %d (%s), want %d-%d (%s)", report.Score, report.Quality, test.minScore, test.maxScore, test.quality)
+			}
+			if len(report.Samples) < 2 || report.Samples[0].Blocks != 1 {
+				t.Fatalf("samples %+v, want single blocks and multi-block reads", report.Samples)
+			}
+			if report.Samples[0].Failures != 0 || report.Samples[0].Attempts != nfc.DefaultRFCheckReads {
+				t.Errorf("single block sample %+v, want no failed reads", report.Samples[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package nfc
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ZeroKey overwrites key material with zeros once it is no longer needed, so it does not stay in
+// memory until the garbage collector reuses it
+func ZeroKey(key []byte) {
+	for i := range key {
+		key[i] = 0
+	}
+}
+
+// KeysEqual compares two keys in constant time, the time taken does not tell how much of them matched
+func KeysEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// decodeKey decodes a hex key of size bytes, clear the key with ZeroKey after use
+func decodeKey(name string, hexKey string, size int) ([]byte, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil || len(key) != size {
+		ZeroKey(key)
+		return nil, fmt.Errorf("invalid LoRa %s, expected %d hex characters", name, size*2)
+	}
+	return key, nil
+}
+
+// writeKey writes a key to the blocks from first on, 4 bytes per block, and reads it back to verify it
+func (m *NfcCard) writeKey(name string, first int, key []byte) error {
+	for i := 0; i < len(key)/4; i++ {
+		_, err := m.WriteBlock(first+i, strings.ToUpper(hex.EncodeToString(key[i*4:i*4+4])))
+		if err != nil {
+			return fmt.Errorf("failed to write block %d: %w", first+i, err)
+		}
+	}
+	written, err := m.ReadBlocks(first, len(key)/4)
+	if err != nil {
+		return fmt.Errorf("failed to read back the %s: %w", name, err)
+	}
+	defer ZeroKey(written)
+	if !KeysEqual(written, key) {
+		return fmt.Errorf("%w: the %s read back differs from the key written", ErrVerifyFailed, name)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package nfc
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// M24LR_SECTOR_BLOCKS is the number of blocks of an M24LR sector (1 Kbit), the unit of its read and
+// write protection. The M24LR64E-R has 64 sectors, the M24LR16E-R 16 and the M24LR04E-R 4.
+const M24LR_SECTOR_BLOCKS = 32
+
+// bits of the M24LR sector security status, returned with a block read with ISO15693_FLAG_OPTION
+const (
+	M24LR_SSS_LOCK         = 0x01 // the protection bits apply
+	M24LR_SSS_READ_PWD     = 0x02 // reading needs the password
+	M24LR_SSS_WRITE_LOCKED = 0x04 // writing is forbidden, otherwise it needs the password
+	M24LR_SSS_PASSWORD     = 0x18 // password protecting the sector, 0 for none
+)
+
+// Sector is a range of M24LR_SECTOR_BLOCKS blocks of the tag, the last sector of a tag whose memory
+// is not a multiple of sectors is shorter
+type Sector struct {
+	Number int
+	First  int
+	Last   int
+}
+
+// Blocks returns the number of blocks of the sector
+func (s Sector) Blocks() int {
+	return s.Last - s.First + 1
+}
+
+// Block returns the block number of the block at offset in the sector
+func (s Sector) Block(offset int) (int, error) {
+	if offset < 0 || offset >= s.Blocks() {
+		return 0, fmt.Errorf("offset %d is outside sector %d (blocks %d-%d)", offset, s.Number, s.First, s.Last)
+	}
+	return s.First + offset, nil
+}
+
+// SectorStatus is the security status of a sector
+type SectorStatus struct {
+	Sector
+	// Status is the sector security status byte, see M24LR_SSS_LOCK
+	Status byte
+}
+
+// Locked reports whether the protection of the sector is active
+func (s *SectorStatus) Locked() bool {
+	return s.Status&M24LR_SSS_LOCK != 0
+}
+
+// Password returns the number (1-3) of the password protecting the sector, 0 if none does
+func (s *SectorStatus) Password() int {
+	return int(s.Status&M24LR_SSS_PASSWORD) >> 3
+}
+
+// Access describes the access to the sector without presenting its password
+func (s *SectorStatus) Access() string {
+	return securityAccess(s.Status)
+}
+
+// securityAccess describes the access granted by a security status byte without the password
+func securityAccess(status byte) string {
+	if status&M24LR_SSS_LOCK == 0 {
+		return "read/write"
+	}
+	read, write := "read", "write with password"
+	if status&M24LR_SSS_READ_PWD != 0 {
+		read = "read with password"
+	}
+	if status&M24LR_SSS_WRITE_LOCKED != 0 {
+		write = "no write"
+	}
+	return read + ", " + write
+}
+
+// BlockRange is a range of consecutive blocks with the same security status
+type BlockRange struct {
+	First int
+	Last  int
+	// Status is the security status byte of the blocks, see M24LR_SSS_LOCK
+	Status byte
+}
+
+// Blocks returns the number of blocks of the range
+func (r *BlockRange) Blocks() int {
+	return r.Last - r.First + 1
+}
+
+// Writable reports whether the blocks can be written without presenting a password
+func (r *BlockRange) Writable() bool {
+	return r.Status&M24LR_SSS_LOCK == 0
+}
+
+// Password returns the number (1-3) of the password protecting the blocks, 0 if none does
+func (r *BlockRange) Password() int {
+	return int(r.Status&M24LR_SSS_PASSWORD) >> 3
+}
+
+// Access describes the access to the blocks without presenting their password
+func (r *BlockRange) Access() string {
+	return securityAccess(r.Status)
+}
+
+// Sectors returns the sectors of the tag from its memory size, see Blocks
+func (m *NfcCard) Sectors() ([]Sector, error) {
+	blocks, err := m.Blocks()
+	if err != nil {
+		return nil, err
+	}
+	var sectors []Sector
+	for first := 0; first < blocks; first += M24LR_SECTOR_BLOCKS {
+		last := first + M24LR_SECTOR_BLOCKS - 1
+		if last >= blocks {
+			last = blocks - 1
+		}
+		sectors = append(sectors, Sector{Number: len(sectors), First: first, Last: last})
+	}
+	return sectors, nil
+}
+
+// Sector returns sector number of the tag
+func (m *NfcCard) Sector(number int) (Sector, error) {
+	sectors, err := m.Sectors()
+	if err != nil {
+		return Sector{}, err
+	}
+	if number < 0 || number >= len(sectors) {
+		return Sector{}, fmt.Errorf("invalid sector %d, the tag has sectors 0-%d", number, len(sectors)-1)
+	}
+	return sectors[number], nil
+}
+
+// SectorSecurityStatus reads the security status of a sector: read single block of its first block
+// with the option flag returns the status before the block data. Needs the transparent exchange.
+func (m *NfcCard) SectorSecurityStatus(number int) (*SectorStatus, error) {
+	sector, err := m.Sector(number)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.blockRequest(ISO15693_FLAG_OPTION, ISO15693_CMD_READ_SINGLE, sector.First, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read security status of sector %d: %w", number, err)
+	}
+	if len(resp) < 1 {
+		return nil, fmt.Errorf("security status of sector %d: %w", number, ErrShortResponse)
+	}
+	return &SectorStatus{Sector: sector, Status: resp[0]}, nil
+}
+
+// SectorSecurityStatuses reads the security status of every sector of the tag
+func (m *NfcCard) SectorSecurityStatuses() ([]SectorStatus, error) {
+	sectors, err := m.Sectors()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]SectorStatus, 0, len(sectors))
+	for _, sector := range sectors {
+		status, err := m.SectorSecurityStatus(sector.Number)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, *status)
+	}
+	return statuses, nil
+}
+
+// BlockSecurityStatus reads the security status of a block, see SectorSecurityStatus. The M24LR
+// reports the status of the sector of the block.
+func (m *NfcCard) BlockSecurityStatus(block int) (byte, error) {
+	resp, err := m.blockRequest(ISO15693_FLAG_OPTION, ISO15693_CMD_READ_SINGLE, block, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read security status of block %d: %w", block, err)
+	}
+	if len(resp) < 1 {
+		return 0, fmt.Errorf("security status of block %d: %w", block, ErrShortResponse)
+	}
+	return resp[0], nil
+}
+
+// LockStatus reads the security status of every block of the tag and returns the ranges of blocks
+// sharing a status, in block order. Reads one block at a time, which takes a few seconds on the
+// larger tags.
+func (m *NfcCard) LockStatus() ([]BlockRange, error) {
+	blocks, err := m.Blocks()
+	if err != nil {
+		return nil, err
+	}
+	var ranges []BlockRange
+	for block := 0; block < blocks; block++ {
+		status, err := m.BlockSecurityStatus(block)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].Status == status {
+			ranges[n-1].Last = block
+			continue
+		}
+		ranges = append(ranges, BlockRange{First: block, Last: block, Status: status})
+	}
+	return ranges, nil
+}
+
+// ReadSector reads every block of a sector
+func (m *NfcCard) ReadSector(number int) ([]byte, error) {
+	sector, err := m.Sector(number)
+	if err != nil {
+		return nil, err
+	}
+	return m.ReadBlocks(sector.First, sector.Blocks())
+}
+
+// ReadSectorBlock reads the block at offset in a sector
+func (m *NfcCard) ReadSectorBlock(number int, offset int) (string, error) {
+	sector, err := m.Sector(number)
+	if err != nil {
+		return "", err
+	}
+	block, err := sector.Block(offset)
+	if err != nil {
+		return "", err
+	}
+	return m.ReadBlock(block)
+}
+
+// WriteSectorBlock writes the block at offset in a sector. When the card is pinned the UID is
+// verified first.
+func (m *NfcCard) WriteSectorBlock(number int, offset int, data []byte) error {
+	sector, err := m.Sector(number)
+	if err != nil {
+		return err
+	}
+	block, err := sector.Block(offset)
+	if err != nil {
+		return err
+	}
+	_, err = m.WriteBlock(block, hex.EncodeToString(data))
+	return err
+}
@@ -0,0 +1,211 @@
+package nfc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Connector opens a new connection to the tag presented to the reader
+type Connector func() (CardTransport, error)
+
+// Session keeps an NfcCard connected across operations. When an operation fails because the
+// connection to the tag was lost, the session reconnects, verifies that the same tag (UID) is
+// presented again and retries the operation, instead of reusing the stale card handle.
+// The cards of a session are pinned to its tag (see NfcCard.PinUID), writes to a swapped tag fail
+// with ErrTagSwapped.
+type Session struct {
+	mu      sync.Mutex
+	connect Connector
+	card    *NfcCard
+	health  *sessionTransport
+	uid     string
+	log     Logger
+
+	// Authenticate is called after every (re)connect, e.g. to present the password of a protected tag
+	Authenticate func(card *NfcCard) error
+	// MaxReconnects is the number of times an operation is retried after reconnecting
+	MaxReconnects int
+	// ReconnectDelay is the time given to the operator to present the tag again before reconnecting
+	ReconnectDelay time.Duration
+	// APDUTimeout is the time a single APDU may take, see NfcCard.SetAPDUTimeout
+	APDUTimeout time.Duration
+	// OperationTimeout limits the time of every operation run by Do, 0 means no limit
+	OperationTimeout time.Duration
+	// Quirks selects the reader specific behaviour, nil uses the quirks of the ReaderNamer transport
+	// or the Omnikey behaviour
+	Quirks *ReaderQuirks
+}
+
+// ReaderNamer is implemented by transports which know the PC/SC name of their reader, e.g. remote readers
+type ReaderNamer interface {
+	ReaderName() string
+}
+
+// sessionTransport records whether the transport itself failed, as opposed to the tag answering
+// with an error status, so the session knows when its card handle went stale
+type sessionTransport struct {
+	CardTransport
+	failed bool
+}
+
+func (t *sessionTransport) Apdu(cmd []byte) ([]byte, error) {
+	resp, err := t.CardTransport.Apdu(cmd)
+	if err != nil {
+		t.failed = true
+	}
+	return resp, err
+}
+
+// NewSession creates a session connecting through connect. The connection is opened by the first operation.
+func NewSession(connect Connector) *Session {
+	return &Session{
+		connect:        connect,
+		log:            defaultLogger,
+		MaxReconnects:  1,
+		ReconnectDelay: 200 * time.Millisecond,
+		APDUTimeout:    DefaultAPDUTimeout,
+	}
+}
+
+// SetLogger sets the logger used by the session and its cards
+func (s *Session) SetLogger(l Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = l
+	if s.card != nil {
+		s.card.SetLogger(l)
+	}
+}
+
+// UID returns the UID of the tag the session is bound to, empty before the first connect
+func (s *Session) UID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.uid
+}
+
+// Card returns the connected card, connecting if needed
+func (s *Session) Card() (*NfcCard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected()
+}
+
+func (s *Session) connected() (*NfcCard, error) {
+	if s.card == nil {
+		err := s.open()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.card, nil
+}
+
+// Do runs op against the connected card. If op fails because the connection was lost, the session
+// reconnects to the same tag and runs op again, up to MaxReconnects times.
+// Operations of concurrent callers are serialized.
+func (s *Session) Do(op func(card *NfcCard) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		card, err := s.connected()
+		if err != nil {
+			return err
+		}
+		s.health.failed = false
+		if s.OperationTimeout > 0 {
+			card.SetDeadline(time.Now().Add(s.OperationTimeout))
+		}
+		err = card.Transaction(op)
+		card.SetDeadline(time.Time{})
+		lost := s.health.failed || card.isStalled()
+		if err == nil || !lost {
+			return err
+		}
+		// never hand out the stale handle again, even if this was the last attempt
+		s.drop()
+		if errors.Is(err, ErrOperationTimeout) || attempt >= s.MaxReconnects {
+			return err
+		}
+		s.log.Warnf("Connection to tag %s lost (%v), reconnecting", s.uid, err)
+		time.Sleep(s.ReconnectDelay)
+	}
+}
+
+// Release disconnects the card and unbinds the session from its tag, so the next operation
+// accepts a different tag. Used between tags in loop modes.
+func (s *Session) Release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.close()
+	s.uid = ""
+	return err
+}
+
+// Close disconnects and unpowers the card, a later operation reconnects to the same tag
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.close()
+}
+
+func (s *Session) close() error {
+	if s.card == nil {
+		return nil
+	}
+	err := s.card.Close()
+	s.card, s.health = nil, nil
+	return err
+}
+
+func (s *Session) open() error {
+	t, err := s.connect()
+	if err != nil {
+		return err
+	}
+	health := &sessionTransport{CardTransport: t}
+	card, err := NewCard(health)
+	if err != nil {
+		return err
+	}
+	card.SetLogger(s.log)
+	card.SetAPDUTimeout(s.APDUTimeout)
+	quirks := s.Quirks
+	if named, ok := t.(ReaderNamer); ok && quirks == nil {
+		quirks = QuirksFor(named.ReaderName())
+	}
+	if quirks != nil {
+		err = card.SetQuirks(quirks)
+		if err != nil {
+			card.Reader.DisconnectCard()
+			return err
+		}
+	}
+
+	if s.uid != "" && !strings.EqualFold(card.UID(), s.uid) {
+		card.Reader.DisconnectCard()
+		return fmt.Errorf("%w: expected UID %s, got %s", ErrTagSwapped, s.uid, card.UID())
+	}
+	card.PinUID(s.uid)
+	if s.Authenticate != nil {
+		err = s.Authenticate(card)
+		if err != nil {
+			card.Reader.DisconnectCard()
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	s.card, s.health, s.uid = card, health, card.UID()
+	return nil
+}
+
+// drop forgets the stale card handle without unbinding the session from its tag
+func (s *Session) drop() {
+	if s.card != nil {
+		s.card.Reader.DisconnectCard()
+	}
+	s.card, s.health = nil, nil
+}
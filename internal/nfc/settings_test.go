@@ -0,0 +1,53 @@
+package nfc_test
+
+import (
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+func TestDecodeLoRaSettingsSenseRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		beaconType string
+		code       int
+		senseRange bool
+	}{
+		{"Sense Condition Range Finder", "09", 0x09, true},
+		{"Sense Asset +", "15", 0x15, false},
+		{"Sense Asset BLE", "0D", 0x0D, false},
+		{"type not in the table", "7E", 0x7E, false},
+		{"blank", "FF", 0xFF, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			blocks := map[int]string{
+				8: "07101580", 9: "50580100", 10: "05001020", 11: "00000000",
+				12: "00000000", 13: "00010000", 14: "10301500", 15: "034A" + test.beaconType + "04",
+			}
+			// the beacon type is a hex code, every one of them decodes in strict mode
+			settings, _, err := nfc.DecodeLoRaSettings(blocks, nfc.ParseStrict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if settings.BeaconType != test.code {
+				t.Errorf("BeaconType = %02X, want %02X", settings.BeaconType, test.code)
+			}
+			if settings.SenseRange != test.senseRange {
+				t.Errorf("SenseRange = %v, want %v", settings.SenseRange, test.senseRange)
+			}
+			want := nfc.LoRaSettings{}
+			if test.senseRange {
+				want = nfc.LoRaSettings{RangeThreshold: 100, SensorPeriod: 5, RangeOffset: 10, MaximumRange: 200}
+			}
+			got := nfc.LoRaSettings{RangeThreshold: settings.RangeThreshold, SensorPeriod: settings.SensorPeriod,
+				RangeOffset: settings.RangeOffset, MaximumRange: settings.MaximumRange}
+			if got != want {
+				t.Errorf("Range fields %+v, want %+v", got, want)
+			}
+			if settings.LowTemperature != 0x50-127 || settings.Accelerometer != 58 {
+				t.Errorf("block 9 decoded to low temperature %d, accelerometer %d", settings.LowTemperature, settings.Accelerometer)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package nfc_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// noSessionTag is an emulated tag behind a reader refusing the transparent session
+type noSessionTag struct {
+	*emulator.Tag
+}
+
+func (t *noSessionTag) Apdu(cmd []byte) ([]byte, error) {
+	if len(cmd) > 1 && cmd[0] == 0xFF && cmd[1] == 0xC2 {
+		return []byte{0x6A, 0x81}, nil
+	}
+	return t.Tag.Apdu(cmd)
+}
+
+func TestSingleTagCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		session bool
+		check   bool
+		err     error
+	}{
+		{"single tag", true, true, nil},
+		{"session refused", false, true, nfc.ErrFieldUnchecked},
+		{"session refused, check disabled", false, false, nil},
+	}
+	defer nfc.SetSingleTagCheck(true)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tag, err := emulator.New(64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var transport nfc.CardTransport = tag
+			if !test.session {
+				transport = &noSessionTag{Tag: tag}
+			}
+			card, err := nfc.NewCard(transport)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nfc.SetSingleTagCheck(test.check)
+			card.PinUID(card.UID())
+			err = card.WriteLoraJoinEui("0011223344556677")
+			if !errors.Is(err, test.err) {
+				t.Errorf("WriteLoraJoinEui() = %v, want %v", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package nfc
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// manufacturers are the IC manufacturers by the ISO/IEC 7816-6 code in the second byte of the UID
+var manufacturers = map[byte]string{
+	0x02: "STMicroelectronics",
+	0x04: "NXP Semiconductors",
+	0x05: "Infineon Technologies",
+	0x07: "Texas Instruments",
+	0x16: "EM Microelectronic",
+}
+
+// stICs are the ICs of STMicroelectronics (manufacturer code 0x02) by IC reference
+var stICs = map[byte]string{
+	0x24: "ST25DV04K",
+	0x26: "ST25DV16K/ST25DV64K",
+	0x4E: "M24LR16E-R",
+	0x5A: "M24LR04E-R",
+	0x5E: "M24LR64E-R",
+}
+
+// MemoryRegion is a range of blocks of the tag memory
+type MemoryRegion struct {
+	Name  string
+	First int
+	Last  int
+}
+
+// ReadSystemInfo returns the system information of the presented tag. Readers with transparent
+// exchange send get system info, the others only report the AFI, DSFID and memory size through
+// their pseudo APDUs, without the IC reference.
+func (m *NfcCard) ReadSystemInfo() (*SystemInfo, error) {
+	uid, err := m.transmit("FFCA000000", 0x9000)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get UID: %w", err)
+	}
+	uid = strings.ToLower(uid)
+	info, err := m.GetSystemInfo(uid)
+	if !errors.Is(err, ErrNotSupported) {
+		return info, err
+	}
+	m.log.Debugf("Get system info through pseudo APDUs: %v", err)
+
+	info = &SystemInfo{UID: uid}
+	for _, value := range []struct {
+		read func() (string, error)
+		dest **byte
+	}{{m.AFI, &info.AFI}, {m.DSFID, &info.DSFID}} {
+		resp, err := value.read()
+		if err != nil {
+			return nil, err
+		}
+		data, err := hex.DecodeString(resp)
+		if err != nil || len(data) != 1 {
+			return nil, fmt.Errorf("system info % X: %w", resp, ErrInvalidLength)
+		}
+		*value.dest = &data[0]
+	}
+	// number of blocks - 1 (least significant byte first) and block size - 1
+	resp, err := m.transmit("FF30040003", 0x9000)
+	if err != nil {
+		return nil, err
+	}
+	data, err := hex.DecodeString(resp)
+	if err != nil || len(data) != 3 {
+		return nil, fmt.Errorf("memory size %s: %w", resp, ErrInvalidLength)
+	}
+	info.Blocks = int(data[0]) + int(data[1])<<8 + 1
+	info.BlockSize = int(data[2]&0x1F) + 1
+	return info, nil
+}
+
+// Manufacturer returns the IC manufacturer coded in the UID, empty when unknown
+func (s *SystemInfo) Manufacturer() string {
+	uid, err := hex.DecodeString(s.UID)
+	if err != nil || len(uid) != 8 {
+		return ""
+	}
+	return manufacturers[uid[1]]
+}
+
+// ICName returns the name of the IC from the manufacturer and the IC reference, empty when unknown
+func (s *SystemInfo) ICName() string {
+	uid, err := hex.DecodeString(s.UID)
+	if err != nil || len(uid) != 8 || uid[1] != 0x02 || s.ICReference == nil {
+		return ""
+	}
+	return stICs[*s.ICReference]
+}
+
+// Layout returns the memory regions of an Asset+ tag: the configuration covered by the CRC, the
+// CRC block and the blocks the tool does not use. Empty when the size is unknown.
+func (s *SystemInfo) Layout() []MemoryRegion {
+	if s.Blocks == 0 {
+		return nil
+	}
+	regions := []MemoryRegion{
+		{"Configuration", 0, ASSET_PLUS_CONFIG_BLOCKS - 1},
+		{"CRC", ASSET_PLUS_CRC_BLOCK, ASSET_PLUS_CRC_BLOCK},
+		{"Unused", ASSET_PLUS_CRC_BLOCK + 1, s.Blocks - 1},
+	}
+	var layout []MemoryRegion
+	for _, region := range regions {
+		if region.First >= s.Blocks {
+			break
+		}
+		if region.Last >= s.Blocks {
+			region.Last = s.Blocks - 1
+		}
+		if region.Last >= region.First {
+			layout = append(layout, region)
+		}
+	}
+	return layout
+}
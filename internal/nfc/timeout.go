@@ -0,0 +1,87 @@
+package nfc
+
+import (
+	"time"
+)
+
+// DefaultAPDUTimeout is the APDU timeout of new cards, a dead reader fails after this time instead of hanging
+const DefaultAPDUTimeout = 5 * time.Second
+
+// Canceler is implemented by transports which can abort an exchange that did not complete in time
+type Canceler interface {
+	Cancel() error
+}
+
+// SetAPDUTimeout sets the time a single APDU exchange may take, 0 waits forever
+func (m *NfcCard) SetAPDUTimeout(timeout time.Duration) {
+	m.apduMu.Lock()
+	defer m.apduMu.Unlock()
+	m.apduTimeout = timeout
+}
+
+// SetDeadline sets the time by which the current operation must be done, APDUs after the deadline
+// fail with ErrOperationTimeout. The zero time clears the deadline.
+func (m *NfcCard) SetDeadline(deadline time.Time) {
+	m.apduMu.Lock()
+	defer m.apduMu.Unlock()
+	m.deadline = deadline
+}
+
+// isStalled reports whether an exchange timed out, the card has to be reconnected
+func (m *NfcCard) isStalled() bool {
+	m.apduMu.Lock()
+	defer m.apduMu.Unlock()
+	return m.stalled
+}
+
+type apduResult struct {
+	resp []byte
+	err  error
+}
+
+// exchange sends one APDU, honouring the APDU timeout and the operation deadline. After a timeout
+// the exchange may still be pending in the transport, so the card refuses further APDUs and has to
+// be reconnected (Session does this automatically). Must be called with apduMu held.
+func (m *NfcCard) exchange(cmd []byte) ([]byte, error) {
+	if m.stalled {
+		return nil, ErrAPDUTimeout
+	}
+	timeout := m.apduTimeout
+	if !m.deadline.IsZero() {
+		remaining := time.Until(m.deadline)
+		if remaining <= 0 {
+			return nil, ErrOperationTimeout
+		}
+		if timeout <= 0 || remaining < timeout {
+			timeout = remaining
+		}
+	}
+	if timeout <= 0 {
+		return m.Reader.Apdu(cmd)
+	}
+
+	done := make(chan apduResult, 1)
+	go func() {
+		resp, err := m.Reader.Apdu(cmd)
+		done <- apduResult{resp, err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		return result.resp, result.err
+	case <-timer.C:
+	}
+
+	m.stalled = true
+	if canceler, ok := m.Reader.(Canceler); ok {
+		err := canceler.Cancel()
+		if err != nil {
+			m.log.Debugf("failed to cancel APDU exchange: %v", err)
+		}
+	}
+	if !m.deadline.IsZero() && !time.Now().Before(m.deadline) {
+		return nil, ErrOperationTimeout
+	}
+	return nil, ErrAPDUTimeout
+}
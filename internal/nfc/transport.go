@@ -0,0 +1,66 @@
+package nfc
+
+import (
+	"fmt"
+
+	"bitbucket.org/bluvision/pcsc/pcsc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
+)
+
+// CardTransport exchanges APDUs with a tag. It is implemented by the PC/SC reader connection
+// as well as by the simulation backends, so NfcCard does not depend on a physical reader.
+type CardTransport interface {
+	Apdu(cmd []byte) ([]byte, error)
+	DisconnectCard() error
+	DisconnectUnpowerCard() error
+}
+
+// pcscTransport adapts a connected PC/SC card to CardTransport
+type pcscTransport struct {
+	card pcsc.Card
+}
+
+func (t *pcscTransport) Apdu(cmd []byte) ([]byte, error) {
+	return t.card.Apdu(cmd)
+}
+
+func (t *pcscTransport) DisconnectCard() error {
+	return t.card.DisconnectCard()
+}
+
+func (t *pcscTransport) DisconnectUnpowerCard() error {
+	return t.card.DisconnectUnpowerCard()
+}
+
+// Cancel disconnects the card handle, which makes a pending SCardTransmit return on the platforms
+// whose PC/SC stack allows disconnecting from another thread
+func (t *pcscTransport) Cancel() error {
+	return t.card.DisconnectCard()
+}
+
+// ConnectPCSC connects to the card presented to the PC/SC reader
+func ConnectPCSC(reader pcsc.Reader) (CardTransport, error) {
+	card, err := reader.ConnectCardPCSC()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to card: %w", err)
+	}
+	return &pcscTransport{card: card}, nil
+}
+
+// NewCard creates a new NfcCard communicating through transport and reads the tag UID
+func NewCard(transport CardTransport) (*NfcCard, error) {
+	m24lr := &NfcCard{
+		Reader:      transport,
+		log:         defaultLogger,
+		apduTimeout: DefaultAPDUTimeout,
+	}
+
+	err := m24lr.getUID()
+	if err != nil {
+		transport.DisconnectCard()
+		return nil, fmt.Errorf("failed to get UID: %w", err)
+	}
+	events.Publish(events.Event{Type: events.TagConnected, UID: m24lr.uid, OK: true})
+
+	return m24lr, nil
+}
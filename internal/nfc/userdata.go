@@ -0,0 +1,223 @@
+package nfc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Blocks 32-47, the spare end of the configuration, hold user data of integrators: short TLV records
+// (type, length, value), see PutUserData. The CRC covers them, so writing user data rewrites the CRC.
+// They are device specific (see IsDeviceSpecificBlock): configuration images leave them out and
+// programming an image keeps the records of the tag.
+const (
+	USERDATA_BLOCK_FIRST = 32
+	USERDATA_BLOCK_LAST  = ASSET_PLUS_CONFIG_BLOCKS - 1
+	USERDATA_SIZE        = (USERDATA_BLOCK_LAST - USERDATA_BLOCK_FIRST + 1) * 4
+)
+
+// Record types of the user data, types 0x00 and 0xFF end the records (blank memory)
+const (
+	USERDATA_ASSET_ID     byte = 0x01
+	USERDATA_CUSTOMER_REF byte = 0x02
+	userDataEnd           byte = 0x00
+	userDataBlank         byte = 0xFF
+)
+
+var userDataNames = map[byte]string{
+	USERDATA_ASSET_ID:     "asset-id",
+	USERDATA_CUSTOMER_REF: "customer-ref",
+}
+
+// UserRecord is a record of the user data area
+type UserRecord struct {
+	Type  byte
+	Value []byte
+}
+
+// Name returns the name of the record type, e.g. "asset-id", or its hex code for other types
+func (r UserRecord) Name() string {
+	return UserDataName(r.Type)
+}
+
+// UserDataName names a record type, e.g. "asset-id", types without a name as hex, e.g. "0x10"
+func UserDataName(recordType byte) string {
+	if name, ok := userDataNames[recordType]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02X", recordType)
+}
+
+// UserDataType parses a record type by name ("asset-id", "customer-ref") or number, e.g. "0x10" or "16"
+func UserDataType(name string) (byte, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for recordType, n := range userDataNames {
+		if n == name {
+			return recordType, nil
+		}
+	}
+	value, err := strconv.ParseUint(name, 0, 8)
+	if err != nil || byte(value) == userDataEnd || byte(value) == userDataBlank {
+		return 0, fmt.Errorf("invalid user data type %q, expected asset-id, customer-ref or a number 1-254", name)
+	}
+	return byte(value), nil
+}
+
+// DecodeUserData decodes the records of the user data area, in the order they are stored
+func DecodeUserData(area []byte) ([]UserRecord, error) {
+	var records []UserRecord
+	for i := 0; i < len(area); {
+		if area[i] == userDataEnd || area[i] == userDataBlank {
+			break
+		}
+		if i+2 > len(area) || i+2+int(area[i+1]) > len(area) {
+			return records, fmt.Errorf("user data record %s at byte %d runs past the end of the area: %w", UserDataName(area[i]), i, ErrInvalidLength)
+		}
+		length := int(area[i+1])
+		records = append(records, UserRecord{Type: area[i], Value: append([]byte(nil), area[i+2:i+2+length]...)})
+		i += 2 + length
+	}
+	return records, nil
+}
+
+// EncodeUserData encodes records into a user data area of USERDATA_SIZE bytes, the bytes after the
+// records are cleared
+func EncodeUserData(records []UserRecord) ([]byte, error) {
+	area := make([]byte, 0, USERDATA_SIZE)
+	for _, r := range records {
+		if r.Type == userDataEnd || r.Type == userDataBlank {
+			return nil, fmt.Errorf("invalid user data type 0x%02X", r.Type)
+		}
+		area = append(area, r.Type, byte(len(r.Value)))
+		area = append(area, r.Value...)
+	}
+	if len(area) > USERDATA_SIZE {
+		return nil, fmt.Errorf("%w: the records need %d bytes, the user data area (blocks %d-%d) has %d", ErrUserDataFull, len(area), USERDATA_BLOCK_FIRST, USERDATA_BLOCK_LAST, USERDATA_SIZE)
+	}
+	return append(area, make([]byte, USERDATA_SIZE-len(area))...), nil
+}
+
+// ReadUserData reads the records of the user data area
+func (m *NfcCard) ReadUserData() ([]UserRecord, error) {
+	area, err := m.ReadBlocks(USERDATA_BLOCK_FIRST, USERDATA_BLOCK_LAST-USERDATA_BLOCK_FIRST+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user data: %w", err)
+	}
+	return DecodeUserData(area)
+}
+
+// GetUserData returns the value of a record type, false if the tag has no such record
+func (m *NfcCard) GetUserData(recordType byte) ([]byte, bool, error) {
+	records, err := m.ReadUserData()
+	if err != nil {
+		return nil, false, err
+	}
+	for _, r := range records {
+		if r.Type == recordType {
+			return r.Value, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// PutUserData adds a record or replaces the record of the same type. Returns ErrUserDataFull if the
+// records do not fit in the area, the tag is then left unchanged.
+func (m *NfcCard) PutUserData(recordType byte, value []byte) error {
+	if len(value) > 255 {
+		return fmt.Errorf("user data %s has %d bytes, at most 255: %w", UserDataName(recordType), len(value), ErrInvalidLength)
+	}
+	records, err := m.ReadUserData()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range records {
+		if records[i].Type == recordType {
+			records[i].Value = value
+			replaced = true
+		}
+	}
+	if !replaced {
+		records = append(records, UserRecord{Type: recordType, Value: value})
+	}
+	return m.writeUserData(records)
+}
+
+// DeleteUserData removes the record of a type, false if the tag has no such record
+func (m *NfcCard) DeleteUserData(recordType byte) (bool, error) {
+	records, err := m.ReadUserData()
+	if err != nil {
+		return false, err
+	}
+	kept := records[:0]
+	for _, r := range records {
+		if r.Type != recordType {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(records) {
+		return false, nil
+	}
+	return true, m.writeUserData(kept)
+}
+
+// writeUserData writes the blocks of the user data area which change, reads them back to verify them
+// and updates the CRC
+func (m *NfcCard) writeUserData(records []UserRecord) error {
+	area, err := EncodeUserData(records)
+	if err != nil {
+		return err
+	}
+	blocks := USERDATA_BLOCK_LAST - USERDATA_BLOCK_FIRST + 1
+	current, err := m.ReadBlocks(USERDATA_BLOCK_FIRST, blocks)
+	if err != nil {
+		return fmt.Errorf("failed to read user data: %w", err)
+	}
+	changed := false
+	for i := 0; i < blocks; i++ {
+		if bytes.Equal(current[i*4:i*4+4], area[i*4:i*4+4]) {
+			continue
+		}
+		changed = true
+		_, err = m.WriteBlock(USERDATA_BLOCK_FIRST+i, strings.ToUpper(hex.EncodeToString(area[i*4:i*4+4])))
+		if err != nil {
+			return fmt.Errorf("failed to write user data block %d: %w", USERDATA_BLOCK_FIRST+i, err)
+		}
+	}
+	written, err := m.ReadBlocks(USERDATA_BLOCK_FIRST, blocks)
+	if err != nil {
+		return fmt.Errorf("failed to read back user data: %w", err)
+	}
+	if !bytes.Equal(written, area) {
+		return fmt.Errorf("%w: the user data read back differs from the data written", ErrVerifyFailed)
+	}
+	if !changed {
+		return nil
+	}
+	return m.CalculateAndWriteCRC()
+}
+
+// ASSET_ID_MAX is the length of a customer asset ID in characters, see WriteAssetID
+const ASSET_ID_MAX = 16
+
+// WriteAssetID stores the customer asset ID of the tag, up to ASSET_ID_MAX printable ASCII
+// characters, as the USERDATA_ASSET_ID record of the user data area
+func (m *NfcCard) WriteAssetID(id string) error {
+	if id == "" || len(id) > ASSET_ID_MAX {
+		return fmt.Errorf("asset ID %q has %d characters, expected 1 to %d: %w", id, len(id), ASSET_ID_MAX, ErrInvalidLength)
+	}
+	for _, c := range id {
+		if c < 0x20 || c > 0x7E {
+			return fmt.Errorf("asset ID %q holds %q, only printable ASCII characters are allowed", id, c)
+		}
+	}
+	return m.PutUserData(USERDATA_ASSET_ID, []byte(id))
+}
+
+// ReadAssetID reads the customer asset ID of the tag, empty if none was written
+func (m *NfcCard) ReadAssetID() (string, error) {
+	id, _, err := m.GetUserData(USERDATA_ASSET_ID)
+	return string(id), err
+}
@@ -0,0 +1,79 @@
+package nfc_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+func TestUserData(t *testing.T) {
+	tests := []struct {
+		name   string
+		put    map[byte]string
+		delete byte
+		want   []nfc.UserRecord
+		err    error
+	}{
+		{"asset ID", map[byte]string{nfc.USERDATA_ASSET_ID: "PAL-00172"}, 0,
+			[]nfc.UserRecord{{Type: nfc.USERDATA_ASSET_ID, Value: []byte("PAL-00172")}}, nil},
+		{"deleted", map[byte]string{nfc.USERDATA_ASSET_ID: "PAL-00172"}, nfc.USERDATA_ASSET_ID, nil, nil},
+		{"full", map[byte]string{nfc.USERDATA_CUSTOMER_REF: string(bytes.Repeat([]byte("x"), nfc.USERDATA_SIZE-1))}, 0,
+			nil, nfc.ErrUserDataFull},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tag, err := emulator.New(64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			card, err := nfc.NewCard(tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = card.CalculateAndWriteCRC()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for recordType, value := range test.put {
+				err = card.PutUserData(recordType, []byte(value))
+				if !errors.Is(err, test.err) {
+					t.Fatalf("PutUserData() = %v, want %v", err, test.err)
+				}
+			}
+			if test.delete != 0 {
+				deleted, err := card.DeleteUserData(test.delete)
+				if err != nil || !deleted {
+					t.Fatalf("DeleteUserData() = %v, %v", deleted, err)
+				}
+			}
+			records, err := card.ReadUserData()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(test.want) {
+				t.Fatalf("ReadUserData() = %v, want %v", records, test.want)
+			}
+			for i := range records {
+				if records[i].Type != test.want[i].Type || !bytes.Equal(records[i].Value, test.want[i].Value) {
+					t.Errorf("record %d = %v, want %v", i, records[i], test.want[i])
+				}
+			}
+			// the area is within the CRC range and left out of configuration images
+			err = card.ValidateCRC()
+			if err != nil {
+				t.Errorf("ValidateCRC() after writing user data = %v", err)
+			}
+			image, err := card.ReadConfigBin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			area := image[nfc.USERDATA_BLOCK_FIRST*4 : (nfc.USERDATA_BLOCK_LAST+1)*4]
+			if !bytes.Equal(area, bytes.Repeat([]byte{0xFF}, nfc.USERDATA_SIZE)) {
+				t.Errorf("configuration image holds user data % X", area)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package nfc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SettingsConflict reports Asset+ settings which are valid on their own but not together, Fix says
+// how to resolve it
+type SettingsConflict struct {
+	Fields  []string
+	Problem string
+	Fix     string
+}
+
+func (e *SettingsConflict) Error() string {
+	return fmt.Sprintf("%s: %s, %s", strings.Join(e.Fields, "/"), e.Problem, e.Fix)
+}
+
+// ValidateDittoSettings checks the settings for values which are inconsistent with each other and
+// returns a SettingsConflict for each
+func ValidateDittoSettings(s *DittoSettings) []error {
+	var conflicts []error
+	conflict := func(problem, fix string, fields ...string) {
+		conflicts = append(conflicts, &SettingsConflict{Fields: fields, Problem: problem, Fix: fix})
+	}
+
+	// Class B: the ping slot period (0 = 1 s is the unset value) is only used by Class B
+	switch s.ClassSelect {
+	case "Class B":
+	case "Unknown":
+		conflict("invalid class bits", "select Class A, B or C", "ClassSelect")
+	default:
+		if s.PingSlotPeriod != parsePingSlotPeriod(0) {
+			conflict(fmt.Sprintf("ping slot period %s set for %s", s.PingSlotPeriod, s.ClassSelect),
+				"select Class B or clear the ping slot period", "ClassSelect", "PingSlotPeriod")
+		}
+	}
+
+	// Data rate: ADR or a fixed spreading factor the region allows for 125 kHz uplinks
+	if s.SpreadingFactor != "ADR" {
+		sf, err := strconv.Atoi(s.SpreadingFactor)
+		maxSF := 12
+		if s.LoRaRegion == parseLoRaRegion(LORA_REGION_US915) {
+			// US915 uplinks are limited to SF10 by the 400 ms dwell time
+			maxSF = 10
+		}
+		if err != nil || sf < 7 || sf > maxSF {
+			conflict(fmt.Sprintf("fixed spreading factor %s not usable in %s", s.SpreadingFactor, s.LoRaRegion),
+				fmt.Sprintf("use SF7 to SF%d or ADR (255)", maxSF), "SpreadingFactor", "LoRaRegion")
+		}
+	}
+	if s.LoRaEnable == "Enabled" && s.LoRaRegion == parseLoRaRegion(-1) {
+		conflict("LoRa enabled without a region", "select a region (-cmd region)", "LoRaEnable", "LoRaRegion")
+	}
+
+	if s.GNSSMax < s.GNSSMin {
+		conflict(fmt.Sprintf("GNSS max %d below GNSS min %d", s.GNSSMax, s.GNSSMin), "raise GNSS max or lower GNSS min",
+			"GNSSMin", "GNSSMax")
+	}
+	if s.BLEGain == "Unknown" {
+		conflict("BLE TX power code not supported by the radio", "use -40, -20, -16, -12, -8, -4, 0, 3 or 4 dBm",
+			"BLEGain")
+	}
+	return conflicts
+}
+
+// ValidateConfigImage decodes the settings of a configuration image and checks them with
+// ValidateDittoSettings
+func ValidateConfigImage(image []byte) ([]error, error) {
+	settings, err := decodeImageSettings(image)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateDittoSettings(settings), nil
+}
+
+// decodeImageSettings decodes the Asset+ settings of a configuration image
+func decodeImageSettings(image []byte) (*DittoSettings, error) {
+	if len(image) < ASSET_PLUS_CONFIG_BLOCKS*4 {
+		return nil, fmt.Errorf("configuration image has %d bytes, expected %d: %w", len(image), CONFIG_BIN_SIZE, ErrInvalidLength)
+	}
+	blocks := make(map[int]string, len(dittoSettingsBlocks))
+	for _, block := range dittoSettingsBlocks {
+		blocks[block] = strings.ToUpper(hex.EncodeToString(image[block*4 : block*4+4]))
+	}
+	settings, _, err := DecodeDittoSettings(blocks, ParseLenient)
+	return settings, err
+}
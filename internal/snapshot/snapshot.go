@@ -0,0 +1,259 @@
+// Package snapshot serializes the complete state of a tag: its memory, the settings decoded from it
+// and where and when it was taken. Snapshots are written by backup, read by compare and can be
+// emulated with -emulate like JSON tag images. The file format is a versioned protobuf message,
+// see snapshot.proto.
+package snapshot
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+const (
+	// Version is the format version written by Save, Load refuses newer versions. Fields added to
+	// Snapshot (and snapshot.proto) with new field numbers, without changing the meaning of the
+	// existing ones, do not need a new version: readers skip fields they do not know and leave
+	// missing ones zero. Version 1 files (gob) are still read.
+	Version = 2
+	// BlockSize is the size of a block in bytes
+	BlockSize = 4
+)
+
+// magic starts every snapshot file, it tells snapshots from JSON images and raw dumps. It is
+// followed by the format version and a newline, except for version 1 which started with
+// "HIDNFC-SNAPSHOT\n".
+var magic = []byte("HIDNFC-SNAPSHOT")
+
+// Snapshot is the state of a tag at one point in time
+type Snapshot struct {
+	Version int
+	Time    time.Time
+	// Tool is the build of the tool which took the snapshot, Station the -station it ran on
+	Tool    buildinfo.Info
+	Station string
+	// Source is the reader or the emulator image the tag was read from
+	Source string
+	Note   string
+
+	UID   string
+	AFI   byte
+	DSFID byte
+	// Memory is the content of all blocks, BlockSize bytes each
+	Memory []byte
+	// Locked are the write protected blocks, if known (emulated tags)
+	Locked []int
+	// Config is the M24LR configuration byte, if known (emulated tags)
+	Config *byte
+
+	// Settings are the Asset+ settings decoded from Memory when the snapshot was taken, Warnings
+	// the fields which could not be decoded
+	Settings *nfc.DittoSettings
+	Warnings []string
+	CRCValid bool
+}
+
+// New creates a snapshot of the memory of a tag and decodes its settings
+func New(uid string, afi, dsfid byte, memory []byte) (*Snapshot, error) {
+	if len(memory)%BlockSize != 0 || len(memory)/BlockSize <= nfc.ASSET_PLUS_CRC_BLOCK {
+		return nil, fmt.Errorf("memory of %d bytes is not a tag image of more than %d blocks: %w", len(memory),
+			nfc.ASSET_PLUS_CRC_BLOCK, nfc.ErrInvalidLength)
+	}
+	s := &Snapshot{
+		Version: Version,
+		Time:    time.Now().UTC(),
+		Tool:    buildinfo.Version(),
+		UID:     strings.ToLower(uid),
+		AFI:     afi,
+		DSFID:   dsfid,
+		Memory:  memory,
+	}
+	s.Decode()
+	return s, nil
+}
+
+// Capture reads the complete memory and the system information of the tag
+func Capture(card *nfc.NfcCard) (*Snapshot, error) {
+	info, err := card.ReadSystemInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read system info: %w", err)
+	}
+	if info.BlockSize != BlockSize {
+		return nil, fmt.Errorf("block size %d: %w", info.BlockSize, nfc.ErrInvalidLength)
+	}
+	memory, err := card.ReadBlocks(0, info.Blocks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %d blocks: %w", info.Blocks, err)
+	}
+	var afi, dsfid byte
+	if info.AFI != nil {
+		afi = *info.AFI
+	}
+	if info.DSFID != nil {
+		dsfid = *info.DSFID
+	}
+	return New(info.UID, afi, dsfid, memory)
+}
+
+// Decode decodes Settings and checks the CRC from Memory
+func (s *Snapshot) Decode() {
+	blocks := make(map[int]string)
+	for block := 0; block < s.Blocks(); block++ {
+		blocks[block] = s.BlockHex(block)
+	}
+	s.Settings, s.Warnings = nil, nil
+	settings, warnings, err := nfc.DecodeDittoSettings(blocks, nfc.ParseLenient)
+	if err != nil {
+		s.Warnings = append(s.Warnings, err.Error())
+	} else {
+		s.Settings = settings
+	}
+	for _, warning := range warnings {
+		s.Warnings = append(s.Warnings, warning.Error())
+	}
+	crc := nfc.CalculateCRC(s.Memory[:nfc.ASSET_PLUS_CONFIG_BLOCKS*BlockSize])
+	crcBlock := s.Block(nfc.ASSET_PLUS_CRC_BLOCK)
+	s.CRCValid = uint16(crcBlock[0])|uint16(crcBlock[1])<<8 == crc
+}
+
+// Blocks returns the number of blocks
+func (s *Snapshot) Blocks() int {
+	return len(s.Memory) / BlockSize
+}
+
+// Block returns the content of a block
+func (s *Snapshot) Block(block int) []byte {
+	return s.Memory[block*BlockSize : (block+1)*BlockSize]
+}
+
+// BlockHex returns the content of a block in upper case hex, as read by nfc.NfcCard.ReadBlock
+func (s *Snapshot) BlockHex(block int) string {
+	return strings.ToUpper(hex.EncodeToString(s.Block(block)))
+}
+
+// IsSnapshot reports whether data starts like a snapshot file
+func IsSnapshot(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Save writes the snapshot to path
+func Save(path string, s *Snapshot) error {
+	s.Version = Version
+	data := fmt.Appendf(append([]byte(nil), magic...), " %d\n", Version)
+	data = append(data, marshal(s)...)
+	tmp := path + ".tmp"
+	err := os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads a snapshot written by Save
+func Load(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes the content of a snapshot file
+func Parse(data []byte) (*Snapshot, error) {
+	if !IsSnapshot(data) {
+		return nil, fmt.Errorf("not a snapshot file")
+	}
+	header, body, ok := bytes.Cut(data, []byte("\n"))
+	if !ok {
+		return nil, fmt.Errorf("snapshot without header line")
+	}
+	s := &Snapshot{}
+	if len(header) == len(magic) {
+		// version 1
+		err := gob.NewDecoder(bytes.NewReader(body)).Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+		}
+	} else {
+		version, err := strconv.Atoi(strings.TrimPrefix(string(header[len(magic):]), " "))
+		if err != nil || version <= 1 {
+			return nil, fmt.Errorf("invalid snapshot header %q", header)
+		}
+		if version > Version {
+			return nil, fmt.Errorf("snapshot version %d not supported, this tool reads versions 1 to %d", version, Version)
+		}
+		err = unmarshal(body, s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+		}
+		if s.Version != version {
+			return nil, fmt.Errorf("snapshot header version %d, message version %d", version, s.Version)
+		}
+	}
+	if s.Version < 1 || s.Version > Version {
+		return nil, fmt.Errorf("snapshot version %d not supported, this tool reads versions 1 to %d", s.Version, Version)
+	}
+	if len(s.Memory)%BlockSize != 0 || s.Blocks() <= nfc.ASSET_PLUS_CRC_BLOCK {
+		return nil, fmt.Errorf("snapshot memory of %d bytes: %w", len(s.Memory), nfc.ErrInvalidLength)
+	}
+	return s, nil
+}
+
+// Difference is a block which differs between two snapshots, Fields names the fields of the Asset+
+// block schema which changed (see nfc.ExplainBlock)
+type Difference struct {
+	Block  int
+	A, B   string
+	Fields []string
+}
+
+// Compare returns the blocks which differ between a and b, blocks beyond the end of the smaller
+// memory are compared with empty content
+func Compare(a, b *Snapshot) []Difference {
+	var differences []Difference
+	blocks := max(a.Blocks(), b.Blocks())
+	for block := 0; block < blocks; block++ {
+		var dataA, dataB string
+		if block < a.Blocks() {
+			dataA = a.BlockHex(block)
+		}
+		if block < b.Blocks() {
+			dataB = b.BlockHex(block)
+		}
+		if dataA == dataB {
+			continue
+		}
+		differences = append(differences, Difference{Block: block, A: dataA, B: dataB, Fields: changedFields(block, dataA, dataB)})
+	}
+	return differences
+}
+
+// changedFields compares the explained fields of the two versions of a block
+func changedFields(block int, a, b string) []string {
+	fieldsA, errA := nfc.ExplainBlock(block, a)
+	fieldsB, errB := nfc.ExplainBlock(block, b)
+	if errA != nil || errB != nil || len(fieldsA) != len(fieldsB) {
+		return nil
+	}
+	var changed []string
+	for i := range fieldsA {
+		if fieldsA[i].Raw != fieldsB[i].Raw || fieldsA[i].Meaning != fieldsB[i].Meaning {
+			changed = append(changed, fieldsA[i].Name)
+		}
+	}
+	if len(changed) == 0 {
+		// the difference is hidden by key redaction, report every field of the block
+		for _, field := range fieldsA {
+			changed = append(changed, field.Name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,106 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func testSnapshot(t *testing.T) *Snapshot {
+	t.Helper()
+	memory := bytes.Repeat([]byte{0x00}, 64*BlockSize)
+	copy(memory[15*BlockSize:], []byte{0x01, 0x0A, 0x09, 0x00})
+	s, err := New("E004010000000001", 0x07, 0x01, memory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := byte(0)
+	s.Time = time.Date(2024, 7, 1, 10, 0, 0, 123, time.UTC)
+	s.Tool = buildinfo.Info{Version: "1.4.0", GitCommit: "abc123", BuildTime: "2024-06-30"}
+	s.Station, s.Source, s.Note = "line-2", "HID Omnikey", "before rework"
+	s.Locked = []int{0, 15, 300}
+	s.Config = &config
+	return s
+}
+
+func TestSaveLoad(t *testing.T) {
+	s := testSnapshot(t)
+	path := filepath.Join(t.TempDir(), "tag.snap")
+	err := Save(path, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("HIDNFC-SNAPSHOT 2\n")) {
+		t.Errorf("header %q, want HIDNFC-SNAPSHOT 2", data[:20])
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, s) {
+		t.Errorf("Load() = %+v, want %+v", loaded, s)
+	}
+}
+
+func TestParse(t *testing.T) {
+	s := testSnapshot(t)
+	s.Version = Version
+	message := marshal(s)
+	// a field added by a later tool
+	unknown := protowire.AppendTag(append([]byte(nil), message...), 99, protowire.BytesType)
+	unknown = protowire.AppendBytes(unknown, []byte("new field"))
+
+	legacy := *s
+	legacy.Version = 1
+	var gobData bytes.Buffer
+	gobData.WriteString("HIDNFC-SNAPSHOT\n")
+	err := gob.NewEncoder(&gobData).Encode(&legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		version int
+		err     string
+	}{
+		{"version 2", append([]byte("HIDNFC-SNAPSHOT 2\n"), message...), 2, ""},
+		{"unknown field", append([]byte("HIDNFC-SNAPSHOT 2\n"), unknown...), 2, ""},
+		{"version 1 (gob)", gobData.Bytes(), 1, ""},
+		{"newer version", append([]byte("HIDNFC-SNAPSHOT 3\n"), message...), 0, "version 3 not supported"},
+		{"header and message differ", append([]byte("HIDNFC-SNAPSHOT 2\n"), marshal(&legacy)...), 0, "message version 1"},
+		{"truncated", append([]byte("HIDNFC-SNAPSHOT 2\n"), message[:len(message)-200]...), 0, "failed to decode"},
+		{"JSON image", []byte(`{"uid": "E004010000000001"}`), 0, "not a snapshot file"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed, err := Parse(test.data)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("Parse() = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Version != test.version || !bytes.Equal(parsed.Memory, s.Memory) || parsed.UID != s.UID ||
+				!reflect.DeepEqual(parsed.Settings, s.Settings) || !parsed.Time.Equal(s.Time) {
+				t.Errorf("Parse() = %+v, want %+v", parsed, s)
+			}
+		})
+	}
+}
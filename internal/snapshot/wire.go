@@ -0,0 +1,286 @@
+package snapshot
+
+import (
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the Snapshot message, see snapshot.proto
+const (
+	fieldVersion protowire.Number = iota + 1
+	fieldTime
+	fieldTool
+	fieldStation
+	fieldSource
+	fieldNote
+	fieldUID
+	fieldAFI
+	fieldDSFID
+	fieldMemory
+	fieldLocked
+	fieldConfig
+	fieldSettings
+	fieldWarnings
+	fieldCRCValid
+)
+
+// settingsFields are the fields of nfc.DittoSettings by their number in the Settings message (index
+// plus one), new fields are appended
+var settingsFields = []string{
+	"BeaconType", "HardwareVersion", "FirmwareVersion", "SleepState", "DebugOption", "MACOption",
+	"SpreadingFactor", "DownlinkBitRate", "UplinkBitRate", "HighTemperature", "LowTemperature",
+	"Accelerometer", "GNSSMin", "GNSSMax", "DOP", "OperationalMode", "LoRaEnable", "LoRaRegion", "ABR2",
+	"BLEGain", "MotionMoved", "MotionStationary", "MotionAccelActivity", "MotionAccelActivityThreshold",
+	"BLEAdvertisingInterval", "BLERefScanInterval", "BLERefRSSI", "BLERefFilter", "BLEAdvertisingType",
+	"PressUplink", "PingSlotPeriod", "Timeout", "BLERefMode", "ClassSelect", "ConfirmedUplinks", "Hopping",
+}
+
+// appendVarint appends a varint field, zero values are left out as in proto3
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// appendBytes appends a string or bytes field, empty values are left out as in proto3
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	return appendMessage(b, num, v)
+}
+
+// appendMessage appends a length delimited field, even if empty
+func appendMessage(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func boolVarint(v bool) uint64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// marshal encodes a snapshot as Snapshot message
+func marshal(s *Snapshot) []byte {
+	var b []byte
+	b = appendVarint(b, fieldVersion, uint64(s.Version))
+	if !s.Time.IsZero() {
+		// google.protobuf.Timestamp
+		var ts []byte
+		ts = appendVarint(ts, 1, uint64(s.Time.Unix()))
+		ts = appendVarint(ts, 2, uint64(s.Time.Nanosecond()))
+		b = appendMessage(b, fieldTime, ts)
+	}
+	var tool []byte
+	tool = appendBytes(tool, 1, []byte(s.Tool.Version))
+	tool = appendBytes(tool, 2, []byte(s.Tool.GitCommit))
+	tool = appendBytes(tool, 3, []byte(s.Tool.BuildTime))
+	b = appendBytes(b, fieldTool, tool)
+	b = appendBytes(b, fieldStation, []byte(s.Station))
+	b = appendBytes(b, fieldSource, []byte(s.Source))
+	b = appendBytes(b, fieldNote, []byte(s.Note))
+	b = appendBytes(b, fieldUID, []byte(s.UID))
+	b = appendVarint(b, fieldAFI, uint64(s.AFI))
+	b = appendVarint(b, fieldDSFID, uint64(s.DSFID))
+	b = appendBytes(b, fieldMemory, s.Memory)
+	var locked []byte
+	for _, block := range s.Locked {
+		locked = protowire.AppendVarint(locked, uint64(block))
+	}
+	b = appendBytes(b, fieldLocked, locked)
+	if s.Config != nil {
+		b = protowire.AppendTag(b, fieldConfig, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(*s.Config))
+	}
+	if s.Settings != nil {
+		b = appendMessage(b, fieldSettings, marshalSettings(s.Settings))
+	}
+	for _, warning := range s.Warnings {
+		b = appendMessage(b, fieldWarnings, []byte(warning))
+	}
+	return appendVarint(b, fieldCRCValid, boolVarint(s.CRCValid))
+}
+
+func marshalSettings(settings *nfc.DittoSettings) []byte {
+	var b []byte
+	v := reflect.ValueOf(settings).Elem()
+	for i, name := range settingsFields {
+		num := protowire.Number(i + 1)
+		field := v.FieldByName(name)
+		switch field.Kind() {
+		case reflect.Int, reflect.Int64:
+			b = appendVarint(b, num, protowire.EncodeZigZag(field.Int()))
+		case reflect.String:
+			b = appendBytes(b, num, []byte(field.String()))
+		case reflect.Float64:
+			if field.Float() != 0 {
+				b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+				b = protowire.AppendFixed64(b, math.Float64bits(field.Float()))
+			}
+		}
+	}
+	return b
+}
+
+// fieldValue is a field of a message, v the value of varint and fixed fields, data the content of
+// length delimited fields
+type fieldValue struct {
+	num  protowire.Number
+	typ  protowire.Type
+	v    uint64
+	data []byte
+}
+
+// consumeFields calls fn with every field of a message. Fields of an unexpected wire type are
+// unknown fields to fn and skipped like fields it does not know.
+func consumeFields(b []byte, fn func(f fieldValue) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		f := fieldValue{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.v, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.v = uint64(v)
+		case protowire.BytesType:
+			f.data, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		err := fn(f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshal decodes a Snapshot message
+func unmarshal(b []byte, s *Snapshot) error {
+	return consumeFields(b, func(f fieldValue) error {
+		switch {
+		case f.typ == protowire.VarintType:
+			switch f.num {
+			case fieldVersion:
+				s.Version = int(f.v)
+			case fieldAFI:
+				s.AFI = byte(f.v)
+			case fieldDSFID:
+				s.DSFID = byte(f.v)
+			case fieldLocked:
+				s.Locked = append(s.Locked, int(f.v))
+			case fieldConfig:
+				config := byte(f.v)
+				s.Config = &config
+			case fieldCRCValid:
+				s.CRCValid = f.v != 0
+			}
+		case f.typ == protowire.BytesType:
+			switch f.num {
+			case fieldTime:
+				return unmarshalTime(f.data, &s.Time)
+			case fieldTool:
+				return unmarshalTool(f.data, &s.Tool)
+			case fieldStation:
+				s.Station = string(f.data)
+			case fieldSource:
+				s.Source = string(f.data)
+			case fieldNote:
+				s.Note = string(f.data)
+			case fieldUID:
+				s.UID = string(f.data)
+			case fieldMemory:
+				s.Memory = append([]byte(nil), f.data...)
+			case fieldLocked:
+				// packed
+				for data := f.data; len(data) > 0; {
+					v, n := protowire.ConsumeVarint(data)
+					if n < 0 {
+						return protowire.ParseError(n)
+					}
+					s.Locked = append(s.Locked, int(v))
+					data = data[n:]
+				}
+			case fieldSettings:
+				s.Settings = &nfc.DittoSettings{}
+				return unmarshalSettings(f.data, s.Settings)
+			case fieldWarnings:
+				s.Warnings = append(s.Warnings, string(f.data))
+			}
+		}
+		return nil
+	})
+}
+
+func unmarshalTime(b []byte, t *time.Time) error {
+	var seconds, nanos uint64
+	err := consumeFields(b, func(f fieldValue) error {
+		if f.typ == protowire.VarintType && f.num == 1 {
+			seconds = f.v
+		}
+		if f.typ == protowire.VarintType && f.num == 2 {
+			nanos = f.v
+		}
+		return nil
+	})
+	*t = time.Unix(int64(seconds), int64(nanos)).UTC()
+	return err
+}
+
+func unmarshalTool(b []byte, tool *buildinfo.Info) error {
+	return consumeFields(b, func(f fieldValue) error {
+		if f.typ != protowire.BytesType {
+			return nil
+		}
+		switch f.num {
+		case 1:
+			tool.Version = string(f.data)
+		case 2:
+			tool.GitCommit = string(f.data)
+		case 3:
+			tool.BuildTime = string(f.data)
+		}
+		return nil
+	})
+}
+
+func unmarshalSettings(b []byte, settings *nfc.DittoSettings) error {
+	v := reflect.ValueOf(settings).Elem()
+	return consumeFields(b, func(f fieldValue) error {
+		if f.num < 1 || int(f.num) > len(settingsFields) {
+			return nil
+		}
+		field := v.FieldByName(settingsFields[f.num-1])
+		switch {
+		case (field.Kind() == reflect.Int || field.Kind() == reflect.Int64) && f.typ == protowire.VarintType:
+			field.SetInt(protowire.DecodeZigZag(f.v))
+		case field.Kind() == reflect.String && f.typ == protowire.BytesType:
+			field.SetString(string(f.data))
+		case field.Kind() == reflect.Float64 && f.typ == protowire.Fixed64Type:
+			field.SetFloat(math.Float64frombits(f.v))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// CertificateEntry is the digest of the birth certificate issued for a tag
+type CertificateEntry struct {
+	UID   string `json:"uid"`
+	Batch string `json:"batch,omitempty"`
+	// Digest is the hex SHA-256 of the certificate without its signature
+	Digest string    `json:"digest"`
+	Issued time.Time `json:"issued"`
+}
+
+// Certificates matches tags to their birth certificates. The tag memory has no block reserved for
+// the digest, so it is kept here: the certificates bucket holds the entries keyed by the lower case
+// UID.
+type Certificates struct {
+	db *DB
+}
+
+// Certificates returns the registry of birth certificate digests
+func (db *DB) Certificates() *Certificates {
+	return &Certificates{db: db}
+}
+
+// Record stores the certificate digest of a tag, replacing an earlier one of the same tag
+func (c *Certificates) Record(entry CertificateEntry) error {
+	entry.UID = strings.ToLower(entry.UID)
+	if entry.Issued.IsZero() {
+		entry.Issued = time.Now().UTC()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	err = c.db.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(certificatesBucket).Put([]byte(entry.UID), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record the certificate of tag %s: %w", entry.UID, err)
+	}
+	return nil
+}
+
+// Get returns the certificate digest of a tag, nil if none was recorded
+func (c *Certificates) Get(uid string) (*CertificateEntry, error) {
+	var entry *CertificateEntry
+	err := c.db.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(certificatesBucket).Get([]byte(strings.ToLower(uid)))
+		if data == nil {
+			return nil
+		}
+		entry = &CertificateEntry{}
+		err := json.Unmarshal(data, entry)
+		if err != nil {
+			return fmt.Errorf("certificate of tag %s: %v", uid, err)
+		}
+		return nil
+	})
+	return entry, err
+}
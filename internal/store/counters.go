@@ -0,0 +1,84 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Counters are named sequence counters, e.g. for the {{counter}} function of export templates, so
+// generated names and IDs stay unique across restarts and stations sharing the database. The
+// counters bucket holds them keyed by name, values as 8 byte big endian.
+type Counters struct {
+	db *DB
+}
+
+// Counters returns the named counters
+func (db *DB) Counters() *Counters {
+	return &Counters{db: db}
+}
+
+func checkCounterName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("counter without name")
+	}
+	return nil
+}
+
+func counterValue(data []byte) uint64 {
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+func encodeCounter(n uint64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, n)
+	return data
+}
+
+// Next increments the counter name and returns its new value, the first value of a counter is 1.
+// Values are never handed out twice, but a value whose use failed is skipped.
+func (c *Counters) Next(name string) (uint64, error) {
+	err := checkCounterName(name)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	err = c.db.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(countersBucket)
+		n = counterValue(b.Get([]byte(name))) + 1
+		return b.Put([]byte(name), encodeCounter(n))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("counter %q: %w", name, err)
+	}
+	return n, nil
+}
+
+// Set sets the counter name, the next value handed out is n+1
+func (c *Counters) Set(name string, n uint64) error {
+	err := checkCounterName(name)
+	if err != nil {
+		return err
+	}
+	return c.db.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(countersBucket).Put([]byte(name), encodeCounter(n))
+	})
+}
+
+// All returns the current value of every counter
+func (c *Counters) All() (map[string]uint64, error) {
+	counters := make(map[string]uint64)
+	err := c.db.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(countersBucket).ForEach(func(k, v []byte) error {
+			counters[string(k)] = counterValue(v)
+			return nil
+		})
+	})
+	return counters, err
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// byNameBucket, in the names bucket, holds the entries keyed by name
+	byNameBucket = []byte("by-name")
+	// byUIDBucket, in the names bucket, holds the current name of every tag keyed by the upper case UID
+	byUIDBucket = []byte("by-uid")
+)
+
+// NameEntry is a BLE local name and the tag it was written to
+type NameEntry struct {
+	Name    string    `json:"name"`
+	UID     string    `json:"uid"`
+	Station string    `json:"station,omitempty"`
+	Written time.Time `json:"written"`
+}
+
+// NameHistory is the history of the BLE local names written to tags, so a name already advertised
+// by another tag is caught before it is written again: duplicate names break the pairing flow of the
+// installer app
+type NameHistory struct {
+	db *DB
+}
+
+// Names returns the history of the BLE local names
+func (db *DB) Names() *NameHistory {
+	return &NameHistory{db: db}
+}
+
+// Owner returns the entry of a name written to a tag other than uid, nil if no other tag has it
+func (h *NameHistory) Owner(name string, uid string) (*NameEntry, error) {
+	var entry *NameEntry
+	err := h.db.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(namesBucket).Bucket(byNameBucket).Get([]byte(name))
+		if data == nil {
+			return nil
+		}
+		e := &NameEntry{}
+		err := json.Unmarshal(data, e)
+		if err != nil {
+			return fmt.Errorf("name history entry %q: %v", name, err)
+		}
+		if !strings.EqualFold(e.UID, uid) {
+			entry = e
+		}
+		return nil
+	})
+	return entry, err
+}
+
+// Name returns the name last written to the tag uid, empty if none was recorded
+func (h *NameHistory) Name(uid string) (string, error) {
+	var name string
+	err := h.db.view(func(tx *bolt.Tx) error {
+		name = string(tx.Bucket(namesBucket).Bucket(byUIDBucket).Get([]byte(strings.ToUpper(uid))))
+		return nil
+	})
+	return name, err
+}
+
+// Record records a name written to a tag. The previous name of the tag is released, it is no longer
+// advertised.
+func (h *NameHistory) Record(entry NameEntry) error {
+	entry.UID = strings.ToUpper(entry.UID)
+	if entry.Written.IsZero() {
+		entry.Written = time.Now().UTC()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	err = h.db.update(func(tx *bolt.Tx) error {
+		names, uids := tx.Bucket(namesBucket).Bucket(byNameBucket), tx.Bucket(namesBucket).Bucket(byUIDBucket)
+		// the previous name may have been written to another tag since (see Owner), it is kept then
+		if previous := uids.Get([]byte(entry.UID)); previous != nil && string(previous) != entry.Name {
+			var owner NameEntry
+			if data := names.Get(previous); data != nil && json.Unmarshal(data, &owner) == nil && owner.UID == entry.UID {
+				err := names.Delete(previous)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		err := names.Put([]byte(entry.Name), data)
+		if err != nil {
+			return err
+		}
+		return uids.Put([]byte(entry.UID), []byte(entry.Name))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record name %q of tag %s: %w", entry.Name, entry.UID, err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// QueueItem is a queued operation. Target names what the payload is sent to, e.g. a hook of a
+// manifest.
+type QueueItem struct {
+	ID        uint64          `json:"-"`
+	Target    string          `json:"target"`
+	Payload   json.RawMessage `json:"payload"`
+	Queued    time.Time       `json:"queued"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"lastError,omitempty"`
+}
+
+// Queue persists operations which failed because their target was unreachable, e.g. the
+// registration of a tag with a network server during a network outage, so they can be retried
+// later instead of being lost. Every queue is a bucket in the queue bucket holding the items keyed
+// by their big endian ID, so they are retried in queueing order.
+type Queue struct {
+	db   *DB
+	name []byte
+}
+
+// Queue returns the queue name, e.g. of the hooks of a manifest
+func (db *DB) Queue(name string) *Queue {
+	return &Queue{db: db, name: []byte(name)}
+}
+
+// bucket returns the bucket of the queue, nil if nothing was queued yet
+func (q *Queue) bucket(tx *bolt.Tx) *bolt.Bucket {
+	return tx.Bucket(queueBucket).Bucket(q.name)
+}
+
+// Push queues a payload for target after a first attempt failed with cause
+func (q *Queue) Push(target string, payload []byte, cause error) error {
+	item := QueueItem{Target: target, Payload: payload, Queued: time.Now().UTC(), Attempts: 1}
+	if cause != nil {
+		item.LastError = cause.Error()
+	}
+	return q.db.update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(queueBucket).CreateBucketIfNotExists(q.name)
+		if err != nil {
+			return err
+		}
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		item.ID = id
+		return putItem(b, item)
+	})
+}
+
+func putItem(b *bolt.Bucket, item QueueItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return b.Put(itemKey(item.ID), data)
+}
+
+func itemKey(id uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return k
+}
+
+// Items returns the queued items, oldest first
+func (q *Queue) Items() ([]QueueItem, error) {
+	var items []QueueItem
+	err := q.db.view(func(tx *bolt.Tx) error {
+		b := q.bucket(tx)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var item QueueItem
+			err := json.Unmarshal(v, &item)
+			if err != nil {
+				return fmt.Errorf("queue item %x: %v", k, err)
+			}
+			item.ID = binary.BigEndian.Uint64(k)
+			items = append(items, item)
+			return nil
+		})
+	})
+	return items, err
+}
+
+// Len returns the number of queued items
+func (q *Queue) Len() (int, error) {
+	n := 0
+	err := q.db.view(func(tx *bolt.Tx) error {
+		if b := q.bucket(tx); b != nil {
+			n = b.Stats().KeyN
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Retry sends the queued items, oldest first, with send. Items sent successfully are removed,
+// the others stay queued with their attempt counted. Returns the number of items sent and still
+// queued.
+func (q *Queue) Retry(send func(QueueItem) error) (sent int, remaining int, err error) {
+	items, err := q.Items()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, item := range items {
+		sendErr := send(item)
+		err = q.db.update(func(tx *bolt.Tx) error {
+			b := q.bucket(tx)
+			if sendErr == nil {
+				return b.Delete(itemKey(item.ID))
+			}
+			item.Attempts++
+			item.LastError = sendErr.Error()
+			return putItem(b, item)
+		})
+		if err != nil {
+			return sent, len(items) - sent, err
+		}
+		if sendErr == nil {
+			sent++
+		}
+	}
+	return sent, len(items) - sent, nil
+}
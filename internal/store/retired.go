@@ -0,0 +1,75 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// RetiredEntry is a retired DevEUI and the tag it was decommissioned from
+type RetiredEntry struct {
+	DevEUI   string    `json:"devEui"`
+	UID      string    `json:"uid"`
+	Reason   string    `json:"reason,omitempty"`
+	Operator string    `json:"operator,omitempty"`
+	Station  string    `json:"station,omitempty"`
+	Retired  time.Time `json:"retired"`
+	// Certificate is the hex SHA-256 of the decommission certificate
+	Certificate string `json:"certificate,omitempty"`
+}
+
+// Retired is the registry of the DevEUIs of decommissioned tags, so a retired DevEUI is never
+// programmed into another tag. The retired bucket holds the entries keyed by the upper case DevEUI
+// without separators.
+type Retired struct {
+	db *DB
+}
+
+// Retired returns the registry of retired DevEUIs
+func (db *DB) Retired() *Retired {
+	return &Retired{db: db}
+}
+
+func normalizeDevEUI(devEUI string) string {
+	return strings.ToUpper(strings.NewReplacer(":", "", "-", "").Replace(devEUI))
+}
+
+// Retire records a DevEUI as retired, replacing an earlier entry of the same DevEUI
+func (r *Retired) Retire(entry RetiredEntry) error {
+	entry.DevEUI = normalizeDevEUI(entry.DevEUI)
+	if entry.Retired.IsZero() {
+		entry.Retired = time.Now().UTC()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	err = r.db.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(retiredBucket).Put([]byte(entry.DevEUI), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to retire DevEUI %s: %w", entry.DevEUI, err)
+	}
+	return nil
+}
+
+// Get returns the entry of a retired DevEUI, nil if the DevEUI is not retired
+func (r *Retired) Get(devEUI string) (*RetiredEntry, error) {
+	var entry *RetiredEntry
+	err := r.db.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(retiredBucket).Get([]byte(normalizeDevEUI(devEUI)))
+		if data == nil {
+			return nil
+		}
+		entry = &RetiredEntry{}
+		err := json.Unmarshal(data, entry)
+		if err != nil {
+			return fmt.Errorf("retired DevEUI %s: %v", devEUI, err)
+		}
+		return nil
+	})
+	return entry, err
+}
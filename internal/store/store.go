@@ -0,0 +1,91 @@
+// Package store is the local bbolt database of a station, one bucket per feature: the EEPROM writes
+// per tag (WearTracker), the BLE local names written (NameHistory), named counters (Counters),
+// retired DevEUIs (Retired), birth certificate digests (Certificates) and queued hooks (Queue). The
+// database is only opened for the duration of a transaction, so several stations can share it on a
+// file system with working file locks; they take turns.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of the features
+var (
+	wearBucket         = []byte("wear")
+	namesBucket        = []byte("names")
+	countersBucket     = []byte("counters")
+	retiredBucket      = []byte("retired")
+	queueBucket        = []byte("queue")
+	certificatesBucket = []byte("certificates")
+)
+
+// lockTimeout is how long a transaction waits for another process using the database
+const lockTimeout = 10 * time.Second
+
+// DB is the database of a station
+type DB struct {
+	path string
+}
+
+// Open checks the database at path, creating it and the buckets of the features if needed
+func Open(path string) (*DB, error) {
+	db := &DB{path: path}
+	err := db.update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{wearBucket, namesBucket, countersBucket, retiredBucket, queueBucket, certificatesBucket} {
+			_, err := tx.CreateBucketIfNotExists(bucket)
+			if err != nil {
+				return err
+			}
+		}
+		names := tx.Bucket(namesBucket)
+		for _, bucket := range [][]byte{byNameBucket, byUIDBucket} {
+			_, err := names.CreateBucketIfNotExists(bucket)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Path returns the file of the database
+func (db *DB) Path() string {
+	return db.path
+}
+
+func (db *DB) open() (*bolt.DB, error) {
+	b, err := bolt.Open(db.path, 0600, &bolt.Options{Timeout: lockTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("database %s is locked by another process", db.path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", db.path, err)
+	}
+	return b, nil
+}
+
+func (db *DB) update(fn func(tx *bolt.Tx) error) error {
+	b, err := db.open()
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	return b.Update(fn)
+}
+
+func (db *DB) view(fn func(tx *bolt.Tx) error) error {
+	b, err := db.open()
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	return b.View(fn)
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
+)
+
+func TestSharedDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "station.db")
+	// two stations sharing the database
+	first, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for want := uint64(1); want <= 4; want++ {
+		db := first
+		if want%2 == 0 {
+			db = second
+		}
+		n, err := db.Counters().Next("trackers")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("Next() = %d, want %d", n, want)
+		}
+	}
+
+	err = first.Names().Record(NameEntry{Name: "Moni-0042", UID: "e004010000000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner, err := second.Names().Owner("Moni-0042", "E004010000000002")
+	if err != nil || owner == nil || owner.UID != "E004010000000001" {
+		t.Errorf("Owner() = %v, %v, want E004010000000001", owner, err)
+	}
+
+	err = first.Retired().Retire(RetiredEntry{DevEUI: "00:11:22:33:44:55:66:77", UID: "E004010000000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := second.Retired().Get("0011223344556677")
+	if err != nil || entry == nil {
+		t.Errorf("Get() = %v, %v, want the retired DevEUI", entry, err)
+	}
+
+	block := 5
+	tracker := first.Wear()
+	tracker.Add(events.Event{Type: events.BlockWritten, UID: "E004010000000001", Block: &block})
+	tracker.Add(events.Event{Type: events.TagDisconnected, UID: "E004010000000001"})
+	record, err := second.Wear().Get("e004010000000001")
+	if err != nil || record.Sessions != 1 || record.Blocks[5] != 1 {
+		t.Errorf("Get() = %+v, %v, want one session writing block 5", record, err)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "station.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, other := db.Queue("batch-2024-07"), db.Queue("batch-2024-08")
+	for _, target := range []string{"register", "notify"} {
+		err = batch.Push(target, []byte(`{}`), errors.New("connection refused"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := other.Len(); err != nil || n != 0 {
+		t.Errorf("Len() of another manifest = %d, %v, want 0", n, err)
+	}
+
+	sent, remaining, err := batch.Retry(func(item QueueItem) error {
+		if item.Target == "notify" {
+			return errors.New("HTTP 503")
+		}
+		return nil
+	})
+	if err != nil || sent != 1 || remaining != 1 {
+		t.Fatalf("Retry() = %d, %d, %v, want 1 sent and 1 remaining", sent, remaining, err)
+	}
+	items, err := batch.Items()
+	if err != nil || len(items) != 1 {
+		t.Fatalf("Items() = %v, %v, want 1 item", items, err)
+	}
+	if items[0].Target != "notify" || items[0].Attempts != 2 || items[0].LastError != "HTTP 503" {
+		t.Errorf("item = %+v, want notify after 2 attempts", items[0])
+	}
+}
+
+func TestCertificates(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "station.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificates := db.Certificates()
+	entry, err := certificates.Get("E004010000000001")
+	if err != nil || entry != nil {
+		t.Fatalf("Get() of an unknown tag = %v, %v", entry, err)
+	}
+	err = certificates.Record(CertificateEntry{UID: "E004010000000001", Batch: "B1", Digest: "00ff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err = certificates.Get("e004010000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil || entry.Digest != "00ff" || entry.Batch != "B1" || entry.Issued.IsZero() {
+		t.Errorf("Get() = %+v, want the recorded digest", entry)
+	}
+}
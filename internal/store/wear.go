@@ -0,0 +1,169 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
+	bolt "go.etcd.io/bbolt"
+)
+
+// WearRecord is the write history of a tag, stored in the wear bucket keyed by the upper case UID
+type WearRecord struct {
+	UID string `json:"uid"`
+	// Sessions is the number of times the tag was written: connections with at least one block write
+	Sessions int `json:"sessions"`
+	// Writes is the number of block writes, Blocks the writes per block
+	Writes int         `json:"writes"`
+	Blocks map[int]int `json:"blocks"`
+	First  time.Time   `json:"first"`
+	Last   time.Time   `json:"last"`
+}
+
+// MostWritten returns the block written most often and its writes, -1 if no block was written
+func (r *WearRecord) MostWritten() (block int, writes int) {
+	block = -1
+	for b, n := range r.Blocks {
+		if n > writes || n == writes && b < block {
+			block, writes = b, n
+		}
+	}
+	return block, writes
+}
+
+// WearTracker counts the EEPROM writes per tag UID, so tags reprogrammed an unusual number of times
+// (rework loops) can be spotted before their worn blocks fail in the field. It records the
+// BlockWritten events of the tags: the writes of a tag are kept in memory and stored as one session
+// when the tag is disconnected or completed, or when the tracker is flushed.
+type WearTracker struct {
+	db *DB
+	// WarnAfter is the number of sessions from which Warn is called after every session of a tag,
+	// 0 never warns
+	WarnAfter int
+	// Warn is called with the record of a tag reprogrammed WarnAfter times or more
+	Warn func(WearRecord)
+	// Failed is called when the writes of a tag recorded by Add cannot be stored
+	Failed func(error)
+
+	mu      sync.Mutex
+	pending map[string]map[int]int
+}
+
+// Wear returns a tracker of the writes per tag
+func (db *DB) Wear() *WearTracker {
+	return &WearTracker{db: db, pending: make(map[string]map[int]int)}
+}
+
+// Add records an event, subscribe it to the events of the tags
+func (t *WearTracker) Add(event events.Event) {
+	if event.UID == "" {
+		return
+	}
+	uid := strings.ToUpper(event.UID)
+	switch event.Type {
+	case events.BlockWritten:
+		if event.Block == nil {
+			return
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.pending[uid] == nil {
+			t.pending[uid] = make(map[int]int)
+		}
+		t.pending[uid][*event.Block]++
+	case events.TagDisconnected, events.ProvisionCompleted:
+		err := t.flush(uid)
+		if err != nil && t.Failed != nil {
+			t.Failed(err)
+		}
+	}
+}
+
+// Flush stores the pending writes of every tag
+func (t *WearTracker) Flush() error {
+	t.mu.Lock()
+	uids := make([]string, 0, len(t.pending))
+	for uid := range t.pending {
+		uids = append(uids, uid)
+	}
+	t.mu.Unlock()
+	var errs []error
+	for _, uid := range uids {
+		errs = append(errs, t.flush(uid))
+	}
+	return errors.Join(errs...)
+}
+
+// flush stores the pending writes of a tag as a session
+func (t *WearTracker) flush(uid string) error {
+	t.mu.Lock()
+	blocks := t.pending[uid]
+	delete(t.pending, uid)
+	t.mu.Unlock()
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	var record WearRecord
+	err := t.db.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(wearBucket)
+		r, err := getWear(b, uid)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		if r.First.IsZero() {
+			r.First = now
+		}
+		r.Last = now
+		r.Sessions++
+		for block, n := range blocks {
+			r.Blocks[block] += n
+			r.Writes += n
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		record = *r
+		return b.Put([]byte(uid), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record the writes of tag %s: %w", uid, err)
+	}
+	if t.WarnAfter > 0 && record.Sessions >= t.WarnAfter && t.Warn != nil {
+		t.Warn(record)
+	}
+	return nil
+}
+
+func getWear(b *bolt.Bucket, uid string) (*WearRecord, error) {
+	r := &WearRecord{UID: uid}
+	data := b.Get([]byte(uid))
+	if data != nil {
+		err := json.Unmarshal(data, r)
+		if err != nil {
+			return nil, fmt.Errorf("wear record %s: %v", uid, err)
+		}
+	}
+	if r.Blocks == nil {
+		r.Blocks = make(map[int]int)
+	}
+	return r, nil
+}
+
+// Get returns the stored record of a tag, without its pending writes. Tags never written have a
+// record without sessions.
+func (t *WearTracker) Get(uid string) (*WearRecord, error) {
+	var record *WearRecord
+	err := t.db.view(func(tx *bolt.Tx) error {
+		var err error
+		record, err = getWear(tx.Bucket(wearBucket), strings.ToUpper(uid))
+		return err
+	})
+	return record, err
+}
@@ -0,0 +1,123 @@
+package transport
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/auth"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// Failures injected by FailureInjector
+const (
+	// FailureCRC answers reads with status 6281 (returned data corrupted), as readers do when the
+	// RF frame of the tag fails its CRC
+	FailureCRC = "crc"
+	// FailureWrite answers writes with status 6581 (memory failure) without writing the tag
+	FailureWrite = "write"
+	// FailureTimeout leaves an APDU unanswered until the timeout of the injector
+	FailureTimeout = "timeout"
+)
+
+// Failures maps a failure (see FailureCRC) to the rate of APDUs it is injected into, 0 to 1
+type Failures map[string]float64
+
+// ParseFailures parses a comma separated list of failure:rate, e.g. "crc:0.05,timeout:0.01"
+func ParseFailures(spec string) (Failures, error) {
+	failures := Failures{}
+	for _, item := range strings.Split(spec, ",") {
+		kind, value, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid failure %q, expected <failure>:<rate>", item)
+		}
+		switch kind {
+		case FailureCRC, FailureWrite, FailureTimeout:
+		default:
+			return nil, fmt.Errorf("unknown failure %q, expected %s, %s or %s", kind, FailureCRC, FailureWrite, FailureTimeout)
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate <= 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid rate %q of failure %s, expected a number above 0 up to 1", value, kind)
+		}
+		failures[kind] = rate
+	}
+	return failures, nil
+}
+
+// String returns the failures in the syntax of ParseFailures
+func (f Failures) String() string {
+	items := make([]string, 0, len(f))
+	for kind, rate := range f {
+		items = append(items, kind+":"+strconv.FormatFloat(rate, 'g', -1, 64))
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// FailureInjector passes APDUs to the wrapped transport and fails some of them, so the error handling
+// of the tool and of integrations can be exercised with the emulator. Reads and writes are told
+// apart as by the proxy permissions. Every injected failure is published as events.FailureInjected.
+type FailureInjector struct {
+	nfc.CardTransport
+	failures Failures
+	delay    time.Duration
+	mu       sync.Mutex
+	rand     *rand.Rand
+}
+
+// NewFailureInjector injects failures into the exchanges of transport. A timeout blocks the APDU
+// for delay before it fails with nfc.ErrAPDUTimeout; use a delay above the APDU timeout so the
+// card detects the timeout itself.
+func NewFailureInjector(transport nfc.CardTransport, failures Failures, delay time.Duration) *FailureInjector {
+	return &FailureInjector{
+		CardTransport: transport,
+		failures:      failures,
+		delay:         delay,
+		rand:          rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (f *FailureInjector) Apdu(cmd []byte) ([]byte, error) {
+	failure := f.failure(cmd)
+	if failure == "" {
+		return f.CardTransport.Apdu(cmd)
+	}
+	name := "APDU"
+	if len(cmd) >= 2 {
+		name = fmt.Sprintf("APDU %02X %02X", cmd[0], cmd[1])
+	}
+	events.Publish(events.Event{Type: events.FailureInjected, Failure: failure, Error: "injected " + failure + " failure of " + name})
+
+	switch failure {
+	case FailureCRC:
+		return []byte{0x62, 0x81}, nil
+	case FailureWrite:
+		return []byte{0x65, 0x81}, nil
+	default:
+		time.Sleep(f.delay)
+		return nil, nfc.ErrAPDUTimeout
+	}
+}
+
+// failure picks the failure to inject into cmd, empty for none
+func (f *FailureInjector) failure(cmd []byte) string {
+	kind := FailureCRC
+	if apduPermission(cmd) != auth.PermissionRead {
+		kind = FailureWrite
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if rate := f.failures[FailureTimeout]; rate > 0 && f.rand.Float64() < rate {
+		return FailureTimeout
+	}
+	if rate := f.failures[kind]; rate > 0 && f.rand.Float64() < rate {
+		return kind
+	}
+	return ""
+}
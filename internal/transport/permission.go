@@ -0,0 +1,230 @@
+package transport
+
+import (
+	"github.com/jenish-rudani/HID_NFC_READER/internal/auth"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// ISO 15693 commands relayed by the transparent exchange which only read the tag, write or lock it.
+// Commands in none of the lists need auth.PermissionErase.
+var (
+	iso15693Reads = map[byte]bool{
+		nfc.ISO15693_CMD_READ_SINGLE:     true,
+		iso15693ReadMultiple:             true,
+		0x25:                             true, // select
+		0x26:                             true, // reset to ready
+		nfc.ISO15693_CMD_GET_SYSTEM_INFO: true,
+		0x2C:                             true, // get multiple block security status
+		nfc.M24LR_CMD_READ_CFG:           true,
+		nfc.M24LR_CMD_CHECK_EH_EN:        true,
+	}
+	iso15693Writes = map[byte]bool{
+		nfc.ISO15693_CMD_WRITE_SINGLE: true,
+		iso15693WriteMultiple:         true,
+		nfc.ISO15693_CMD_WRITE_AFI:    true,
+		nfc.ISO15693_CMD_WRITE_DSFID:  true,
+		nfc.M24LR_CMD_WRITE_EH_CFG:    true,
+		nfc.M24LR_CMD_SET_RST_EH_EN:   true,
+		nfc.M24LR_CMD_WRITE_DO_CFG:    true,
+	}
+	iso15693Locks = map[byte]bool{
+		0x22: true, // lock block
+		0x28: true, // lock AFI
+		0x2A: true, // lock DSFID
+		0xB1: true, // M24LR write password
+		0xB2: true, // M24LR lock sector
+	}
+)
+
+// the read and write multiple blocks commands
+const (
+	iso15693ReadMultiple  = 0x23
+	iso15693WriteMultiple = 0x24
+)
+
+// minBlockSize is the smallest block size of the tags, the block range of a read binary is derived
+// from its length with it so no key block is missed
+const minBlockSize = 4
+
+// apduPermission returns the permission (see auth.PermissionRead) a client needs to send an APDU.
+// Reads need auth.PermissionRead, except reads of key blocks (see nfc.IsSecretBlock) which need
+// auth.PermissionProgram. Writes need auth.PermissionProgram, while erasing blocks (writing them all
+// 0xFF), clearing key blocks (all 0x00) and locks need auth.PermissionErase. APDUs which are not
+// addressed to the reader and ISO 15693 commands which can not be classified need
+// auth.PermissionErase, other reader commands auth.PermissionProgram.
+func apduPermission(cmd []byte) string {
+	if len(cmd) < 4 || cmd[0] != 0xFF {
+		return auth.PermissionErase
+	}
+	data := []byte{}
+	if len(cmd) > 5 {
+		data = cmd[5:]
+	}
+	switch cmd[1] {
+	case 0xCA, 0x30: // get data, get system info
+		return auth.PermissionRead
+	case 0xB0: // read binary
+		return readPermission(apduBlocks(cmd))
+	case 0xD6: // update binary
+		block, _ := apduBlocks(cmd)
+		return writePermission(block, data)
+	case 0xC2:
+		if cmd[3] == 0x00 {
+			// manage session
+			return auth.PermissionRead
+		}
+		return transparentPermission(cmd)
+	default:
+		// other reader commands, e.g. reader settings
+		return auth.PermissionProgram
+	}
+}
+
+// iso15693Request is the ISO 15693 frame of a transparent exchange, its only data object
+type iso15693Request struct {
+	flags   byte
+	command byte
+	// block is the block number, -1 if the frame is too short to hold one
+	block int
+	// params follow the block number
+	params []byte
+}
+
+// parseTransparent returns the ISO 15693 request of a transparent exchange APDU
+func parseTransparent(cmd []byte) (*iso15693Request, bool) {
+	if len(cmd) < 7 || cmd[5] != 0x95 {
+		return nil, false
+	}
+	data := cmd[5:]
+	frame := data[2:]
+	if data[1] == 0x81 && len(data) >= 3 {
+		frame = data[3:]
+	}
+	if len(frame) < 2 {
+		return nil, false
+	}
+	request := &iso15693Request{flags: frame[0], command: frame[1], block: -1}
+	// block commands: [UID] block number (2 bytes with the protocol extension), then their data
+	params := frame[2:]
+	if request.flags&nfc.ISO15693_FLAG_ADDRESSED != 0 && len(params) >= 8 {
+		params = params[8:]
+	}
+	size := 1
+	if request.flags&nfc.ISO15693_FLAG_EXTENSION != 0 {
+		size = 2
+	}
+	if len(params) >= size {
+		request.block = int(params[0])
+		if size == 2 {
+			request.block |= int(params[1]) << 8
+		}
+		params = params[size:]
+	}
+	request.params = params
+	return request, true
+}
+
+// apduBlocks returns the first block and the number of blocks an APDU reads or writes: read and
+// update binary, ISO 15693 read and write of single and multiple blocks. The count is 0 for other
+// APDUs.
+func apduBlocks(cmd []byte) (int, int) {
+	if len(cmd) < 4 || cmd[0] != 0xFF {
+		return 0, 0
+	}
+	block := int(cmd[2])<<8 | int(cmd[3])
+	switch cmd[1] {
+	case 0xB0: // the length is the number of bytes, 0 for 256
+		length := 256
+		if len(cmd) > 4 && cmd[4] != 0 {
+			length = int(cmd[4])
+		}
+		return block, (length + minBlockSize - 1) / minBlockSize
+	case 0xD6:
+		if len(cmd) <= 5 {
+			return block, 0
+		}
+		return block, (len(cmd) - 5 + minBlockSize - 1) / minBlockSize
+	case 0xC2:
+		request, ok := parseTransparent(cmd)
+		if !ok || request.block < 0 || request.flags&nfc.ISO15693_FLAG_INVENTORY != 0 {
+			return 0, 0
+		}
+		switch request.command {
+		case nfc.ISO15693_CMD_READ_SINGLE, nfc.ISO15693_CMD_WRITE_SINGLE:
+			return request.block, 1
+		case iso15693ReadMultiple, iso15693WriteMultiple:
+			if len(request.params) < 1 {
+				return request.block, 1
+			}
+			return request.block, int(request.params[0]) + 1
+		}
+	}
+	return 0, 0
+}
+
+// transparentPermission classifies the ISO 15693 frame of a transparent exchange APDU
+func transparentPermission(cmd []byte) string {
+	request, ok := parseTransparent(cmd)
+	if !ok {
+		return auth.PermissionErase
+	}
+	if request.flags&nfc.ISO15693_FLAG_INVENTORY != 0 {
+		return auth.PermissionRead
+	}
+	switch {
+	case iso15693Locks[request.command]:
+		return auth.PermissionErase
+	case request.command == nfc.ISO15693_CMD_READ_SINGLE || request.command == iso15693ReadMultiple:
+		if request.block < 0 || (request.command == iso15693ReadMultiple && len(request.params) < 1) {
+			return auth.PermissionErase
+		}
+		return readPermission(apduBlocks(cmd))
+	case request.command == nfc.ISO15693_CMD_WRITE_SINGLE:
+		if request.block < 0 || len(request.params) == 0 {
+			return auth.PermissionErase
+		}
+		return writePermission(request.block, request.params)
+	case iso15693Writes[request.command]:
+		return auth.PermissionProgram
+	case iso15693Reads[request.command]:
+		return auth.PermissionRead
+	default:
+		return auth.PermissionErase
+	}
+}
+
+// readPermission returns the permission needed to read count blocks from block, key blocks need
+// auth.PermissionProgram
+func readPermission(block int, count int) string {
+	for b := block; b < block+count; b++ {
+		if nfc.IsSecretBlock(b) {
+			return auth.PermissionProgram
+		}
+	}
+	return auth.PermissionRead
+}
+
+// writePermission returns the permission needed to write data from block: erasing needs
+// auth.PermissionErase, as does clearing a key block (all 0x00)
+func writePermission(block int, data []byte) string {
+	if len(data) == 0 {
+		return auth.PermissionProgram
+	}
+	if allBytes(data, 0xFF) {
+		return auth.PermissionErase
+	}
+	if allBytes(data, 0x00) && readPermission(block, (len(data)+minBlockSize-1)/minBlockSize) != auth.PermissionRead {
+		// the range holds a key block
+		return auth.PermissionErase
+	}
+	return auth.PermissionProgram
+}
+
+func allBytes(data []byte, value byte) bool {
+	for _, b := range data {
+		if b != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/auth"
+)
+
+// uid is the address of the transparent frames, least significant byte first
+var uid = []byte{0xF0, 0x30, 0x86, 0xAF, 0xC1, 0x35, 0x02, 0xE0}
+
+// transparent wraps an ISO 15693 frame addressed to uid into a transparent exchange APDU
+func transparent(flags byte, command byte, params ...byte) []byte {
+	frame := append([]byte{flags | 0x20, command}, uid...)
+	frame = append(frame, params...)
+	data := append([]byte{0x95, byte(len(frame))}, frame...)
+	return append([]byte{0xFF, 0xC2, 0x00, 0x01, byte(len(data))}, data...)
+}
+
+func TestAPDUPermission(t *testing.T) {
+	tests := []struct {
+		name string
+		apdu []byte
+		want string
+	}{
+		{"get UID", []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}, auth.PermissionRead},
+		{"read block 0", []byte{0xFF, 0xB0, 0x00, 0x00, 0x04}, auth.PermissionRead},
+		{"read key block 3", []byte{0xFF, 0xB0, 0x00, 0x03, 0x04}, auth.PermissionProgram},
+		{"read blocks 0-3", []byte{0xFF, 0xB0, 0x00, 0x00, 0x10}, auth.PermissionProgram},
+		{"read 256 bytes from 0", []byte{0xFF, 0xB0, 0x00, 0x00, 0x00}, auth.PermissionProgram},
+		{"read block 44", []byte{0xFF, 0xB0, 0x00, 0x2C, 0x04}, auth.PermissionRead},
+		{"write block 7", []byte{0xFF, 0xD6, 0x00, 0x07, 0x04, 0x01, 0x02, 0x03, 0x04}, auth.PermissionProgram},
+		{"erase block 7", []byte{0xFF, 0xD6, 0x00, 0x07, 0x04, 0xFF, 0xFF, 0xFF, 0xFF}, auth.PermissionErase},
+		{"zero block 7", []byte{0xFF, 0xD6, 0x00, 0x07, 0x04, 0x00, 0x00, 0x00, 0x00}, auth.PermissionProgram},
+		{"write key block 3", []byte{0xFF, 0xD6, 0x00, 0x03, 0x04, 0x01, 0x02, 0x03, 0x04}, auth.PermissionProgram},
+		{"zero key block 3", []byte{0xFF, 0xD6, 0x00, 0x03, 0x04, 0x00, 0x00, 0x00, 0x00}, auth.PermissionErase},
+		{"zero key block 6", []byte{0xFF, 0xD6, 0x00, 0x06, 0x04, 0x00, 0x00, 0x00, 0x00}, auth.PermissionErase},
+		{"start session", []byte{0xFF, 0xC2, 0x00, 0x00, 0x02, 0x81, 0x00}, auth.PermissionRead},
+		{"reader setting", []byte{0xFF, 0x00, 0x52, 0x00, 0x00}, auth.PermissionProgram},
+		{"not for the reader", []byte{0x00, 0xA4, 0x04, 0x00, 0x00}, auth.PermissionErase},
+		{"short", []byte{0xFF}, auth.PermissionErase},
+		{"inventory", []byte{0xFF, 0xC2, 0x00, 0x01, 0x05, 0x95, 0x03, 0x26, 0x01, 0x00}, auth.PermissionRead},
+		{"read single block 0", transparent(0x02, 0x20, 0x00), auth.PermissionRead},
+		{"read single key block 4", transparent(0x02, 0x20, 0x04), auth.PermissionProgram},
+		{"read single extended block 300", transparent(0x0A, 0x20, 0x2C, 0x01), auth.PermissionRead},
+		{"read multiple blocks 0-3", transparent(0x02, 0x23, 0x00, 0x03), auth.PermissionProgram},
+		{"read multiple blocks 7-9", transparent(0x02, 0x23, 0x07, 0x02), auth.PermissionRead},
+		{"security status", transparent(0x42, 0x20, 0x00), auth.PermissionRead},
+		{"system info", transparent(0x02, 0x2B), auth.PermissionRead},
+		{"write single block 7", transparent(0x02, 0x21, 0x07, 0x01, 0x02, 0x03, 0x04), auth.PermissionProgram},
+		{"erase single block 7", transparent(0x02, 0x21, 0x07, 0xFF, 0xFF, 0xFF, 0xFF), auth.PermissionErase},
+		{"zero single key block 5", transparent(0x02, 0x21, 0x05, 0x00, 0x00, 0x00, 0x00), auth.PermissionErase},
+		{"write AFI", transparent(0x02, 0x27, 0x01), auth.PermissionProgram},
+		{"lock block", transparent(0x02, 0x22, 0x07), auth.PermissionErase},
+		{"lock sector", transparent(0x02, 0xB2, 0x02, 0x00), auth.PermissionErase},
+		{"unknown command", transparent(0x02, 0xC0), auth.PermissionErase},
+		{"not a frame", []byte{0xFF, 0xC2, 0x00, 0x01, 0x02, 0x90, 0x00}, auth.PermissionErase},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := apduPermission(test.apdu); got != test.want {
+				t.Errorf("apduPermission(% X) = %s, want %s", test.apdu, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package transport
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// Recorder passes every APDU to the wrapped transport and writes the exchange as a trace
+// which can be replayed with LoadTrace. In CM mode (see nfc.SetCMMode) the exchanges reading or
+// writing a block CM mode hides (see nfc.IsHiddenBlock) are left out, a comment takes their place.
+type Recorder struct {
+	nfc.CardTransport
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewRecorder records the exchanges of transport to w
+func NewRecorder(transport nfc.CardTransport, w io.Writer) *Recorder {
+	return &Recorder{CardTransport: transport, w: w}
+}
+
+func (r *Recorder) Apdu(cmd []byte) ([]byte, error) {
+	resp, err := r.CardTransport.Apdu(cmd)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if block, hidden := hiddenBlock(cmd); hidden {
+		fmt.Fprintf(r.w, "# exchange on block %d not recorded in CM mode\n", block)
+		return resp, err
+	}
+	if err != nil {
+		fmt.Fprintf(r.w, "# > %X failed: %v\n", cmd, err)
+		return resp, err
+	}
+	fmt.Fprintf(r.w, "> %X\n< %X\n", cmd, resp)
+	return resp, nil
+}
+
+// hiddenBlock returns the first block hidden in CM mode an APDU reads or writes, if any
+func hiddenBlock(cmd []byte) (int, bool) {
+	if !nfc.CMMode() {
+		return 0, false
+	}
+	first, count := apduBlocks(cmd)
+	for block := first; block < first+count; block++ {
+		if nfc.IsHiddenBlock(block) {
+			return block, true
+		}
+	}
+	return 0, false
+}
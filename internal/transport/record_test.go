@@ -0,0 +1,48 @@
+package transport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+func TestRecorderCMMode(t *testing.T) {
+	tag, err := emulator.New(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apdus := []struct {
+		name string
+		apdu []byte
+		// recorded reports whether the exchange is in the trace in CM mode
+		recorded bool
+	}{
+		{"get UID", []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}, true},
+		{"read block 10", []byte{0xFF, 0xB0, 0x00, 0x0A, 0x04}, true},
+		{"read JoinEUI block 0", []byte{0xFF, 0xB0, 0x00, 0x00, 0x04}, false},
+		{"read key block 4", []byte{0xFF, 0xB0, 0x00, 0x04, 0x04}, false},
+		{"read blocks 8-11", []byte{0xFF, 0xB0, 0x00, 0x08, 0x10}, true},
+		{"read blocks 5-8", []byte{0xFF, 0xB0, 0x00, 0x05, 0x10}, false},
+		{"write key block 6", []byte{0xFF, 0xD6, 0x00, 0x06, 0x04, 0x01, 0x02, 0x03, 0x04}, false},
+	}
+	for _, cm := range []bool{false, true} {
+		nfc.SetCMMode(cm)
+		for _, test := range apdus {
+			var trace bytes.Buffer
+			recorder := NewRecorder(tag, &trace)
+			_, err := recorder.Apdu(test.apdu)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			recorded := strings.HasPrefix(trace.String(), ">")
+			if want := test.recorded || !cm; recorded != want {
+				t.Errorf("CM mode %v, %s: recorded %v, want %v: %q", cm, test.name, recorded, want, trace.String())
+			}
+		}
+	}
+	nfc.SetCMMode(false)
+	nfc.SetRedactKeys(false)
+}
@@ -0,0 +1,185 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Exchange is one recorded command/response pair
+type Exchange struct {
+	Command  []byte
+	Response []byte
+}
+
+// Replay serves the responses of a recorded APDU trace in order. Every command must match
+// the recorded one, so a replay also verifies that the tool still issues the same APDUs.
+type Replay struct {
+	exchanges []Exchange
+	next      int
+}
+
+// NewReplay creates a replay backend for the exchanges
+func NewReplay(exchanges []Exchange) *Replay {
+	return &Replay{exchanges: exchanges}
+}
+
+// LoadTrace reads a trace file as written by Recorder
+func LoadTrace(path string) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	exchanges, err := ParseTrace(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return NewReplay(exchanges), nil
+}
+
+// ParseTrace parses a trace where every command line "> <hex>" is followed by its response line "< <hex>".
+// Whitespace inside the hex is ignored and lines starting with '#' are comments.
+func ParseTrace(r io.Reader) ([]Exchange, error) {
+	var exchanges []Exchange
+	var pending []byte
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line[0] != '>' && line[0] != '<' {
+			return nil, fmt.Errorf("line %d: expected '>' or '<'", lineNumber)
+		}
+		data, err := decodeHex(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		if line[0] == '>' {
+			if pending != nil {
+				return nil, fmt.Errorf("line %d: command without response", lineNumber)
+			}
+			pending = data
+			continue
+		}
+		if pending == nil {
+			return nil, fmt.Errorf("line %d: response without command", lineNumber)
+		}
+		exchanges = append(exchanges, Exchange{Command: pending, Response: data})
+		pending = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return nil, fmt.Errorf("trace ends with a command without response")
+	}
+	return exchanges, nil
+}
+
+func (r *Replay) Apdu(cmd []byte) ([]byte, error) {
+	if r.next >= len(r.exchanges) {
+		return nil, fmt.Errorf("replay exhausted after %d exchanges, unexpected APDU % X", len(r.exchanges), cmd)
+	}
+	exchange := r.exchanges[r.next]
+	if !strings.EqualFold(hex.EncodeToString(cmd), hex.EncodeToString(exchange.Command)) {
+		return nil, fmt.Errorf("replay exchange %d: expected APDU % X, got % X", r.next+1, exchange.Command, cmd)
+	}
+	r.next++
+	return exchange.Response, nil
+}
+
+func (r *Replay) DisconnectCard() error {
+	return nil
+}
+
+func (r *Replay) DisconnectUnpowerCard() error {
+	return nil
+}
+
+// Remaining returns the number of exchanges which were not replayed yet
+func (r *Replay) Remaining() int {
+	return len(r.exchanges) - r.next
+}
+
+// Fixture serves responses looked up by command from a YAML fixture, independent of the order
+// of the commands. Example:
+//
+//	responses:
+//	  FFCA000000: E004015012345678 9000
+//	  FFB0000004: 53706563 9000
+//	default: 6A82
+type Fixture struct {
+	responses map[string][]byte
+	fallback  []byte
+}
+
+type fixtureFile struct {
+	Responses map[string]string `yaml:"responses"`
+	Default   string            `yaml:"default"`
+}
+
+// LoadFixture reads a YAML fixture file
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := fixtureFile{}
+	err = yaml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	fixture := &Fixture{responses: make(map[string][]byte)}
+	for cmd, resp := range file.Responses {
+		cmdBytes, err := decodeHex(cmd)
+		if err != nil {
+			return nil, fmt.Errorf("%s: command %s: %v", path, cmd, err)
+		}
+		respBytes, err := decodeHex(resp)
+		if err != nil {
+			return nil, fmt.Errorf("%s: response of %s: %v", path, cmd, err)
+		}
+		fixture.responses[hex.EncodeToString(cmdBytes)] = respBytes
+	}
+	if file.Default != "" {
+		fixture.fallback, err = decodeHex(file.Default)
+		if err != nil {
+			return nil, fmt.Errorf("%s: default response: %v", path, err)
+		}
+	}
+	return fixture, nil
+}
+
+func (f *Fixture) Apdu(cmd []byte) ([]byte, error) {
+	if resp, ok := f.responses[hex.EncodeToString(cmd)]; ok {
+		return resp, nil
+	}
+	if f.fallback != nil {
+		return f.fallback, nil
+	}
+	return nil, fmt.Errorf("no fixture response for APDU % X", cmd)
+}
+
+func (f *Fixture) DisconnectCard() error {
+	return nil
+}
+
+func (f *Fixture) DisconnectUnpowerCard() error {
+	return nil
+}
+
+func decodeHex(s string) ([]byte, error) {
+	s = strings.Join(strings.Fields(s), "")
+	return hex.DecodeString(s)
+}
@@ -0,0 +1,303 @@
+package transport
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/auth"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// The TCP proxy protocol exchanges frames of a one byte opcode (requests) or status (responses),
+// a big endian 16 bit payload length and the payload. Every TCP connection is one card connection:
+// the client opens it with opConnect, whose payload is its access token (empty without), and the
+// server answers with the name of its reader.
+const (
+	opConnect    = 'C'
+	opApdu       = 'A'
+	opDisconnect = 'D'
+	opUnpower    = 'U'
+
+	statusOK    = 0
+	statusError = 1
+)
+
+func writeFrame(w io.Writer, code byte, payload []byte) error {
+	if len(payload) > 0xFFFF {
+		return fmt.Errorf("frame payload of %d bytes too long", len(payload))
+	}
+	frame := make([]byte, 3, 3+len(payload))
+	frame[0] = code
+	binary.BigEndian.PutUint16(frame[1:], uint16(len(payload)))
+	_, err := w.Write(append(frame, payload...))
+	return err
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [3]byte
+	_, err := io.ReadFull(r, header[:])
+	if err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[1:]))
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// clientToken and clientTLS authenticate the clients of proxy servers, see SetClientAuth
+var (
+	clientToken string
+	clientTLS   *tls.Config
+)
+
+// SetClientAuth sets the access token sent to proxy servers (see Server.SetTokens) and the TLS
+// configuration of tls:// transports (see ClientTLS), nil uses the system roots
+func SetClientAuth(token string, config *tls.Config) {
+	clientToken, clientTLS = token, config
+}
+
+// TCPClient is a card transport proxied by a remote instance running Serve
+type TCPClient struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	reader string
+}
+
+// DialTCP connects to the proxy server at address (host:port) and to the card presented to its
+// reader, over TLS if config is not nil
+func DialTCP(address string, config *tls.Config) (*TCPClient, error) {
+	var conn net.Conn
+	var err error
+	if config != nil {
+		conn, err = tls.Dial("tcp", address, config)
+	} else {
+		conn, err = net.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &TCPClient{conn: conn}
+	reader, err := c.call(opConnect, []byte(clientToken))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.reader = string(reader)
+	return c, nil
+}
+
+func (c *TCPClient) call(op byte, payload []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := writeFrame(c.conn, op, payload)
+	if err != nil {
+		return nil, err
+	}
+	status, resp, err := readFrame(c.conn)
+	if err != nil {
+		return nil, err
+	}
+	if status != statusOK {
+		return nil, fmt.Errorf("proxy: %s", resp)
+	}
+	return resp, nil
+}
+
+// ReaderName returns the PC/SC name of the reader of the proxy server
+func (c *TCPClient) ReaderName() string {
+	return c.reader
+}
+
+func (c *TCPClient) Apdu(cmd []byte) ([]byte, error) {
+	return c.call(opApdu, cmd)
+}
+
+func (c *TCPClient) DisconnectCard() error {
+	_, err := c.call(opDisconnect, nil)
+	c.conn.Close()
+	return err
+}
+
+func (c *TCPClient) DisconnectUnpowerCard() error {
+	_, err := c.call(opUnpower, nil)
+	c.conn.Close()
+	return err
+}
+
+// Cancel closes the connection, which makes a pending Apdu return
+func (c *TCPClient) Cancel() error {
+	return c.conn.Close()
+}
+
+// Default deadlines of the proxy server, see Server
+const (
+	DefaultHandshakeTimeout = 10 * time.Second
+	DefaultIdleTimeout      = 2 * time.Minute
+)
+
+// Server proxies the card of a local reader to TCPClient instances. Clients are served one at a
+// time, as they share the reader. A client has HandshakeTimeout to authenticate before it waits for
+// the reader, and is dropped once it sends no frame for IdleTimeout.
+type Server struct {
+	HandshakeTimeout time.Duration
+	IdleTimeout      time.Duration
+
+	mu      sync.Mutex
+	connect nfc.Connector
+	reader  string
+	log     nfc.Logger
+	tokens  *auth.Tokens
+}
+
+// NewServer creates a proxy server connecting to the card with connect, reader is the name
+// reported to the clients
+func NewServer(connect nfc.Connector, reader string, log nfc.Logger) *Server {
+	return &Server{
+		HandshakeTimeout: DefaultHandshakeTimeout,
+		IdleTimeout:      DefaultIdleTimeout,
+		connect:          connect,
+		reader:           reader,
+		log:              log,
+	}
+}
+
+// SetTokens requires clients to present one of the access tokens, their APDUs are limited to the
+// permission of the token. Without tokens every client may send any APDU.
+func (s *Server) SetTokens(tokens *auth.Tokens) {
+	s.tokens = tokens
+}
+
+// Serve accepts clients on listener until it is closed. Pass a TLS listener (see ServerTLS) to
+// encrypt the connections and, with a client CA, authenticate the clients by certificate.
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// authenticate returns the access token of the payload of opConnect, nil without tokens
+func (s *Server) authenticate(payload []byte) (*auth.Token, error) {
+	if s.tokens == nil {
+		return nil, nil
+	}
+	return s.tokens.Find(string(payload))
+}
+
+// handshake reads the opConnect of a new client within HandshakeTimeout and authenticates it, the
+// reader is not held yet
+func (s *Server) handshake(conn net.Conn) (*auth.Token, error) {
+	conn.SetReadDeadline(time.Now().Add(s.HandshakeTimeout))
+	op, payload, err := readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	if op != opConnect {
+		return nil, fmt.Errorf("unexpected opcode %q, expected connect", op)
+	}
+	return s.authenticate(payload)
+}
+
+// reply writes a response frame within IdleTimeout
+func (s *Server) reply(conn net.Conn, err error, resp []byte) error {
+	conn.SetWriteDeadline(time.Now().Add(s.IdleTimeout))
+	if err != nil {
+		return writeFrame(conn, statusError, []byte(err.Error()))
+	}
+	return writeFrame(conn, statusOK, resp)
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	// client is the access token of the client, nil without tokens
+	client, err := s.handshake(conn)
+	if err != nil {
+		s.log.Warnf("Client %s rejected: %v", conn.RemoteAddr(), err)
+		s.reply(conn, err, nil)
+		return
+	}
+	if client != nil {
+		s.log.Infof("Client %s authenticated as %s (%s)", conn.RemoteAddr(), client.Name, client.Permission)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log.Infof("Client %s connected", conn.RemoteAddr())
+	card, err := s.connect()
+	defer func() {
+		if card != nil {
+			card.DisconnectCard()
+		}
+		s.log.Infof("Client %s disconnected", conn.RemoteAddr())
+	}()
+	err = s.reply(conn, err, []byte(s.reader))
+	if err != nil {
+		s.log.Warnf("Client %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		op, payload, err := readFrame(conn)
+		if err != nil {
+			var netErr net.Error
+			switch {
+			case errors.As(err, &netErr) && netErr.Timeout():
+				s.log.Warnf("Client %s idle for %s, dropped", conn.RemoteAddr(), s.IdleTimeout)
+			case !errors.Is(err, io.EOF):
+				s.log.Warnf("Client %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		var resp []byte
+		switch {
+		case op == opConnect && card == nil:
+			client, err = s.authenticate(payload)
+			if err != nil {
+				s.log.Warnf("Client %s rejected: %v", conn.RemoteAddr(), err)
+				s.reply(conn, err, nil)
+				return
+			}
+			card, err = s.connect()
+			resp = []byte(s.reader)
+		case card == nil:
+			err = errors.New("not connected to a card")
+		case op == opApdu:
+			if need := apduPermission(payload); client != nil && !client.Allows(need) {
+				err = fmt.Errorf("%w: token %s has %s permission, the APDU needs %s", auth.ErrDenied, client.Name, client.Permission, need)
+				s.log.Warnf("Client %s: APDU % X refused: %v", conn.RemoteAddr(), payload, err)
+				break
+			}
+			resp, err = card.Apdu(payload)
+		case op == opDisconnect:
+			err = card.DisconnectCard()
+			card = nil
+		case op == opUnpower:
+			err = card.DisconnectUnpowerCard()
+			card = nil
+		default:
+			err = fmt.Errorf("unexpected opcode %q", op)
+		}
+
+		err = s.reply(conn, err, resp)
+		if err != nil {
+			s.log.Warnf("Client %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/auth"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// startServer serves an emulated tag on a loopback listener and returns its address
+func startServer(t *testing.T, tokens *auth.Tokens, idle time.Duration) string {
+	t.Helper()
+	tag, err := emulator.New(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(func() (nfc.CardTransport, error) { return tag, nil }, "emulated", log.WithFields(nil))
+	server.HandshakeTimeout = 200 * time.Millisecond
+	server.IdleTimeout = idle
+	server.SetTokens(tokens)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go server.Serve(listener)
+	return listener.Addr().String()
+}
+
+func testTokens() *auth.Tokens {
+	return &auth.Tokens{Tokens: []auth.Token{
+		{Name: "desk", SHA256: auth.HashToken("read-token"), Permission: auth.PermissionRead},
+		{Name: "line", SHA256: auth.HashToken("program-token"), Permission: auth.PermissionProgram},
+	}}
+}
+
+func dial(t *testing.T, address string, token string) (*TCPClient, error) {
+	t.Helper()
+	SetClientAuth(token, nil)
+	t.Cleanup(func() { SetClientAuth("", nil) })
+	return DialTCP(address, nil)
+}
+
+func TestServerSilentClientDoesNotHoldReader(t *testing.T) {
+	address := startServer(t, testTokens(), time.Minute)
+	// connects but never authenticates
+	silent, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		client, err := dial(t, address, "read-token")
+		if err == nil {
+			_, err = client.Apdu([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+			client.DisconnectCard()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("an unauthenticated client holds the reader")
+	}
+
+	// the silent client is dropped after the handshake timeout
+	silent.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = silent.Read(make([]byte, 64))
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		t.Error("the silent client was not dropped after the handshake timeout")
+	}
+}
+
+func TestServerDropsIdleClient(t *testing.T) {
+	address := startServer(t, nil, 100*time.Millisecond)
+	client, err := dial(t, address, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	_, err = client.Apdu([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+	if err == nil {
+		t.Fatal("APDU of an idle client answered after the idle timeout")
+	}
+
+	// the reader is free for the next client
+	next, err := dial(t, address, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer next.DisconnectCard()
+	_, err = next.Apdu([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerTokens(t *testing.T) {
+	address := startServer(t, testTokens(), time.Minute)
+	_, err := dial(t, address, "wrong-token")
+	if err == nil || !strings.Contains(err.Error(), "unknown access token") {
+		t.Errorf("DialTCP(wrong token) = %v, want unknown access token", err)
+	}
+
+	client, err := dial(t, address, "read-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.DisconnectCard()
+	_, err = client.Apdu([]byte{0xFF, 0xB0, 0x00, 0x00, 0x04})
+	if err != nil {
+		t.Errorf("read of block 0 with a read token: %v", err)
+	}
+	_, err = client.Apdu([]byte{0xFF, 0xB0, 0x00, 0x03, 0x04})
+	if err == nil || !strings.Contains(err.Error(), auth.ErrDenied.Error()) {
+		t.Errorf("read of key block 3 with a read token = %v, want denied", err)
+	}
+}
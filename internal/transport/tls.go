@@ -0,0 +1,72 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ServerTLS returns the TLS configuration of the proxy server from "<cert>,<key>[,<client CA>]" (PEM
+// files). With a client CA only clients presenting a certificate signed by it are accepted (mTLS).
+func ServerTLS(spec string) (*tls.Config, error) {
+	files := strings.Split(spec, ",")
+	if len(files) != 2 && len(files) != 3 {
+		return nil, fmt.Errorf("invalid TLS files %q, expected <cert>,<key>[,<client CA>]", spec)
+	}
+	cert, err := tls.LoadX509KeyPair(files[0], files[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %v", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if len(files) == 3 {
+		config.ClientCAs, err = loadCertPool(files[2])
+		if err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ClientTLS returns the TLS configuration of tls:// transports from "[<CA>][,<cert>,<key>]" (PEM
+// files). The CA verifies the server instead of the system roots, the certificate authenticates
+// the client to servers requiring mTLS.
+func ClientTLS(spec string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if spec == "" {
+		return config, nil
+	}
+	files := strings.Split(spec, ",")
+	if len(files) != 1 && len(files) != 3 {
+		return nil, fmt.Errorf("invalid TLS files %q, expected [<CA>][,<cert>,<key>]", spec)
+	}
+	var err error
+	if files[0] != "" {
+		config.RootCAs, err = loadCertPool(files[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(files) == 3 {
+		cert, err := tls.LoadX509KeyPair(files[1], files[2])
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no PEM certificates", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,46 @@
+package transport
+
+import (
+	"crypto/tls"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// Open creates a simulation transport from a -transport spec:
+//
+//	replay:<trace file>        replay a recorded APDU trace
+//	replay:<fixture.yaml>      serve responses from a YAML fixture
+//	emulate:<image file>       emulate an M24LR tag persisted to the image file
+//	tcp://<host>:<port>        use the reader of a remote instance running the proxy server
+//	tls://<host>:<port>        the same over TLS, see SetClientAuth
+func Open(spec string) (nfc.CardTransport, error) {
+	kind, arg, ok := strings.Cut(spec, ":")
+	if !ok || arg == "" {
+		return nil, fmt.Errorf("invalid transport %q", spec)
+	}
+	switch kind {
+	case "replay":
+		switch strings.ToLower(filepath.Ext(arg)) {
+		case ".yaml", ".yml":
+			return LoadFixture(arg)
+		default:
+			return LoadTrace(arg)
+		}
+	case "emulate":
+		return emulator.Load(arg)
+	case "tcp":
+		return DialTCP(strings.TrimPrefix(arg, "//"), nil)
+	case "tls":
+		config := clientTLS
+		if config == nil {
+			config = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		return DialTCP(strings.TrimPrefix(arg, "//"), config)
+	default:
+		return nil, fmt.Errorf("unknown transport %q", kind)
+	}
+}
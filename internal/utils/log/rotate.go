@@ -0,0 +1,210 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// backupLayout is the timestamp suffix of rotated files, it sorts chronologically
+const backupLayout = "20060102-150405.000"
+
+// RotatingFile is a writer appending to a file which is rotated once it grows beyond maxSize bytes
+// or, if daily is set, when the day changes. Rotated files are renamed to <path>.<timestamp>.
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	daily      bool
+	maxBackups int
+	file       *os.File
+	size       int64
+	day        string
+}
+
+// NewRotatingFile opens (or creates) path for appending. maxSize <= 0 disables size based rotation,
+// maxBackups <= 0 keeps every rotated file.
+func NewRotatingFile(path string, maxSize int64, daily bool, maxBackups int) (*RotatingFile, error) {
+	r := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		daily:      daily,
+		maxBackups: maxBackups,
+	}
+	err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.shouldRotate(len(p)) {
+		err := r.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotatingFile) open() error {
+	err := os.MkdirAll(filepath.Dir(r.path), 0755)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	r.day = info.ModTime().Format("2006-01-02")
+	if r.size == 0 {
+		r.day = time.Now().Format("2006-01-02")
+	}
+	return nil
+}
+
+func (r *RotatingFile) shouldRotate(next int) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.maxSize > 0 && r.size+int64(next) > r.maxSize {
+		return true
+	}
+	return r.daily && time.Now().Format("2006-01-02") != r.day
+}
+
+func (r *RotatingFile) rotate() error {
+	err := r.file.Close()
+	r.file = nil
+	if err != nil {
+		return err
+	}
+	backup := fmt.Sprintf("%s.%s", r.path, time.Now().Format(backupLayout))
+	err = os.Rename(r.path, backup)
+	if err != nil {
+		return err
+	}
+	r.removeOldBackups()
+	return r.open()
+}
+
+func (r *RotatingFile) removeOldBackups() {
+	if r.maxBackups <= 0 {
+		return
+	}
+	backups := r.backups()
+	if len(backups) <= r.maxBackups {
+		return
+	}
+	sort.Strings(backups)
+	for _, backup := range backups[:len(backups)-r.maxBackups] {
+		os.Remove(backup)
+	}
+}
+
+// backups returns the rotated files of the log, <path>.<timestamp>. Other files sharing the prefix,
+// e.g. <path>.sha256, are not backups and are left alone.
+func (r *RotatingFile) backups() []string {
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return nil
+	}
+	prefix := filepath.Base(r.path) + "."
+	var backups []string
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(backupLayout, suffix); err == nil {
+			backups = append(backups, filepath.Join(filepath.Dir(r.path), entry.Name()))
+		}
+	}
+	return backups
+}
+
+// fileFields are added to every entry written to the log file
+var fileFields logrus.Fields
+
+// SetFileFields adds fields (e.g. the tool version) to every entry written to the log file, so
+// audit logs identify the build which produced them
+func SetFileFields(fields map[string]interface{}) {
+	fileFields = fields
+}
+
+// fileHook writes every entry as JSON to its writer, independent of the console formatter
+type fileHook struct {
+	writer    io.Writer
+	formatter logrus.Formatter
+}
+
+func (h *fileHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *fileHook) Fire(entry *logrus.Entry) error {
+	if len(fileFields) > 0 {
+		withFields := *entry
+		withFields.Data = make(logrus.Fields, len(entry.Data)+len(fileFields))
+		for key, value := range fileFields {
+			withFields.Data[key] = value
+		}
+		for key, value := range entry.Data {
+			withFields.Data[key] = value
+		}
+		entry = &withFields
+	}
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	_, err = h.writer.Write(line)
+	return err
+}
+
+// SetFileOutput additionally writes all log entries as JSON to a rotating file at path.
+// maxSizeMB <= 0 disables size based rotation; the returned closer closes the file.
+func SetFileOutput(path string, maxSizeMB int, daily bool, maxBackups int) (io.Closer, error) {
+	file, err := NewRotatingFile(path, int64(maxSizeMB)*1024*1024, daily, maxBackups)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %v", err)
+	}
+	AddHook(&fileHook{writer: file, formatter: &logrus.JSONFormatter{}})
+	return file, nil
+}
@@ -0,0 +1,39 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveOldBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	files := []string{
+		"app.log.20240101-120000.000",
+		"app.log.20240102-120000.000",
+		"app.log.20240103-120000.000",
+		"app.log.sha256",
+		"app.log.lock",
+		"app.log.20240101-120000.000.sha256",
+	}
+	for _, name := range files {
+		err := os.WriteFile(filepath.Join(dir, name), nil, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := NewRotatingFile(path, 0, false, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.removeOldBackups()
+	for i, name := range files {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if removed := os.IsNotExist(err); removed != (i == 0) {
+			t.Errorf("%s removed = %v, want %v", name, removed, i == 0)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/batch"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/legacy"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// runImportLegacy converts an export of the legacy provisioning application into a keystore, the
+// records of a run-manifest JSON export with keys (<name>_keystore.jsonl), and one configuration
+// image per distinct configuration in the export (<name>_profile_<n>.bin, device blocks blank and
+// encrypted with -config-key-file if set). Params: <export.xml|export.csv>[,<output directory>].
+func runImportLegacy(params string) error {
+	if params == "" {
+		return fmt.Errorf("missing params (legacy export file)")
+	}
+	path, dir, _ := strings.Cut(params, ",")
+	if dir == "" {
+		dir = filepath.Dir(path)
+	}
+	devices, err := legacy.Read(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	keystore := filepath.Join(dir, name+"_keystore.jsonl")
+	if _, err := os.Stat(keystore); err == nil {
+		return fmt.Errorf("%s already exists, remove it to import again", keystore)
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+
+	keystoreExport := batch.Export{Format: "json", Keys: true}
+	profiles := make(map[[sha256.Size]byte]string)
+	imported, skipped := 0, 0
+	for _, device := range devices {
+		err = device.Validate()
+		if err != nil {
+			log.Warnf("Skipping %v\n", err)
+			skipped++
+			continue
+		}
+		record := batch.Record{
+			Time:           device.Programmed,
+			Batch:          name,
+			Station:        "legacy",
+			UID:            device.UID,
+			DevEUI:         device.DevEUI,
+			JoinEUI:        device.JoinEUI,
+			JoinKey:        device.JoinKey,
+			KeyFingerprint: nfc.KeyFingerprint(device.JoinKey),
+			OK:             true,
+		}
+		if record.Time.IsZero() {
+			record.Time = export.Now()
+		}
+		if device.NwkKey != "" {
+			record.NwkKey, record.NwkKeyFingerprint = device.NwkKey, nfc.KeyFingerprint(device.NwkKey)
+		}
+		err = batch.AppendExport(keystoreExport, keystore, record, nfc.MaskKey)
+		if err != nil {
+			return err
+		}
+		imported++
+
+		if device.Config == nil {
+			continue
+		}
+		if len(device.Config) < nfc.CONFIG_BIN_SIZE {
+			log.Warnf("Record %d: configuration has %d bytes, expected %d, no profile written\n", device.Line, len(device.Config), nfc.CONFIG_BIN_SIZE)
+			continue
+		}
+		// the legacy application exported the CRC block as well
+		image, err := nfc.BlankDeviceBlocks(device.Config[:nfc.CONFIG_BIN_SIZE])
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(image)
+		if _, ok := profiles[sum]; ok {
+			continue
+		}
+		profile := filepath.Join(dir, fmt.Sprintf("%s_profile_%d.bin", name, len(profiles)+1))
+		err = nfc.SaveConfigBin(profile, image)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %v", profile, err)
+		}
+		profiles[sum] = profile
+		fmt.Printf("Profile %s (firmware %s, region %s)\n", profile, device.Firmware, device.Region)
+	}
+	fmt.Printf("Imported %d devices into %s, %d skipped, %d profiles\n", imported, keystore, skipped, len(profiles))
+	return nil
+}
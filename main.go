@@ -3,590 +3,2318 @@ package main
 import (
 	"bitbucket.org/bluvision/pcsc/pcsc"
 	"bufio"
+	"crypto/tls"
 	"encoding/base64"
-	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/actions"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/auth"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/batch"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/config"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/fips"
 	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/store"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/transport"
 	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
 	"math/big"
+	"net"
 	"os"
+	"path"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var command string
 var params string
 var versionFlag bool
+var scriptFile string
+var scriptVarsFile string
+var scriptVarsRow int
+var scriptVars = scriptVariables{}
+var configFile string
+var readerName string
+var readerFilter string
+var readerMinFirmware string
+var readerFirmwareCheck string
+var exportFile string
+var logFile string
+var logMaxSize int
+var logRotateDaily bool
+var logMaxBackups int
+var transportSpec string
+var recordFile string
+var injectFailure string
+var serveAddr string
+var serveTokens string
+var serveTLS string
+var serveIdleTimeout time.Duration
+var transportToken string
+var transportTLS string
+var networkServer string
+var networkServerToken string
+var retiredRegistry *store.Retired
+var decommissionDir string
+var decommissionKey string
+var decommissionDelete bool
+var rekeyLog string
+var rekeyRecords string
+var counterStore *store.Counters
+var nameHistory *store.NameHistory
+var nameCollision string
+var namePad string
+var redactKeys bool
+var exportKeys bool
+var cmMode bool
+var fipsMode bool
+var cmKeystore string
+var cmKeystoreKey string
+var summaryFile string
+var exportChecksums bool
+var exportTemplate string
+var keySchema string
+var timeZone string
+var duplicates string
+var actionsFile string
+var eventsLog string
+var localDB *store.DB
+var storeDB string
+var wearWarn int
+var wearTracker *store.WearTracker
+var postReadActions *actions.Queue
+var outputFormat string
+var readChunk int
+var blockSize int
+var blockCache bool
+var rewriteUnchanged bool
+var allowMultipleTags bool
+var allowAnySKU bool
+var familyAFI string
+var beaconTypesFile string
+var timeFormat string
+var configKey string
+var configKeyFile string
+var imageSigningKey string
+var imageVerifyKey string
+var allowUnsignedImages bool
+var station string
+var operator string
+var reportSince string
+var reportFormat string
+var emulateFile string
+
+// readerLabel names the reader (or transport) in use, e.g. in snapshots
+var readerLabel string
+var logLevel string
+var logFormat string
+var quiet bool
+var verbose bool
+var strict bool
+var allowConflicts bool
+var apduTimeout time.Duration
+var operationTimeout time.Duration
+var assignmentsFile string
+var verifyCount int
+var forceFactory bool
+var confirmUID string
+var operatorPinHash string
+var operatorPIN string
+var allowUnauthorized bool
+var approvalKey string
+var approvalToken string
+var approvalSigningKey string
+var approvalSubject string
+var auditLogFile string
 
 func initCommandLine() {
-	flag.StringVar(&command, "cmd", "SerialNumberTest", "SerialNumberTest")
-	flag.StringVar(&params, "param", "", "params")
+	flag.StringVar(&command, "cmd", "SerialNumberTest", "command(s) to run: \"cmd1,cmd2\" or \"cmd1=param1;cmd2=param2\", -cmd commands lists them")
+	flag.StringVar(&params, "param", "", "params shared by commands without their own \"=param\"")
+	flag.StringVar(&scriptFile, "script", "", "script file, one command and its params per line")
+	flag.Var(&scriptVars, "var", "script variable NAME=VALUE, can be repeated")
+	flag.StringVar(&scriptVarsFile, "vars", "", "CSV file with a header row providing script variables")
+	flag.IntVar(&scriptVarsRow, "row", 1, "data row of the -vars CSV file to use (1 based)")
 	flag.BoolVar(&versionFlag, "version", false, "Print version information")
+	flag.StringVar(&configFile, "config", config.DefaultPath(), "configuration file holding defaults for these options")
+	flag.StringVar(&readerName, "reader", "", "name (or part of it) of the reader to use, defaults to the first reader")
+	flag.StringVar(&readerFilter, "reader-filter", "", "comma separated globs of the readers which may be used, e.g. \"OMNIKEY 5422\"; prefix with ! to exclude, e.g. \"!Alcor*\"")
+	flag.StringVar(&readerMinFirmware, "reader-min-firmware", "", "minimum reader firmware for ISO 15693 writes per model, \"<model>=<version>[,...]\" matched against the reader and product name, e.g. \"OMNIKEY 5022=1.14\"")
+	flag.StringVar(&readerFirmwareCheck, "reader-firmware-check", "warn", "what to do when the reader firmware is older than -reader-min-firmware: warn, abort or off")
+	flag.StringVar(&exportFile, "export", "lora_info.csv", "CSV file written by readloraloop when no param is given")
+	flag.StringVar(&exportTemplate, "export-template", "", "YAML file selecting the columns of the readloraloop CSV (header and text/template value per column)")
+	flag.StringVar(&keySchema, "key-schema", batch.SchemaLoRaWAN10, "key columns of the readloraloop CSV: lorawan-1.0 (JoinKey) or lorawan-1.1 (NwkKey and AppKey)")
+	flag.StringVar(&actionsFile, "actions", "", "YAML file of actions (command, gpio, sound, message) run after every tag that succeeded or failed in loop modes")
+	flag.IntVar(&readChunk, "read-chunk", 0, "blocks read per APDU for configuration reads: 1, 4 or 32, 0 detects the largest the reader supports")
+	flag.IntVar(&blockSize, "block-size", nfc.DEFAULT_BLOCK_SIZE, "block size of the tags in bytes (e.g. 8 for ISO 15693 tags with 8 byte blocks), 0 uses the size reported by the system information of every tag")
+	flag.BoolVar(&allowMultipleTags, "allow-multiple-tags", false, "write to the tag even when other tags are in the field (by default the field is checked with an ISO 15693 inventory before the first write, and writes fail when the reader cannot run it)")
+	flag.BoolVar(&allowAnySKU, "allow-any-sku", false, "write product commands, LoRa identity fields, blocks and BLE beacon identities to tags whose beacon type (SKU) does not have them, see -beacon-types")
+	flag.StringVar(&beaconTypesFile, "beacon-types", "", "YAML file of beacon types (SKUs) adding to or replacing the built in ones, so new SKUs are recognized")
+	flag.StringVar(&familyAFI, "afi", "", "application family (hex AFI, e.g. 07) tags are restricted to: only they are listed by inventory and tags of other families are not written")
+	flag.BoolVar(&rewriteUnchanged, "rewrite-unchanged", false, "programTag and run-manifest profiles write every block of the image, by default blocks already holding the data are not written to spare the EEPROM")
+	flag.BoolVar(&blockCache, "block-cache", false, "keep the blocks read and written, so the CRC written after every field write is computed without reading the configuration again")
+	flag.StringVar(&outputFormat, "output", "text", "command results as text or json (one JSON object per command)")
+	flag.StringVar(&summaryFile, "summary", "", "append the session summary of loop and batch modes (tags attempted, succeeded, failed with reasons, cycle time) as a JSON line to this file")
+	flag.BoolVar(&exportChecksums, "export-checksums", false, "write a sha256sum file (<file>.sha256) next to every export, report and log file and update it with every write, so the files can be verified after transfer (see -cmd verify-export)")
+	flag.StringVar(&eventsLog, "events-log", "", "JSON lines file receiving every tag event (connects, block writes, CRC checks, completed tags)")
+	flag.StringVar(&storeDB, "db", "", "local database of the station, stations may share it on a file system with working file locks: the EEPROM writes per tag (see -cmd wear), the BLE local names writeblelocal checks for a name another tag already advertises, the named counters export templates increment with {{counter \"<name>\"}} (see -cmd counter), the DevEUIs retired by decommission, which run-manifest refuses to program, the birth certificate digests and the hooks run-manifest recorded and queued")
+	flag.StringVar(&nameCollision, "name-collision", "abort", "writeblelocal with a name -db has for another tag: abort or warn (and write it)")
+	flag.StringVar(&namePad, "name-pad", "0x00", "byte padding BLE local names written by writeblelocal: 0x00, or 0x30 ('0') for installations expecting names padded with '0' characters")
+	flag.IntVar(&wearWarn, "wear-warn", 20, "warn after every write of a tag -db recorded as written this many times or more (rework loops), 0 never warns")
+	flag.StringVar(&duplicates, "duplicates", "skip", "readloraloop handling of a tag read twice in a row (left on the reader): skip or warn (export it again)")
+	flag.StringVar(&timeZone, "timezone", "UTC", "time zone of exported timestamps: UTC, Local or an IANA name such as Europe/Berlin")
+	flag.StringVar(&timeFormat, "time-format", "rfc3339", "layout of exported timestamps: rfc3339, rfc3339nano, datetime (2006-01-02 15:04:05) or a Go layout")
+	flag.StringVar(&logLevel, "log-level", "info", "log level: trace, debug, info, warn, error, fatal")
+	flag.StringVar(&logFormat, "log-format", "text", "console log format: text, nocolor or json")
+	flag.BoolVar(&quiet, "quiet", false, "only print command results and errors")
+	flag.BoolVar(&verbose, "verbose", false, "enable debug logging")
+	flag.BoolVar(&forceFactory, "force-factory", false, "allow writing factory assigned data such as the BLE MAC")
+	flag.StringVar(&confirmUID, "confirm-uid", "", "UID of the tag, required together with -force-factory")
+	flag.StringVar(&operatorPinHash, "operator-pin-hash", "", "hash of the operator PIN required for destructive commands (erase, writeConfigBin, programTag, writeblemac, rekey, decommission), see -cmd hashpin")
+	flag.StringVar(&operatorPIN, "pin", "", "operator PIN for destructive commands, prompted for when not given")
+	flag.BoolVar(&allowUnauthorized, "allow-unauthorized", false, "run destructive commands without -operator-pin-hash or -approval-key configured, which refuses them otherwise")
+	flag.StringVar(&approvalKey, "approval-key", "", "public key file verifying -approval-token, destructive commands then require a token unless -operator-pin-hash is set")
+	flag.StringVar(&approvalToken, "approval-token", "", "signed approval for a destructive command, see -cmd approve")
+	flag.StringVar(&approvalSigningKey, "approval-signing-key", "", "signing key used by -cmd approve, see -cmd genapprovalkey")
+	flag.StringVar(&approvalSubject, "approval-subject", "", "name of the approver recorded in tokens created by -cmd approve")
+	flag.StringVar(&auditLogFile, "audit-log", "audit.log", "JSON lines file recording every attempt to run a destructive command")
+	flag.StringVar(&assignmentsFile, "assignments", "beacon_assignments.csv", "CSV file logging the identities given by ibeaconloop and eddystoneloop")
+	flag.IntVar(&verifyCount, "count", 0, "number of tags verify-batch inspects, overrides the count of the inspection profile; number of tags rekey rekeys or migrate-blename repairs, 0 until stopped")
+	flag.DurationVar(&apduTimeout, "apdu-timeout", nfc.DefaultAPDUTimeout, "time a single APDU may take before the reader is considered dead, 0 waits forever")
+	flag.DurationVar(&operationTimeout, "op-timeout", 0, "time limit for every command, e.g. 30s, 0 means no limit (loop commands count the operator time too)")
+	flag.BoolVar(&strict, "strict", false, "fail when a settings field cannot be decoded instead of warning")
+	flag.BoolVar(&allowConflicts, "allow-conflicts", false, "apply configuration images whose settings conflict (see cfgcheck) with a warning instead of refusing them")
+	flag.StringVar(&transportSpec, "transport", "pcsc", "card transport: pcsc, replay:<trace file>, replay:<fixture.yaml>, emulate:<image file> or tcp://<host>:<port> (see -serve)")
+	flag.StringVar(&emulateFile, "emulate", "", "emulate a tag persisted to this image file instead of using a reader, same as -transport emulate:<file>")
+	flag.StringVar(&serveAddr, "serve", "", "act as APDU proxy for the reader on this address (e.g. :7000) instead of running commands, clients use -transport tcp://host:7000; needs -serve-tokens unless the address is loopback (e.g. 127.0.0.1:7000)")
+	flag.StringVar(&serveTokens, "serve-tokens", "", "YAML file of the access tokens -serve accepts, each with permission read, program or erase (see -cmd gentoken); clients without a valid token are rejected")
+	flag.DurationVar(&serveIdleTimeout, "serve-idle-timeout", transport.DefaultIdleTimeout, "drop a -serve client which sends nothing for this time, it holds the reader meanwhile")
+	flag.StringVar(&serveTLS, "serve-tls", "", "serve over TLS: <cert>,<key>[,<client CA>] PEM files, with a client CA only clients with a certificate it signed are accepted (mTLS); clients use -transport tls://host:port")
+	flag.StringVar(&transportToken, "transport-token", "", "access token sent to a -serve proxy with -serve-tokens, prefer HIDNFC_TRANSPORT_TOKEN")
+	flag.StringVar(&transportTLS, "transport-tls", "", "TLS of tls:// transports: [<CA>][,<cert>,<key>] PEM files, the CA verifies the proxy instead of the system roots, the certificate authenticates the client (mTLS)")
+	flag.StringVar(&networkServer, "network-server", "", "network server crosscheck looks tags up on, rekey sets the new keys on and decommission deletes devices from: chirpstack:<REST API url> (ChirpStack v4) or ttn:<url>,<application ID> (The Things Stack v3)")
+	flag.StringVar(&networkServerToken, "network-server-token", "", "API key of -network-server, allowed to read device keys (and update them for rekey, delete devices with -decommission-delete); prefer HIDNFC_NETWORK_SERVER_TOKEN")
+	flag.StringVar(&decommissionDir, "decommission-dir", "decommissioned", "directory of the decommission certificates (<UID>_decommission.json)")
+	flag.StringVar(&decommissionKey, "decommission-key", "", "key signing the decommission certificates, created with -cmd genapprovalkey")
+	flag.StringVar(&rekeyLog, "rekey-log", "rekey.csv", "CSV file rekey logs the old and new JoinKey fingerprint of every tag to")
+	flag.StringVar(&rekeyRecords, "rekey-records", "", "run-manifest exports or batch reports (comma separated) listing the tags of the batch rekey accepts")
+	flag.BoolVar(&decommissionDelete, "decommission-delete", false, "decommission also deletes the device from -network-server")
+	flag.BoolVar(&redactKeys, "redact-keys", false, "mask LoRa JoinKeys in console output, logs and exports (default true with -serve)")
+	flag.BoolVar(&exportKeys, "export-keys", false, "write full JoinKeys to exports even with -redact-keys")
+	flag.BoolVar(&fipsMode, "fips", false, "FIPS mode: generate keys with FIPS approved primitives only (P-256 keystore keys, no Ed25519 approval keys), always on in builds with the validated module (make compile-linux-fips)")
+	flag.BoolVar(&cmMode, "cm-mode", false, "contract manufacturer mode: hide JoinEUIs and keys entirely in console output, exports and -record traces, records with keys only go to the sealed -cm-keystore; backup and -serve are refused")
+	flag.StringVar(&cmKeystore, "cm-keystore", "cm_keystore.jsonl", "sealed keystore written by readloraloop and run-manifest in -cm-mode, and by rekey")
+	flag.StringVar(&cmKeystoreKey, "cm-keystore-key", "", "public key (<file>.pub of -cmd genkeystorekey) sealing the -cm-keystore")
+	flag.StringVar(&configKey, "config-key", "", "hex AES key (16, 24 or 32 bytes) encrypting generateConfigBin images and decrypting encrypted images, prefer -config-key-file or HIDNFC_CONFIG_KEY")
+	flag.StringVar(&configKeyFile, "config-key-file", "", "file holding the hex -config-key, see -cmd genconfigkey")
+	flag.StringVar(&imageSigningKey, "image-signing-key", "", "key signing the images of generateConfigBin (<file>.sig), created with -cmd genapprovalkey")
+	flag.StringVar(&imageVerifyKey, "image-verify-key", "", "public key writeConfigBin, programTag and run-manifest verify image signatures (<file>.sig) with, images without a valid signature are refused")
+	flag.BoolVar(&allowUnsignedImages, "allow-unsigned-images", false, "apply configuration images without signature verification when no -image-verify-key is configured, which refuses them otherwise")
+	flag.StringVar(&station, "station", defaultStation(), "name of this provisioning station in run-manifest records")
+	flag.StringVar(&operator, "operator", "", "name of the operator recorded in the birth certificates of run-manifest")
+	flag.StringVar(&reportSince, "since", "", "only count records from this date (YYYY-MM-DD) in -cmd report")
+	flag.StringVar(&reportFormat, "report-format", "text", "output of -cmd report: text, json or html")
+	flag.StringVar(&recordFile, "record", "", "record the APDU exchanges of this session to a trace file")
+	flag.StringVar(&injectFailure, "inject-failure", "", "fail APDUs at random with the emulator or a replay transport: comma separated crc|write|timeout:<rate>, e.g. crc:0.05,timeout:0.01")
+	flag.StringVar(&logFile, "log-file", "", "also write JSON logs to this file")
+	flag.IntVar(&logMaxSize, "log-max-size", 10, "rotate the log file once it exceeds this size in MB, 0 disables")
+	flag.BoolVar(&logRotateDaily, "log-rotate-daily", false, "rotate the log file every day")
+	flag.IntVar(&logMaxBackups, "log-max-backups", 10, "number of rotated log files to keep, 0 keeps all")
 	flag.Parse()
 }
 
-func writeLoraInfoToCSV(filename string, info *nfc.LoraInfo, isNewFile bool) error {
-	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// applyConfig loads the configuration file and HIDNFC_* environment variables and uses them for every
+// option not given on the command line. Precedence: flags, environment, configuration file, defaults;
+// except for the config.Secured options, which a flag may not change once the configuration sets them,
+// and which /etc/hidnfc.yaml and ~/.hidnfc.yaml pin whatever -config selects.
+func applyConfig() error {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	if !explicit["config"] {
+		if path, ok := os.LookupEnv(config.EnvName("config")); ok {
+			configFile = path
+			explicit["config"] = true
+		}
+	}
+
+	cfg, err := config.Load(configFile, explicit["config"])
+	if err != nil {
+		return err
+	}
+	for name, value := range cfg.Values() {
+		if explicit[name] && config.Secured[name] && !config.SameValue(flag.Lookup(name).Value.String(), value) {
+			return fmt.Errorf("-%s may not change %s set by the configuration", name, name)
+		}
+		if explicit[name] || flag.Lookup(name) == nil {
+			continue
+		}
+		err = flag.Set(name, value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value %q: %v", name, value, err)
+		}
+	}
+	return nil
+}
+
+// defaultStation returns the host name, which identifies the station unless -station is given
+func defaultStation() string {
+	name, err := os.Hostname()
 	if err != nil {
-		return fmt.Errorf("failed to open CSV file: %v", err)
+		return "unknown"
+	}
+	return name
+}
+
+// applyRedaction enables -redact-keys, which defaults to on in server mode
+func applyRedaction() {
+	redactSet := false
+	flag.Visit(func(f *flag.Flag) {
+		redactSet = redactSet || f.Name == "redact-keys"
+	})
+	if serveAddr != "" && !redactSet {
+		redactKeys = true
 	}
-	defer file.Close()
+	nfc.SetRedactKeys(redactKeys)
+}
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+// applyFIPS enables FIPS mode with -fips, binaries built with the validated module always run in it
+func applyFIPS() {
+	if fipsMode {
+		fips.Enable()
+	}
+	if !fips.Enabled() {
+		return
+	}
+	if fips.Validated() {
+		log.Debugf("FIPS mode, cryptography by the validated BoringCrypto module")
+		return
+	}
+	log.Warnf("FIPS mode restricts the primitives used, but this binary is not built with the validated module (make compile-linux-fips)")
+}
 
-	// Write header if new file
-	if isNewFile {
-		header := []string{"Timestamp", "DevEUI", "JoinEUI", "JoinKey", "CRC Status"}
-		if err := writer.Write(header); err != nil {
-			return fmt.Errorf("failed to write CSV header: %v", err)
+// applyConfigKey sets the key of encrypted configuration images from -config-key or -config-key-file
+func applyConfigKey() error {
+	key := configKey
+	if configKeyFile != "" {
+		data, err := os.ReadFile(configKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read configuration key: %v", err)
 		}
+		key = string(data)
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil
+	}
+	keyBytes, err := hex.DecodeString(key)
+	if err != nil {
+		return fmt.Errorf("invalid configuration key, expected hex: %v", err)
 	}
+	return nfc.SetConfigBinKey(keyBytes)
+}
 
-	// Write data
-	record := []string{
-		info.Timestamp,
-		info.DevEUI,
-		info.JoinEUI,
-		info.JoinKey,
-		info.CRCStatus,
+// applyLogOptions configures the log packages from -log-level/-log-format, -quiet and -verbose win over -log-level
+func applyLogOptions() {
+	level := logLevel
+	if verbose {
+		level = "debug"
+	}
+	if quiet {
+		level = "error"
+	}
+	log.SetLevel(level)
+	log.SetFormat(logFormat)
+}
+
+// printInfo prints progress messages, these are suppressed by -quiet
+func printInfo(format string, args ...interface{}) {
+	if quiet {
+		return
+	}
+	fmt.Printf(format, args...)
+}
+
+// filterReaders applies the -reader-filter patterns to the reader names. Patterns are comma separated
+// globs matched case insensitively against the full reader name, a pattern without wildcards matches
+// any name containing it. A pattern starting with '!' excludes the readers it matches. Without any
+// allow pattern every reader not excluded is kept.
+func filterReaders(readers []string, filter string) ([]string, error) {
+	var allow, deny []string
+	for _, pattern := range strings.Split(filter, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		excluded := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		if !strings.ContainsAny(pattern, "*?[") {
+			pattern = "*" + pattern + "*"
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid reader filter %q: %v", pattern, err)
+		}
+		if excluded {
+			deny = append(deny, pattern)
+		} else {
+			allow = append(allow, pattern)
+		}
+	}
+	matches := func(patterns []string, reader string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, strings.ToLower(reader)); ok {
+				return true
+			}
+		}
+		return false
 	}
 
-	if err := writer.Write(record); err != nil {
-		return fmt.Errorf("failed to write CSV record: %v", err)
+	var filtered []string
+	for _, reader := range readers {
+		if matches(deny, reader) || len(allow) > 0 && !matches(allow, reader) {
+			log.Debugf("Reader %q excluded by -reader-filter", reader)
+			continue
+		}
+		filtered = append(filtered, reader)
+	}
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("no reader matches -reader-filter %q, found %v", filter, readers)
+	}
+	return filtered, nil
+}
+
+// selectReader returns the first reader whose name contains name, or the first reader if name is empty
+func selectReader(readers []string, name string) (string, error) {
+	if name == "" {
+		return readers[0], nil
+	}
+	for _, reader := range readers {
+		if strings.Contains(strings.ToLower(reader), strings.ToLower(name)) {
+			return reader, nil
+		}
 	}
+	return "", fmt.Errorf("reader %q not found in %v", name, readers)
+}
 
+// checkReaderFirmware checks the firmware of the selected reader against -reader-min-firmware,
+// readers without a matching requirement are not queried
+func checkReaderFirmware(reader string) error {
+	if readerFirmwareCheck != "warn" && readerFirmwareCheck != "abort" && readerFirmwareCheck != "off" {
+		return fmt.Errorf("invalid -reader-firmware-check %q, expected warn, abort or off", readerFirmwareCheck)
+	}
+	requirements, err := nfc.ParseFirmwareRequirements(readerMinFirmware)
+	if err != nil {
+		return err
+	}
+	if readerFirmwareCheck == "off" || nfc.MatchFirmwareRequirement(requirements, reader) == nil {
+		return nil
+	}
+	info := nfc.ReadReaderInfo(reader)
+	err = info.CheckFirmware(requirements)
+	if err == nil {
+		log.Debugf("Reader %q firmware %s", reader, info.FirmwareVersion)
+		return nil
+	}
+	if readerFirmwareCheck == "abort" && errors.Is(err, nfc.ErrReaderFirmware) {
+		return fmt.Errorf("%v, update the reader or use -reader-firmware-check warn", err)
+	}
+	log.Warnf("%v\n", err)
 	return nil
 }
 
-func nfcRunCommands(command string, nfcCardInstance *nfc.NfcCard) error {
-	var err error
-	switch command {
+// loraExportRow is the record readloraloop exports, the fields are available to -export-template
+type loraExportRow struct {
+	Timestamp      string
+	Station        string
+	UID            string
+	DevEUI         string
+	JoinEUI        string
+	JoinKey        string
+	KeyFingerprint string
+	CRCStatus      string
+	// NwkKey and AppKey are the root keys of LoRaWAN 1.1, both are the JoinKey of the tag
+	NwkKey string
+	AppKey string
+	// AssetID is the customer asset ID, empty if none was written
+	AssetID string
+}
 
-	case "readAllBlocks":
-		_, err = nfcCardInstance.ReadAllBlocks(true)
-		if err != nil {
-			log.Errorf("Failed to read all blocks: %v\n", err)
-			break
+// subscribeEvents registers the subscribers of the tag events: the debug log, -events-log, the
+// write counts of -db and the post-read actions of -actions
+func subscribeEvents() error {
+	events.Subscribe(func(event events.Event) {
+		log.Debugf("Event %s: UID %s, OK %t %s", event.Type, event.UID, event.OK, event.Error)
+	})
+	if eventsLog != "" {
+		events.Subscribe(func(event events.Event) {
+			event.Time = event.Time.In(export.Location())
+			data, err := json.Marshal(event)
+			if err == nil {
+				err = export.AppendLine(eventsLog, data)
+			}
+			if err != nil {
+				log.Warnf("Failed to write event to %s: %v", eventsLog, err)
+			}
+		})
+	}
+	if localDB != nil {
+		wearTracker = localDB.Wear()
+		wearTracker.WarnAfter = wearWarn
+		wearTracker.Warn = func(r store.WearRecord) {
+			block, writes := r.MostWritten()
+			log.Warnf("Tag %s has been written %d times (%d block writes, block %d %d times), check it for EEPROM wear before shipping", r.UID, r.Sessions, r.Writes, block, writes)
 		}
-	case "readConfigBin":
-		if params == "" {
-			log.Errorf("Missing params (Binary File Name)\n")
-			break
+		wearTracker.Failed = func(err error) {
+			log.Warnf("%v", err)
 		}
-		err = nfcCardInstance.PrintConfigFields(params, true)
+		events.Subscribe(wearTracker.Add)
+	}
+	if actionsFile != "" {
+		postRead, err := actions.Load(actionsFile)
 		if err != nil {
-			log.Errorf("Failed to read %s, err: %v\n", params, err)
-			break
+			return err
 		}
+		postReadActions = actions.NewQueue(postRead)
+		events.Subscribe(postReadActions.Handle)
+	}
+	return nil
+}
 
-	case "generateConfigBin":
-		if params == "" {
-			log.Warn("Missing params (Binary File Name), Using default name: AssetPlus_Config.bin\n")
-			params = "AssetPlus_Config.bin"
+// tagCompleted publishes the ProvisionCompleted event of a tag handled by a loop mode
+func tagCompleted(command string, uid string, err error) {
+	events.Publish(events.Event{Type: events.ProvisionCompleted, UID: uid, Command: command, OK: err == nil, Error: events.ErrorString(err)})
+}
+
+// finishSummary prints the summary of the tags completed by loop and batch modes and appends it
+// to -summary, sessions which completed no tag have no summary
+func finishSummary(summary *events.Summary) {
+	summary.Finish()
+	if summary.Attempted == 0 {
+		return
+	}
+	fmt.Println("\n" + summary.String())
+	if summaryFile == "" {
+		return
+	}
+	summary.Station = station
+	summary.Started, summary.Finished = summary.Started.In(export.Location()), summary.Finished.In(export.Location())
+	data, err := json.Marshal(summary)
+	if err == nil {
+		err = export.AppendLine(summaryFile, data)
+	}
+	if err != nil {
+		log.Errorf("Failed to write session summary to %s: %v\n", summaryFile, err)
+	}
+}
+
+// loadExportTemplate loads -export-template, nil if none is set. The template is checked against an
+// empty row so a misspelled field fails before the first tag instead of on every tag.
+func loadExportTemplate() (*export.Template, error) {
+	if exportTemplate == "" {
+		return nil, nil
+	}
+	tmpl, err := export.LoadTemplate(exportTemplate)
+	if err != nil {
+		return nil, err
+	}
+	err = tmpl.Check(loraExportRow{})
+	if err != nil {
+		return nil, fmt.Errorf("export template %s: %v", exportTemplate, err)
+	}
+	return tmpl, nil
+}
+
+func writeLoraInfoToCSV(filename string, uid string, info *nfc.LoraInfo, tmpl *export.Template) error {
+	row := loraExportRow{
+		Timestamp:      export.FormatTime(info.Time),
+		Station:        station,
+		UID:            uid,
+		DevEUI:         info.DevEUI,
+		JoinEUI:        info.JoinEUI,
+		JoinKey:        info.JoinKey,
+		KeyFingerprint: nfc.KeyFingerprint(info.JoinKey),
+		CRCStatus:      info.CRCStatus,
+		AssetID:        info.AssetID,
+	}
+	row.NwkKey, row.AppKey = row.JoinKey, row.JoinKey
+	if cmMode {
+		record := batch.Record{
+			Time:           info.Time,
+			Station:        station,
+			UID:            uid,
+			DevEUI:         info.DevEUI,
+			JoinEUI:        info.JoinEUI,
+			JoinKey:        info.JoinKey,
+			KeyFingerprint: row.KeyFingerprint,
+			OK:             info.CRCStatus == "VALID",
 		}
-		err = nfcCardInstance.GenerateConfigBin(params)
+		err := sealRecord(record)
 		if err != nil {
-			log.Errorf("Failed to generate %s, err: %v\n", params, err)
-			break
+			return err
+		}
+		row.JoinEUI, row.JoinKey, row.NwkKey, row.AppKey = "", "", "", ""
+	} else if !exportKeys {
+		row.JoinKey = nfc.RedactKey(row.JoinKey)
+		row.NwkKey, row.AppKey = row.JoinKey, row.JoinKey
+	}
+	if tmpl != nil {
+		record, err := tmpl.Row(row)
+		if err != nil {
+			return err
 		}
-		fmt.Printf("Generated %s successfully\n", params)
+		return export.AppendCSV(filename, tmpl.Header(), record)
+	}
+	if keySchema == batch.SchemaLoRaWAN11 {
+		header := []string{"Timestamp", "DevEUI", "JoinEUI", "NwkKey", "AppKey", "CRC Status"}
+		return export.AppendCSV(filename, header, []string{row.Timestamp, row.DevEUI, row.JoinEUI, row.NwkKey, row.AppKey, row.CRCStatus})
+	}
+	header := []string{"Timestamp", "DevEUI", "JoinEUI", "JoinKey", "CRC Status"}
+	record := []string{
+		row.Timestamp,
+		row.DevEUI,
+		row.JoinEUI,
+		row.JoinKey,
+		row.CRCStatus,
+	}
+	return export.AppendCSV(filename, header, record)
+}
 
-	case "readloraloop":
-		filename := "lora_info.csv"
-		if params != "" {
-			filename = params
+// nfcRunCommands runs a command and renders its result in -output format
+func nfcRunCommands(command string, params string, nfcCardInstance *nfc.NfcCard) error {
+	if outputFormat == "json" {
+		result, err := runCommand(command, params, nfcCardInstance, nil)
+		if writeErr := result.WriteJSON(os.Stdout); writeErr != nil {
+			log.Errorf("Failed to write result: %v\n", writeErr)
 		}
+		return err
+	}
+	_, err := runCommand(command, params, nfcCardInstance, os.Stdout)
+	return err
+}
 
-		// Check if file exists
-		isNewFile := true
-		if _, err := os.Stat(filename); err == nil {
-			isNewFile = false
+// execCommand runs a command on the tag with the handler it was registered with, its fields and
+// messages are recorded in result
+func execCommand(command string, params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	c, ok := findCommand(command)
+	if !ok || c.run == nil {
+		err := fmt.Errorf("unknown command '%s', use -cmd commands to list all commands", command)
+		if ok {
+			err = fmt.Errorf("%s does not run on a tag, run it on its own", command)
 		}
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err := nfcCardInstance.CheckProduct(commandProducts[command])
+	if err != nil {
+		log.Errorf("%s: %v\n", command, err)
+		return err
+	}
+	return c.run(params, nfcCardInstance, result)
+}
+
+func readAllBlocksCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	var data []byte
+	data, err = nfcCardInstance.ReadAllBlocks()
+	if err != nil {
+		log.Errorf("Failed to read all blocks: %v\n", err)
+		return err
+	}
+	for i := 0; i < len(data)/4; i++ {
+		result.Set(fmt.Sprintf("Block %02d", i), nfc.RedactBlock(i, strings.ToUpper(hex.EncodeToString(data[i*4:i*4+4]))))
+	}
+	return err
+}
+
+func readConfigBinCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (Binary File Name)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	var fields []nfc.ConfigField
+	fields, err = nfcCardInstance.ConfigFields(params, true)
+	if err != nil {
+		log.Errorf("Failed to read %s, err: %v\n", params, err)
+		return err
+	}
+	setConfigFields(result, fields)
+	return err
+}
 
-		reader := bufio.NewReader(os.Stdin)
-		tagCount := 0
+func generateConfigBinCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		log.Warn("Missing params (Binary File Name), Using default name: AssetPlus_Config.bin\n")
+		params = "AssetPlus_Config.bin"
+	}
+	err = nfcCardInstance.GenerateConfigBin(params)
+	if err != nil {
+		log.Errorf("Failed to generate %s, err: %v\n", params, err)
+		return err
+	}
+	result.Message("Generated %s successfully", params)
+	if imageSigningKey != "" {
+		err = signConfigImage(params)
+		if err != nil {
+			log.Errorf("Failed to sign %s, err: %v\n", params, err)
+			return err
+		}
+		result.Message("Signed %s (%s.sig)", params, params)
+	}
+	return err
+}
 
-		fmt.Println("Starting LoRa reading loop...")
-		fmt.Printf("Results will be saved to: %s\n", filename)
-		fmt.Println("Press 'x' to exit or any other key to read next tag...")
+func writeConfigBinCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (Binary File Name)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = authorizeDestructive("writeConfigBin", nfcCardInstance.UID())
+	if err != nil {
+		log.Errorf("Refusing to apply %s, err: %v\n", params, err)
+		return err
+	}
+	var config []byte
+	config, err = loadVerifiedConfigImage(params)
+	if err != nil {
+		log.Errorf("Refusing to apply %s, err: %v\n", params, err)
+		return err
+	}
+	err = checkImageCapabilities(params, config, nfcCardInstance)
+	if err != nil {
+		log.Errorf("Refusing to apply %s, err: %v\n", params, err)
+		return err
+	}
+	var written []int
+	written, err = nfcCardInstance.WriteConfigBin(config)
+	if err != nil {
+		log.Errorf("Failed to apply %s, err: %v\n", params, err)
+		return err
+	}
+	result.Message("Applied %s, %d blocks written", params, len(written))
+	return err
+}
 
-		for {
-			fmt.Print("\nPress <Enter> to read next tag (or 'x' + <Enter> to exit): ")
-			input, _ := reader.ReadString('\n')
-			input = strings.TrimSpace(strings.ToLower(input))
+func programTagCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (Binary File Name)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = authorizeDestructive("programTag", nfcCardInstance.UID())
+	if err != nil {
+		log.Errorf("Refusing to program %s, err: %v\n", params, err)
+		return err
+	}
+	var image []byte
+	image, err = loadVerifiedConfigImage(params)
+	if err != nil {
+		log.Errorf("Refusing to program %s, err: %v\n", params, err)
+		return err
+	}
+	err = checkImageCapabilities(params, image, nfcCardInstance)
+	if err != nil {
+		log.Errorf("Refusing to program %s, err: %v\n", params, err)
+		return err
+	}
+	var written []int
+	written, err = nfcCardInstance.ProgramTag(image)
+	if err != nil {
+		log.Errorf("Failed to program %s, err: %v\n", params, err)
+		return err
+	}
+	result.Message("Programmed %s, %d blocks written", params, len(written))
+	return err
+}
 
-			if input == "x" {
-				fmt.Printf("Loop ended. Total tags read: %d\n", tagCount)
-				break
-			}
+func readloraloopCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	filename := exportFile
+	if params != "" {
+		filename = params
+	}
+	var tmpl *export.Template
+	tmpl, err = loadExportTemplate()
+	if err != nil {
+		log.Errorf("Failed to load export template: %v\n", err)
+		return err
+	}
 
-			fmt.Println("Reading tag...")
-			info, err := nfcCardInstance.ReadLoraInfo()
-			if err != nil {
-				log.Errorf("Failed to read LoRa info: %v\n", err)
-				continue
-			}
+	err = batch.CheckSchema(keySchema)
+	if err != nil {
+		log.Errorf("Invalid -key-schema: %v\n", err)
+		return err
+	}
+	if duplicates != "skip" && duplicates != "warn" {
+		err = fmt.Errorf("invalid -duplicates %q, expected skip or warn", duplicates)
+		log.Errorf("%v\n", err)
+		return err
+	}
+	pinnedUID := nfcCardInstance.PinnedUID()
+	defer nfcCardInstance.PinUID(pinnedUID)
 
-			// Print info to console
-			fmt.Println("Tag Read Successfully: ")
-			fmt.Printf("\tDevEUI: %s\n", info.DevEUI)
-			fmt.Printf("\tJoinEUI: %s\n", info.JoinEUI)
-			fmt.Printf("\tJoinKey: %s\n", info.JoinKey)
-			fmt.Printf("\tCRC Status: %s\n", info.CRCStatus)
+	reader := bufio.NewReader(os.Stdin)
+	tagCount := 0
+	// lastUID is the tag read last, a tag left on the reader is read again with the next <Enter>
+	lastUID := ""
 
-			// Write to CSV
-			err = writeLoraInfoToCSV(filename, info, isNewFile)
-			if err != nil {
-				log.Errorf("Failed to write to CSV: %v\n", err)
-				continue
-			}
+	fmt.Println("Starting LoRa reading loop...")
+	fmt.Printf("Results will be saved to: %s\n", filename)
+	fmt.Println("Press 'x' to exit or any other key to read next tag...")
 
-			tagCount++
-			isNewFile = false
-			fmt.Printf("Tag information saved to %s (Total tags: %d)\n", filename, tagCount)
-		}
+	for {
+		fmt.Print("\nPress <Enter> to read next tag (or 'x' + <Enter> to exit): ")
+		input, _ := reader.ReadString('\n')
+		input = strings.TrimSpace(strings.ToLower(input))
 
-	case "erase":
-		log.Warn("WARNING: This will erase all data from the NFC tag!")
-		if params != "confirm" {
-			log.Error("To erase the tag, use: -cmd erase -param confirm")
+		if input == "x" {
+			result.Message("Loop ended. Total tags read: %d", tagCount)
 			break
 		}
-		err := nfcCardInstance.EraseTag()
+
+		fmt.Println("Reading tag...")
+		uid, err := nfcCardInstance.NextTag()
 		if err != nil {
-			log.Errorf("Failed to erase tag: %v\n", err)
-			break
+			log.Errorf("Failed to read tag: %v\n", err)
+			tagCompleted("readloraloop", "", err)
+			continue
+		}
+		if strings.EqualFold(uid, lastUID) {
+			if duplicates == "skip" {
+				log.Warnf("Tag %s was just read and is still on the reader, skipping. Present the next tag\n", uid)
+				continue
+			}
+			log.Warnf("Tag %s was just read, writing it again\n", uid)
 		}
-		fmt.Println("Tag erased successfully")
+		lastUID = uid
 
-		// Validate the erasure
-		err = nfcCardInstance.ValidateCRC()
+		info, err := nfcCardInstance.ReadLoraInfo()
 		if err != nil {
-			log.Errorf("Post-erase CRC validation failed: %v\n", err)
-			break
+			log.Errorf("Failed to read LoRa info: %v\n", err)
+			tagCompleted("readloraloop", uid, err)
+			continue
 		}
-		fmt.Println("Post-erase CRC validation successful")
 
-	case "SerialNumberTest":
-		SerialNumberTest()
+		// Print info to console
+		fmt.Println("Tag Read Successfully: ")
+		fmt.Printf("\tDevEUI: %s\n", info.DevEUI)
+		fmt.Printf("\tJoinEUI: %s\n", nfc.RedactJoinEUI(info.JoinEUI))
+		fmt.Printf("\tJoinKey: %s (Fingerprint: %s)\n", nfc.RedactKey(info.JoinKey), nfc.KeyFingerprint(info.JoinKey))
+		fmt.Printf("\tCRC Status: %s\n", info.CRCStatus)
 
-	case "validateCrc":
-		err = nfcCardInstance.ValidateCRC()
-		if err != nil {
-			log.Errorf("Failed to validate CRC: %v\n", err)
-			break
+		// the tag was read, a failed export is not a failure of the tag
+		tagCompleted("readloraloop", uid, nil)
+		exportErr := writeLoraInfoToCSV(filename, uid, info, tmpl)
+		if exportErr != nil {
+			log.Errorf("Failed to export tag %s to %s, record it by hand: %v\n", uid, filename, exportErr)
+			continue
 		}
 
-	case "readblelocal":
-		name, err := nfcCardInstance.ReadBLELocalName()
-		if err != nil {
-			log.Errorf("Failed to read BLE local name: %v\n", err)
-			break
-		}
-		fmt.Printf("BLE Local Name: %s\n", name)
+		tagCount++
+		fmt.Printf("Tag information saved to %s (Total tags: %d)\n", filename, tagCount)
+	}
+	return err
+}
 
-	case "writeblelocal":
-		if params == "" {
-			log.Errorf("Missing params (local name)\n")
-			break
-		}
-		err = nfcCardInstance.WriteBLELocalName(params)
+func eraseCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	log.Warn("WARNING: This will erase all data from the NFC tag!")
+	if params != "confirm" {
+		err = errors.New("erase needs confirmation")
+		log.Error("To erase the tag, use: -cmd erase -param confirm")
+		return err
+	}
+	err = authorizeDestructive("erase", nfcCardInstance.UID())
+	if err != nil {
+		log.Errorf("Erase refused: %v\n", err)
+		return err
+	}
+	err = nfcCardInstance.EraseTag()
+	if err != nil {
+		log.Errorf("Failed to erase tag: %v\n", err)
+		return err
+	}
+	result.Message("Tag erased successfully")
+
+	// Validate the erasure
+	err = nfcCardInstance.ValidateCRC()
+	if err != nil {
+		log.Errorf("Post-erase CRC validation failed: %v\n", err)
+		return err
+	}
+	result.Message("Post-erase CRC validation successful")
+	return err
+}
+
+func serialNumberTestCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	err = printReaderInventory()
+	if err != nil {
+		log.Errorf("Failed to list readers: %v\n", err)
+		return err
+	}
+	return err
+}
+
+func validateCrcCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	err = nfcCardInstance.ValidateCRC()
+	if err != nil {
+		log.Errorf("Failed to validate CRC: %v\n", err)
+		return err
+	}
+	return err
+}
+
+func readblelocalCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	var name string
+	name, err = nfcCardInstance.ReadBLELocalName()
+	if err != nil {
+		log.Errorf("Failed to read BLE local name: %v\n", err)
+		return err
+	}
+	result.Set("BLE Local Name", name)
+	return err
+}
+
+func writeblelocalCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (local name)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	var name []byte
+	name, err = nfc.ParseText(params)
+	if err != nil {
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = checkNameCollision(string(name), nfcCardInstance.UID())
+	if err != nil {
+		log.Errorf("BLE local name not written: %v\n", err)
+		return err
+	}
+	err = nfcCardInstance.WriteBLELocalNameBytes(name)
+	if err != nil {
+		log.Errorf("Failed to write BLE local name: %v\n", err)
+		return err
+	}
+	err = recordName(string(name), nfcCardInstance.UID())
+	if err != nil {
+		log.Errorf("%v\n", err)
+		return err
+	}
+	result.Message("BLE local name written successfully")
+	return err
+}
+
+func writelorajoineuiCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (JoinEUI)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = nfcCardInstance.WriteLoraJoinEui(params)
+	if err != nil {
+		log.Errorf("Failed to write LoRa JoinEUI: %v\n", err)
+		return err
+	}
+	result.Message("LoRa JoinEUI written successfully")
+	return err
+}
+
+func writelorajoinkeyCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (JoinKey)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	var joinKey string
+	joinKey, err = nfcCardInstance.ReadLoraJoinKey()
+	if err != nil {
+		log.Errorf("Failed to read LoRa Join Key: %v\n", err)
+		return err
+	}
+	result.Set("Previous LoRa Join Key", nfc.RedactKey(strings.ToUpper(joinKey)))
+	result.Set("Previous LoRa Join Key Fingerprint", nfc.KeyFingerprint(joinKey))
+
+	err = nfcCardInstance.WriteLoraJoinKey(params)
+	if err != nil {
+		log.Errorf("Failed to write LoRa Join Key: %v\n", err)
+		return err
+	}
+
+	joinKey, err = nfcCardInstance.ReadLoraJoinKey()
+	if err != nil {
+		log.Errorf("Failed to read LoRa Join Key: %v\n", err)
+		return err
+	}
+	result.Set("Current LoRa Join Key", nfc.RedactKey(strings.ToUpper(joinKey)))
+	result.Set("Current LoRa Join Key Fingerprint", nfc.KeyFingerprint(joinKey))
+	result.Message("LoRa Join Key written successfully")
+	return err
+}
+
+func writeloradeveuiCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (DevEUI)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = nfcCardInstance.WriteLoraDevEui(params)
+	if err != nil {
+		log.Errorf("Failed to write LoRa DevEUI: %v\n", err)
+		return err
+	}
+	result.Message("LoRa DevEUI written successfully")
+	return err
+}
+
+func rfcheckCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	reads := nfc.DefaultRFCheckReads
+	if params != "" {
+		reads, err = strconv.Atoi(params)
+		if err != nil || reads <= 0 {
+			err = fmt.Errorf("invalid number of reads %q", params)
+			log.Errorf("%v\n", err)
+			return err
+		}
+	}
+	var report *nfc.RFReport
+	report, err = nfcCardInstance.RFCheck(reads)
+	if err != nil {
+		log.Errorf("Failed to check RF coupling: %v\n", err)
+		return err
+	}
+	for _, sample := range report.Samples {
+		ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+		result.Set(fmt.Sprintf("%d byte reads", sample.Blocks*4), fmt.Sprintf("%d/%d ok, error rate %.1f%%, latency min/avg/max %.1f/%.1f/%.1f ms",
+			sample.Reads-sample.Failures, sample.Reads, 100*sample.ErrorRate(), ms(sample.Min), ms(sample.Avg), ms(sample.Max)))
+	}
+	result.Set("RF Score", report.Score)
+	result.Set("RF Quality", report.Quality)
+	if report.Quality != "good" {
+		result.Message("Reposition the tag on the reader or check the fixture, then run rfcheck again")
+	}
+	return err
+}
+
+func inventoryCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	var tags []nfc.InventoryTag
+	tags, err = nfcCardInstance.Inventory()
+	if err != nil {
+		log.Errorf("Inventory failed: %v\n", err)
+		return err
+	}
+	for i, tag := range tags {
+		line := fmt.Sprintf("UID %s DSFID %02X", tag.UID, tag.DSFID)
+		// the AFI is only in the system info, tags which do not answer are listed without it
+		info, infoErr := nfcCardInstance.GetSystemInfo(tag.UID)
+		if infoErr != nil {
+			log.Warnf("Failed to read system info of %s: %v\n", tag.UID, infoErr)
+		} else if info.AFI != nil {
+			line += fmt.Sprintf(" AFI %02X", *info.AFI)
+		}
+		result.Set(fmt.Sprintf("Tag %d", i+1), line)
+	}
+	result.Set("Tags", len(tags))
+	return err
+}
+
+func sysinfoCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	var info *nfc.SystemInfo
+	info, err = nfcCardInstance.ReadSystemInfo()
+	if err != nil {
+		log.Errorf("Failed to read system info: %v\n", err)
+		return err
+	}
+	result.Set("UID", info.UID)
+	if manufacturer := info.Manufacturer(); manufacturer != "" {
+		result.Set("Manufacturer", manufacturer)
+	}
+	if info.ICReference != nil {
+		ic := fmt.Sprintf("%02X", *info.ICReference)
+		if name := info.ICName(); name != "" {
+			ic += " (" + name + ")"
+		}
+		result.Set("IC Reference", ic)
+	}
+	if info.DSFID != nil {
+		result.Set("DSFID", fmt.Sprintf("%02X", *info.DSFID))
+	}
+	if info.AFI != nil {
+		result.Set("AFI", fmt.Sprintf("%02X", *info.AFI))
+	}
+	if info.Blocks > 0 {
+		result.Set("Block Size", fmt.Sprintf("%d bytes", info.BlockSize))
+		result.Set("Blocks", info.Blocks)
+		result.Set("Memory", fmt.Sprintf("%d bytes", info.Blocks*info.BlockSize))
+	}
+	for _, region := range info.Layout() {
+		name := fmt.Sprintf("Blocks %d-%d", region.First, region.Last)
+		if region.First == region.Last {
+			name = fmt.Sprintf("Block %d", region.First)
+		}
+		result.Set(name, region.Name)
+	}
+	return err
+}
+
+func sectorsCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	var statuses []nfc.SectorStatus
+	statuses, err = nfcCardInstance.SectorSecurityStatuses()
+	if err != nil {
+		log.Errorf("Failed to read sectors: %v\n", err)
+		return err
+	}
+	setSectorsResult(result, statuses)
+	return err
+}
+
+func lockstatusCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	var ranges []nfc.BlockRange
+	ranges, err = nfcCardInstance.LockStatus()
+	if err != nil {
+		log.Errorf("Failed to read the lock status: %v\n", err)
+		return err
+	}
+	setLockStatusResult(result, ranges)
+	return err
+}
+
+func readsectorCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (sector)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = readSector(nfcCardInstance, result, params)
+	if err != nil {
+		log.Errorf("%v\n", err)
+		return err
+	}
+	return err
+}
+
+func ehconfigCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params != "" {
+		err = applyEHSettings(nfcCardInstance, params)
+		if err != nil {
+			log.Errorf("Failed to configure energy harvesting: %v\n", err)
+			return err
+		}
+	}
+	var config *nfc.M24LRConfig
+	config, err = nfcCardInstance.ReadM24LRConfig()
+	if err != nil {
+		log.Errorf("Failed to read energy harvesting configuration: %v\n", err)
+		return err
+	}
+	setEHResult(result, config)
+	return err
+}
+
+func writeIdentifierCommand(command string) commandHandler {
+	return func(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+		var err error
+		name := "AFI"
+		if command == "writedsfid" {
+			name = "DSFID"
+		}
+		if params == "" {
+			err = fmt.Errorf("missing params (%s)", name)
+			log.Errorf("%v\n", err)
+			return err
+		}
+		var value []byte
+		value, err = hex.DecodeString(params)
+		if err != nil || len(value) != 1 {
+			err = fmt.Errorf("invalid %s %q, expected 2 hex characters", name, params)
+			log.Errorf("%v\n", err)
+			return err
+		}
+		if command == "writeafi" {
+			err = nfcCardInstance.WriteAFI(value[0])
+		} else {
+			err = nfcCardInstance.WriteDSFID(value[0])
+		}
+		if err != nil {
+			log.Errorf("%v\n", err)
+			return err
+		}
+		result.Set(name, params)
+		return err
+	}
+}
+
+func calibrateCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	window := defaultCalibrationWindow
+	if params != "" {
+		window, err = strconv.Atoi(params)
+		if err != nil || window <= 0 {
+			err = fmt.Errorf("invalid calibration window %q", params)
+			log.Errorf("%v\n", err)
+			return err
+		}
+	}
+	var rate float64
+	rate, err = runCalibration(window, nfcCardInstance)
+	if err != nil {
+		log.Errorf("Calibration stopped: %v\n", err)
+		return err
+	}
+	result.Set("Success Rate", fmt.Sprintf("%.0f%%", 100*rate))
+	return err
+}
+
+func readloraCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	var mac, devEui, joinEui, joinKey string
+	var macUint, devEuiUint, joinEuiUint uint64
+	mac, err = nfcCardInstance.ReadBleMac()
+	if err != nil {
+		log.Errorf("Failed to read BLE MAC: %v\n", err)
+		return err
+	}
+	macUint, err = strconv.ParseUint(strings.ReplaceAll(mac, ":", ""), 16, 64)
+	if err != nil {
+		log.Errorf("Failed to parse BLE MAC: %v\n", err)
+		return err
+	}
+	result.Set("BLE MAC", strings.ToUpper(mac))
+	result.Set("BLE MAC Decimal", macUint)
+	// Read DevEUI
+	devEui, err = nfcCardInstance.ReadLoraDevEui()
+	if err != nil {
+		log.Errorf("Failed to read LoRa DevEUI: %v\n", err)
+		return err
+	}
+	devEuiUint, err = strconv.ParseUint(strings.ReplaceAll(devEui, ":", ""), 16, 64)
+	if err != nil {
+		log.Errorf("Failed to parse DevEUI: %v\n", err)
+		return err
+	}
+	result.Set("LoRa DevEUI", strings.ToUpper(devEui))
+	result.Set("LoRa DevEUI Cleaned", strings.ToUpper(strings.ReplaceAll(devEui, ":", "")))
+	result.Set("LoRa DevEUI Decimal", devEuiUint)
+
+	// Read Join EUI
+	joinEui, err = nfcCardInstance.ReadLoraJoinEui()
+	if err != nil {
+		log.Errorf("Failed to read LoRa JoinEUI: %v\n", err)
+		return err
+	}
+	joinEuiUint, err = strconv.ParseUint(strings.ReplaceAll(joinEui, ":", ""), 16, 64)
+	if err != nil {
+		log.Errorf("Failed to parse JoinEUI: %v\n", err)
+		return err
+	}
+	if nfc.CMMode() {
+		result.Set("LoRa JoinEUI", nfc.HIDDEN)
+	} else {
+		result.Set("LoRa JoinEUI", strings.ToUpper(joinEui))
+		result.Set("LoRa JoinEUI Decimal", joinEuiUint)
+	}
+
+	// Read Join Key
+	joinKey, err = nfcCardInstance.ReadLoraJoinKey()
+	if err != nil {
+		log.Errorf("Failed to read LoRa Join Key: %v\n", err)
+		return err
+	}
+	if nfc.RedactKeys() {
+		result.Set("LoRa JoinKey", nfc.RedactKey(strings.ToUpper(joinKey)))
+		result.Set("LoRa JoinKey Fingerprint", nfc.KeyFingerprint(joinKey))
+	} else {
+		bigNum := new(big.Int)
+		joinKeyInt, success := bigNum.SetString(joinKey, 16)
+		if !success {
+			err = fmt.Errorf("invalid Join Key %q", joinKey)
+			log.Errorf("%v\n", err)
+			return err
+		}
+		joinKeyBase64 := base64.StdEncoding.EncodeToString([]byte(joinKey))
+		result.Set("LoRa JoinKey", strings.ToUpper(joinKey))
+		result.Set("LoRa JoinKey Decimal", joinKeyInt)
+		result.Set("LoRa JoinKey Base64", joinKeyBase64)
+		result.Set("LoRa JoinKey Fingerprint", nfc.KeyFingerprint(joinKey))
+	}
+
+	// best effort, older tags may hold other data in the user data area
+	assetID, assetErr := nfcCardInstance.ReadAssetID()
+	if assetErr != nil {
+		log.Warnf("Failed to read asset ID: %v\n", assetErr)
+	} else if assetID != "" {
+		result.Set("Asset ID", assetID)
+	}
+
+	// Print validation results
+	if (strings.Compare(joinEui, "0000000000000000") == 0) ||
+		(strings.Compare(joinEui, "FFFFFFFFFFFFFFFF") == 0) {
+		log.Warn("JoinEUI has default value - needs to be programmed")
+	}
+
+	if (strings.Compare(joinKey, "00000000000000000000000000000000") == 0) ||
+		(strings.Compare(joinKey, "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF") == 0) {
+		log.Warn("Join Key has default value - needs to be programmed")
+	}
+
+	var fields []nfc.ConfigField
+	fields, err = nfcCardInstance.ConfigFields("", false)
+	if err != nil {
+		log.Errorf("Failed to read config fields: %v\n", err)
+		return err
+	}
+	setConfigFields(result, fields)
+	result.Message("Completed reading LoRa information")
+	return err
+}
+
+func sleepCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	//Extract Params
+	var sleepState bool
+	sleepState, err = strconv.ParseBool(params)
+	if err != nil {
+		log.Errorf("Failed to parse params: %v\n", err)
+		return err
+	}
+	err = nfcCardInstance.WriteTagSleepBit(sleepState)
+	if err != nil {
+		log.Errorf("Failed to set sleep state: %v\n", err)
+	}
+	return err
+}
+
+func readmacsCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	var loraMac, bleMac string
+	loraMac, err = nfcCardInstance.ReadLoraDevEui()
+	if err != nil {
+		log.Errorf("Failed to read LoRa MAC: %v\n", err)
+		return err
+	}
+	bleMac, err = nfcCardInstance.ReadBleMac()
+	if err != nil {
+		log.Errorf("Failed to read MACs: %v\n", err)
+		return err
+	}
+	result.Set("Lora MAC", strings.ToUpper(loraMac))
+	result.Set("BLE MAC", "01:"+strings.ToUpper(bleMac))
+	return err
+}
+
+func writeblemacCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (BLE MAC)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	if !forceFactory {
+		err = fmt.Errorf("the BLE MAC is assigned in production, use -force-factory -confirm-uid %s to overwrite it", nfcCardInstance.UID())
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = authorizeDestructive("writeblemac", nfcCardInstance.UID())
+	if err != nil {
+		log.Errorf("BLE MAC not written: %v\n", err)
+		return err
+	}
+	err = nfcCardInstance.WriteBleMac(params, confirmUID)
+	if err != nil {
+		log.Errorf("Failed to write BLE MAC: %v\n", err)
+		return err
+	}
+	var bleMac string
+	bleMac, err = nfcCardInstance.ReadBleMac()
+	if err != nil {
+		log.Errorf("Failed to read BLE MAC: %v\n", err)
+		return err
+	}
+	result.Set("BLE MAC", strings.ToUpper(bleMac))
+	result.Message("BLE MAC written successfully")
+	return err
+}
+
+func readskuCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	var info *nfc.BeaconInfo
+	info, err = nfcCardInstance.ReadSKU()
+	if err != nil {
+		log.Errorf("Failed to read SKU: %v\n", err)
+		return err
+	}
+	result.Set("Beacon Type", info.BeaconType)
+	result.Set("Beacon Name", info.Name)
+	return err
+}
+
+func wearCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if wearTracker == nil {
+		err = fmt.Errorf("no -db given")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	var record *store.WearRecord
+	record, err = wearTracker.Get(nfcCardInstance.UID())
+	if err != nil {
+		log.Errorf("Failed to read wear record: %v\n", err)
+		return err
+	}
+	result.Set("UID", record.UID)
+	result.Set("Sessions", record.Sessions)
+	result.Set("Block Writes", record.Writes)
+	if record.Sessions > 0 {
+		block, writes := record.MostWritten()
+		result.Set("Most Written Block", fmt.Sprintf("%d (%d writes)", block, writes))
+		result.Set("First Written", export.FormatTime(record.First))
+		result.Set("Last Written", export.FormatTime(record.Last))
+	}
+	return err
+}
+
+func capabilitiesCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	var caps *nfc.Capabilities
+	caps, err = nfcCardInstance.Capabilities()
+	if err != nil {
+		log.Errorf("Failed to probe capabilities: %v\n", err)
+		return err
+	}
+	if caps.BeaconType != nil {
+		result.Set("Beacon Type", fmt.Sprintf("%s (%s)", caps.BeaconType.BeaconType, caps.BeaconType.Name))
+	}
+	result.Set("Firmware Version", fmt.Sprintf("%.1f", float64(caps.FirmwareVersion)/10))
+	result.Set("Blocks", caps.Blocks)
+	for _, flag := range []struct {
+		name string
+		on   bool
+	}{
+		{"supportsClassB", caps.SupportsClassB},
+		{"supportsGNSS", caps.SupportsGNSS},
+		{"senseRange", caps.SenseRange},
+	} {
+		result.Set(flag.name, flag.on)
+	}
+	return err
+}
+
+func setskuCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (Beacon Type), one of: %s", strings.Join(nfc.BeaconTypes, ", "))
+		log.Errorf("%v\n", err)
+		return err
+	}
+	var info *nfc.BeaconInfo
+	info, err = nfcCardInstance.WriteSKU(params)
+	if err != nil {
+		log.Errorf("Failed to write SKU: %v\n", err)
+		return err
+	}
+	result.Message("Beacon Type %s (%s) written successfully", info.BeaconType, info.Name)
+	return err
+}
+
+func readibeaconCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	var info *nfc.UUIDInfo
+	info, err = nfcCardInstance.ReadUUID(1)
+	if err != nil {
+		log.Errorf("Failed to read iBeacon identity: %v\n", err)
+		return err
+	}
+	result.Set("iBeacon UUID", strings.ToUpper(info.UUID))
+	result.Set("iBeacon Major", strings.ToUpper(info.Major))
+	result.Set("iBeacon Minor", strings.ToUpper(info.Minor))
+	return err
+}
+
+func writeibeaconCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	uuid, major, minor, parseErr := parseIBeaconIdentity(params)
+	if parseErr != nil {
+		err = parseErr
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = nfcCardInstance.WriteIBeaconIdentity(uuid, major, minor)
+	if err != nil {
+		log.Errorf("Failed to write iBeacon identity: %v\n", err)
+		return err
+	}
+	result.Message("iBeacon identity written successfully: UUID %s, Major %d, Minor %d", strings.ToUpper(uuid), major, minor)
+	return err
+}
+
+func readassetidCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	var assetID string
+	assetID, err = nfcCardInstance.ReadAssetID()
+	if err != nil {
+		log.Errorf("Failed to read asset ID: %v\n", err)
+		return err
+	}
+	if assetID == "" {
+		result.Message("No asset ID")
+		return err
+	}
+	result.Set("Asset ID", assetID)
+	return err
+}
+
+func writeassetidCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (asset ID)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	var id []byte
+	id, err = nfc.ParseText(params)
+	if err != nil {
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = nfcCardInstance.WriteAssetID(string(id))
+	if err != nil {
+		log.Errorf("Failed to write asset ID: %v\n", err)
+		return err
+	}
+	result.Message("Asset ID %s written successfully", id)
+	return err
+}
+
+func readuserdataCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	var records []nfc.UserRecord
+	records, err = nfcCardInstance.ReadUserData()
+	if err != nil {
+		log.Errorf("Failed to read user data: %v\n", err)
+		return err
+	}
+	if params != "" {
+		var recordType byte
+		recordType, err = nfc.UserDataType(params)
+		if err != nil {
+			log.Errorf("%v\n", err)
+			return err
+		}
+		kept := records[:0]
+		for _, r := range records {
+			if r.Type == recordType {
+				kept = append(kept, r)
+			}
+		}
+		records = kept
+	}
+	for _, r := range records {
+		result.Set(r.Name(), nfc.FormatText(r.Value))
+	}
+	if len(records) == 0 {
+		result.Message("No user data")
+	}
+	return err
+}
+
+func writeuserdataCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	name, value, ok := strings.Cut(params, "=")
+	if !ok {
+		err = fmt.Errorf("missing params (type=value)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	var recordType byte
+	recordType, err = nfc.UserDataType(name)
+	if err != nil {
+		log.Errorf("%v\n", err)
+		return err
+	}
+	var data []byte
+	data, err = nfc.ParseText(value)
+	if err != nil {
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = nfcCardInstance.PutUserData(recordType, data)
+	if err != nil {
+		log.Errorf("Failed to write user data: %v\n", err)
+		return err
+	}
+	result.Message("User data %s written successfully", nfc.UserDataName(recordType))
+	return err
+}
+
+func deleteuserdataCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (type)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	var recordType byte
+	recordType, err = nfc.UserDataType(params)
+	if err != nil {
+		log.Errorf("%v\n", err)
+		return err
+	}
+	var deleted bool
+	deleted, err = nfcCardInstance.DeleteUserData(recordType)
+	if err != nil {
+		log.Errorf("Failed to delete user data: %v\n", err)
+		return err
+	}
+	if !deleted {
+		result.Message("No user data %s", nfc.UserDataName(recordType))
+		return err
+	}
+	result.Message("User data %s deleted successfully", nfc.UserDataName(recordType))
+	return err
+}
+
+func backupCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (snapshot file name[,note])")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = backupTag(params, nfcCardInstance, result)
+	if err != nil {
+		log.Errorf("Failed to back up tag: %v\n", err)
+		return err
+	}
+	return err
+}
+
+func crosscheckCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	err = crosscheckTag(nfcCardInstance, result)
+	if err != nil {
+		log.Errorf("Cross check failed: %v\n", err)
+		return err
+	}
+	return err
+}
+
+func rekeyCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (batch name)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = runRekey(params, nfcCardInstance)
+	if err != nil {
+		log.Errorf("Rekey failed: %v\n", err)
+		return err
+	}
+	return err
+}
+
+func migrateBlenameCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		params = "blename_migration.csv"
+	}
+	err = runMigrateBLEName(params, nfcCardInstance)
+	if err != nil {
+		log.Errorf("BLE name migration failed: %v\n", err)
+		return err
+	}
+	return err
+}
+
+func decommissionCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	err = decommissionTag(params, nfcCardInstance, result)
+	if err != nil {
+		log.Errorf("Decommission failed: %v\n", err)
+		return err
+	}
+	return err
+}
+
+func compareCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (snapshot file name)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = compareTag(params, nfcCardInstance, result)
+	if err != nil {
+		log.Errorf("Failed to compare tag: %v\n", err)
+		return err
+	}
+	return err
+}
+
+func runManifestCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (manifest file)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = runManifest(params, nfcCardInstance)
+	if err != nil {
+		log.Errorf("Production run stopped: %v\n", err)
+		return err
+	}
+	return err
+}
+
+func verifyBatchCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (inspection profile)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	err = runVerifyBatch(params, nfcCardInstance)
+	if err != nil {
+		log.Errorf("Inspection stopped: %v\n", err)
+		return err
+	}
+	return err
+}
+
+func beaconLoopCommand(command string) commandHandler {
+	return func(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+		var err error
+		if params == "" {
+			err = fmt.Errorf("missing params (UUID,major,minor for ibeaconloop, namespace,instance for eddystoneloop)")
+			log.Errorf("%v\n", err)
+			return err
+		}
+		err = runBeaconLoop(params, command == "eddystoneloop", nfcCardInstance)
+		if err != nil {
+			log.Errorf("Beacon loop stopped: %v\n", err)
+			return err
+		}
+		return err
+	}
+}
+
+func exportbeaconsCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (manifest file name, .json or .csv)")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	var count int
+	count, err = exportBeaconManifest(assignmentsFile, params)
+	if err != nil {
+		log.Errorf("Failed to export beacon manifest: %v\n", err)
+		return err
+	}
+	result.Message("Exported %d beacons to %s", count, params)
+	return err
+}
+
+func exportMobileCommand(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	var err error
+	if params == "" {
+		err = fmt.Errorf("missing params (bundle file name[,configuration file])")
+		log.Errorf("%v\n", err)
+		return err
+	}
+	var link string
+	link, err = exportMobileBundle(params, nfcCardInstance)
+	if err != nil {
+		log.Errorf("Failed to export mobile bundle: %v\n", err)
+		return err
+	}
+	result.Message("Exported mobile bundle to %s", strings.Split(params, ",")[0])
+	result.Set("Deep link (render as QR code for the mobile app)", link)
+	return err
+}
+
+// printReaderInventory prints the product name, serial number and firmware version of every reader,
+// as a JSON array with -output json
+func printReaderInventory() error {
+	readers, err := nfc.ReaderInventory()
+	if err != nil {
+		return err
+	}
+	requirements, err := nfc.ParseFirmwareRequirements(readerMinFirmware)
+	if err != nil {
+		return err
+	}
+	if outputFormat == "json" {
+		data, err := json.MarshalIndent(readers, "", "  ")
 		if err != nil {
-			log.Errorf("Failed to write BLE local name: %v\n", err)
-			break
+			return err
 		}
-		fmt.Println("BLE local name written successfully")
-
-	case "writelorajoineui":
-		if params == "" {
-			log.Errorf("Missing params (JoinEUI)\n")
-			break
+		fmt.Println(string(data))
+		return nil
+	}
+	for i, reader := range readers {
+		fmt.Printf("Reader %d: %s\n", i, reader.Name)
+		if reader.ProductName != "" {
+			fmt.Printf("\tProduct Name:     %s\n", reader.ProductName)
 		}
-		err = nfcCardInstance.WriteLoraJoinEui(params)
-		if err != nil {
-			log.Errorf("Failed to write LoRa JoinEUI: %v\n", err)
-			break
+		if reader.SerialNumber != "" {
+			fmt.Printf("\tSerial Number:    %s\n", reader.SerialNumber)
 		}
-		fmt.Println("LoRa JoinEUI written successfully")
-
-	case "writelorajoinkey":
-		if params == "" {
-			log.Errorf("Missing params (JoinKey)\n")
-			break
+		if reader.FirmwareVersion != "" {
+			fmt.Printf("\tFirmware Version: %s\n", reader.FirmwareVersion)
 		}
-		joinKey, err := nfcCardInstance.ReadLoraJoinKey()
-		if err != nil {
-			log.Errorf("Failed to read LoRa Join Key: %v\n", err)
-			break
+		if reader.Error != "" {
+			log.Errorf("%s: %s\n", reader.Name, reader.Error)
 		}
-		fmt.Printf("Previous LoRa Join Key: %s\n", strings.ToUpper(joinKey))
-
-		err = nfcCardInstance.WriteLoraJoinKey(params)
-		if err != nil {
-			log.Errorf("Failed to write LoRa Join Key: %v\n", err)
-			break
+		if requirements != nil {
+			err = reader.CheckFirmware(requirements)
+			if err != nil {
+				log.Warnf("%v\n", err)
+			}
 		}
+	}
+	return nil
+}
 
-		joinKey, err = nfcCardInstance.ReadLoraJoinKey()
-		if err != nil {
-			log.Errorf("Failed to read LoRa Join Key: %v\n", err)
-			break
-		}
-		fmt.Printf("Current LoRa Join Key: %s\n", strings.ToUpper(joinKey))
-		fmt.Println("LoRa Join Key written successfully")
+func printVersion() {
+	fmt.Printf("Version: \n")
+	fmt.Printf("\tHID NFC Reader %s\n", VERSION)
+	fmt.Printf("\tGit commit: %s\n", GITCOMMIT)
+	fmt.Printf("\tBuilt at: %s\n", BUILDTIME)
+	if fips.Validated() {
+		fmt.Printf("\tFIPS: validated BoringCrypto module\n")
+	}
+}
 
-	case "writeloradeveui":
-		if params == "" {
-			log.Errorf("Missing params (DevEUI)\n")
-			break
-		}
-		err = nfcCardInstance.WriteLoraDevEui(params)
+func main() {
+	initCommandLine()
+	err := applyConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	applyLogOptions()
+	applyRedaction()
+	applyFIPS()
+	err = applyCMMode()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	err = applyConfigKey()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	err = export.SetTimeFormat(timeZone, timeFormat)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	export.SetChecksums(exportChecksums)
+	if storeDB != "" {
+		localDB, err = store.Open(storeDB)
 		if err != nil {
-			log.Errorf("Failed to write LoRa DevEUI: %v\n", err)
-			break
+			log.Fatalf("%v", err)
 		}
-		fmt.Println("LoRa DevEUI written successfully")
-	case "readlora":
-		fmt.Println("Reading all Information:")
-
-		mac, err := nfcCardInstance.ReadBleMac()
+		counterStore, nameHistory, retiredRegistry = localDB.Counters(), localDB.Names(), localDB.Retired()
+		export.SetCounter(counterStore.Next)
+	}
+	err = subscribeEvents()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if postReadActions != nil {
+		defer postReadActions.Close()
+	}
+	if wearTracker != nil {
+		defer func() {
+			err := wearTracker.Flush()
+			if err != nil {
+				log.Warnf("%v", err)
+			}
+		}()
+	}
+	if nameCollision != "abort" && nameCollision != "warn" {
+		log.Fatalf("invalid -name-collision %q, expected abort or warn", nameCollision)
+	}
+	err = nfc.SetReadChunk(readChunk)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	err = nfc.SetBlockSize(blockSize)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	nfc.SetBlockCache(blockCache)
+	padding, err := nfc.ParseNamePadding(namePad)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	nfc.SetNamePadding(padding)
+	nfc.SetRewriteUnchanged(rewriteUnchanged)
+	nfc.SetSingleTagCheck(!allowMultipleTags)
+	nfc.SetBeaconTypeCheck(!allowAnySKU)
+	err = nfc.SetFamilyAFI(familyAFI)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if beaconTypesFile != "" {
+		err = nfc.LoadBeaconTypes(beaconTypesFile)
 		if err != nil {
-			log.Errorf("Failed to read BLE MAC: %v\n", err)
-			break
+			log.Fatalf("Failed to load beacon types: %v", err)
 		}
-		macUint, err := strconv.ParseUint(strings.ReplaceAll(mac, ":", ""), 16, 64)
+	}
+	if outputFormat != "text" && outputFormat != "json" {
+		log.Fatalf("invalid -output %q, expected text or json", outputFormat)
+	}
+	if logFile != "" {
+		log.SetFileFields(buildinfo.Version().Fields())
+		logCloser, err := log.SetFileOutput(logFile, logMaxSize, logRotateDaily, logMaxBackups)
 		if err != nil {
-			log.Errorf("Failed to parse BLE MAC: %v\n", err)
-			break
+			log.Fatalf("%v", err)
 		}
-		fmt.Printf("\tBLE MAC: %s (Decimal: %d)\n", strings.ToUpper(mac), macUint)
-		// Read DevEUI
-		devEui, err := nfcCardInstance.ReadLoraDevEui()
+		defer logCloser.Close()
+	}
+
+	// Handle version flag
+	if versionFlag {
+		fmt.Printf("HID NFC Reader %s\n", VERSION)
+		fmt.Printf("Git commit: %s\n", GITCOMMIT)
+		fmt.Printf("Built at: %s\n", BUILDTIME)
+		return
+	}
+	if !quiet {
+		printVersion()
+	}
+
+	if command == "compare" && strings.Contains(params, ",") {
+		err = runCompare(params)
 		if err != nil {
-			log.Errorf("Failed to read LoRa DevEUI: %v\n", err)
-			break
+			log.Fatalf("%v", err)
 		}
-		devEuiUint, err := strconv.ParseUint(strings.ReplaceAll(devEui, ":", ""), 16, 64)
+		return
+	}
+	if c, ok := findCommand(command); ok && c.offline != nil {
+		err = c.offline(params)
 		if err != nil {
-			log.Errorf("Failed to parse DevEUI: %v\n", err)
-			break
+			log.Fatalf("%v", err)
 		}
-		fmt.Printf("\tLoRa DevEUI->  (HEX: %s) (Cleaned: %s) (Decimal: %d)\n", strings.ToUpper(devEui), strings.ToUpper(strings.ReplaceAll(devEui, ":", "")), devEuiUint)
+		return
+	}
 
-		// Read Join EUI
-		joinEui, err := nfcCardInstance.ReadLoraJoinEui()
+	var steps []commandStep
+	if scriptFile == "" {
+		var err error
+		steps, err = parseCommandChain(command, params)
 		if err != nil {
-			log.Errorf("Failed to read LoRa JoinEUI: %v\n", err)
-			break
+			log.Fatalf("%v", err)
 		}
-		joinEuiUint, err := strconv.ParseUint(strings.ReplaceAll(joinEui, ":", ""), 16, 64)
+		err = validateCommands(steps)
 		if err != nil {
-			log.Errorf("Failed to parse JoinEUI: %v\n", err)
-			break
+			log.Fatalf("%v", err)
 		}
-		fmt.Printf("\tLoRa JoinEUI-> (HEX: %s) (Decimal: %d)\n", strings.ToUpper(joinEui), joinEuiUint)
+	}
 
-		// Read Join Key
-		joinKey, err := nfcCardInstance.ReadLoraJoinKey()
+	if emulateFile != "" {
+		transportSpec = "emulate:" + emulateFile
+	}
+	clientTLS, err := transport.ClientTLS(transportTLS)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	transport.SetClientAuth(transportToken, clientTLS)
+	var failures transport.Failures
+	if injectFailure != "" {
+		failures, err = transport.ParseFailures(injectFailure)
 		if err != nil {
-			log.Errorf("Failed to read LoRa Join Key: %v\n", err)
-			break
-		}
-		bigNum := new(big.Int)
-		joinKeyInt, success := bigNum.SetString(joinKey, 16)
-		if !success {
-			log.Error("Invalid number string")
-			break
-		}
-		joinKeyBase64 := base64.StdEncoding.EncodeToString([]byte(joinKey))
-		fmt.Printf("\tLoRa JoinKe->  (Hex: %s) (Decimal: %d) (Hex Encoded to Base64: %s)\n", strings.ToUpper(joinKey), joinKeyInt, joinKeyBase64)
-
-		// Print validation results
-		if (strings.Compare(joinEui, "0000000000000000") == 0) ||
-			(strings.Compare(joinEui, "FFFFFFFFFFFFFFFF") == 0) {
-			log.Warn("JoinEUI has default value - needs to be programmed")
+			log.Fatalf("invalid -inject-failure: %v", err)
 		}
-
-		if (strings.Compare(joinKey, "00000000000000000000000000000000") == 0) ||
-			(strings.Compare(joinKey, "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF") == 0) {
-			log.Warn("Join Key has default value - needs to be programmed")
+		if !strings.HasPrefix(transportSpec, "emulate:") && !strings.HasPrefix(transportSpec, "replay:") {
+			log.Fatalf("-inject-failure needs -emulate or a replay transport, it is never applied to a reader")
 		}
+	}
 
-		err = nfcCardInstance.PrintConfigFields("", false)
+	var connect nfc.Connector
+	var quirks *nfc.ReaderQuirks
+	readerLabel = transportSpec
+	if transportSpec == "pcsc" {
+		// Initialize PCSC
+		ctx, err := pcsc.NewContext()
 		if err != nil {
-			log.Errorf("Failed to print config fields: %v\n", err)
-			break
+			fmt.Printf("Failed to create PCSC context: %v\n", err)
+			return
 		}
-		fmt.Println("\nCompleted reading LoRa information")
+		defer ctx.Release()
 
-	case "sleep":
-		if params == "" {
-			log.Errorf("Missing params\n")
-			break
-		}
-		//Extract Params
-		sleepState, err := strconv.ParseBool(params)
-		if err != nil {
-			log.Errorf("Failed to parse params: %v\n", err)
-			break
-		}
-		err = nfcCardInstance.WriteTagSleepBit(sleepState)
+		// List readers
+		rdrlst, err := pcsc.ListReaders(ctx)
 		if err != nil {
-			log.Errorf("Failed to set sleep state: %v\n", err)
-		}
-	case "loraDwnTrgL":
-		if params == "" {
-			log.Errorf("Missing params\n")
-			break
+			fmt.Printf("Failed to list readers: %v\n", err)
+			return
 		}
-		loraFailedDownLinktrigerLeave, err := strconv.ParseUint(params, 10, 8)
-		if err != nil {
-			log.Errorf("Failed to parse params: %v\n", err)
-			break
+
+		if len(rdrlst) == 0 {
+			fmt.Println("No readers found")
+			return
 		}
-		err = nfcCardInstance.WriteLoraDwnTrgL(uint8(loraFailedDownLinktrigerLeave))
+
+		rdrlst, err = filterReaders(rdrlst, readerFilter)
 		if err != nil {
-			log.Errorf("Failed to write loraDwnTrgL: %v\n", err)
-			break
+			log.Errorf("%v\n", err)
+			return
 		}
-		fmt.Println("loraDwnTrgL written successfully")
 
-	case "uplinkEnable":
-		if params == "" {
-			log.Errorf("Missing params\n")
-			break
-		}
-		//Extract Params
-		bitValue, err := strconv.ParseBool(params)
+		selectedReader, err := selectReader(rdrlst, readerName)
 		if err != nil {
-			log.Errorf("Failed to parse params: %v\n", err)
-			break
+			log.Errorf("%v\n", err)
+			return
 		}
 
-		err = nfcCardInstance.WriteTagUplinkBit(bitValue)
+		err = checkReaderFirmware(selectedReader)
 		if err != nil {
-			log.Errorf("Failed to write tag post bit: %v\n", err)
-			break
+			log.Errorf("%v\n", err)
+			return
 		}
-		fmt.Println("Tag uplink written successfully")
 
-	case "tagpostbit":
-		if params == "" {
-			log.Errorf("Missing params\n")
-			break
+		quirks = nfc.QuirksFor(selectedReader)
+		readerLabel = selectedReader
+		log.Debugf("Using reader %q (%s)", selectedReader, quirks.Name)
+		connect = func() (nfc.CardTransport, error) {
+			return nfc.ConnectPCSC(pcsc.NewReader(ctx, selectedReader))
 		}
-		//Extract Params
-		bitValue, err := strconv.ParseBool(params)
-		if err != nil {
-			log.Errorf("Failed to parse params: %v\n", err)
-			break
+	} else {
+		connect = func() (nfc.CardTransport, error) {
+			cardTransport, err := transport.Open(transportSpec)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open transport %s: %v", transportSpec, err)
+			}
+			return cardTransport, nil
 		}
-		err = nfcCardInstance.WriteTagPostBit(bitValue)
-		if err != nil {
-			log.Errorf("Failed to write tag post bit: %v\n", err)
-			break
+	}
+
+	if failures != nil {
+		// injected timeouts outlast the APDU timeout, so the card runs into its own timeout
+		delay := time.Duration(0)
+		if apduTimeout > 0 {
+			delay = apduTimeout + time.Second
 		}
-		fmt.Println("Tag post bit written successfully")
+		log.Warnf("Injecting failures %s", failures)
+		connectCard := connect
+		connect = func() (nfc.CardTransport, error) {
+			cardTransport, err := connectCard()
+			if err != nil {
+				return nil, err
+			}
+			return transport.NewFailureInjector(cardTransport, failures, delay), nil
+		}
+	}
 
-	case "readmacs":
-		loraMac, err := nfcCardInstance.ReadLoraDevEui()
+	if recordFile != "" {
+		trace, err := os.Create(recordFile)
 		if err != nil {
-			log.Errorf("Failed to read LoRa MAC: %v\n", err)
-			break
+			log.Errorf("Failed to create trace file: %v\n", err)
+			return
 		}
-		bleMac, err := nfcCardInstance.ReadBleMac()
-		if err != nil {
-			log.Errorf("Failed to read MACs: %v\n", err)
-			break
+		defer trace.Close()
+		connectCard := connect
+		connect = func() (nfc.CardTransport, error) {
+			cardTransport, err := connectCard()
+			if err != nil {
+				return nil, err
+			}
+			return transport.NewRecorder(cardTransport, trace), nil
 		}
-		fmt.Printf("Lora MAC-> %s\n", strings.ToUpper(loraMac))
-		fmt.Printf("BLE MAC-> 01:%s\n", strings.ToUpper(bleMac))
-	case "cfgr":
-		settings, err := nfcCardInstance.ReadDittoSettings()
+	}
+
+	if serveAddr != "" {
+		err = serveReader(serveAddr, connect, readerLabel)
 		if err != nil {
-			log.Errorf("Failed to read settings: %v\n", err)
-			break
+			log.Errorf("%v\n", err)
 		}
-		nfc.PrintMappedDittoSettings(settings)
+		return
 	}
 
-	return err
-}
-
-func initNfc(ctx *pcsc.Context, readerName string) (*nfc.NfcCard, error) {
-	reader := pcsc.NewReader(ctx, readerName)
-	cardInstance, err := nfc.NewCardReader(reader)
+	session := nfc.NewSession(connect)
+	session.APDUTimeout = apduTimeout
+	session.OperationTimeout = operationTimeout
+	session.Quirks = quirks
+	defer session.Close()
+	_, err = session.Card()
 	if err != nil {
-		log.Errorf("Failed to create nfcCard: %v\n", err)
-		return nil, err
+		log.Errorf("Failed to initialize NFC card reader: %v\n", err)
+		return
 	}
-	return cardInstance, nil
-}
 
-// convertToASCII converts a byte slice to an ASCII string and returns both ASCII and hex representations
-func convertToASCII(data []byte) string {
-	var result strings.Builder
-	for _, b := range data {
-		if b >= 32 && b <= 126 { // Printable ASCII range
-			result.WriteByte(b)
-		} else if b == 0 {
-			break // Stop at null terminator
-		} else {
-			result.WriteString(fmt.Sprintf("\\x%02x", b))
+	summary := events.NewSummary()
+	events.Subscribe(summary.Add)
+	defer finishSummary(summary)
+
+	if scriptFile != "" {
+		err = runScript(scriptFile, session)
+		if err != nil {
+			log.Errorf("Failed to run script %s: %v\n", scriptFile, err)
+			return
+		}
+	} else {
+		err = runChain(steps, session)
+		if err != nil {
+			return
 		}
 	}
-	return result.String()
-}
-
-func SerialNumberTest() {
-	getFwVersion := []byte{0xFF, 0x70, 0x07, 0x6B, 0x08, 0xA2, 0x06, 0xA0, 0x04, 0xA0, 0x02, 0x92, 0x00, 0x00}
-	getProductName := []byte{0xFF, 0x70, 0x07, 0x6B, 0x08, 0xA2, 0x06, 0xA0, 0x04, 0xA0, 0x02, 0x82, 0x00, 0x00}
-	ctx, err := pcsc.NewContext()
+	err = session.Close()
 	if err != nil {
-		log.Fatal("Not connection")
+		log.Errorf("Failed to disconnect card: %v\n", err)
+		return
 	}
-	defer ctx.Release()
-	readers, err := pcsc.ListReaders(ctx)
-	for i, el := range readers {
-		fmt.Printf("\treader %v: %s\n", i, el)
+	printInfo("\nSUCCESS\n")
+}
+
+// serveReader proxies the card of the reader to -transport tcp:// clients until interrupted
+func serveReader(address string, connect nfc.Connector, reader string) error {
+	server := transport.NewServer(publishingConnector(connect, reader), reader, log.WithFields(nil))
+	if serveIdleTimeout <= 0 {
+		return fmt.Errorf("invalid -serve-idle-timeout %s", serveIdleTimeout)
 	}
-	samReaders := make([]pcsc.Reader, 0)
-	for _, el := range readers {
-		samReaders = append(samReaders, pcsc.NewReader(ctx, el))
+	server.IdleTimeout = serveIdleTimeout
+	if serveTokens == "" && !isLoopback(address) {
+		return fmt.Errorf("-serve on %s needs -serve-tokens, only a loopback address (e.g. 127.0.0.1:7000) may serve without access tokens", address)
 	}
-	for _, samReader := range samReaders {
-		sam, err := samReader.ConnectSamCard_T0()
+	if serveTokens != "" {
+		tokens, err := auth.LoadTokens(serveTokens)
 		if err != nil {
-			log.Errorf("%s\n", err)
-			continue
+			return fmt.Errorf("failed to load access tokens: %v", err)
 		}
-		fmt.Printf("######## ######### ######## Readers ######## ######## ########\n")
-		var apdu []byte
-		apdu, err = sam.Apdu(getProductName)
+		server.SetTokens(tokens)
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", address, err)
+	}
+	defer listener.Close()
+	if serveTLS != "" {
+		config, err := transport.ServerTLS(serveTLS)
 		if err != nil {
-			log.Errorf("error transmitting read firmware control apdu %v,  err: %v", apdu, err)
-			continue
+			return err
 		}
+		listener = tls.NewListener(listener, config)
+	}
+	if serveTokens == "" {
+		log.Warnf("Serving reader %q on %s without access tokens, every local client may read and write tags", reader, listener.Addr())
+	} else {
+		log.Infof("Serving reader %q on %s", reader, listener.Addr())
+	}
+	return server.Serve(listener)
+}
 
-		productInfo, err := nfc.ParseAPDU(apdu)
-		if err != nil {
-			log.Errorf("error parsing parse apdu, apdu: % x, err: %v", apdu, err)
-		} else {
-			fmt.Printf("Product Name: %s\n", convertToASCII([]byte(productInfo.ValueRaw)))
-		}
+// isLoopback reports whether the listen address (host:port) only accepts connections of this host
+func isLoopback(address string) bool {
+	tcpAddr, err := net.ResolveTCPAddr("tcp", address)
+	return err == nil && tcpAddr.IP != nil && tcpAddr.IP.IsLoopback()
+}
 
-		apdu, err = sam.Apdu(getFwVersion)
+// publishingConnector publishes the TagConnected and TagDisconnected events of the connections
+// opened for remote clients, the cards of server mode live on the client side. TagConnected carries
+// the capabilities of the tag.
+func publishingConnector(connect nfc.Connector, reader string) nfc.Connector {
+	return func() (nfc.CardTransport, error) {
+		t, err := connect()
 		if err != nil {
-			log.Errorf("error transmitting read firmware control apdu %v,  err: %v", apdu, err)
-			continue
+			return nil, err
 		}
-		serialInfo, err := nfc.ParseAPDU(apdu)
-		if err != nil {
-			fmt.Printf("Error parsing serial number: apdu % x,  err: %v", apdu, err)
-		} else {
-			fmt.Printf("Serial Number: %s\n", serialInfo.Value)
+		uid := ""
+		resp, err := t.Apdu([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+		if err == nil && len(resp) > 2 {
+			uid = hex.EncodeToString(resp[:len(resp)-2])
 		}
-		err = sam.DisconnectCard()
+		var flags []string
+		caps, err := nfc.ProbeCapabilities(t)
 		if err != nil {
-			log.Errorf("error %v", err)
+			log.Debugf("Tag %s: failed to probe capabilities: %v", uid, err)
+		} else {
+			log.Infof("Tag %s: %s", uid, caps)
+			flags = caps.Flags()
 		}
-		fmt.Printf("######## ######### ######## Done ######## ######## ########\n")
-
+		events.Publish(events.Event{Type: events.TagConnected, UID: uid, Reader: reader, Capabilities: flags, OK: true})
+		return &publishingTransport{CardTransport: t, uid: uid, reader: reader}, nil
 	}
+}
 
+type publishingTransport struct {
+	nfc.CardTransport
+	uid    string
+	reader string
 }
 
-func printVersion() {
-	fmt.Printf("Version: \n")
-	fmt.Printf("\tHID NFC Reader %s\n", VERSION)
-	fmt.Printf("\tGit commit: %s\n", GITCOMMIT)
-	fmt.Printf("\tBuilt at: %s\n", BUILDTIME)
+func (t *publishingTransport) DisconnectCard() error {
+	err := t.CardTransport.DisconnectCard()
+	events.Publish(events.Event{Type: events.TagDisconnected, UID: t.uid, Reader: t.reader, OK: err == nil, Error: events.ErrorString(err)})
+	return err
 }
 
-func main() {
-	initCommandLine()
+func (t *publishingTransport) DisconnectUnpowerCard() error {
+	err := t.CardTransport.DisconnectUnpowerCard()
+	events.Publish(events.Event{Type: events.TagDisconnected, UID: t.uid, Reader: t.reader, OK: err == nil, Error: events.ErrorString(err)})
+	return err
+}
 
-	// Handle version flag
-	if versionFlag {
-		fmt.Printf("HID NFC Reader %s\n", VERSION)
-		fmt.Printf("Git commit: %s\n", GITCOMMIT)
-		fmt.Printf("Built at: %s\n", BUILDTIME)
-		return
+// runChain runs the steps of a -cmd chain on the card of the session, stopping at the first step
+// which fails
+func runChain(steps []commandStep, session *nfc.Session) error {
+	for _, step := range steps {
+		printInfo("\nRunning command: [%s]\n\n", step.name)
+		err := session.Do(func(card *nfc.NfcCard) error {
+			return nfcRunCommands(step.name, step.param, card)
+		})
+		if err != nil {
+			return fmt.Errorf("%s failed: %v", step.name, err)
+		}
 	}
-	printVersion()
+	return nil
+}
 
-	if command == "srnr" {
-		SerialNumberTest()
-		return
-	}
+// commandStep is a single command of a -cmd chain together with its own parameter
+type commandStep struct {
+	name  string
+	param string
+}
 
-	// If parameters are provided, format them based on the command
-	var formattedParam string
-	if params != "" {
-		switch params {
-		case "writeloradeveui", "writelorajoineui":
-			// For 8-byte keys (16 hex chars)
-			formattedParam = formatKey(params)
-			if len(strings.ReplaceAll(formattedParam, ":", "")) != 16 {
-				log.Fatalf("Invalid key length for DevEUI/JoinEUI. Expected 16 hex characters, got %d",
-					len(strings.ReplaceAll(formattedParam, ":", "")))
+// parseCommandChain splits the -cmd value into steps. Steps are separated by ';' and may carry their
+// own parameter as "cmd=param"; steps without one use the shared -param. For backward compatibility
+// a step without '=' may still list several comma separated commands sharing the -param value, e.g.
+// "readlora,readmacs" or "writeloradeveui=0011223344556677;writelorajoineui=AABBCCDDEEFF0011;readlora".
+// Separators inside double quotes belong to the parameter, e.g. writeblelocal="Dock;7".
+func parseCommandChain(chain string, sharedParam string) ([]commandStep, error) {
+	segments, err := splitUnquoted(chain, ';')
+	if err != nil {
+		return nil, err
+	}
+	var steps []commandStep
+	for _, segment := range segments {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+		if name, param, ok := strings.Cut(segment, "="); ok {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return nil, fmt.Errorf("missing command name in %q", segment)
+			}
+			formatted, err := formatParam(name, strings.TrimSpace(param))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			steps = append(steps, commandStep{name: name, param: formatted})
+			continue
+		}
+		names, err := splitUnquoted(segment, ',')
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
 			}
-		case "writelorajoinkey":
-			// For 16-byte keys (32 hex chars)
-			formattedParam = formatKey(params)
-			if len(strings.ReplaceAll(formattedParam, ":", "")) != 32 {
-				log.Fatalf("Invalid key length for Join Key. Expected 32 hex characters, got %d",
-					len(strings.ReplaceAll(formattedParam, ":", "")))
+			formatted, err := formatParam(name, sharedParam)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
 			}
-		default:
-			formattedParam = params
+			steps = append(steps, commandStep{name: name, param: formatted})
 		}
 	}
-	params = formattedParam
-
-	// Initialize PCSC
-	ctx, err := pcsc.NewContext()
-	if err != nil {
-		fmt.Printf("Failed to create PCSC context: %v\n", err)
-		return
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("no command given")
 	}
-	defer ctx.Release()
+	return steps, nil
+}
 
-	// List readers
-	rdrlst, err := pcsc.ListReaders(ctx)
-	if err != nil {
-		fmt.Printf("Failed to list readers: %v\n", err)
-		return
+// splitUnquoted splits s at every sep outside double quotes, the quotes are kept
+func splitUnquoted(s string, sep rune) ([]string, error) {
+	var parts []string
+	quoted := false
+	start := 0
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
 	}
-
-	if len(rdrlst) == 0 {
-		fmt.Println("No readers found")
-		return
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
 	}
+	return append(parts, s[start:]), nil
+}
 
-	nfcCardReader, err := initNfc(ctx, rdrlst[0])
-	if err != nil {
-		log.Errorf("Failed to initialize NFC card reader: %v\n", err)
-		return
+// formatParam validates and normalizes the parameter of commands writing LoRa keys
+func formatParam(command string, param string) (string, error) {
+	if param == "" {
+		return param, nil
 	}
-	defer nfcCardReader.Close()
-
-	commands := strings.Split(command, ",")
-	for _, cmd := range commands {
-		fmt.Printf("\nRunning command: [%s]\n\n", cmd)
-		err := nfcRunCommands(cmd, nfcCardReader)
-		if err != nil {
-			return
+	switch command {
+	case "writeloradeveui", "writelorajoineui":
+		// For 8-byte keys (16 hex chars)
+		key := formatKey(param)
+		if len(key) != 16 {
+			return "", fmt.Errorf("invalid key length for DevEUI/JoinEUI. Expected 16 hex characters, got %d", len(key))
+		}
+		return key, nil
+	case "writelorajoinkey":
+		// For 16-byte keys (32 hex chars)
+		key := formatKey(param)
+		if len(key) != 32 {
+			return "", fmt.Errorf("invalid key length for Join Key. Expected 32 hex characters, got %d", len(key))
 		}
+		return key, nil
+	default:
+		return param, nil
+	}
+}
+
+// parseIBeaconIdentity parses the "UUID,major,minor" param of writeibeacon, major and minor are decimal
+func parseIBeaconIdentity(param string) (string, uint16, uint16, error) {
+	parts := strings.Split(param, ",")
+	if len(parts) != 3 {
+		return "", 0, 0, fmt.Errorf("invalid params '%s', expected UUID,major,minor", param)
 	}
-	err = nfcCardReader.Reader.DisconnectUnpowerCard()
+	major, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 16)
 	if err != nil {
-		log.Errorf("Failed to disconnect card: %v\n", err)
-		return
+		return "", 0, 0, fmt.Errorf("invalid iBeacon major '%s', expected 0-65535", parts[1])
+	}
+	minor, err := strconv.ParseUint(strings.TrimSpace(parts[2]), 10, 16)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid iBeacon minor '%s', expected 0-65535", parts[2])
 	}
-	fmt.Println("\nSUCCESS")
+	return strings.TrimSpace(parts[0]), uint16(major), uint16(minor), nil
 }
 
 func formatKey(key string) string {
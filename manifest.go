@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+)
+
+// manifestBeacon is one beacon of the registry manifest written by exportbeacons
+type manifestBeacon struct {
+	UID      string `json:"uid"`
+	MAC      string `json:"mac"`
+	UUID     string `json:"uuid"`
+	Major    uint16 `json:"major"`
+	Minor    uint16 `json:"minor"`
+	Instance string `json:"instance"`
+	TxPower  string `json:"txPower"`
+	// ToolVersion is the build of the tool which programmed the beacon
+	ToolVersion string `json:"toolVersion,omitempty"`
+}
+
+// beaconManifest is the JSON manifest written by exportbeacons
+type beaconManifest struct {
+	Tool    buildinfo.Info   `json:"tool"`
+	Beacons []manifestBeacon `json:"beacons"`
+}
+
+// readBeaconAssignments reads the assignments logged by the beacon loops, a tag programmed more than
+// once is listed with its latest identity. The columns are looked up by name, the loops added columns
+// over time and files of earlier versions have fewer of them.
+func readBeaconAssignments(filename string) ([]manifestBeacon, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open assignments file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read assignments file: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("assignments file %s is empty", filename)
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"UID", "Major", "Minor"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("assignments file %s has no %s column", filename, name)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	var beacons []manifestBeacon
+	index := make(map[string]int)
+	for line, record := range records[1:] {
+		beacon := manifestBeacon{
+			UID:         field(record, "UID"),
+			MAC:         field(record, "BLE MAC"),
+			UUID:        field(record, "UUID"),
+			Instance:    field(record, "Instance"),
+			TxPower:     field(record, "TX Power"),
+			ToolVersion: field(record, "Tool Version"),
+		}
+		major, err := strconv.ParseUint(field(record, "Major"), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid major: %v", line+2, err)
+		}
+		minor, err := strconv.ParseUint(field(record, "Minor"), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid minor: %v", line+2, err)
+		}
+		beacon.Major, beacon.Minor = uint16(major), uint16(minor)
+
+		if i, ok := index[beacon.UID]; ok {
+			beacons[i] = beacon
+			continue
+		}
+		index[beacon.UID] = len(beacons)
+		beacons = append(beacons, beacon)
+	}
+	return beacons, nil
+}
+
+// exportBeaconManifest writes the beacons of the assignments file to filename, as JSON for a .json
+// file and as CSV otherwise
+func exportBeaconManifest(assignments string, filename string) (int, error) {
+	beacons, err := readBeaconAssignments(assignments)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create manifest: %v", err)
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return len(beacons), encoder.Encode(beaconManifest{Tool: buildinfo.Version(), Beacons: beacons})
+	}
+
+	writer := csv.NewWriter(file)
+	err = writer.Write([]string{"uid", "mac", "uuid", "major", "minor", "instance", "txPower", "toolVersion"})
+	if err != nil {
+		return 0, fmt.Errorf("failed to write manifest header: %v", err)
+	}
+	for _, beacon := range beacons {
+		err = writer.Write([]string{
+			beacon.UID, beacon.MAC, beacon.UUID,
+			strconv.Itoa(int(beacon.Major)), strconv.Itoa(int(beacon.Minor)),
+			beacon.Instance, beacon.TxPower, beacon.ToolVersion,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to write manifest record: %v", err)
+		}
+	}
+	writer.Flush()
+	return len(beacons), writer.Error()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadBeaconAssignments(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want []manifestBeacon
+		err  bool
+	}{
+		{
+			"current columns",
+			"Timestamp,UID,BLE MAC,UUID,Major,Minor,Instance,TX Power,Tool Version\n" +
+				"2024-07-01T10:00:00Z,E001,AA:BB,F7826DA6,1,2,000000000001,-4,v1.4.0\n",
+			[]manifestBeacon{{UID: "E001", MAC: "AA:BB", UUID: "F7826DA6", Major: 1, Minor: 2, Instance: "000000000001", TxPower: "-4", ToolVersion: "v1.4.0"}},
+			false,
+		},
+		{
+			"seven columns of earlier versions",
+			"Timestamp,UID,BLE MAC,UUID,Major,Minor,TX Power\n" +
+				"2024-07-01T10:00:00Z,E001,AA:BB,F7826DA6,1,2,-4\n",
+			[]manifestBeacon{{UID: "E001", MAC: "AA:BB", UUID: "F7826DA6", Major: 1, Minor: 2, TxPower: "-4"}},
+			false,
+		},
+		{
+			"rows of several versions",
+			"Timestamp,UID,BLE MAC,UUID,Major,Minor,Instance,TX Power\n" +
+				"2024-07-01T10:00:00Z,E001,AA:BB,F7826DA6,1,2,000000000001,-4\n" +
+				"2024-07-02T10:00:00Z,E002,AA:BC,F7826DA6,1,3,000000000002,-4,v1.4.0\n",
+			[]manifestBeacon{
+				{UID: "E001", MAC: "AA:BB", UUID: "F7826DA6", Major: 1, Minor: 2, Instance: "000000000001", TxPower: "-4"},
+				{UID: "E002", MAC: "AA:BC", UUID: "F7826DA6", Major: 1, Minor: 3, Instance: "000000000002", TxPower: "-4"},
+			},
+			false,
+		},
+		{
+			"reprogrammed tag",
+			"UID,Major,Minor\nE001,1,2\nE002,1,3\nE001,1,4\n",
+			[]manifestBeacon{{UID: "E001", Major: 1, Minor: 4}, {UID: "E002", Major: 1, Minor: 3}},
+			false,
+		},
+		{"no Minor column", "UID,Major\nE001,1\n", nil, true},
+		{"invalid major", "UID,Major,Minor\nE001,x,2\n", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "assignments.csv")
+			err := os.WriteFile(path, []byte(test.file), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			beacons, err := readBeaconAssignments(path)
+			if (err != nil) != test.err {
+				t.Fatalf("readBeaconAssignments() error = %v, want error %v", err, test.err)
+			}
+			if !reflect.DeepEqual(beacons, test.want) {
+				t.Errorf("readBeaconAssignments() = %+v, want %+v", beacons, test.want)
+			}
+		})
+	}
+}
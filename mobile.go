@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// exportMobileBundle writes the mobile app bundle of the tag configuration, or of the configuration
+// file given after the output file, and returns its deep link
+func exportMobileBundle(params string, nfcCardInstance *nfc.NfcCard) (string, error) {
+	filename, configFile, _ := strings.Cut(params, ",")
+	filename, configFile = strings.TrimSpace(filename), strings.TrimSpace(configFile)
+
+	var config []byte
+	var source string
+	var err error
+	if configFile != "" {
+		config, err = nfc.LoadConfigBin(configFile)
+		if err != nil {
+			return "", err
+		}
+		source = configFile
+	} else {
+		config, err = nfcCardInstance.ReadConfigBin()
+		if err != nil {
+			return "", err
+		}
+		source = nfcCardInstance.UID()
+	}
+
+	bundle, err := nfc.NewMobileBundle(config, source)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode mobile bundle: %v", err)
+	}
+	err = os.WriteFile(filename, append(data, '\n'), 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write mobile bundle: %v", err)
+	}
+	return bundle.DeepLink()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
+)
+
+func TestReadLoraLoopExportFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		export   string
+		exported bool
+	}{
+		{"exported", "tags.csv", true},
+		{"export fails", filepath.Join("missing", "tags.csv"), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(d string) { duplicates = d }(duplicates)
+			duplicates = "skip"
+			filename := filepath.Join(t.TempDir(), test.export)
+
+			// one <Enter> reads the tag, x ends the loop
+			stdin, input, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+			os.Stdin = stdin
+			_, err = input.WriteString("\nx\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			input.Close()
+
+			var completed []events.Event
+			unsubscribe := events.Subscribe(func(event events.Event) {
+				if event.Type == events.ProvisionCompleted {
+					completed = append(completed, event)
+				}
+			})
+			defer unsubscribe()
+
+			result, err := runCommand("readloraloop", filename, testCard(t, false), io.Discard)
+			if err != nil || !result.OK {
+				t.Fatalf("readloraloop failed: %v", err)
+			}
+			if len(completed) != 1 || !completed[0].OK {
+				t.Errorf("completed tags %+v, want one successful read", completed)
+			}
+			_, err = os.Stat(filename)
+			if exported := err == nil; exported != test.exported {
+				t.Errorf("exported = %v, want %v", exported, test.exported)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdh"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/batch"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/keystore"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/netserver"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// rekeyRun is the rekeying of the tags of a batch whose keys leaked
+type rekeyRun struct {
+	batch  string
+	server netserver.Server
+	// recipient is the public key sealing the new keys to -cm-keystore
+	recipient *ecdh.PublicKey
+	// members are the DevEUIs of the tags of the batch by UID, read from -rekey-records
+	members map[string]string
+}
+
+// rekeyResult is the outcome of rekeying one tag, logged to -rekey-log
+type rekeyResult struct {
+	devEUI         string
+	oldFingerprint string
+	newFingerprint string
+	// networkServer is "updated", "not registered", "restored" or empty without -network-server
+	networkServer string
+}
+
+func newRekeyRun(name string) (*rekeyRun, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing params (batch name)")
+	}
+	// a new key which only exists on the tag is lost, so the keystore is required
+	if cmKeystoreKey == "" {
+		return nil, fmt.Errorf("rekey needs -cm-keystore-key, the new keys are sealed to -cm-keystore")
+	}
+	recipient, err := keystore.LoadPublicKey(cmKeystoreKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load keystore key: %v", err)
+	}
+	members, err := readBatchMembers(name, rekeyRecords)
+	if err != nil {
+		return nil, err
+	}
+	run := &rekeyRun{batch: name, recipient: recipient, members: members}
+	if networkServer != "" {
+		run.server, err = netserver.Open(networkServer, networkServerToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return run, nil
+}
+
+// readBatchMembers reads the DevEUIs by UID of the tags programmed for the batch name from the
+// run-manifest exports or batch reports listed in files (comma separated)
+func readBatchMembers(name string, files string) (map[string]string, error) {
+	if files == "" {
+		return nil, fmt.Errorf("rekey needs -rekey-records, the exports or reports of batch %s", name)
+	}
+	members := make(map[string]string)
+	for _, file := range strings.Split(files, ",") {
+		records, err := batch.ReadRecords(strings.TrimSpace(file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", file, err)
+		}
+		for _, record := range records {
+			if record.Batch == name && record.OK {
+				members[strings.ToUpper(record.UID)] = strings.ToUpper(record.DevEUI)
+			}
+		}
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("no tags of batch %s in %s", name, files)
+	}
+	return members, nil
+}
+
+// checkMember refuses tags which were not programmed for the batch, or carry another DevEUI than
+// the one recorded for them
+func (run *rekeyRun) checkMember(uid string, devEUI string) error {
+	recorded, ok := run.members[strings.ToUpper(uid)]
+	if !ok {
+		return fmt.Errorf("tag %s is not in the records of batch %s", uid, run.batch)
+	}
+	if recorded != "" && !strings.EqualFold(recorded, devEUI) {
+		return fmt.Errorf("tag %s has DevEUI %s, batch %s recorded %s", uid, devEUI, run.batch, recorded)
+	}
+	return nil
+}
+
+// seal appends the record with the new key to -cm-keystore
+func (run *rekeyRun) seal(record batch.Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	err = keystore.Append(cmKeystore, run.recipient, data)
+	if err != nil {
+		return fmt.Errorf("failed to write keystore %s: %v", cmKeystore, err)
+	}
+	return nil
+}
+
+// sealFailed marks the new key of the record failed in the keystore
+func (run *rekeyRun) sealFailed(record batch.Record, err error) {
+	record.OK, record.Error = false, err.Error()
+	sealErr := run.seal(record)
+	if sealErr != nil {
+		log.Errorf("%v\n", sealErr)
+	}
+}
+
+// rekey generates a new JoinKey for the tag uid of the batch, seals it to the keystore, sets it on
+// the Join Server and writes it to the tag. The keystore gets the key first, so a key in use is never
+// missing from the keystore, and the Join Server gets it before the tag, so a tag never has a key the
+// Join Server refused. When the tag write fails the old key is written back to the tag and restored
+// on the Join Server; a key whose write failed is marked failed in the keystore.
+func (run *rekeyRun) rekey(uid string, nfcCardInstance *nfc.NfcCard) (*rekeyResult, error) {
+	info, err := nfcCardInstance.ReadLoraInfo()
+	if err != nil {
+		return nil, err
+	}
+	result := &rekeyResult{devEUI: strings.ReplaceAll(info.DevEUI, ":", "")}
+	err = run.checkMember(uid, result.devEUI)
+	if err != nil {
+		return result, err
+	}
+	err = authorizeDestructive("rekey", uid)
+	if err != nil {
+		return result, err
+	}
+	oldKey, err := nfcCardInstance.ReadLoraJoinKeyBytes()
+	if err != nil {
+		return result, err
+	}
+	defer nfc.ZeroKey(oldKey)
+	result.oldFingerprint = nfc.KeyFingerprint(hex.EncodeToString(oldKey))
+	newKey, err := batch.RandomKey()
+	if err != nil {
+		return result, fmt.Errorf("failed to generate JoinKey: %w", err)
+	}
+	result.newFingerprint = nfc.KeyFingerprint(newKey)
+
+	record := batch.Record{
+		Time:           export.Now(),
+		Batch:          run.batch,
+		Station:        station,
+		UID:            uid,
+		DevEUI:         result.devEUI,
+		JoinEUI:        strings.ReplaceAll(info.JoinEUI, ":", ""),
+		JoinKey:        newKey,
+		KeyFingerprint: result.newFingerprint,
+		AssetID:        info.AssetID,
+		OK:             true,
+	}
+	err = run.seal(record)
+	if err != nil {
+		return result, err
+	}
+
+	if run.server != nil {
+		err = run.server.UpdateKeys(result.devEUI, newKey, "")
+		switch {
+		case err == nil:
+			result.networkServer = "updated"
+		case errors.Is(err, netserver.ErrNotFound):
+			result.networkServer = "not registered"
+			log.Warnf("DevEUI %s is not registered on %s, register the new key from the keystore", result.devEUI, run.server.Name())
+		default:
+			err = fmt.Errorf("failed to set JoinKey on %s, the tag keeps its key: %w", run.server.Name(), err)
+			run.sealFailed(record, err)
+			return result, err
+		}
+	}
+
+	key, err := hex.DecodeString(newKey)
+	if err != nil {
+		return result, err
+	}
+	err = nfcCardInstance.WriteLoraJoinKeyBytes(key)
+	nfc.ZeroKey(key)
+	if err != nil {
+		err = fmt.Errorf("failed to write JoinKey: %w", err)
+		run.sealFailed(record, err)
+		return result, run.rollback(nfcCardInstance, result, oldKey, err)
+	}
+	return result, nil
+}
+
+// rollback restores the old key on the tag and the Join Server after the new key could not be
+// written to the tag, and returns the write error with what could not be restored
+func (run *rekeyRun) rollback(nfcCardInstance *nfc.NfcCard, result *rekeyResult, oldKey []byte, writeErr error) error {
+	err := nfcCardInstance.WriteLoraJoinKeyBytes(oldKey)
+	if err != nil {
+		writeErr = fmt.Errorf("%w, the old key could not be written back, the tag is left with a partial key: %v", writeErr, err)
+	}
+	if result.networkServer == "updated" {
+		err = run.server.UpdateKeys(result.devEUI, hex.EncodeToString(oldKey), "")
+		if err != nil {
+			return fmt.Errorf("%w, the old key could not be restored on %s, restore it from the keystore: %v", writeErr, run.server.Name(), err)
+		}
+		result.networkServer = "restored"
+	}
+	return writeErr
+}
+
+// log appends the result of a tag to -rekey-log, the keys themselves are never logged
+func (run *rekeyRun) log(uid string, result *rekeyResult, err error) error {
+	if result == nil {
+		result = &rekeyResult{}
+	}
+	outcome, message := "OK", ""
+	if err != nil {
+		outcome, message = "FAILED", err.Error()
+	}
+	header := []string{"Timestamp", "Batch", "Station", "Operator", "UID", "DevEUI", "Old Key Fingerprint", "New Key Fingerprint", "Network Server", "Result", "Error"}
+	return export.AppendCSV(rekeyLog, header, []string{
+		export.FormatTime(export.Now()), run.batch, station, operator, uid, result.devEUI,
+		result.oldFingerprint, result.newFingerprint, result.networkServer, outcome, message,
+	})
+}
+
+// runRekey replaces the JoinKey of the tags of a compromised batch: the operator presents one tag after
+// another until 'x' (or -count tags are rekeyed), every tag gets a new random JoinKey which is sealed
+// to -cm-keystore, written to the tag and, with -network-server, set on the Join Server. The old and
+// new key fingerprints of every tag are logged to -rekey-log.
+func runRekey(name string, nfcCardInstance *nfc.NfcCard) error {
+	run, err := newRekeyRun(name)
+	if err != nil {
+		return err
+	}
+	pinnedUID := nfcCardInstance.PinnedUID()
+	defer nfcCardInstance.PinUID(pinnedUID)
+
+	rekeyed := make(map[string]bool)
+	failed := 0
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("Rekeying batch %s, new keys are sealed to %s\n", run.batch, cmKeystore)
+	fmt.Printf("Old and new key fingerprints will be logged to: %s\n", rekeyLog)
+	defer func() {
+		fmt.Printf("Batch %s: %d tags rekeyed, %d failed\n", run.batch, len(rekeyed), failed)
+	}()
+	for verifyCount == 0 || len(rekeyed) < verifyCount {
+		fmt.Printf("\n[%d] Present the next tag and press <Enter> (or 'x' + <Enter> to stop): ", len(rekeyed)+1)
+		input, _ := reader.ReadString('\n')
+		if strings.TrimSpace(strings.ToLower(input)) == "x" {
+			fmt.Println("Rekeying stopped by the operator")
+			return nil
+		}
+
+		uid, err := nfcCardInstance.NextTag()
+		if err != nil {
+			log.Errorf("Failed to read tag: %v\n", err)
+			tagCompleted("rekey", "", err)
+			continue
+		}
+		if rekeyed[uid] {
+			log.Warnf("Tag %s was already rekeyed, skipping\n", uid)
+			continue
+		}
+		result, err := run.rekey(uid, nfcCardInstance)
+		if err != nil {
+			failed++
+			log.Errorf("Failed to rekey tag %s: %v\n", uid, err)
+		} else {
+			rekeyed[uid] = true
+			fmt.Printf("Tag %s rekeyed: DevEUI %s, JoinKey fingerprint %s -> %s\n", uid, result.devEUI, result.oldFingerprint, result.newFingerprint)
+		}
+		logErr := run.log(uid, result, err)
+		if logErr != nil {
+			log.Errorf("Failed to write rekey log: %v\n", logErr)
+		}
+		tagCompleted("rekey", uid, err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/keystore"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/netserver"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// keyServer is a Join Server holding the JoinKey of one device, failing updates if failing
+type keyServer struct {
+	key     string
+	failing bool
+}
+
+func (s *keyServer) Name() string { return "test server" }
+
+func (s *keyServer) Device(devEUI string) (*netserver.Device, error) {
+	return &netserver.Device{DevEUI: devEUI, AppKey: s.key}, nil
+}
+
+func (s *keyServer) UpdateKeys(devEUI string, appKey string, nwkKey string) error {
+	if s.failing {
+		return errors.New("connection refused")
+	}
+	s.key = strings.ToUpper(appKey)
+	return nil
+}
+
+func (s *keyServer) DeleteDevice(devEUI string) error { return nil }
+
+// newKeyTag is an emulated tag which refuses to store a JoinKey other than the one it has in block 5
+type newKeyTag struct {
+	*emulator.Tag
+	block5 []byte
+}
+
+func (t *newKeyTag) Apdu(cmd []byte) ([]byte, error) {
+	if t.block5 != nil && len(cmd) == 9 && cmd[1] == 0xD6 && cmd[3] == 5 && !bytes.Equal(cmd[5:], t.block5) {
+		return []byte{0x65, 0x81}, nil
+	}
+	return t.Tag.Apdu(cmd)
+}
+
+func TestRekey(t *testing.T) {
+	const oldKey = "00112233445566778899AABBCCDDEEFF"
+	tests := []struct {
+		name      string
+		devEUI    string
+		batch     string
+		failing   bool
+		failWrite bool
+		denied    bool
+		err       string
+		tagKey    string
+		serverKey string
+	}{
+		{"rekeyed", "FFFFFFFFFFFFFFFF", "B1", false, false, false, "", "", ""},
+		{"not in the batch", "FFFFFFFFFFFFFFFF", "B2", false, false, false, "not in the records of batch", oldKey, oldKey},
+		{"other DevEUI", "0011223344556677", "B1", false, false, false, "has DevEUI", oldKey, oldKey},
+		{"Join Server failed", "FFFFFFFFFFFFFFFF", "B1", true, false, false, "the tag keeps its key", oldKey, oldKey},
+		{"tag write failed", "FFFFFFFFFFFFFFFF", "B1", false, true, false, "failed to write JoinKey", oldKey, oldKey},
+		{"not authorized", "FFFFFFFFFFFFFFFF", "B1", false, false, true, "not authorized", oldKey, oldKey},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			defer func(keystoreKey, keystorePath, audit string, unauthorized bool) {
+				cmKeystoreKey, cmKeystore, auditLogFile, allowUnauthorized = keystoreKey, keystorePath, audit, unauthorized
+			}(cmKeystoreKey, cmKeystore, auditLogFile, allowUnauthorized)
+			err := keystore.GenerateKey(filepath.Join(dir, "keystore.key"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmKeystoreKey = filepath.Join(dir, "keystore.key.pub")
+			cmKeystore = filepath.Join(dir, "keystore.jsonl")
+			auditLogFile = filepath.Join(dir, "audit.log")
+			allowUnauthorized = !test.denied
+
+			tag, err := emulator.New(64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cardTransport := &newKeyTag{Tag: tag}
+			card, err := nfc.NewCard(cardTransport)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = card.WriteLoraJoinKey(oldKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.failWrite {
+				cardTransport.block5, _ = card.ReadBlocks(5, 1)
+			}
+
+			records := filepath.Join(dir, "export.csv")
+			err = os.WriteFile(records, []byte("Timestamp,Batch,UID,DevEUI,OK\n"+
+				"2024-07-01T10:00:00Z,B1,E004010000000001,0011223344556677,true\n"+
+				"2024-07-01T10:00:01Z,"+test.batch+","+card.UID()+","+test.devEUI+",true\n"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rekeyRecords = records
+			defer func() { rekeyRecords = "" }()
+
+			run, err := newRekeyRun("B1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := &keyServer{key: oldKey, failing: test.failing}
+			run.server = server
+			result, err := run.rekey(card.UID(), card)
+			if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("rekey() = %v, want %q", err, test.err)
+			}
+
+			tagKey, err := card.ReadLoraJoinKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.tagKey == "" {
+				if nfc.KeyFingerprint(tagKey) != result.newFingerprint || server.key != strings.ToUpper(tagKey) {
+					t.Errorf("tag key %s, server key %s, want the new key", tagKey, server.key)
+				}
+				return
+			}
+			if !strings.EqualFold(tagKey, test.tagKey) {
+				t.Errorf("tag key = %s, want %s", tagKey, test.tagKey)
+			}
+			if server.key != test.serverKey {
+				t.Errorf("server key = %s, want %s", server.key, test.serverKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// Field is a named value read or written by a command
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Result is the outcome of a command: the fields it read, its messages, the blocks it wrote and how
+// long it took. With -output text the lines are streamed while the command runs, so they stay in
+// order with the progress of loop modes; with -output json the result is printed when it is done.
+type Result struct {
+	Command       string   `json:"command"`
+	UID           string   `json:"uid,omitempty"`
+	OK            bool     `json:"ok"`
+	Error         string   `json:"error,omitempty"`
+	Fields        []Field  `json:"fields,omitempty"`
+	Messages      []string `json:"messages,omitempty"`
+	BlocksWritten []int    `json:"blocksWritten,omitempty"`
+	// Retries are the written blocks which needed more than one attempt, keyed by block
+	Retries map[int]int `json:"retries,omitempty"`
+	// Marginal is set when any block needed retries, the tag is weak or badly positioned
+	Marginal   bool  `json:"marginal,omitempty"`
+	DurationMs int64 `json:"durationMs"`
+
+	stream io.Writer
+}
+
+func newResult(command string, uid string, stream io.Writer) *Result {
+	return &Result{Command: command, UID: uid, stream: stream}
+}
+
+// Set records a field, e.g. result.Set("LoRa DevEUI", devEui)
+func (r *Result) Set(name string, value interface{}) {
+	field := Field{Name: name, Value: fmt.Sprint(value)}
+	r.Fields = append(r.Fields, field)
+	if r.stream != nil {
+		fmt.Fprintf(r.stream, "%s: %s\n", field.Name, field.Value)
+	}
+}
+
+// Message records a message for the operator, e.g. that a value was written
+func (r *Result) Message(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	r.Messages = append(r.Messages, message)
+	if r.stream != nil {
+		fmt.Fprintln(r.stream, message)
+	}
+}
+
+// WriteJSON writes the result as a single JSON line
+func (r *Result) WriteJSON(w io.Writer) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// runCommand runs a single command and returns its result, text output is streamed to stream
+func runCommand(command string, params string, nfcCardInstance *nfc.NfcCard, stream io.Writer) (*Result, error) {
+	result := newResult(command, nfcCardInstance.UID(), stream)
+	start := time.Now()
+	attempts := events.WriteAttempts{}
+	unsubscribe := events.Subscribe(func(event events.Event) {
+		if event.Type == events.BlockWritten && event.OK && event.Block != nil {
+			result.BlocksWritten = append(result.BlocksWritten, *event.Block)
+		}
+		attempts.Add(event)
+	})
+	err := execCommand(command, params, nfcCardInstance, result)
+	unsubscribe()
+	if errors.Is(err, nfc.ErrBlockLocked) {
+		result.Message("The tag refused a write to a write protected block, -cmd lockstatus maps its locked blocks")
+	}
+	result.Retries = attempts.Retries()
+	if result.Retries != nil {
+		result.Marginal = true
+		result.Message("Marginal tag, blocks needed retries (block:attempts): %s", events.FormatRetries(result.Retries))
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.OK = err == nil
+	result.Error = events.ErrorString(err)
+	return result, err
+}
+
+// setConfigFields adds decoded configuration fields to a command result, with their description
+func setConfigFields(result *Result, fields []nfc.ConfigField) {
+	for _, field := range fields {
+		value := field.Value
+		if field.Description != "" {
+			value += " (" + field.Description + ")"
+		}
+		result.Set(field.Name, value)
+	}
+}
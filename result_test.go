@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// failingTag is an emulated tag whose APDUs fail with status 6581 (memory failure) once failing
+type failingTag struct {
+	*emulator.Tag
+	failing bool
+}
+
+func (t *failingTag) Apdu(cmd []byte) ([]byte, error) {
+	if t.failing {
+		return []byte{0x65, 0x81}, nil
+	}
+	return t.Tag.Apdu(cmd)
+}
+
+// testCard returns a card on an erased emulated tag, whose APDUs fail after the UID was read if
+// failing is set
+func testCard(t *testing.T, failing bool) *nfc.NfcCard {
+	t.Helper()
+	tag, err := emulator.New(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardTransport := &failingTag{Tag: tag}
+	card, err := nfc.NewCard(cardTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardTransport.failing = failing
+	return card
+}
+
+func TestRunCommandOK(t *testing.T) {
+	tests := []struct {
+		command string
+		params  string
+		failing bool
+		ok      bool
+	}{
+		{"readblelocal", "", false, true},
+		{"readAllBlocks", "", false, true},
+		{"readAllBlocks", "", true, false},
+		{"readblelocal", "", true, false},
+		{"readlora", "", true, false},
+		{"readmacs", "", true, false},
+		{"writeblelocal", "", false, false},
+		{"writelorajoinkey", "", false, false},
+		{"writelorajoinkey", "00112233445566778899AABBCCDDEEFF", true, false},
+		{"readsector", "", false, false},
+		{"sleep", "", false, false},
+		{"sleep", "maybe", false, false},
+		{"erase", "", false, false},
+		{"no-such-command", "", false, false},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s/%s/%v", test.command, test.params, test.failing), func(t *testing.T) {
+			result, err := runCommand(test.command, test.params, testCard(t, test.failing), io.Discard)
+			if result.OK != test.ok {
+				t.Errorf("OK = %v, want %v (error %v)", result.OK, test.ok, err)
+			}
+			if (err == nil) != test.ok {
+				t.Errorf("error = %v, want ok %v", err, test.ok)
+			}
+			if !result.OK && result.Error == "" {
+				t.Error("failed result without error")
+			}
+		})
+	}
+}
+
+func TestReadAllBlocksFields(t *testing.T) {
+	result, err := runCommand("readAllBlocks", "", testCard(t, false), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Fields) != 48 || result.Fields[47].Name != "Block 47" || result.Fields[47].Value != "FFFFFFFF" {
+		t.Errorf("fields %+v, want blocks 00-47 of the erased tag", result.Fields)
+	}
+}
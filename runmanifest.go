@@ -0,0 +1,681 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/auth"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/batch"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/events"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/store"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// productionRun is a production batch being executed by run-manifest
+type productionRun struct {
+	manifest *batch.Manifest
+	pool     *batch.Pool
+	profile  []byte
+	steps    []commandStep
+	report   *batch.Report
+	// certificateKey signs the birth certificates, nil leaves them unsigned
+	certificateKey ed25519.PrivateKey
+
+	// mu guards the progress below, tags are finished by the workers of the run
+	mu   sync.Mutex
+	done map[string]bool
+	// pending is the DevEUI handed out to the tag being programmed
+	pending string
+	// inFlight maps the UIDs of the written tags being finished by a worker to their DevEUI
+	inFlight map[string]string
+	// certificates are the birth certificates of the written tags, saved once the tag is finished
+	certificates map[string]*batch.Certificate
+	// stateErr is the first failure to save the state, the run stops on it
+	stateErr error
+
+	// queue holds the failed invocations of queued hooks, nil if the manifest queues none
+	queue *store.Queue
+	// sampler selects the tags audited, nil if the manifest has no audit
+	sampler *batch.Sampler
+}
+
+func newProductionRun(filename string) (*productionRun, error) {
+	manifest, err := batch.LoadManifest(filename)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := batch.NewPool(manifest.EUIs, manifest.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	run := &productionRun{
+		manifest: manifest,
+		pool:     pool,
+		report:   &batch.Report{Batch: manifest.Name, Tool: buildinfo.Version(), Started: export.Now(), Quantity: manifest.Quantity},
+		done:     make(map[string]bool),
+		inFlight: make(map[string]string),
+
+		certificates: make(map[string]*batch.Certificate),
+	}
+	err = run.resume()
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Profile != "" {
+		run.profile, err = loadVerifiedConfigImage(manifest.Path(manifest.Profile))
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %v", manifest.Profile, err)
+		}
+	}
+	if manifest.Commands != "" {
+		run.steps, err = parseCommandChain(manifest.Commands, "")
+		if err == nil {
+			err = validateCommands(run.steps)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("manifest commands: %v", err)
+		}
+	}
+	if manifest.Certificates != nil && manifest.Certificates.Key != "" {
+		run.certificateKey, err = auth.LoadPrivateKey(manifest.Path(manifest.Certificates.Key))
+		if err != nil {
+			return nil, fmt.Errorf("certificate key: %v", err)
+		}
+	}
+	if manifest.Certificates != nil && manifest.Certificates.Record && localDB == nil {
+		return nil, fmt.Errorf("manifest %s records certificate digests, give -db to hold them", manifest.Name)
+	}
+	if manifest.QueuesHooks() {
+		if localDB == nil {
+			return nil, fmt.Errorf("manifest %s queues hooks, give -db to hold the queue", manifest.Name)
+		}
+		run.queue = localDB.Queue(manifest.Name)
+	}
+	if manifest.Audit != nil {
+		run.sampler = batch.NewSampler(manifest.Audit.Every)
+	}
+	return run, nil
+}
+
+// resume continues an interrupted run from the state file of the manifest
+func (run *productionRun) resume() error {
+	stateFile := run.manifest.Path(run.manifest.State)
+	state, err := batch.LoadState(stateFile)
+	if err != nil || state == nil {
+		return err
+	}
+	if state.Complete {
+		return fmt.Errorf("batch %s is already complete (%s), remove the state file to run it again", run.manifest.Name, stateFile)
+	}
+	err = run.pool.Resume(state.NextDevEUI)
+	if err != nil {
+		return fmt.Errorf("cannot resume from %s: %v", stateFile, err)
+	}
+	if state.Report != nil {
+		run.report = state.Report
+		run.report.Quantity = run.manifest.Quantity
+	}
+	for _, uid := range state.Done {
+		run.done[uid] = true
+	}
+	interrupted := state.InFlight
+	if state.Pending != "" {
+		interrupted = append(interrupted, state.Pending)
+	}
+	for _, devEUI := range interrupted {
+		// the tag may or may not have been written, the DevEUI is not handed out again
+		if run.pool.Derives() {
+			log.Warnf("DevEUI %s was being written when the run was interrupted, present its tag again to finish it\n", devEUI)
+		} else {
+			log.Warnf("DevEUI %s was being written when the run was interrupted, it is not reused\n", devEUI)
+		}
+		run.report.Add(batch.Record{Time: export.Now(), Batch: run.manifest.Name, Station: station, DevEUI: devEUI, Error: "run interrupted"})
+	}
+	fmt.Printf("Resuming production run %s from %s: %d/%d tags done\n", run.manifest.Name, stateFile, run.report.Succeeded, run.manifest.Quantity)
+	return run.saveState(false)
+}
+
+// saveState persists the progress of the run, the run stops if it cannot be saved since EUIs
+// could be handed out twice after a crash. Callers hold mu once the workers run.
+func (run *productionRun) saveState(complete bool) error {
+	state := &batch.State{
+		Batch:      run.manifest.Name,
+		NextDevEUI: run.pool.NextDevEUI(),
+		Pending:    run.pending,
+		Done:       make([]string, 0, len(run.done)),
+		Report:     run.report,
+		Complete:   complete,
+	}
+	for uid := range run.done {
+		state.Done = append(state.Done, uid)
+	}
+	sort.Strings(state.Done)
+	for _, devEUI := range run.inFlight {
+		state.InFlight = append(state.InFlight, devEUI)
+	}
+	sort.Strings(state.InFlight)
+	return state.Save(run.manifest.Path(run.manifest.State))
+}
+
+// program writes the identity and the profile to the tag and reads its configuration back, the
+// image is checked by finish. Blocks which needed more than one write attempt mark the record marginal.
+func (run *productionRun) program(uid string, id *batch.Identity, card *nfc.NfcCard) (batch.Record, *nfc.CRCImage, error) {
+	record := batch.Record{Time: export.Now(), Batch: run.manifest.Name, Station: station, UID: uid}
+	record.DevEUI, record.JoinEUI, record.JoinKey = id.DevEUI, id.JoinEUI, id.JoinKey
+	record.DevEUISource = id.DevEUISource
+	record.KeyFingerprint = nfc.KeyFingerprint(id.JoinKey)
+
+	attempts := events.WriteAttempts{}
+	unsubscribe := events.Subscribe(func(event events.Event) {
+		if strings.EqualFold(event.UID, uid) {
+			attempts.Add(event)
+		}
+	})
+	var image *nfc.CRCImage
+	var err error
+	if run.hasHooks(batch.HookPreWrite) {
+		image, err = card.ReadCRCImage()
+		if err != nil {
+			err = fmt.Errorf("failed to read tag for pre-write hooks: %w", err)
+		} else {
+			err = run.runHooks(batch.HookPreWrite, record, image)
+		}
+	}
+	if err == nil {
+		image, err = run.write(id, card)
+	}
+	if err == nil && run.manifest.Certificates != nil {
+		record.Certificate, err = run.issueCertificate(record, image, card)
+	}
+	if err == nil {
+		// best effort, older tags may hold other data in the user data area
+		var assetErr error
+		record.AssetID, assetErr = card.ReadAssetID()
+		if assetErr != nil {
+			log.Warnf("Failed to read asset ID of tag %s: %v\n", record.UID, assetErr)
+			record.AssetID = ""
+		}
+	}
+	unsubscribe()
+	record.Retries = attempts.Retries()
+	record.Marginal = record.Retries != nil
+	return record, image, err
+}
+
+// issueCertificate creates the birth certificate of a written tag and records its digest in -db if
+// the manifest asks for it, the certificate is saved by finish once the tag passed its checks.
+// Returns the hex digest of the certificate.
+func (run *productionRun) issueCertificate(record batch.Record, image *nfc.CRCImage, card *nfc.NfcCard) (string, error) {
+	certificate := &batch.Certificate{
+		UID:            record.UID,
+		Batch:          record.Batch,
+		DevEUI:         record.DevEUI,
+		KeyFingerprint: record.KeyFingerprint,
+		Firmware:       image.FirmwareVersion(),
+		ProfileHash:    batch.ProfileHash(run.profile, run.manifest.Commands),
+		Operator:       operator,
+		Station:        record.Station,
+		Tool:           buildinfo.Version(),
+		Issued:         record.Time,
+	}
+	if run.certificateKey != nil {
+		err := certificate.Sign(run.certificateKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign certificate: %w", err)
+		}
+	}
+	digest, err := certificate.Digest()
+	if err != nil {
+		return "", err
+	}
+	if run.manifest.Certificates.Record {
+		err = localDB.Certificates().Record(store.CertificateEntry{UID: record.UID, Batch: record.Batch,
+			Digest: hex.EncodeToString(digest), Issued: record.Time})
+		if err != nil {
+			return "", err
+		}
+	}
+	run.mu.Lock()
+	run.certificates[record.UID] = certificate
+	run.mu.Unlock()
+	return hex.EncodeToString(digest), nil
+}
+
+// saveCertificate writes the birth certificate issued for the tag uid, if any
+func (run *productionRun) saveCertificate(uid string) error {
+	run.mu.Lock()
+	certificate := run.certificates[uid]
+	run.mu.Unlock()
+	if certificate == nil {
+		return nil
+	}
+	path, err := certificate.Save(run.manifest.Path(run.manifest.Certificates.Dir))
+	if err != nil {
+		return err
+	}
+	log.Debugf("Birth certificate of tag %s written to %s", uid, path)
+	return nil
+}
+
+func (run *productionRun) write(id *batch.Identity, card *nfc.NfcCard) (*nfc.CRCImage, error) {
+	err := checkIdentityFields(id, card)
+	if err != nil {
+		return nil, err
+	}
+	if run.profile != nil {
+		// the profile and the identity are programmed in a single pass
+		image, err := nfc.SetLoraIdentity(run.profile, id.DevEUI, id.JoinEUI, id.JoinKey)
+		if err != nil {
+			return nil, err
+		}
+		err = checkImageCapabilities(run.manifest.Profile, image, card)
+		if err != nil {
+			return nil, err
+		}
+		_, err = card.ProgramTag(image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to program profile: %w", err)
+		}
+	}
+	for _, step := range run.steps {
+		err := nfcRunCommands(step.name, step.param, card)
+		if err != nil {
+			return nil, fmt.Errorf("%s failed: %w", step.name, err)
+		}
+	}
+	if run.profile != nil {
+		return card.ReadCRCImage()
+	}
+	err = card.WriteLoraDevEui(id.DevEUI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write DevEUI: %w", err)
+	}
+	err = card.WriteLoraJoinEui(id.JoinEUI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write JoinEUI: %w", err)
+	}
+	err = card.WriteLoraJoinKey(id.JoinKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write JoinKey: %w", err)
+	}
+	return card.ReadCRCImage()
+}
+
+// checkIdentityFields refuses tags whose beacon type does not hold a field of the identity or
+// requires one the identity lacks, e.g. a LoRa identity for a BLE badge. Blank tags, which get their
+// beacon type from the profile or the manifest commands, are not checked.
+func checkIdentityFields(id *batch.Identity, card *nfc.NfcCard) error {
+	values := []struct {
+		field, value string
+	}{
+		{nfc.FIELD_DEVEUI, id.DevEUI},
+		{nfc.FIELD_JOINEUI, id.JoinEUI},
+		{nfc.FIELD_JOINKEY, id.JoinKey},
+	}
+	var fields []string
+	has := make(map[string]bool)
+	for _, v := range values {
+		if v.value != "" {
+			fields = append(fields, v.field)
+			has[v.field] = true
+		}
+	}
+	err := card.CheckFields(fields...)
+	if err != nil {
+		return err
+	}
+	required, err := card.RequiredFields()
+	if err != nil {
+		return err
+	}
+	for _, field := range required {
+		if !has[strings.ToLower(field)] {
+			return fmt.Errorf("%w: the beacon type requires %s, the EUI pool has none", nfc.ErrWrongBeaconType, field)
+		}
+	}
+	return nil
+}
+
+// finish checks the CRC of a written tag and runs the post-write and post-finalize hooks, on a
+// worker of the run. Returns why the tag failed, nil if it was completed.
+func (run *productionRun) finish(record batch.Record, image *nfc.CRCImage) error {
+	err := image.Validate()
+	if err == nil {
+		err = run.runHooks(batch.HookPostWrite, record, image)
+	}
+	if err == nil {
+		err = run.saveCertificate(record.UID)
+	}
+	if err == nil {
+		err = run.runHooks(batch.HookPostFinalize, record, image)
+	}
+	run.complete(record, err)
+	return err
+}
+
+// complete records the outcome of a tag: report, exports and state
+func (run *productionRun) complete(record batch.Record, err error) {
+	if err == nil {
+		record.OK = true
+	}
+	sealErr := sealRecord(record)
+	if err == nil && sealErr != nil {
+		err = sealErr
+		record.OK = false
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	record = hideRecord(record)
+	run.mu.Lock()
+	run.report.Add(record)
+	run.export(record)
+	if record.OK {
+		run.done[record.UID] = true
+	}
+	delete(run.inFlight, record.UID)
+	delete(run.certificates, record.UID)
+	if run.pending == record.DevEUI {
+		run.pending = ""
+	}
+	saveErr := run.saveState(false)
+	if saveErr != nil && run.stateErr == nil {
+		run.stateErr = saveErr
+	}
+	run.mu.Unlock()
+
+	if !record.OK {
+		log.Errorf("Tag %s failed: %s\n", record.UID, record.Error)
+		tagCompleted("run-manifest", record.UID, err)
+		return
+	}
+	fmt.Printf("Tag %s programmed: DevEUI %s, JoinKey fingerprint %s\n", record.UID, record.DevEUI, record.KeyFingerprint)
+	if record.Marginal {
+		log.Warnf("Tag %s is marginal, blocks needed retries (block:attempts): %s\n", record.UID, events.FormatRetries(record.Retries))
+	}
+	tagCompleted("run-manifest", record.UID, nil)
+}
+
+// devEUIOwner returns the UID of the tag programmed or being finished with devEUI, empty if none
+func (run *productionRun) devEUIOwner(devEUI string) string {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	for uid, inFlight := range run.inFlight {
+		if inFlight == devEUI {
+			return uid
+		}
+	}
+	for _, record := range run.report.Records {
+		if record.OK && record.DevEUI == devEUI {
+			return record.UID
+		}
+	}
+	return ""
+}
+
+func (run *productionRun) progress() (int, int, error) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	return run.report.Succeeded, len(run.inFlight), run.stateErr
+}
+
+// hasHooks reports whether the manifest has hooks for stage
+func (run *productionRun) hasHooks(stage string) bool {
+	for _, hook := range run.manifest.Hooks {
+		if hook.RunsAt(stage) {
+			return true
+		}
+	}
+	return false
+}
+
+// runHooks runs the hooks of the manifest for stage, with the tag state on stdin
+func (run *productionRun) runHooks(stage string, record batch.Record, image *nfc.CRCImage) error {
+	record = hideRecord(record)
+	payload, err := hookPayload(stage, record, image)
+	if err != nil {
+		return err
+	}
+	for _, hook := range run.manifest.Hooks {
+		if !hook.RunsAt(stage) {
+			continue
+		}
+		output, err := runHook(hook, payload, record)
+		if err == nil {
+			continue
+		}
+		log.Warnf("%s hook %s failed for tag %s: %v: %s\n", stage, hook.Name, record.UID, err, strings.TrimSpace(string(output)))
+		if hook.Queue && run.queue != nil {
+			queueErr := run.queue.Push(hook.Name, payload, err)
+			if queueErr == nil {
+				log.Warnf("%s hook %s of tag %s queued for retry\n", stage, hook.Name, record.UID)
+				continue
+			}
+			log.Errorf("Failed to queue %s hook %s of tag %s: %v\n", stage, hook.Name, record.UID, queueErr)
+		}
+		if !hook.Optional {
+			return fmt.Errorf("%s hook %s failed: %v", stage, hook.Name, err)
+		}
+	}
+	return nil
+}
+
+// hookPayload returns the HookInput of a tag as JSON
+func hookPayload(stage string, record batch.Record, image *nfc.CRCImage) ([]byte, error) {
+	input := batch.HookInput{Stage: stage, Record: record}
+	// after writing, hooks only run for tags which passed the checks so far
+	input.OK = stage != batch.HookPreWrite
+	if image != nil {
+		input.Config = strings.ToUpper(hex.EncodeToString(image.Config))
+		input.CRC = fmt.Sprintf("%04X", image.Stored)
+	}
+	return json.Marshal(input)
+}
+
+// runHook runs a hook with the HookInput payload on stdin, or posts it to the URL of the hook.
+// Returns the output of the command or the response to the post.
+func runHook(hook batch.Hook, payload []byte, record batch.Record) ([]byte, error) {
+	if hook.URL != "" {
+		return postHook(hook.URL, payload)
+	}
+	cmd := exec.Command(hook.Command[0], hook.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(),
+		"HIDNFC_BATCH="+record.Batch,
+		"HIDNFC_UID="+record.UID,
+		"HIDNFC_DEVEUI="+record.DevEUI,
+		"HIDNFC_JOINEUI="+record.JoinEUI,
+		"HIDNFC_JOINKEY="+record.JoinKey,
+	)
+	return cmd.CombinedOutput()
+}
+
+func (run *productionRun) export(record batch.Record) {
+	for _, export := range run.manifest.Exports {
+		err := batch.AppendExport(export, run.manifest.Path(export.Path), record, nfc.MaskKey)
+		if err != nil {
+			log.Errorf("Failed to export tag %s: %v\n", record.UID, err)
+		}
+	}
+}
+
+// runManifest executes the production batch of a manifest: the operator presents one tag after
+// another until the quantity is reached, every tag gets the next DevEUI of the pool (or one derived
+// from its UID, see batch.Derivation), the profile and the manifest commands, then the hooks run,
+// the result is exported and the birth certificate of the tag is saved (see batch.Certificates).
+// The batch report is written when the run ends. The progress is saved to the state file of the
+// manifest, running the manifest again after an interruption resumes the run. With Workers the CRC check, hooks and exports of a tag run in the
+// background while the operator presents the next tag. Failed queued hooks are retried in the
+// background and on the next run, see batch.Hook. Tags sampled by the audit of the manifest are
+// verified in depth, see batch.Audit.
+func runManifest(filename string, nfcCardInstance *nfc.NfcCard) error {
+	run, err := newProductionRun(filename)
+	if err != nil {
+		return err
+	}
+	if run.queue != nil {
+		// hooks queued by an earlier run are sent first
+		run.retryQueue()
+		defer run.retryInBackground(queueRetryInterval)()
+	}
+	pinnedUID := nfcCardInstance.PinnedUID()
+	defer nfcCardInstance.PinUID(pinnedUID)
+	defer func() {
+		run.report.Finished = export.Now()
+		fmt.Println(run.report.Summary())
+		reportFile := run.manifest.Path(run.manifest.Report)
+		err := run.report.Write(reportFile)
+		if err != nil {
+			log.Errorf("Failed to write batch report: %v\n", err)
+			return
+		}
+		fmt.Printf("Batch report written to %s\n", reportFile)
+	}()
+
+	workers := batch.NewWorkerPool(run.manifest.Workers)
+	defer workers.Close()
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("Starting production run %s: %d tags\n", run.manifest.Name, run.manifest.Quantity)
+	for {
+		succeeded, inFlight, err := run.progress()
+		if err != nil {
+			return err
+		}
+		if succeeded >= run.manifest.Quantity {
+			break
+		}
+		if succeeded+inFlight >= run.manifest.Quantity {
+			// the remaining tags are being finished, more are needed only if some of them fail
+			workers.Wait()
+			continue
+		}
+		fmt.Printf("\n[%d/%d] Present the next tag and press <Enter> (or 'x' + <Enter> to stop): ",
+			succeeded+inFlight+1, run.manifest.Quantity)
+		input, _ := reader.ReadString('\n')
+		if strings.TrimSpace(strings.ToLower(input)) == "x" {
+			fmt.Println("Run stopped by the operator")
+			return nil
+		}
+
+		uid, err := nfcCardInstance.NextTag()
+		if err != nil {
+			log.Errorf("Failed to read tag: %v\n", err)
+			tagCompleted("run-manifest", "", err)
+			continue
+		}
+		run.mu.Lock()
+		_, finishing := run.inFlight[uid]
+		programmed := run.done[uid] || finishing
+		run.mu.Unlock()
+		if programmed {
+			log.Warnf("Tag %s was already programmed in this run, skipping\n", uid)
+			continue
+		}
+
+		// a DevEUI is consumed by every attempt, so it is never given to two tags even if a failed
+		// attempt got as far as writing it
+		id, err := run.pool.Next(uid)
+		if err != nil {
+			return err
+		}
+		// derived DevEUIs of two UIDs may collide, the second tag is refused
+		if owner := run.devEUIOwner(id.DevEUI); run.pool.Derives() && owner != "" {
+			run.complete(batch.Record{Time: export.Now(), Batch: run.manifest.Name, Station: station, UID: uid,
+				DevEUI: id.DevEUI, DevEUISource: id.DevEUISource}, fmt.Errorf("derived DevEUI %s is already used by tag %s", id.DevEUI, owner))
+			continue
+		}
+		if retiredRegistry != nil {
+			entry, err := retiredRegistry.Get(id.DevEUI)
+			if err != nil {
+				return err
+			}
+			if entry != nil {
+				run.complete(batch.Record{Time: export.Now(), Batch: run.manifest.Name, Station: station, UID: uid,
+					DevEUI: id.DevEUI, DevEUISource: id.DevEUISource}, fmt.Errorf("DevEUI %s was retired with tag %s", id.DevEUI, entry.UID))
+				continue
+			}
+		}
+		run.mu.Lock()
+		run.pending = id.DevEUI
+		err = run.saveState(false)
+		run.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		record, image, err := run.program(uid, id, nfcCardInstance)
+		if err != nil {
+			run.complete(record, err)
+			continue
+		}
+		run.mu.Lock()
+		run.inFlight[uid] = id.DevEUI
+		run.pending = ""
+		err = run.saveState(false)
+		run.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		var audit *tagAudit
+		if run.sampler != nil && run.sampler.Next() {
+			fmt.Printf("Tag %s is selected for audit, keep it on the reader\n", uid)
+			audit = run.deepVerify(record, image, nfcCardInstance)
+		}
+		workers.Submit(func() {
+			err := run.finish(record, image)
+			if audit != nil {
+				run.completeAudit(audit, record, image, err)
+			}
+		})
+	}
+	workers.Wait()
+	fmt.Println("\nBatch complete")
+	return run.saveState(true)
+}
+
+// runReport prints the yield statistics of the run-manifest exports and batch reports listed in
+// files (comma separated) in -report-format
+func runReport(files string) error {
+	var since time.Time
+	if reportSince != "" {
+		var err error
+		since, err = time.ParseInLocation("2006-01-02", reportSince, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid -since %q, expected YYYY-MM-DD", reportSince)
+		}
+	}
+	var records []batch.Record
+	for _, file := range strings.Split(files, ",") {
+		fileRecords, err := batch.ReadRecords(strings.TrimSpace(file))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", file, err)
+		}
+		records = append(records, fileRecords...)
+	}
+
+	stats := batch.ComputeStats(records, since)
+	switch reportFormat {
+	case "text":
+		stats.WriteText(os.Stdout)
+		return nil
+	case "json":
+		return stats.WriteJSON(os.Stdout)
+	case "html":
+		return stats.WriteHTML(os.Stdout)
+	default:
+		return fmt.Errorf("invalid -report-format %q, expected text, json or html", reportFormat)
+	}
+}
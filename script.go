@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// scriptVariables collects the repeatable -var NAME=VALUE flags
+type scriptVariables map[string]string
+
+func (v scriptVariables) String() string {
+	pairs := make([]string, 0, len(v))
+	for name, value := range v {
+		pairs = append(pairs, name+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (v scriptVariables) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("invalid variable %q, expected NAME=VALUE", value)
+	}
+	v[name] = val
+	return nil
+}
+
+var scriptVariablePattern = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+
+// loadScriptVariables merges the variables of the selected CSV row with the -var flags, flags take precedence
+func loadScriptVariables() (map[string]string, error) {
+	vars := make(map[string]string)
+	if scriptVarsFile != "" {
+		file, err := os.Open(scriptVarsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open variables file: %v", err)
+		}
+		defer file.Close()
+
+		records, err := csv.NewReader(file).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read variables file: %v", err)
+		}
+		if scriptVarsRow < 1 || scriptVarsRow >= len(records) {
+			return nil, fmt.Errorf("row %d not found in %s (%d data rows)", scriptVarsRow, scriptVarsFile, len(records)-1)
+		}
+		header := records[0]
+		row := records[scriptVarsRow]
+		for i, name := range header {
+			if i < len(row) {
+				vars[strings.TrimSpace(name)] = strings.TrimSpace(row[i])
+			}
+		}
+	}
+	for name, value := range scriptVars {
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// expandScriptVariables replaces every ${NAME} in line, unknown variables are an error
+func expandScriptVariables(line string, vars map[string]string) (string, error) {
+	var missing []string
+	expanded := scriptVariablePattern.ReplaceAllStringFunc(line, func(match string) string {
+		name := scriptVariablePattern.FindStringSubmatch(match)[1]
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined variable(s): %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// runScript executes every line of the script file against the same card session, reconnecting to the same tag if needed.
+// Each line holds a command followed by its params, empty lines and lines starting with '#' are skipped.
+func runScript(filename string, session *nfc.Session) error {
+	vars, err := loadScriptVariables()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line, err = expandScriptVariables(line, vars)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+
+		cmd, param, _ := strings.Cut(line, " ")
+		if _, ok := findCommand(cmd); !ok {
+			return fmt.Errorf("line %d: unknown command '%s'", lineNumber, cmd)
+		}
+		param, err = formatParam(cmd, strings.TrimSpace(param))
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+
+		printInfo("\nRunning command: [%s] (line %d)\n\n", cmd, lineNumber)
+		err = session.Do(func(card *nfc.NfcCard) error {
+			return nfcRunCommands(cmd, param, card)
+		})
+		if err != nil {
+			return fmt.Errorf("line %d: %s failed: %v", lineNumber, cmd, err)
+		}
+	}
+	return scanner.Err()
+}
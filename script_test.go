@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/emulator"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+func TestRunScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		vars    scriptVariables
+		csv     string
+		row     int
+		err     string
+		written bool
+	}{
+		{"comments and blank lines", "# provision\n\nwriteassetid PAL-1\nwriteblelocal Moni\n", nil, "", 0, "", true},
+		{"quoted param", "writeblelocal \"Moni\"\n", nil, "", 0, "", true},
+		{"-var", "writeblelocal ${NAME}\n", scriptVariables{"NAME": "Moni"}, "", 0, "", true},
+		{"CSV row", "writeblelocal ${NAME}\n", nil, "NAME\nOther\nMoni\n", 2, "", true},
+		{"-var over CSV row", "writeblelocal ${NAME}\n", scriptVariables{"NAME": "Moni"}, "NAME\nOther\n", 1, "", true},
+		{"CSV row out of range", "writeblelocal ${NAME}\n", nil, "NAME\nMoni\n", 2, "row 2 not found", false},
+		{"undefined variable", "writeassetid PAL-1\nwriteblelocal ${NAME}\n", nil, "", 0, "line 2: undefined variable(s): NAME", false},
+		{"unknown command", "nosuchcommand\nwriteblelocal Moni\n", nil, "", 0, "line 1: unknown command", false},
+		{"invalid key", "writelorajoinkey 0011\nwriteblelocal Moni\n", nil, "", 0, "line 1: invalid key length", false},
+		{"stops at the failing line", "writeassetid PAL-1\nreadsector\nwriteblelocal Moni\n", nil, "", 0, "line 2: readsector failed", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(vars scriptVariables, file string, row int) {
+				scriptVars, scriptVarsFile, scriptVarsRow = vars, file, row
+			}(scriptVars, scriptVarsFile, scriptVarsRow)
+			dir := t.TempDir()
+			scriptVars, scriptVarsFile, scriptVarsRow = test.vars, "", test.row
+			if test.csv != "" {
+				scriptVarsFile = filepath.Join(dir, "vars.csv")
+				err := os.WriteFile(scriptVarsFile, []byte(test.csv), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			script := filepath.Join(dir, "provision.txt")
+			err := os.WriteFile(script, []byte(test.script), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tag, err := emulator.New(64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			session := nfc.NewSession(func() (nfc.CardTransport, error) { return tag, nil })
+			defer session.Close()
+
+			err = runScript(script, session)
+			if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("runScript() = %v, want %q", err, test.err)
+			}
+			card, err := session.Card()
+			if err != nil {
+				t.Fatal(err)
+			}
+			name, err := card.ReadBLELocalName()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (name == "Moni") != test.written {
+				t.Errorf("BLE local name = %q, want the last line run %v", name, test.written)
+			}
+		})
+	}
+}
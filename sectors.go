@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+)
+
+// setSectorsResult lists the sectors of the tag with their security status
+func setSectorsResult(result *Result, statuses []nfc.SectorStatus) {
+	for _, status := range statuses {
+		value := fmt.Sprintf("blocks %d-%d, %s", status.First, status.Last, status.Access())
+		if password := status.Password(); password > 0 {
+			value += fmt.Sprintf(", password %d", password)
+		}
+		result.Set(fmt.Sprintf("Sector %d", status.Number), value)
+	}
+	result.Set("Sectors", len(statuses))
+}
+
+// setLockStatusResult sets the ranges of blocks with their access into the result: the blocks
+// written without a password and the locked ones, which refuse writes or need a password
+func setLockStatusResult(result *Result, ranges []nfc.BlockRange) {
+	writable, locked := 0, 0
+	for _, r := range ranges {
+		value := r.Access()
+		if r.Writable() {
+			writable += r.Blocks()
+		} else {
+			locked += r.Blocks()
+			value = "locked, " + value
+		}
+		if password := r.Password(); password > 0 {
+			value += fmt.Sprintf(", password %d", password)
+		}
+		name := fmt.Sprintf("Blocks %d-%d", r.First, r.Last)
+		if r.First == r.Last {
+			name = fmt.Sprintf("Block %d", r.First)
+		}
+		result.Set(name, value)
+	}
+	result.Set("Writable blocks", writable)
+	result.Set("Locked blocks", locked)
+}
+
+// readSector reads a sector (param: the sector number) into the result, one field per block
+func readSector(card *nfc.NfcCard, result *Result, params string) error {
+	number, err := strconv.Atoi(params)
+	if err != nil {
+		return fmt.Errorf("invalid sector %q", params)
+	}
+	sector, err := card.Sector(number)
+	if err != nil {
+		return err
+	}
+	data, err := card.ReadSector(number)
+	if err != nil {
+		return fmt.Errorf("failed to read sector %d: %w", number, err)
+	}
+	size := len(data) / sector.Blocks()
+	for i := 0; i < sector.Blocks(); i++ {
+		block := strings.ToUpper(hex.EncodeToString(data[i*size : (i+1)*size]))
+		result.Set(fmt.Sprintf("Block %d", sector.First+i), nfc.RedactBlock(sector.First+i, block))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/snapshot"
+)
+
+// backupTag writes a snapshot of the complete tag state to a file, params: <file>[,<note>]. Refused
+// in CM mode, the snapshot would hold the keys in plain text.
+func backupTag(params string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	if nfc.CMMode() {
+		return fmt.Errorf("backup is not available with -cm-mode, the snapshot would hold the keys")
+	}
+	path, note, _ := strings.Cut(params, ",")
+	snap, err := snapshot.Capture(nfcCardInstance)
+	if err != nil {
+		return err
+	}
+	snap.Station = station
+	snap.Source = readerLabel
+	snap.Note = note
+	err = snapshot.Save(path, snap)
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %v", path, err)
+	}
+	result.Set("UID", snap.UID)
+	result.Set("Blocks", snap.Blocks())
+	result.Set("CRC Valid", snap.CRCValid)
+	result.Message("Saved snapshot of %d blocks to %s", snap.Blocks(), path)
+	return nil
+}
+
+// compareTag compares a snapshot with the presented tag
+func compareTag(path string, nfcCardInstance *nfc.NfcCard, result *Result) error {
+	saved, err := snapshot.Load(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	current, err := snapshot.Capture(nfcCardInstance)
+	if err != nil {
+		return err
+	}
+	differences := snapshot.Compare(saved, current)
+	for _, difference := range differences {
+		result.Set(fmt.Sprintf("Block %d", difference.Block), formatDifference(difference))
+	}
+	result.Message("%d blocks differ from %s (taken %s)", len(differences), path, export.FormatTime(saved.Time))
+	return nil
+}
+
+// runCompare compares two snapshot files without a reader, params: <a.snap>,<b.snap>
+func runCompare(params string) error {
+	pathA, pathB, _ := strings.Cut(params, ",")
+	var snaps []*snapshot.Snapshot
+	for _, path := range []string{pathA, pathB} {
+		snap, err := snapshot.Load(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		fmt.Printf("%s: UID %s, %d blocks, taken %s on %s\n", path, snap.UID, snap.Blocks(), export.FormatTime(snap.Time), snap.Station)
+		snaps = append(snaps, snap)
+	}
+	differences := snapshot.Compare(snaps[0], snaps[1])
+	for _, difference := range differences {
+		fmt.Printf("Block %d: %s\n", difference.Block, formatDifference(difference))
+	}
+	fmt.Printf("%d blocks differ\n", len(differences))
+	return nil
+}
+
+// formatDifference prints a changed block as "<old> -> <new> (<fields>)", key blocks redacted
+func formatDifference(difference snapshot.Difference) string {
+	a, b := difference.A, difference.B
+	if nfc.IsSecretBlock(difference.Block) {
+		a, b = nfc.RedactKey(a), nfc.RedactKey(b)
+	} else if nfc.IsHiddenBlock(difference.Block) {
+		a, b = nfc.RedactJoinEUI(a), nfc.RedactJoinEUI(b)
+	}
+	text := fmt.Sprintf("%s -> %s", a, b)
+	if len(difference.Fields) > 0 {
+		text += " (" + strings.Join(difference.Fields, ", ") + ")"
+	}
+	return text
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/batch"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/nfc"
+	"github.com/jenish-rudani/HID_NFC_READER/internal/utils/log"
+)
+
+// inspectionRun is the state of verify-batch: the expectations of the profile resolved once
+type inspectionRun struct {
+	profile    *batch.Inspection
+	image      []byte
+	beaconType *nfc.BeaconInfo
+	firmware   string
+}
+
+// identityBlocks are the blocks of the LoRa identity checked by inspection profiles with identity
+var identityBlocks = []struct {
+	name  string
+	first int
+	count int
+}{
+	{"DevEUI", nfc.ASSET_PLUS_LORA_DEV_EUI_BLOCK_MSB, 2},
+	{"JoinEUI", nfc.ASSET_PLUS_LORA_JOIN_EUI_BLOCK_MSB, 2},
+	{"JoinKey", nfc.ASSET_PLUS_LORA_JOIN_KEY_BLOCK_MSB1, 4},
+}
+
+func newInspectionRun(filename string) (*inspectionRun, error) {
+	profile, err := batch.LoadInspection(filename)
+	if err != nil {
+		return nil, err
+	}
+	if verifyCount > 0 {
+		profile.Count = verifyCount
+	}
+	if profile.Count == 0 {
+		return nil, fmt.Errorf("inspection profile %s: no count, set count or -count", profile.Name)
+	}
+	run := &inspectionRun{profile: profile}
+	if profile.Image != "" {
+		// the image is only compared, its settings are not checked for conflicts as images to write are
+		data, err := os.ReadFile(profile.Path(profile.Image))
+		if err == nil {
+			run.image, err = nfc.DecodeConfigBin(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("image %s: %v", profile.Image, err)
+		}
+	}
+	if profile.BeaconType != "" {
+		run.beaconType, err = nfc.FindBeaconType(profile.BeaconType)
+		if err != nil {
+			return nil, fmt.Errorf("inspection profile %s: %v", profile.Name, err)
+		}
+	}
+	if profile.Firmware != "" {
+		version, err := strconv.ParseFloat(profile.Firmware, 64)
+		if err != nil {
+			return nil, fmt.Errorf("inspection profile %s: invalid firmware %q, expected a version such as 8.2", profile.Name, profile.Firmware)
+		}
+		run.firmware = fmt.Sprintf("%.1f", version)
+	}
+	return run, nil
+}
+
+// inspect checks the configuration of a tag against the profile and returns the failed checks
+func (run *inspectionRun) inspect(image *nfc.CRCImage) []string {
+	var failures []string
+	if err := image.Validate(); err != nil {
+		failures = append(failures, err.Error())
+	}
+	if run.image != nil {
+		mismatches, err := nfc.ImageMismatches(image.Config, run.image)
+		if err != nil {
+			failures = append(failures, err.Error())
+		} else if len(mismatches) > 0 {
+			failures = append(failures, fmt.Sprintf("blocks %v differ from the image", mismatches))
+		}
+	}
+	if run.beaconType != nil {
+		tagType := fmt.Sprintf("%02X", image.Config[15*4+2])
+		if tagType != run.beaconType.BeaconType {
+			failures = append(failures, fmt.Sprintf("beacon type %s, expected %s (%s)", tagType, run.beaconType.BeaconType, run.beaconType.Name))
+		}
+	}
+	if run.firmware != "" && image.FirmwareVersion() != run.firmware {
+		failures = append(failures, fmt.Sprintf("firmware %s, expected %s", image.FirmwareVersion(), run.firmware))
+	}
+	if run.profile.Identity {
+		for _, field := range identityBlocks {
+			data := image.Config[field.first*4 : (field.first+field.count)*4]
+			if bytes.Equal(data, bytes.Repeat([]byte{0x00}, len(data))) || bytes.Equal(data, bytes.Repeat([]byte{0xFF}, len(data))) {
+				failures = append(failures, fmt.Sprintf("%s not programmed", field.name))
+			}
+		}
+	}
+	return failures
+}
+
+// writeInspectionResult appends the result of a tag to the results of the profile
+func (run *inspectionRun) writeInspectionResult(uid string, devEUI string, failures []string) error {
+	result := "PASS"
+	if len(failures) > 0 {
+		result = "FAIL"
+	}
+	header := []string{"Timestamp", "Lot", "Station", "UID", "DevEUI", "Result", "Failures"}
+	record := []string{export.FormatTime(export.Now()), run.profile.Name, station, uid, devEUI, result, strings.Join(failures, "; ")}
+	return export.AppendCSV(run.profile.Path(run.profile.Results), header, record)
+}
+
+// runVerifyBatch is the incoming inspection of pre-programmed tags: the operator presents one tag
+// after another until the count of the profile is reached, every tag is read and checked against
+// the profile (see batch.Inspection) and its result, pass or fail with the failed checks, is
+// appended to the results CSV. Tags are only read, a tag presented twice is skipped.
+func runVerifyBatch(filename string, nfcCardInstance *nfc.NfcCard) error {
+	run, err := newInspectionRun(filename)
+	if err != nil {
+		return err
+	}
+	pinnedUID := nfcCardInstance.PinnedUID()
+	defer nfcCardInstance.PinUID(pinnedUID)
+
+	inspected := make(map[string]bool)
+	passed, failed := 0, 0
+	reader := bufio.NewReader(os.Stdin)
+	results := run.profile.Path(run.profile.Results)
+	fmt.Printf("Starting incoming inspection %s: %d tags\n", run.profile.Name, run.profile.Count)
+	fmt.Printf("Results will be saved to: %s\n", results)
+	defer func() {
+		fmt.Printf("Inspection %s: %d/%d tags inspected, %d passed, %d failed\n", run.profile.Name,
+			passed+failed, run.profile.Count, passed, failed)
+	}()
+	for passed+failed < run.profile.Count {
+		fmt.Printf("\n[%d/%d] Present the next tag and press <Enter> (or 'x' + <Enter> to stop): ", passed+failed+1, run.profile.Count)
+		input, _ := reader.ReadString('\n')
+		if strings.TrimSpace(strings.ToLower(input)) == "x" {
+			fmt.Println("Inspection stopped by the operator")
+			return nil
+		}
+
+		uid, err := nfcCardInstance.NextTag()
+		if err != nil {
+			log.Errorf("Failed to read tag: %v\n", err)
+			tagCompleted("verify-batch", "", err)
+			continue
+		}
+		if inspected[uid] {
+			log.Warnf("Tag %s was already inspected, skipping\n", uid)
+			continue
+		}
+
+		devEUI, err := nfcCardInstance.ReadLoraDevEui()
+		var image *nfc.CRCImage
+		if err == nil {
+			image, err = nfcCardInstance.ReadCRCImage()
+		}
+		if err != nil {
+			// a tag which could not be read is not counted, the operator presents it again
+			log.Errorf("Failed to read tag %s: %v\n", uid, err)
+			tagCompleted("verify-batch", uid, err)
+			continue
+		}
+		failures := run.inspect(image)
+		inspected[uid] = true
+		if len(failures) > 0 {
+			failed++
+			fmt.Printf("Tag %s FAILED:\n", uid)
+			for _, failure := range failures {
+				fmt.Printf("\t%s\n", failure)
+			}
+			err = fmt.Errorf("inspection failed: %s", strings.Join(failures, "; "))
+		} else {
+			passed++
+			fmt.Printf("Tag %s passed\n", uid)
+		}
+
+		writeErr := run.writeInspectionResult(uid, strings.ToUpper(devEUI), failures)
+		if writeErr != nil {
+			log.Errorf("Failed to write inspection result: %v\n", writeErr)
+		}
+		tagCompleted("verify-batch", uid, err)
+	}
+	return nil
+}
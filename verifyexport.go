@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenish-rudani/HID_NFC_READER/internal/export"
+)
+
+// runVerifyExport checks the files listed in params (comma separated) against their checksum files
+// and fails if any of them does not match
+func runVerifyExport(params string) error {
+	if params == "" {
+		return fmt.Errorf("missing params (export file)")
+	}
+	failed := 0
+	for _, path := range strings.Split(params, ",") {
+		path = strings.TrimSpace(path)
+		err := export.VerifyChecksum(path)
+		if err != nil {
+			fmt.Printf("FAILED %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("OK     %s\n", path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d files failed verification", failed)
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 package main
 
+import "github.com/jenish-rudani/HID_NFC_READER/internal/buildinfo"
+
 // These variables will be set at build time using -ldflags
 var (
 	// VERSION Version represents the current version of the application
@@ -9,3 +11,7 @@ var (
 	// BUILDTIME represents when the binary was built
 	BUILDTIME = "unknown"
 )
+
+func init() {
+	buildinfo.Set(VERSION, GITCOMMIT, BUILDTIME)
+}